@@ -0,0 +1,100 @@
+package dcron
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/libi/dcron/cron"
+)
+
+// AuditEvent is the record of one run of a job, see AuditJob.
+type AuditEvent struct {
+	JobName string    `json:"job_name"`
+	NodeID  string    `json:"node_id,omitempty"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	// Error is the error returned by the job, or the recovered value
+	// if it panicked, it is empty if the run succeeded.
+	Error    string `json:"error,omitempty"`
+	Panicked bool   `json:"panicked,omitempty"`
+}
+
+// Duration returns how long the run took.
+func (e AuditEvent) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// AuditSink receives the AuditEvent of each run, Record is called in the
+// goroutine of the run after it finished, so it should not block long.
+type AuditSink interface {
+	Record(event AuditEvent)
+}
+
+// AuditJob returns a wrapper which records each run of the job to sink,
+// with the nodeID of this node. Use it with AddJobWithWrappers, so only the
+// runs in the node which runs the job are recorded, the global chain set by
+// CronOptionChain also sees the triggers skipped by the node check. A panic
+// is recorded and re-raised, so an outer cron.Recover still sees it, the
+// retries of a cron.RetryIfFailed inside it are recorded as one run.
+func (d *Dcron) AuditJob(sink AuditSink) cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		return cron.FuncErrorJob(func() (err error) {
+			event := AuditEvent{
+				JobName: cron.JobName(j),
+				Start:   d.clock.Now(),
+			}
+			if !d.runningLocally {
+				event.NodeID = d.nodePool.GetNodeID()
+			}
+			defer func() {
+				event.End = d.clock.Now()
+				if r := recover(); r != nil {
+					event.Error, event.Panicked = fmt.Sprint(r), true
+					sink.Record(event)
+					panic(r)
+				}
+				if err != nil {
+					event.Error = err.Error()
+				}
+				sink.Record(event)
+			}()
+			if ej, ok := j.(cron.ErrorJob); ok {
+				return ej.RunWithError()
+			}
+			j.Run()
+			return nil
+		})
+	}
+}
+
+// JSONLineAuditSink writes each AuditEvent as a line of JSON,
+// e.g. to a file opened with os.O_APPEND.
+type JSONLineAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewJSONLineAuditSink create a JSONLineAuditSink writing to w.
+func NewJSONLineAuditSink(w io.Writer) *JSONLineAuditSink {
+	return &JSONLineAuditSink{enc: json.NewEncoder(w)}
+}
+
+// Record implements AuditSink.
+func (s *JSONLineAuditSink) Record(event AuditEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(event); err != nil {
+		s.err = err
+	}
+}
+
+// Err returns the last error of writing the events, if any.
+func (s *JSONLineAuditSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,19 @@
+package dcron
+
+import "github.com/libi/dcron/cron"
+
+// AddBroadcastJob add a cron func which runs in every node of the node pool
+// at the scheduled time, e.g. to invalidate the local cache or reload the
+// config, instead of the node which owns it by the hash ring. The job is
+// not in the hash ring at all, and its runs are not deduplicated by
+// WithExecutionLock. The runs in each node go through the wrappers of the
+// chain of that node independently.
+func (d *Dcron) AddBroadcastJob(jobName, cronStr string, cmd func()) error {
+	return d.addMarkedJob(jobName, cronStr, cron.FuncJob(cmd), &d.broadcastJobs, struct{}{})
+}
+
+// isBroadcastJob returns true if the job is added by AddBroadcastJob.
+func (d *Dcron) isBroadcastJob(jobName string) bool {
+	_, ok := d.broadcastJobs.Load(jobName)
+	return ok
+}
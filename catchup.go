@@ -0,0 +1,94 @@
+package dcron
+
+import "time"
+
+// catchUpMissedRuns runs the runs of the jobs which should have fired
+// between their last successful runs and since, when no node ran them,
+// e.g. the whole cluster was down, see WithCatchUp. It waits as
+// runJobsOnStart for the node pool to be settled, and each job catches up
+// in the node which owns it.
+func (d *Dcron) catchUpMissedRuns(since time.Time) {
+	if _, ok := d.kvDriver(); !ok {
+		d.logger.Warnf("driver is not a KVDriver, the missed runs do not catch up")
+		return
+	}
+	ctx := d.runtimeContext()
+	tick := time.NewTicker(d.nodeUpdateDuration)
+	defer tick.Stop()
+	if !d.waitForSettledPool(ctx, tick) {
+		return
+	}
+	d.jobsRWMut.RLock()
+	jobNames := make([]string, 0, len(d.jobs))
+	for jobName := range d.jobs {
+		jobNames = append(jobNames, jobName)
+	}
+	d.jobsRWMut.RUnlock()
+	for len(jobNames) > 0 {
+		pending := jobNames[:0]
+		for _, jobName := range jobNames {
+			if !d.catchUpJob(jobName, since) {
+				pending = append(pending, jobName)
+			}
+		}
+		if jobNames = pending; len(jobNames) == 0 {
+			return
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// catchUpJob runs the missed runs of the job before since, the last
+// catchUpMax of them, if this node owns it. It returns false if the
+// owner can not be decided now.
+func (d *Dcron) catchUpJob(jobName string, since time.Time) bool {
+	d.jobsRWMut.RLock()
+	job, ok := d.jobs[jobName]
+	d.jobsRWMut.RUnlock()
+	if !ok {
+		return true
+	}
+	owned, err := d.checkJobAvailable(jobName)
+	if err != nil {
+		return false
+	}
+	if !owned {
+		return true
+	}
+	entry := d.cr.Entry(job.ID)
+	if !entry.Valid() {
+		return true
+	}
+	lastRun, _, err := d.LastRunTime(jobName)
+	if err != nil {
+		d.logger.Errorf("get the last run of job '%s' error, it does not catch up, err=%v", jobName, err)
+		return true
+	}
+	if lastRun.IsZero() {
+		return true
+	}
+	missed := make([]time.Time, 0, d.catchUpMax)
+	dropped := 0
+	for t := lastRun; ; {
+		if t = entry.Schedule.Next(t); t.IsZero() || t.After(since) {
+			break
+		}
+		if len(missed) == d.catchUpMax {
+			missed = append(missed[:0], missed[1:]...)
+			dropped++
+		}
+		missed = append(missed, t)
+	}
+	if dropped > 0 {
+		d.logger.Warnf("job '%s' missed %d runs, only the last %d of them catch up", jobName, dropped+len(missed), len(missed))
+	}
+	for i, t := range missed {
+		d.logger.Infof("job '%s' catches up the run %d/%d missed at %v", jobName, i+1, len(missed), t)
+		_ = job.runAt(t)
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package dcron
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/libi/dcron/driver"
+)
+
+const clockKeyPre = "clock:"
+
+func clockKey(nodeID string) string {
+	return clockKeyPre + nodeID
+}
+
+// ClockSkewCallback is called when the clock of the node of nodeID is found
+// skewed from the clock of this node, skew is positive if it is ahead.
+type ClockSkewCallback func(nodeID string, skew time.Duration)
+
+// checkClockSkew advertises the time of this node in the driver once per
+// node update duration, and compares the times of the other nodes in the
+// node pool with it, see WithClockSkewWarning. The time is deleted when
+// dcron is stopped.
+func (d *Dcron) checkClockSkew() {
+	defer d.advertisers.Done()
+	kv, ok := d.kvDriver()
+	if !ok {
+		d.logger.Warnf("driver is not a KVDriver, the clock skew of the nodes is not checked")
+		return
+	}
+	ctx := d.runtimeContext()
+	nodeID := d.nodePool.GetNodeID()
+	defer func() {
+		delCtx, cancel := context.WithTimeout(context.Background(), d.nodeUpdateDuration)
+		defer cancel()
+		if err := kv.Del(delCtx, clockKey(nodeID)); err != nil {
+			d.logger.Errorf("delete the time of this node error, err=%v", err)
+		}
+	}()
+	tick := time.NewTicker(d.nodeUpdateDuration)
+	defer tick.Stop()
+	// the nodes which are reported, they are reported again
+	// after their skew is back under the threshold.
+	skewed := make(map[string]struct{})
+	for {
+		skews, err := d.clockSkews(ctx, kv, nodeID)
+		if err != nil {
+			d.logger.Errorf("check the clock skew of the nodes error, err=%v", err)
+		}
+		for node, skew := range skews {
+			if _, ok := skewed[node]; ok {
+				continue
+			}
+			skewed[node] = struct{}{}
+			d.logger.Warnf("the clock of node %s is skewed by %v from this node, "+
+				"the jobs may run twice or be missed", node, skew)
+			if d.clockSkewCallback != nil {
+				d.clockSkewCallback(node, skew)
+			}
+		}
+		if err == nil {
+			for node := range skewed {
+				if _, ok := skews[node]; !ok {
+					delete(skewed, node)
+				}
+			}
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// clockSkews advertises the time of this node and returns the skews of the
+// nodes which exceed the threshold. A time read from the driver was written
+// at most one node update duration ago, so a node behind this node is only
+// reported if it is behind by more than the threshold plus the duration,
+// and the skew is the lower bound.
+func (d *Dcron) clockSkews(ctx context.Context, kv driver.KVDriver, nodeID string) (map[string]time.Duration, error) {
+	if err := kv.Set(ctx, clockKey(nodeID), strconv.FormatInt(d.clock.Now().UnixNano(), 10)); err != nil {
+		return nil, err
+	}
+	skews := make(map[string]time.Duration)
+	for _, node := range d.nodePool.GetNodes() {
+		if node == nodeID {
+			continue
+		}
+		value, ok, err := kv.Get(ctx, clockKey(node))
+		if err != nil {
+			return skews, err
+		}
+		if !ok {
+			continue
+		}
+		nsec, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		skew := time.Unix(0, nsec).Sub(d.clock.Now())
+		if skew > d.clockSkewThreshold {
+			skews[node] = skew
+		} else if skew < -d.clockSkewThreshold-d.nodeUpdateDuration {
+			skews[node] = skew + d.nodeUpdateDuration
+		}
+	}
+	return skews, nil
+}
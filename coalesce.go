@@ -0,0 +1,180 @@
+package dcron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/libi/dcron/cron"
+	"github.com/libi/dcron/driver"
+)
+
+var (
+	// ErrCoalesceUnsupported is returned by AddCoalescedJob if the driver
+	// does not implement both driver.KVDriver and driver.LockDriver.
+	ErrCoalesceUnsupported = errors.New("the driver does not support the coalesced jobs")
+	// ErrCoalescedResultTimeout is passed to the consume func of
+	// AddCoalescedJob if the result of the run is not published in time,
+	// e.g. the node computing it died.
+	ErrCoalescedResultTimeout = errors.New("the result of the coalesced job is not published in time")
+)
+
+const (
+	coalesceLockKeyPre   = "coalesce:"
+	coalesceResultKeyPre = "coalesce-result:"
+
+	// the bounds of the interval of polling the result.
+	coalescePollMin = 10 * time.Millisecond
+	coalescePollMax = time.Second
+)
+
+// coalescedResult is the result of a run published in the driver, Run is
+// the key of the run, so a node does not consume the result of the last run.
+type coalescedResult struct {
+	Run    string `json:"run"`
+	Result string `json:"result"`
+	Err    string `json:"err,omitempty"`
+}
+
+// AddCoalescedJob add a broadcast job whose result is computed once in the
+// cluster for each scheduled time and consumed by every node, like a
+// distributed singleflight, e.g. to build a heavy shared cache once and
+// load it in all the nodes.
+//
+// For each run, the nodes race for a lock of the run in the driver, keyed
+// by the job name and the scheduled time as the execution lock does, see
+// WithDedupKeyFunc. The winner calls compute and publishes the result in
+// the driver, the other nodes poll the driver until it is published. Then
+// consume is called in every node with the result, or the error returned
+// by compute. If the result is not published before the next scheduled time,
+// consume is called with ErrCoalescedResultTimeout. If the lock can not be
+// acquired because of the driver, the node computes the result by itself.
+// Only the result of the last run is kept in the driver.
+//
+// The driver must implement driver.KVDriver and driver.LockDriver, or
+// ErrCoalesceUnsupported is returned. Running locally, compute and consume
+// run in this node.
+func (d *Dcron) AddCoalescedJob(jobName, cronStr string,
+	compute func(ctx context.Context) (string, error),
+	consume func(ctx context.Context, result string, err error)) error {
+	if err := validateJob(jobName, compute); err != nil {
+		return err
+	}
+	if consume == nil {
+		return ErrNilJobFunc
+	}
+	if !d.runningLocally {
+		_, isKV := d.driver.(driver.KVDriver)
+		_, isLock := d.driver.(driver.LockDriver)
+		if !isKV || !isLock {
+			return ErrCoalesceUnsupported
+		}
+	}
+	job := cron.FuncContextJob(func(ctx context.Context) {
+		result, err := d.coalesce(ctx, jobName, compute)
+		consume(ctx, result, err)
+	})
+	return d.addMarkedJob(jobName, cronStr, job, &d.broadcastJobs, struct{}{})
+}
+
+// coalesce returns the result of the run of ctx, which is computed by this
+// node if it wins the lock of the run, otherwise it is waited for.
+func (d *Dcron) coalesce(ctx context.Context, jobName string,
+	compute func(ctx context.Context) (string, error)) (string, error) {
+	kv, hasKV := d.kvDriver()
+	if !hasKV {
+		return compute(ctx)
+	}
+	ld := withLockTimeout(d.driver.(driver.LockDriver), d.driverOpTimeout())
+	scheduled, _ := ScheduledTimeFromContext(ctx)
+	run := d.dedupKey(jobName, scheduled)
+	wait := d.coalesceWait(jobName)
+	lockKey := coalesceLockKeyPre + run
+	won, err := ld.AcquireLock(ctx, lockKey, wait)
+	if err != nil {
+		d.logger.Errorf("acquire the lock of coalesced job '%s' error, compute it in this node, err=%v", jobName, err)
+		return compute(ctx)
+	}
+	if !won {
+		return d.waitCoalescedResult(ctx, kv, jobName, run, wait)
+	}
+	defer d.releaseCoalesceLock(ld, lockKey, time.Now().Add(wait))
+	result, err := compute(ctx)
+	published := coalescedResult{Run: run, Result: result}
+	if err != nil {
+		published.Err = err.Error()
+	}
+	value, _ := json.Marshal(published)
+	if serr := kv.Set(ctx, coalesceResultKeyPre+jobName, string(value)); serr != nil {
+		d.logger.Errorf("publish the result of coalesced job '%s' error, err=%v", jobName, serr)
+	}
+	return result, err
+}
+
+// releaseCoalesceLock releases the lock of a run at deadline, when the other
+// nodes do not wait for the result anymore, or when dcron is stopped. The
+// lock is not left to its ttl, since some drivers keep the locks until the
+// session of the node ends, e.g. zookeeper.
+func (d *Dcron) releaseCoalesceLock(ld driver.LockDriver, key string, deadline time.Time) {
+	ctx := d.runtimeContext()
+	d.goTracked(func() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		if err := ld.ReleaseLock(context.Background(), key); err != nil {
+			d.logger.Errorf("release the lock of '%s' error, err=%v", key, err)
+		}
+	})
+}
+
+// waitCoalescedResult polls the driver for the result of run until wait
+// passed.
+func (d *Dcron) waitCoalescedResult(ctx context.Context, kv driver.KVDriver, jobName, run string, wait time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	poll := wait / 20
+	if poll < coalescePollMin {
+		poll = coalescePollMin
+	}
+	if poll > coalescePollMax {
+		poll = coalescePollMax
+	}
+	tick := time.NewTicker(poll)
+	defer tick.Stop()
+	for {
+		value, ok, err := kv.Get(ctx, coalesceResultKeyPre+jobName)
+		var published coalescedResult
+		if err == nil && ok && json.Unmarshal([]byte(value), &published) == nil && published.Run == run {
+			if published.Err != "" {
+				return published.Result, errors.New(published.Err)
+			}
+			return published.Result, nil
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return "", ErrCoalescedResultTimeout
+		}
+	}
+}
+
+// coalesceWait returns how long the nodes wait for the result of a run,
+// which is the interval of the job, or the node update duration if it
+// can not be decided.
+func (d *Dcron) coalesceWait(jobName string) time.Duration {
+	d.jobsRWMut.RLock()
+	job, ok := d.jobs[jobName]
+	d.jobsRWMut.RUnlock()
+	if ok {
+		if schedule, err := d.cr.Parse(job.CronStr); err == nil {
+			if interval := scheduleInterval(schedule, d.clock.Now()); interval > 0 {
+				return interval
+			}
+		}
+	}
+	return d.nodeUpdateDuration
+}
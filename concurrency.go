@@ -0,0 +1,123 @@
+package dcron
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/libi/dcron/cron"
+	"github.com/libi/dcron/dlog"
+	"github.com/libi/dcron/driver"
+)
+
+// ErrInvalidMaxConcurrency is returned if the max concurrency is not positive.
+var ErrInvalidMaxConcurrency = errors.New("max concurrency must be positive")
+
+const concurrencyKeyPre = "sem:"
+
+func concurrencyKey(jobName string, permit int) string {
+	return concurrencyKeyPre + jobName + ":" + strconv.Itoa(permit)
+}
+
+// AddJobWithMaxConcurrency add a cron func which runs in at most max nodes
+// at the same time in the cluster, e.g. while the owner of the job moves
+// from a node to another one. A run takes one of the max permits of the job
+// from the driver.LockDriver, or it is skipped if all the permits are taken.
+// A permit is refreshed every half of the heartbeat TTL during the run, so
+// the permit of a dead node expires with its heartbeat.
+//
+// If the driver is not a LockDriver, the limit is not enforced. If the
+// driver fails to acquire a permit, the run is skipped.
+func (d *Dcron) AddJobWithMaxConcurrency(jobName, cronStr string, cmd func(), max int) error {
+	if max <= 0 {
+		return ErrInvalidMaxConcurrency
+	}
+	return d.AddJobWithWrappers(jobName, cronStr, cmd, d.limitConcurrency(max))
+}
+
+// limitConcurrency returns a JobWrapper which runs the job
+// only if it acquires a permit.
+func (d *Dcron) limitConcurrency(max int) cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		jobName := cron.JobName(j)
+		return cron.FuncErrorJob(func() error {
+			release, ok := d.acquirePermit(jobName, max)
+			if !ok {
+				return nil
+			}
+			defer release()
+			if ej, ok := j.(cron.ErrorJob); ok {
+				return ej.RunWithError()
+			}
+			j.Run()
+			return nil
+		})
+	}
+}
+
+// acquirePermit acquires a free one of the max permits of the job, it returns
+// false if the run should be skipped. The returned release func must be
+// called after the run.
+func (d *Dcron) acquirePermit(jobName string, max int) (release func(), ok bool) {
+	if d.runningLocally || d.driver == nil {
+		return func() {}, true
+	}
+	ld, isLockDriver := d.driver.(driver.LockDriver)
+	if !isLockDriver {
+		d.logger.Warnf("driver is not a LockDriver, max concurrency of job '%s' is not enforced", jobName)
+		return func() {}, true
+	}
+	ld = withLockTimeout(ld, d.driverOpTimeout())
+	ttl := d.heartbeatTTL()
+	for permit := 0; permit < max; permit++ {
+		key := concurrencyKey(jobName, permit)
+		ok, err := ld.AcquireLock(d.runtimeContext(), key, ttl)
+		if err != nil {
+			d.logger.Errorf("acquire permit of job '%s' error, skip it, err=%v", jobName, err)
+			return nil, false
+		}
+		if ok {
+			return d.holdLock(ld, "permit", jobName, key, ttl), true
+		}
+	}
+	dlog.Warnw(d.logger, "job reached the max concurrency in the cluster, skip it",
+		"job_name", jobName, "max_concurrency", max, "node_id", d.NodeID())
+	return nil, false
+}
+
+// holdLock refreshes the lock of key until the returned release func is
+// called, which releases the lock. what is the lock in the logs, e.g.
+// "permit".
+func (d *Dcron) holdLock(ld driver.LockDriver, what, jobName, key string, ttl time.Duration) (release func()) {
+	done := make(chan struct{})
+	if lr, ok := ld.(driver.LockRefresher); ok {
+		d.goTracked(func() {
+			tick := time.NewTicker(ttl / 2)
+			defer tick.Stop()
+			for {
+				select {
+				case <-tick.C:
+					ok, err := lr.RefreshLock(context.Background(), key, ttl)
+					if err != nil {
+						d.logger.Errorf("refresh %s of job '%s' error, err=%v", what, jobName, err)
+						continue
+					}
+					if !ok {
+						d.logger.Warnf("%s of job '%s' is lost during the run", what, jobName)
+						return
+					}
+				case <-done:
+					return
+				}
+			}
+		})
+	}
+	return func() {
+		close(done)
+		// the runtime context may be canceled by Stop during the run.
+		if err := ld.ReleaseLock(context.Background(), key); err != nil {
+			d.logger.Errorf("release %s of job '%s' error, err=%v", what, jobName, err)
+		}
+	}
+}
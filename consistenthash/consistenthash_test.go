@@ -0,0 +1,91 @@
+package consistenthash
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCollisionIndependentOfOrder(t *testing.T) {
+	// all keys collide into the same virtual node.
+	constHash := func([]byte) uint32 { return 1 }
+	a := New(3, constHash)
+	a.Add("a", "b", "c")
+	b := New(3, constHash)
+	b.Add("c", "b", "a")
+	if a.Get("job") != "a" || b.Get("job") != "a" {
+		t.Errorf("expected owner a, got %s and %s", a.Get("job"), b.Get("job"))
+	}
+}
+
+func TestRebalanceMovement(t *testing.T) {
+	const (
+		numberOfJobs = 10000
+		replicas     = 50
+	)
+	nodes := make([]string, 0, 6)
+	for i := 0; i < 6; i++ {
+		nodes = append(nodes, "distributed-cron:TestRebalanceMovement:"+strconv.Itoa(i))
+	}
+
+	for name, hash := range map[string]Hash{"crc32": nil, "fnv1a": FNV1a} {
+		before := New(replicas, hash)
+		before.Add(nodes[:5]...)
+		after := New(replicas, hash)
+		after.Add(nodes...)
+
+		moved := 0
+		for i := 0; i < numberOfJobs; i++ {
+			jobName := "job-" + strconv.Itoa(i)
+			oldOwner, newOwner := before.Get(jobName), after.Get(jobName)
+			if oldOwner == newOwner {
+				continue
+			}
+			moved++
+			// the jobs only move to the new node.
+			if newOwner != nodes[5] {
+				t.Errorf("%s: job %s moved from %s to %s", name, jobName, oldOwner, newOwner)
+			}
+		}
+		if moved*4 >= numberOfJobs {
+			t.Errorf("%s: expected less than 25%% of jobs moved, got %d/%d", name, moved, numberOfJobs)
+		}
+		t.Logf("%s: %d/%d jobs moved", name, moved, numberOfJobs)
+	}
+}
+
+func TestStats(t *testing.T) {
+	const numberOfJobs = 10000
+	m := New(50, FNV1a)
+	if stats := m.Stats(); len(stats) != 0 {
+		t.Errorf("expected no stats of an empty ring, got %v", stats)
+	}
+	m.Add("a", "b")
+	m.AddWithWeight("c", 2)
+
+	stats := m.Stats()
+	owned := make(map[string]int)
+	for i := 0; i < numberOfJobs; i++ {
+		owned[m.Get("job-"+strconv.Itoa(i))]++
+	}
+	var total float64
+	for key, replicas := range map[string]int{"a": 50, "b": 50, "c": 100} {
+		stat := stats[key]
+		if stat.VirtualNodes != replicas {
+			t.Errorf("expected %d virtual nodes of %s, got %d", replicas, key, stat.VirtualNodes)
+		}
+		// the share is close to the fraction of the jobs it owns.
+		if diff := stat.Share - float64(owned[key])/numberOfJobs; diff > 0.05 || diff < -0.05 {
+			t.Errorf("expected the share of %s close to %d/%d, got %f", key, owned[key], numberOfJobs, stat.Share)
+		}
+		total += stat.Share
+	}
+	if total < 0.999999 || total > 1.000001 {
+		t.Errorf("expected the shares sum to 1, got %f", total)
+	}
+
+	one := New(1, nil)
+	one.Add("a")
+	if stat := one.Stats()["a"]; stat.VirtualNodes != 1 || stat.Share != 1 {
+		t.Errorf("expected the only virtual node owns the ring, got %+v", stat)
+	}
+}
@@ -0,0 +1,191 @@
+package dcron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync/atomic"
+
+	"github.com/libi/dcron/driver"
+)
+
+// ErrCordonUnsupported is returned by Cordon and Uncordon if the driver
+// does not implement both driver.KVDriver and driver.LockDriver.
+var ErrCordonUnsupported = errors.New("the driver does not support cordoning the nodes")
+
+const (
+	// the sorted nodeIDs of the cordoned nodes.
+	cordonKey     = "cordoned"
+	cordonLockKey = "cordoned-lock"
+)
+
+// Cordon excludes this node from the hash ring of all the nodes, so its
+// jobs move to the other nodes, like draining a node of kubernetes before
+// the maintenance. The cordon is advertised in the driver, and the nodes
+// exclude this node in their next sync of the nodes. The runs in flight in
+// this node are not canceled and run to completion, use StopWait to wait
+// for them, but no new run starts here until Uncordon. If all the nodes are
+// cordoned, no job runs. The cordon is cleared when this node is stopped,
+// or when a node of the same nodeID is started if this node crashed.
+//
+// The driver must implement driver.KVDriver and driver.LockDriver, or
+// ErrCordonUnsupported is returned. ErrRunningLocally is returned if dcron
+// is running locally, and ErrDcronNotRunning if it is not running.
+func (d *Dcron) Cordon() error {
+	return d.setCordoned(true)
+}
+
+// Uncordon adds this node cordoned by Cordon back to the hash ring of all
+// the nodes, in their next sync of the nodes.
+func (d *Dcron) Uncordon() error {
+	return d.setCordoned(false)
+}
+
+// IsCordoned returns true if this node is cordoned by Cordon.
+func (d *Dcron) IsCordoned() bool {
+	return atomic.LoadInt32(&d.cordoned) == 1
+}
+
+func (d *Dcron) setCordoned(cordoned bool) error {
+	if d.runningLocally {
+		return ErrRunningLocally
+	}
+	if atomic.LoadInt32(&d.running) != dcronRunning {
+		return ErrDcronNotRunning
+	}
+	if _, isLock := d.driver.(driver.LockDriver); !isLock {
+		return ErrCordonUnsupported
+	}
+	if _, isKV := d.kvDriver(); !isKV {
+		return ErrCordonUnsupported
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.driverOpTimeout())
+	defer cancel()
+	if err := d.updateCordoned(ctx, cordoned); err != nil {
+		return err
+	}
+	if cordoned {
+		atomic.StoreInt32(&d.cordoned, 1)
+		d.logger.Warnf("node %s is cordoned, its jobs move to the other nodes", d.nodePool.GetNodeID())
+	} else {
+		atomic.StoreInt32(&d.cordoned, 0)
+		d.logger.Infof("node %s is uncordoned", d.nodePool.GetNodeID())
+	}
+	return nil
+}
+
+// updateCordoned adds this node to the cordoned nodes in the driver, or
+// removes it, under a lock of the driver. The nodes which are not in the
+// driver anymore are removed too.
+func (d *Dcron) updateCordoned(ctx context.Context, cordoned bool) error {
+	kv, _ := d.kvDriver()
+	release, err := d.lockKey(ctx, cordonLockKey)
+	if err != nil {
+		return err
+	}
+	defer release()
+	current, err := d.readCordoned(ctx, kv)
+	if err != nil {
+		return err
+	}
+	alive, err := d.driver.GetNodes(ctx)
+	if err != nil {
+		return err
+	}
+	nodeID := d.nodePool.GetNodeID()
+	nodes := make([]string, 0, len(current)+1)
+	for _, node := range alive {
+		if _, ok := current[node]; ok && node != nodeID {
+			nodes = append(nodes, node)
+		}
+	}
+	if cordoned {
+		nodes = append(nodes, nodeID)
+	}
+	sort.Strings(nodes)
+	value, err := json.Marshal(nodes)
+	if err != nil {
+		return err
+	}
+	return kv.Set(ctx, cordonKey, string(value))
+}
+
+// clearStaleCordon uncordons this node when dcron is started, if it is
+// left in the cordoned nodes by a process of the same nodeID which was
+// not stopped, e.g. it crashed while it was cordoned.
+func (d *Dcron) clearStaleCordon() {
+	if _, isLock := d.driver.(driver.LockDriver); !isLock {
+		return
+	}
+	kv, isKV := d.kvDriver()
+	if !isKV {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.driverOpTimeout())
+	defer cancel()
+	cordoned, err := d.readCordoned(ctx, kv)
+	if err != nil {
+		d.logger.Errorf("read the cordoned nodes error, err=%v", err)
+		return
+	}
+	nodeID := d.nodePool.GetNodeID()
+	if _, ok := cordoned[nodeID]; !ok {
+		return
+	}
+	if err = d.updateCordoned(ctx, false); err != nil {
+		d.logger.Errorf("uncordon this node error, err=%v", err)
+		return
+	}
+	d.logger.Warnf("node %s is left cordoned by its last run, it is uncordoned", nodeID)
+}
+
+// readCordoned returns the cordoned nodes in the driver.
+func (d *Dcron) readCordoned(ctx context.Context, kv driver.KVDriver) (map[string]struct{}, error) {
+	value, ok, err := kv.Get(ctx, cordonKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var nodes []string
+	if err = json.Unmarshal([]byte(value), &nodes); err != nil {
+		d.logger.Errorf("invalid cordoned nodes, err=%v", err)
+		return nil, nil
+	}
+	cordoned := make(map[string]struct{}, len(nodes))
+	for _, node := range nodes {
+		cordoned[node] = struct{}{}
+	}
+	return cordoned, nil
+}
+
+// uncordonedNodes is the NodeFilter which drops the cordoned nodes.
+func (d *Dcron) uncordonedNodes(ctx context.Context, nodes []string) ([]string, error) {
+	kv, ok := d.kvDriver()
+	if !ok {
+		return nodes, nil
+	}
+	cordoned, err := d.readCordoned(ctx, kv)
+	if err != nil || len(cordoned) == 0 {
+		return nodes, err
+	}
+	ret := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if _, ok := cordoned[node]; !ok {
+			ret = append(ret, node)
+		}
+	}
+	return ret, nil
+}
+
+// clearCordon uncordons this node when dcron is stopped.
+func (d *Dcron) clearCordon() {
+	if !d.IsCordoned() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.nodeUpdateDuration)
+	defer cancel()
+	if err := d.updateCordoned(ctx, false); err != nil {
+		d.logger.Errorf("uncordon this node error, err=%v", err)
+	}
+	atomic.StoreInt32(&d.cordoned, 0)
+}
@@ -1,13 +1,22 @@
 package cron
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/libi/dcron/dlog"
 )
 
+// ErrJobTimeout is returned by the job wrapped by TimeoutJob
+// when the timeout is reached.
+var ErrJobTimeout = errors.New("job timeout")
+
 // JobWrapper decorates the given Job with some behavior.
 type JobWrapper func(Job) Job
 
@@ -31,23 +40,92 @@ func NewChain(c ...JobWrapper) Chain {
 // is equivalent to:
 //
 //	m1(m2(m3(job)))
+//
+// If the job is a NamedJob or a LoggedJob, each wrapper sees a job of the
+// same name and logger.
 func (c Chain) Then(j Job) Job {
+	name, logger := JobName(j), jobLogger(j, nil)
 	for i := range c.wrappers {
 		j = c.wrappers[len(c.wrappers)-i-1](j)
+		_, named := j.(NamedJob)
+		_, logged := j.(LoggedJob)
+		if (name != "" && !named) || (logger != nil && !logged) {
+			j = namedJob{Job: j, name: name, logger: logger}
+		}
 	}
 	return j
 }
 
+// PanicHandler is called by RecoverWithHandler with the recovered panic
+// value and the stack, jobName is "" if the job is not a NamedJob.
+type PanicHandler func(jobName string, recovered interface{}, stack []byte)
+
+// PanicPolicy decides what RecoverWithPolicy does after a panic is logged.
+type PanicPolicy int
+
+const (
+	// PanicRecover recovers the panic and continues, it is the default.
+	PanicRecover PanicPolicy = iota
+	// PanicRethrow panics again with the recovered value after the handler
+	// is called, so the process crashes and can be restarted. The stack of
+	// the original panic is logged.
+	PanicRethrow
+	// PanicCallback recovers the panic and calls the handler.
+	PanicCallback
+)
+
+func (p PanicPolicy) String() string {
+	switch p {
+	case PanicRecover:
+		return "Recover"
+	case PanicRethrow:
+		return "Rethrow"
+	case PanicCallback:
+		return "Callback"
+	}
+	return "Unknown"
+}
+
 // Recover panics in wrapped jobs and log them with the provided logger.
+// The errors returned by an ErrorJob are logged too, and passed through.
 func Recover(logger dlog.Logger) JobWrapper {
+	return RecoverWithPolicy(logger, PanicRecover, nil)
+}
+
+// RecoverWithHandler is the same as Recover, and calls handler after the
+// panic is logged, e.g. to report it to an error tracking service.
+func RecoverWithHandler(logger dlog.Logger, handler PanicHandler) JobWrapper {
+	return RecoverWithPolicy(logger, PanicCallback, handler)
+}
+
+// RecoverWithPolicy is the same as Recover, but after the panic is logged
+// with its stack, handler is called if it is not nil, and the panic is
+// handled by policy.
+func RecoverWithPolicy(logger dlog.Logger, policy PanicPolicy, handler PanicHandler) JobWrapper {
 	return func(j Job) Job {
-		return FuncJob(func() {
+		logger := jobLogger(j, logger)
+		return FuncErrorJob(func() (err error) {
 			defer func() {
 				if r := recover(); r != nil {
-					logger.Errorf("panic: stack %v\n%s\n", r, debug.Stack())
+					stack := debug.Stack()
+					if tp, ok := r.(timeoutPanic); ok {
+						r, stack = tp.recovered, tp.stack
+					}
+					if logs(logger, LogPanic) {
+						dlog.Errorw(logger, "panic", jobKV(j, "recovered", r, "policy", policy, "stack", string(stack))...)
+					}
+					if handler != nil {
+						handler(JobName(j), r, stack)
+					}
+					if policy == PanicRethrow {
+						panic(r)
+					}
 				}
 			}()
-			j.Run()
+			if err = runJob(j); err != nil && logs(logger, LogPanic) {
+				dlog.Errorw(logger, "job failed", jobKV(j, "err", err)...)
+			}
+			return err
 		})
 	}
 }
@@ -56,34 +134,349 @@ func Recover(logger dlog.Logger) JobWrapper {
 // previous one is complete. Jobs running after a delay of more than a minute
 // have the delay logged at Info.
 func DelayIfStillRunning(logger dlog.Logger) JobWrapper {
+	return DelayIfStillRunningWithClock(logger, DefaultClock)
+}
+
+// DelayIfStillRunningWithClock is the same as DelayIfStillRunning,
+// but the delay is measured by clock.
+func DelayIfStillRunningWithClock(logger dlog.Logger, clock Clock) JobWrapper {
+	return delayIfStillRunning(logger, clock, -1)
+}
+
+// DelayIfStillRunningBounded is the same as DelayIfStillRunning, but at most
+// max runs are delayed at the same time, a run triggered when max runs are
+// already waiting is dropped and logged at Warn level, so a slow job does
+// not build up a backlog which fires in a burst later.
+func DelayIfStillRunningBounded(max int, logger dlog.Logger) JobWrapper {
+	if max < 0 {
+		max = 0
+	}
+	return delayIfStillRunning(logger, DefaultClock, max)
+}
+
+// delayIfStillRunning delays at most max runs, max < 0 means no limit.
+func delayIfStillRunning(logger dlog.Logger, clock Clock, max int) JobWrapper {
 	return func(j Job) Job {
+		logger := jobLogger(j, logger)
 		var mu sync.Mutex
-		return FuncJob(func() {
-			start := time.Now()
-			mu.Lock()
+		var waiting int32
+		return FuncErrorJob(func() error {
+			start := clock.Now()
+			delayed := !mu.TryLock()
+			if delayed {
+				if max >= 0 && atomic.AddInt32(&waiting, 1) > int32(max) {
+					atomic.AddInt32(&waiting, -1)
+					dlog.Warnw(logger, "drop, too many delayed runs", jobKV(j, "max", max)...)
+					if nj, ok := j.(NotifiedJob); ok {
+						nj.Skipped()
+					}
+					return nil
+				}
+				mu.Lock()
+				if max >= 0 {
+					atomic.AddInt32(&waiting, -1)
+				}
+			}
 			defer mu.Unlock()
-			if dur := time.Since(start); dur > time.Minute {
-				logger.Infof("delay duration=%v", dur)
+			dur := clock.Now().Sub(start)
+			if dur > time.Minute && logs(logger, LogDelay) {
+				dlog.Infow(logger, "delay", jobKV(j, "duration", dur)...)
+			}
+			if nj, ok := j.(NotifiedJob); ok && delayed {
+				nj.Delayed(dur)
 			}
-			j.Run()
+			return runJob(j)
 		})
 	}
 }
 
+// skipLogWindow is the window in which the skips of a job are logged once
+// by SkipIfStillRunning.
+const skipLogWindow = time.Minute
+
 // SkipIfStillRunning skips an invocation of the Job if a previous invocation is
-// still running. It logs skips to the given logger at Info level.
+// still running. It logs skips to the given logger at Info level. The first
+// skip is logged immediately, the following skips in a minute are counted
+// and logged as one message by the next skip after the minute.
 func SkipIfStillRunning(logger dlog.Logger) JobWrapper {
+	return SkipIfStillRunningWithClock(logger, DefaultClock)
+}
+
+// SkipIfStillRunningWithClock is the same as SkipIfStillRunning,
+// but the window of the skip logs is measured by clock.
+func SkipIfStillRunningWithClock(logger dlog.Logger, clock Clock) JobWrapper {
+	return skipIfStillRunning(logger, clock, 0)
+}
+
+// SkipIfStillRunningWarnAfter is the same as SkipIfStillRunning, but once
+// the running invocation has been running for stuckAfter, every skip is
+// logged at Error level with how long it has been running and how many runs
+// are skipped since it started, so a hung job can be alerted on.
+func SkipIfStillRunningWarnAfter(stuckAfter time.Duration, logger dlog.Logger) JobWrapper {
+	return SkipIfStillRunningWarnAfterWithClock(stuckAfter, logger, DefaultClock)
+}
+
+// SkipIfStillRunningWarnAfterWithClock is the same as
+// SkipIfStillRunningWarnAfter, but the time is measured by clock.
+func SkipIfStillRunningWarnAfterWithClock(stuckAfter time.Duration, logger dlog.Logger, clock Clock) JobWrapper {
+	return skipIfStillRunning(logger, clock, stuckAfter)
+}
+
+// skipIfStillRunning logs the skips at Error level once the running
+// invocation is running for stuckAfter, stuckAfter <= 0 means never.
+func skipIfStillRunning(logger dlog.Logger, clock Clock, stuckAfter time.Duration) JobWrapper {
 	return func(j Job) Job {
+		logger := jobLogger(j, logger)
 		var ch = make(chan struct{}, 1)
 		ch <- struct{}{}
-		return FuncJob(func() {
+		var (
+			mu          sync.Mutex
+			windowStart time.Time
+			skipped     int
+			// the start time of the running invocation, and the
+			// number of the runs skipped since then.
+			runningSince time.Time
+			skippedSince int
+		)
+		logSkip := func() {
+			mu.Lock()
+			defer mu.Unlock()
+			now := clock.Now()
+			skippedSince++
+			if stuckAfter > 0 && now.Sub(runningSince) >= stuckAfter {
+				dlog.Errorw(logger, "skip, job is still running",
+					jobKV(j, "running_for", now.Sub(runningSince), "skipped_runs", skippedSince)...)
+				return
+			}
+			if !logs(logger, LogSkip) {
+				return
+			}
+			if !windowStart.IsZero() && now.Sub(windowStart) < skipLogWindow {
+				skipped++
+				return
+			}
+			if skipped > 0 {
+				dlog.Infow(logger, "skipped", jobKV(j, "times", skipped+1, "in_the_last", now.Sub(windowStart))...)
+			} else {
+				dlog.Infow(logger, "skip", jobKV(j)...)
+			}
+			windowStart, skipped = now, 0
+		}
+		return FuncErrorJob(func() error {
 			select {
 			case v := <-ch:
 				defer func() { ch <- v }()
-				j.Run()
+				mu.Lock()
+				runningSince, skippedSince = clock.Now(), 0
+				mu.Unlock()
+				return runJob(j)
 			default:
-				logger.Infof("skip")
+				logSkip()
+				if nj, ok := j.(NotifiedJob); ok {
+					nj.Skipped()
+				}
+				return nil
 			}
 		})
 	}
 }
+
+// ExponentialBackoff returns 1s, 2s, 4s, ... for attempt 1, 2, 3, ...
+// The delay is capped at one minute.
+func ExponentialBackoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 7 {
+		return time.Minute
+	}
+	return time.Second << (attempt - 1)
+}
+
+// RetryIfFailed re-runs the Job when it panics, or when it is an ErrorJob
+// and returns an error, up to maxRetries times.
+// Before each retry it sleeps backoff(attempt), where attempt starts from 1.
+// Once the retries are exhausted the last failure is logged at Error, a panic
+// is re-raised so an outer Recover still sees it, and an error is returned.
+func RetryIfFailed(maxRetries int, backoff func(attempt int) time.Duration, logger dlog.Logger) JobWrapper {
+	return RetryIfFailedWithContext(context.Background(), maxRetries, backoff, logger)
+}
+
+// RetryIfFailedWithContext is the same as RetryIfFailed, but the backoff
+// sleeps are interrupted once ctx is done. Cancel ctx on shutdown so that
+// a retrying job does not block for the whole backoff duration.
+func RetryIfFailedWithContext(ctx context.Context, maxRetries int, backoff func(attempt int) time.Duration, logger dlog.Logger) JobWrapper {
+	return func(j Job) Job {
+		logger := jobLogger(j, logger)
+		return FuncErrorJob(func() error {
+			for attempt := 0; ; attempt++ {
+				r, err := runAndRecover(j)
+				if r == nil && err == nil {
+					return nil
+				}
+				if attempt >= maxRetries {
+					dlog.Errorw(logger, "retry exhausted", jobKV(j, "retries", maxRetries, "panic", r, "err", err)...)
+					return failed(r, err)
+				}
+				delay := backoff(attempt + 1)
+				if logs(logger, LogRetry) {
+					dlog.Infow(logger, "retry", jobKV(j, "attempt", attempt+1, "delay", delay, "panic", r, "err", err)...)
+				}
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					dlog.Errorw(logger, "retry interrupted", jobKV(j, "attempt", attempt+1, "err", ctx.Err())...)
+					return failed(r, err)
+				}
+			}
+		})
+	}
+}
+
+// jobKV prepends the name of j to keysAndValues if j is a NamedJob.
+func jobKV(j Job, keysAndValues ...any) []any {
+	if nj, ok := j.(NamedJob); ok && nj.JobName() != "" {
+		return append([]any{"job_name", nj.JobName()}, keysAndValues...)
+	}
+	return keysAndValues
+}
+
+// runAndRecover runs the job and returns the recovered panic value, if any,
+// and the error returned by the job.
+func runAndRecover(j Job) (r interface{}, err error) {
+	defer func() {
+		r = recover()
+	}()
+	err = runJob(j)
+	return
+}
+
+// failed re-raises the panic r if it is not nil, otherwise returns err.
+func failed(r interface{}, err error) error {
+	if r != nil {
+		panic(r)
+	}
+	return err
+}
+
+// JitterJob delays each run of the Job by a random duration in [0, max),
+// to spread the jobs which are scheduled at the same time.
+func JitterJob(max time.Duration) JobWrapper {
+	return JitterJobWithContext(context.Background(), max)
+}
+
+// JitterJobWithContext is the same as JitterJob, but the delay is
+// interrupted once ctx is done, and then the run is dropped.
+// Cancel ctx on shutdown so that the shutdown is not blocked.
+func JitterJobWithContext(ctx context.Context, max time.Duration) JobWrapper {
+	return func(j Job) Job {
+		return FuncErrorJob(func() error {
+			if max > 0 {
+				timer := time.NewTimer(time.Duration(rand.Int63n(int64(max))))
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return nil
+				}
+			}
+			return runJob(j)
+		})
+	}
+}
+
+// TimeoutJob bounds the runtime of the wrapped Job to d. The job is run in
+// its own goroutine, and if it is not finished after d, a timeout is logged
+// at Error and control returns to the scheduler.
+//
+// Job.Run() can not be canceled, so a timed out job keeps running in the
+// background; only the scheduler is unblocked. Chained inside
+// SkipIfStillRunning, a timed out job is considered done and the next run
+// is allowed. If the wrapped job is a ContextJob, it is run with a context
+// which is canceled when the timeout is reached, so it can abort by itself.
+// The other wrappers of this package do not pass the context through, put
+// TimeoutJob last in the chain so it wraps the ContextJob directly.
+// A timed out run returns ErrJobTimeout to the outer wrappers.
+//
+// A panic of the job before the timeout is raised again in the goroutine
+// of the caller, so the outer wrappers like Recover see it, Recover logs
+// the stack of the job instead of the stack of TimeoutJob. A panic after
+// the timeout is logged at Error, since nobody waits for the job anymore.
+func TimeoutJob(d time.Duration, logger dlog.Logger) JobWrapper {
+	return func(j Job) Job {
+		logger := jobLogger(j, logger)
+		return keepIdentity(j, FuncErrorJob(func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), d)
+			defer cancel()
+			done := make(chan timeoutResult, 1)
+			go func() {
+				var result timeoutResult
+				defer func() {
+					if r := recover(); r != nil {
+						result = timeoutResult{panicked: true, recovered: r, stack: debug.Stack()}
+						// the job is a TimeoutJob too.
+						if tp, ok := r.(timeoutPanic); ok {
+							result.recovered, result.stack = tp.recovered, tp.stack
+						}
+					}
+					done <- result
+				}()
+				switch cj := j.(type) {
+				case ContextErrorJob:
+					result.err = cj.RunWithContextError(ctx)
+				case ContextJob:
+					cj.RunWithContext(ctx)
+				default:
+					result.err = runJob(j)
+				}
+			}()
+			select {
+			case result := <-done:
+				if result.panicked {
+					panic(timeoutPanic{recovered: result.recovered, stack: result.stack})
+				}
+				return result.err
+			case <-ctx.Done():
+				dlog.Errorw(logger, "timeout, job is still running", jobKV(j, "timeout", d)...)
+				go func() {
+					if result := <-done; result.panicked && logs(logger, LogPanic) {
+						dlog.Errorw(logger, "panic after timeout", jobKV(j, "recovered", result.recovered, "stack", string(result.stack))...)
+					}
+				}()
+				return ErrJobTimeout
+			}
+		}))
+	}
+}
+
+// timeoutResult is the result of a run of the job wrapped by TimeoutJob.
+type timeoutResult struct {
+	err       error
+	panicked  bool
+	recovered interface{}
+	stack     []byte
+}
+
+// timeoutPanic is raised by TimeoutJob in the goroutine of the caller with
+// the panic of the job and the stack of the job, Recover unwraps it.
+type timeoutPanic struct {
+	recovered interface{}
+	stack     []byte
+}
+
+func (p timeoutPanic) String() string {
+	return fmt.Sprint(p.recovered)
+}
+
+// keepIdentity returns wrapped with the name and the logger of j, if j is
+// a NamedJob or a LoggedJob, so the wrappers outside of it see them without
+// a Chain.
+func keepIdentity(j, wrapped Job) Job {
+	name, logger := JobName(j), jobLogger(j, nil)
+	if name == "" && logger == nil {
+		return wrapped
+	}
+	return namedJob{Job: wrapped, name: name, logger: logger}
+}
@@ -1,9 +1,12 @@
 package cron
 
 import (
+	"context"
+	"errors"
 	"io"
 	"log"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -72,6 +75,99 @@ func TestChainRecover(t *testing.T) {
 	})
 }
 
+type namedPanickingJob string
+
+func (j namedPanickingJob) Run() { panic("namedPanickingJob panics") }
+
+func (j namedPanickingJob) JobName() string { return string(j) }
+
+func TestChainRecoverWithHandler(t *testing.T) {
+	var (
+		gotName  string
+		gotPanic interface{}
+		gotStack []byte
+	)
+	handler := func(jobName string, recovered interface{}, stack []byte) {
+		gotName, gotPanic, gotStack = jobName, recovered, stack
+	}
+	logger := dlog.DefaultPrintfLogger(log.New(io.Discard, "", 0))
+
+	// the name is kept through the inner wrappers.
+	NewChain(RecoverWithHandler(logger, handler), SkipIfStillRunning(logger), DelayIfStillRunning(logger)).
+		Then(namedPanickingJob("job1")).
+		Run()
+	if gotName != "job1" {
+		t.Errorf("expected job name job1, got %q", gotName)
+	}
+	if gotPanic != "namedPanickingJob panics" {
+		t.Errorf("unexpected recovered value %v", gotPanic)
+	}
+	if !strings.Contains(string(gotStack), "namedPanickingJob") {
+		t.Errorf("expected the stack of the panic, got %s", gotStack)
+	}
+
+	NewChain(RecoverWithHandler(logger, handler)).
+		Then(FuncJob(func() { panic("anonymous") })).
+		Run()
+	if gotName != "" || gotPanic != "anonymous" {
+		t.Errorf("unexpected job name %q or recovered value %v", gotName, gotPanic)
+	}
+}
+
+func TestChainRecoverWithPolicy(t *testing.T) {
+	var buf syncWriter
+	logger := newBufLogger(&buf)
+	var handled interface{}
+	handler := func(jobName string, recovered interface{}, stack []byte) {
+		handled = recovered
+	}
+
+	t.Run("rethrow panics again after the handler", func(t *testing.T) {
+		handled = nil
+		defer func() {
+			if r := recover(); r != "namedPanickingJob panics" {
+				t.Errorf("expected the panic rethrown, got %v", r)
+			}
+			if handled != "namedPanickingJob panics" {
+				t.Errorf("expected the handler called, got %v", handled)
+			}
+			// the stack of the original panic is logged.
+			if !strings.Contains(buf.String(), "namedPanickingJob") || !strings.Contains(buf.String(), "policy=Rethrow") {
+				t.Errorf("expected the stack logged, got %s", buf.String())
+			}
+		}()
+		NewChain(RecoverWithPolicy(logger, PanicRethrow, handler)).
+			Then(namedPanickingJob("job1")).
+			Run()
+	})
+
+	t.Run("callback recovers after the handler", func(t *testing.T) {
+		handled = nil
+		NewChain(RecoverWithPolicy(logger, PanicCallback, handler)).
+			Then(FuncJob(func() { panic("callback") })).
+			Run()
+		if handled != "callback" {
+			t.Errorf("expected the handler called, got %v", handled)
+		}
+	})
+
+	t.Run("panic policy of cron wraps the chain", func(t *testing.T) {
+		handled = nil
+		panicking := func(j Job) Job {
+			return FuncJob(func() { panic("wrapper") })
+		}
+		c := New(WithChain(panicking), WithLogger(logger), WithPanicPolicy(PanicCallback, handler))
+		id, err := c.AddFunc("@every 1h", func() {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Entry(id).WrappedJob.Run()
+		if handled != "wrapper" {
+			t.Errorf("expected the panic of the chain handled, got %v", handled)
+		}
+	})
+}
+
 type countJob struct {
 	m       sync.Mutex
 	started int
@@ -162,6 +258,39 @@ func TestChainDelayIfStillRunning(t *testing.T) {
 	})
 }
 
+func TestChainDelayIfStillRunningBounded(t *testing.T) {
+	var j countJob
+	j.delay = 100 * time.Millisecond
+	wrappedJob := NewChain(DelayIfStillRunningBounded(1, DiscardLogger)).Then(&j)
+	go wrappedJob.Run()
+	<-time.After(10 * time.Millisecond)
+	go wrappedJob.Run()
+	<-time.After(10 * time.Millisecond)
+
+	// the first job is running and the second one is waiting,
+	// so the third one is dropped immediately.
+	start := time.Now()
+	wrappedJob.Run()
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Error("expected the third job dropped immediately, got delayed", d)
+	}
+
+	<-time.After(250 * time.Millisecond)
+	started, done := j.Started(), j.Done()
+	if started != 2 || done != 2 {
+		t.Error("expected two jobs done, got", started, done)
+	}
+
+	// a job is queued again once the backlog is drained.
+	go wrappedJob.Run()
+	<-time.After(10 * time.Millisecond)
+	go wrappedJob.Run()
+	<-time.After(250 * time.Millisecond)
+	if done := j.Done(); done != 4 {
+		t.Error("expected four jobs done, got", done)
+	}
+}
+
 func TestChainSkipIfStillRunning(t *testing.T) {
 
 	t.Run("runs immediately", func(t *testing.T) {
@@ -254,3 +383,412 @@ func TestChainSkipIfStillRunning(t *testing.T) {
 	})
 
 }
+
+type failingJob struct {
+	m     sync.Mutex
+	runs  int
+	fails int
+}
+
+func (j *failingJob) Run() {
+	j.m.Lock()
+	defer j.m.Unlock()
+	j.runs++
+	if j.runs <= j.fails {
+		panic("failingJob fails")
+	}
+}
+
+func (j *failingJob) Runs() int {
+	defer j.m.Unlock()
+	j.m.Lock()
+	return j.runs
+}
+
+func TestChainRetryIfFailed(t *testing.T) {
+	noBackoff := func(int) time.Duration { return 0 }
+
+	t.Run("succeeds after retries", func(t *testing.T) {
+		j := &failingJob{fails: 2}
+		NewChain(RetryIfFailed(3, noBackoff, DiscardLogger)).Then(j).Run()
+		if c := j.Runs(); c != 3 {
+			t.Errorf("expected job run 3 times, got %d", c)
+		}
+	})
+
+	t.Run("panics once retries exhausted", func(t *testing.T) {
+		j := &failingJob{fails: 10}
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("panic expected, but none received")
+				}
+			}()
+			NewChain(RetryIfFailed(2, noBackoff, DiscardLogger)).Then(j).Run()
+		}()
+		if c := j.Runs(); c != 3 {
+			t.Errorf("expected job run 3 times, got %d", c)
+		}
+	})
+
+	t.Run("composed with Recover", func(t *testing.T) {
+		j := &failingJob{fails: 10}
+		NewChain(Recover(DiscardLogger), RetryIfFailed(1, noBackoff, DiscardLogger)).Then(j).Run()
+		if c := j.Runs(); c != 2 {
+			t.Errorf("expected job run 2 times, got %d", c)
+		}
+	})
+
+	t.Run("backoff interrupted by context", func(t *testing.T) {
+		j := &failingJob{fails: 10}
+		ctx, cancel := context.WithCancel(context.Background())
+		longBackoff := func(int) time.Duration { return time.Hour }
+		wrappedJob := NewChain(
+			Recover(DiscardLogger),
+			RetryIfFailedWithContext(ctx, 3, longBackoff, DiscardLogger),
+		).Then(j)
+		done := make(chan struct{})
+		go func() {
+			wrappedJob.Run()
+			close(done)
+		}()
+		<-time.After(10 * time.Millisecond)
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expected retry backoff to be interrupted")
+		}
+		if c := j.Runs(); c != 1 {
+			t.Errorf("expected job run once, got %d", c)
+		}
+	})
+}
+
+func TestChainErrorJob(t *testing.T) {
+	noBackoff := func(int) time.Duration { return 0 }
+	errFailed := errors.New("failed")
+
+	t.Run("retried on error", func(t *testing.T) {
+		var runs int
+		j := FuncErrorJob(func() error {
+			runs++
+			if runs < 3 {
+				return errFailed
+			}
+			return nil
+		})
+		err := NewChain(RetryIfFailed(3, noBackoff, DiscardLogger)).Then(j).(ErrorJob).RunWithError()
+		if err != nil || runs != 3 {
+			t.Errorf("expected job run 3 times without error, got %d, %v", runs, err)
+		}
+	})
+
+	t.Run("error passed through the chain", func(t *testing.T) {
+		var runs int
+		j := FuncErrorJob(func() error {
+			runs++
+			return errFailed
+		})
+		wrappedJob := NewChain(
+			Recover(DiscardLogger),
+			SkipIfStillRunning(DiscardLogger),
+			RetryIfFailed(1, noBackoff, DiscardLogger),
+		).Then(j)
+		ej, ok := wrappedJob.(ErrorJob)
+		if !ok {
+			t.Fatal("expected the wrapped job is an ErrorJob")
+		}
+		if err := ej.RunWithError(); err != errFailed {
+			t.Errorf("expected %v, got %v", errFailed, err)
+		}
+		if runs != 2 {
+			t.Errorf("expected job run 2 times, got %d", runs)
+		}
+	})
+
+	t.Run("plain job succeeds", func(t *testing.T) {
+		var j countJob
+		err := NewChain(Recover(DiscardLogger), DelayIfStillRunning(DiscardLogger)).Then(&j).(ErrorJob).RunWithError()
+		if err != nil || j.Done() != 1 {
+			t.Errorf("expected job run once without error, got %d, %v", j.Done(), err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		j := FuncErrorJob(func() error {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		})
+		err := NewChain(TimeoutJob(10*time.Millisecond, DiscardLogger)).Then(j).(ErrorJob).RunWithError()
+		if err != ErrJobTimeout {
+			t.Errorf("expected %v, got %v", ErrJobTimeout, err)
+		}
+	})
+}
+
+func TestChainJitterJob(t *testing.T) {
+	t.Run("delayed less than max", func(t *testing.T) {
+		var j countJob
+		start := time.Now()
+		NewChain(JitterJob(50 * time.Millisecond)).Then(&j).Run()
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("expected delay less than 50ms, got %v", elapsed)
+		}
+		if j.Done() != 1 {
+			t.Errorf("expected job run once, got %d", j.Done())
+		}
+	})
+
+	t.Run("interrupted by context", func(t *testing.T) {
+		var j countJob
+		ctx, cancel := context.WithCancel(context.Background())
+		wrappedJob := NewChain(JitterJobWithContext(ctx, time.Hour)).Then(&j)
+		done := make(chan struct{})
+		go func() {
+			wrappedJob.Run()
+			close(done)
+		}()
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expected jitter to be interrupted")
+		}
+		if j.Started() != 0 {
+			t.Errorf("expected job not run, got %d", j.Started())
+		}
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	expects := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	for i, expect := range expects {
+		if d := ExponentialBackoff(i + 1); d != expect {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, expect, d)
+		}
+	}
+	if d := ExponentialBackoff(100); d != time.Minute {
+		t.Errorf("expected backoff capped at 1m, got %v", d)
+	}
+}
+
+type ctxJob struct {
+	countJob
+	canceled chan struct{}
+}
+
+func (j *ctxJob) RunWithContext(ctx context.Context) {
+	<-ctx.Done()
+	close(j.canceled)
+}
+
+func TestChainTimeoutJob(t *testing.T) {
+
+	t.Run("returns when job done", func(t *testing.T) {
+		var j countJob
+		j.delay = 5 * time.Millisecond
+		NewChain(TimeoutJob(time.Second, DiscardLogger)).Then(&j).Run()
+		if c := j.Done(); c != 1 {
+			t.Errorf("expected job done, got %d", c)
+		}
+	})
+
+	t.Run("returns on timeout", func(t *testing.T) {
+		var j countJob
+		j.delay = 200 * time.Millisecond
+		start := time.Now()
+		NewChain(TimeoutJob(20*time.Millisecond, DiscardLogger)).Then(&j).Run()
+		if dur := time.Since(start); dur > 100*time.Millisecond {
+			t.Errorf("expected scheduler unblocked after timeout, took %v", dur)
+		}
+		if started, done := j.Started(), j.Done(); started != 1 || done != 0 {
+			t.Error("expected job started, but not finished, got", started, done)
+		}
+	})
+
+	t.Run("timed out job does not block SkipIfStillRunning", func(t *testing.T) {
+		var j countJob
+		j.delay = 200 * time.Millisecond
+		wrappedJob := NewChain(
+			SkipIfStillRunning(DiscardLogger),
+			TimeoutJob(20*time.Millisecond, DiscardLogger),
+		).Then(&j)
+		wrappedJob.Run()
+		wrappedJob.Run()
+		if c := j.Started(); c != 2 {
+			t.Errorf("expected job started twice, got %d", c)
+		}
+	})
+
+	t.Run("context job is canceled", func(t *testing.T) {
+		j := &ctxJob{canceled: make(chan struct{})}
+		NewChain(TimeoutJob(10*time.Millisecond, DiscardLogger)).Then(j).Run()
+		select {
+		case <-j.canceled:
+		case <-time.After(time.Second):
+			t.Fatal("expected context of job canceled")
+		}
+	})
+
+	t.Run("panic is recovered by the outer Recover", func(t *testing.T) {
+		var recovered interface{}
+		handler := func(jobName string, r interface{}, stack []byte) { recovered = r }
+		j := RecoverWithHandler(DiscardLogger, handler)(TimeoutJob(time.Second, DiscardLogger)(FuncJob(func() {
+			panic("YOLO")
+		})))
+		j.Run()
+		if recovered != "YOLO" {
+			t.Errorf("expected the panic recovered, got %v", recovered)
+		}
+	})
+
+	t.Run("stack of the job is passed to the outer Recover", func(t *testing.T) {
+		var recoveredStack []byte
+		handler := func(jobName string, r interface{}, stack []byte) { recoveredStack = stack }
+		j := RecoverWithHandler(DiscardLogger, handler)(TimeoutJob(time.Second, DiscardLogger)(FuncJob(panicInJob)))
+		j.Run()
+		if !strings.Contains(string(recoveredStack), "panicInJob") {
+			t.Errorf("expected the stack of the job, got %s", recoveredStack)
+		}
+	})
+
+	t.Run("error of context error job is returned", func(t *testing.T) {
+		expected := errors.New("failed")
+		j := TimeoutJob(time.Second, DiscardLogger)(FuncContextErrorJob(func(ctx context.Context) error {
+			return expected
+		}))
+		if err := j.(ErrorJob).RunWithError(); err != expected {
+			t.Errorf("expected %v, got %v", expected, err)
+		}
+	})
+
+	t.Run("name is kept", func(t *testing.T) {
+		j := TimeoutJob(time.Second, DiscardLogger)(NewNamedJob("job", FuncJob(func() {})))
+		if name := JobName(j); name != "job" {
+			t.Errorf("expected the name kept, got %q", name)
+		}
+	})
+}
+
+func panicInJob() {
+	panic("YOLO")
+}
+
+type notifiedJob struct {
+	countJob
+	skipped int32
+	delayed int32
+}
+
+func (j *notifiedJob) Skipped() {
+	j.m.Lock()
+	j.skipped++
+	j.m.Unlock()
+}
+
+func (j *notifiedJob) Delayed(time.Duration) {
+	j.m.Lock()
+	j.delayed++
+	j.m.Unlock()
+}
+
+func TestChainNotifiedJob(t *testing.T) {
+
+	t.Run("notified when skipped", func(t *testing.T) {
+		j := &notifiedJob{}
+		j.delay = 50 * time.Millisecond
+		wrappedJob := NewChain(SkipIfStillRunning(DiscardLogger)).Then(j)
+		go wrappedJob.Run()
+		<-time.After(10 * time.Millisecond)
+		wrappedJob.Run()
+		j.m.Lock()
+		defer j.m.Unlock()
+		if j.skipped != 1 {
+			t.Errorf("expected skipped once, got %d", j.skipped)
+		}
+	})
+
+	t.Run("notified when delayed", func(t *testing.T) {
+		j := &notifiedJob{}
+		j.delay = 50 * time.Millisecond
+		wrappedJob := NewChain(DelayIfStillRunning(DiscardLogger)).Then(j)
+		wrappedJob.Run()
+		go wrappedJob.Run()
+		<-time.After(10 * time.Millisecond)
+		wrappedJob.Run()
+		j.m.Lock()
+		defer j.m.Unlock()
+		if j.delayed != 1 {
+			t.Errorf("expected delayed once, got %d", j.delayed)
+		}
+	})
+}
+
+func TestSuppressLogs(t *testing.T) {
+	var buf syncWriter
+	logger := SuppressLogs(dlog.VerbosePrintfLogger(log.New(&buf, "", 0)), LogSkip|LogRetry)
+
+	// the skip is not logged.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	skipped := NewChain(SkipIfStillRunning(logger)).Then(FuncJob(func() {
+		close(started)
+		<-release
+	}))
+	go skipped.Run()
+	<-started
+	skipped.Run()
+	close(release)
+
+	// the retry is not logged.
+	NewChain(RetryIfFailed(1, func(int) time.Duration { return 0 }, logger)).Then(&failingJob{fails: 1}).Run()
+
+	// the panic is still logged.
+	NewChain(Recover(logger)).Then(FuncJob(func() { panic("suppressed panics") })).Run()
+	logger.Infof("not suppressed")
+
+	out := buf.String()
+	for _, msg := range []string{"skip", "retry"} {
+		if strings.Contains(out, msg) {
+			t.Errorf("expected %q suppressed, got %q", msg, out)
+		}
+	}
+	for _, msg := range []string{"panic", "not suppressed"} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("expected %q logged, got %q", msg, out)
+		}
+	}
+
+	// the categories are merged if the logger is suppressed again.
+	buf = syncWriter{}
+	logger = SuppressLogs(logger, LogPanic)
+	NewChain(Recover(logger)).Then(FuncJob(func() { panic("suppressed panics") })).Run()
+	if out := buf.String(); out != "" {
+		t.Errorf("expected the panic suppressed, got %q", out)
+	}
+}
+
+func TestChainLoggedJob(t *testing.T) {
+	var global, own syncWriter
+	globalLogger, ownLogger := newBufLogger(&global), newBufLogger(&own)
+
+	chain := NewChain(Recover(globalLogger), SkipIfStillRunning(globalLogger))
+	job := chain.Then(NewLoggedJob(NewNamedJob("noisy", FuncJob(func() { panic("noisy panics") })), ownLogger))
+	job.Run()
+	if out := global.String(); out != "" {
+		t.Errorf("expected nothing logged to the global logger, got %q", out)
+	}
+	if out := own.String(); !strings.Contains(out, "noisy panics") || !strings.Contains(out, "job_name=noisy") {
+		t.Errorf("expected the panic of the job logged to its logger, got %q", out)
+	}
+	if name := JobName(job); name != "noisy" {
+		t.Errorf("expected the name kept, got %q", name)
+	}
+
+	// the other jobs log to the global logger.
+	chain.Then(FuncJob(func() { panic("other panics") })).Run()
+	if out := global.String(); !strings.Contains(out, "other panics") {
+		t.Errorf("expected the panic logged to the global logger, got %q", out)
+	}
+}
@@ -9,8 +9,8 @@ type ConstantDelaySchedule struct {
 }
 
 // Every returns a crontab Schedule that activates once every duration.
-// Delays of less than a second are not supported (will round up to 1 second).
-// Any fields less than a Second are truncated.
+// Delays of less than a second are not supported (will round up to 1 second),
+// use EverySubSecond for them. Any fields less than a Second are truncated.
 func Every(duration time.Duration) ConstantDelaySchedule {
 	if duration < time.Second {
 		duration = time.Second
@@ -25,3 +25,26 @@ func Every(duration time.Duration) ConstantDelaySchedule {
 func (schedule ConstantDelaySchedule) Next(t time.Time) time.Time {
 	return t.Add(schedule.Delay - time.Duration(t.Nanosecond())*time.Nanosecond)
 }
+
+// SubSecondDelaySchedule represents a recurring duty cycle of less than
+// a second, e.g. "@every 100ms". The activations are aligned to the
+// multiples of Delay since the zero time, so the schedulers started at
+// different times activate at the same times.
+type SubSecondDelaySchedule struct {
+	Delay time.Duration
+}
+
+// EverySubSecond returns a Schedule that activates once every duration,
+// which must be positive and less than a second, otherwise it is the same
+// as Every.
+func EverySubSecond(duration time.Duration) Schedule {
+	if duration <= 0 || duration >= time.Second {
+		return Every(duration)
+	}
+	return SubSecondDelaySchedule{Delay: duration}
+}
+
+// Next returns the next multiple of Delay after t.
+func (schedule SubSecondDelaySchedule) Next(t time.Time) time.Time {
+	return t.Truncate(schedule.Delay).Add(schedule.Delay)
+}
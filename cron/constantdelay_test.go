@@ -52,3 +52,31 @@ func TestConstantDelayNext(t *testing.T) {
 		}
 	}
 }
+
+func TestSubSecondDelayNext(t *testing.T) {
+	tests := []struct {
+		time     string
+		delay    time.Duration
+		expected string
+	}{
+		{"Mon Jul 9 14:45:00 2012", 100 * time.Millisecond, "Mon Jul 9 14:45:00.1 2012"},
+		{"Mon Jul 9 14:45:00.05 2012", 100 * time.Millisecond, "Mon Jul 9 14:45:00.1 2012"},
+		{"Mon Jul 9 14:45:00.1 2012", 100 * time.Millisecond, "Mon Jul 9 14:45:00.2 2012"},
+		{"Mon Jul 9 14:59:59.9 2012", 250 * time.Millisecond, "Mon Jul 9 15:00 2012"},
+	}
+	for _, c := range tests {
+		actual := EverySubSecond(c.delay).Next(getTime(c.time))
+		expected := getTime(c.expected)
+		if !actual.Equal(expected) {
+			t.Errorf("%s, \"%s\": (expected) %v != %v (actual)", c.time, c.delay, expected, actual)
+		}
+	}
+
+	// a second or more is the same as Every.
+	if s := EverySubSecond(time.Second + time.Millisecond); s != Every(time.Second) {
+		t.Errorf("expected Every(1s), got %v", s)
+	}
+	if s := EverySubSecond(0); s != Every(time.Second) {
+		t.Errorf("expected Every(1s), got %v", s)
+	}
+}
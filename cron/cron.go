@@ -2,7 +2,10 @@ package cron
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,14 +21,38 @@ type Cron struct {
 	stop      chan struct{}
 	add       chan *Entry
 	remove    chan EntryID
+	replace   chan replaceRequest
 	snapshot  chan chan []Entry
+	nextOnce  chan nextOnceRequest
 	running   bool
 	logger    dlog.Logger
 	runningMu sync.Mutex
 	location  *time.Location
 	parser    ScheduleParser
+	clock     Clock
 	nextID    EntryID
 	jobWaiter sync.WaitGroup
+
+	// recoverWrapper is set by WithPanicPolicy, it wraps the chain.
+	recoverWrapper func(logger dlog.Logger) JobWrapper
+}
+
+// ErrEntryNotFound is returned if the entry to be replaced is not found.
+var ErrEntryNotFound = errors.New("entry not found")
+
+// replaceRequest replaces the schedule and the job of the entry ID,
+// found is sent true if the entry is found.
+type replaceRequest struct {
+	entry *Entry
+	found chan bool
+}
+
+// nextOnceRequest overrides the next time of the entry ID once, found is
+// sent true if the entry is found.
+type nextOnceRequest struct {
+	id    EntryID
+	next  time.Time
+	found chan bool
 }
 
 // ScheduleParser is an interface for schedule spec parsers that return a Schedule
@@ -38,6 +65,101 @@ type Job interface {
 	Run()
 }
 
+// ContextJob is a Job which is able to observe cancellation.
+// Wrappers like TimeoutJob call RunWithContext instead of Run when
+// the wrapped job implements it.
+type ContextJob interface {
+	Job
+	RunWithContext(ctx context.Context)
+}
+
+// ContextErrorJob is a ContextJob which reports its failure by returning
+// an error. TimeoutJob calls RunWithContextError instead of RunWithContext
+// when the wrapped job implements it, and returns the error.
+type ContextErrorJob interface {
+	ContextJob
+	RunWithContextError(ctx context.Context) error
+}
+
+// ErrorJob is a Job which reports its failure by returning an error.
+// Wrappers like Recover and RetryIfFailed call RunWithError instead of Run
+// when the wrapped job implements it, and the wrappers of this package
+// return an ErrorJob, so the error is passed through the chain.
+type ErrorJob interface {
+	Job
+	RunWithError() error
+}
+
+// NamedJob is a Job which has a name, e.g. the job of dcron.
+// Chain keeps the name for the wrappers outside of other wrappers,
+// so they can get it by JobName.
+type NamedJob interface {
+	Job
+	JobName() string
+}
+
+// JobName returns the name of j if it is a NamedJob, otherwise "".
+func JobName(j Job) string {
+	if nj, ok := j.(NamedJob); ok {
+		return nj.JobName()
+	}
+	return ""
+}
+
+// NewNamedJob returns a NamedJob of name which runs j,
+// so the wrappers of it can log the name.
+func NewNamedJob(name string, j Job) Job {
+	return namedJob{Job: j, name: name}
+}
+
+// LoggedJob is a Job which has its own logger, e.g. a job of dcron added
+// by AddJobWithLogger. The wrappers of it, like Recover and
+// SkipIfStillRunning, log to this logger instead of the one they are
+// created with. Chain keeps the logger for the wrappers outside of other
+// wrappers, as it does for NamedJob.
+type LoggedJob interface {
+	Job
+	JobLogger() dlog.Logger
+}
+
+// NewLoggedJob returns a LoggedJob which runs j and logs to logger,
+// it keeps the name of j.
+func NewLoggedJob(j Job, logger dlog.Logger) Job {
+	return namedJob{Job: j, name: JobName(j), logger: logger}
+}
+
+// jobLogger returns the logger of j if it is a LoggedJob with a logger,
+// otherwise logger.
+func jobLogger(j Job, logger dlog.Logger) dlog.Logger {
+	if lj, ok := j.(LoggedJob); ok && lj.JobLogger() != nil {
+		return lj.JobLogger()
+	}
+	return logger
+}
+
+// namedJob keeps the name and the logger of the job wrapped by a
+// JobWrapper.
+type namedJob struct {
+	Job
+	name   string
+	logger dlog.Logger
+}
+
+func (j namedJob) JobName() string { return j.name }
+
+func (j namedJob) JobLogger() dlog.Logger { return j.logger }
+
+func (j namedJob) RunWithError() error { return runJob(j.Job) }
+
+// NotifiedJob is a Job which is notified when SkipIfStillRunning or
+// DelayIfStillRunning skips or delays it. The wrapper must be chained
+// directly outside of the job to see it.
+type NotifiedJob interface {
+	Job
+	Skipped()
+	Delayed(d time.Duration)
+}
+
 // Schedule describes a job's duty cycle.
 type Schedule interface {
 	// Next returns the next activation time, later than the given time.
@@ -71,6 +193,10 @@ type Entry struct {
 	// It is kept around so that user code that needs to get at the job later,
 	// e.g. via Entries() can do so.
 	Job Job
+
+	// chain is the chain of the entry added by AddJobWithChain,
+	// nil means the chain of the Cron.
+	chain *Chain
 }
 
 // Valid returns true if this is not the zero entry.
@@ -120,11 +246,14 @@ func New(opts ...Option) *Cron {
 		stop:      make(chan struct{}),
 		snapshot:  make(chan chan []Entry),
 		remove:    make(chan EntryID),
+		replace:   make(chan replaceRequest),
+		nextOnce:  make(chan nextOnceRequest),
 		running:   false,
 		runningMu: sync.Mutex{},
 		logger:    DefaultLogger,
 		location:  time.Local,
 		parser:    standardParser,
+		clock:     DefaultClock,
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -137,6 +266,44 @@ type FuncJob func()
 
 func (f FuncJob) Run() { f() }
 
+// FuncContextJob is a wrapper that turns a func(context.Context) into a cron.ContextJob.
+// Run calls the func with context.Background().
+type FuncContextJob func(ctx context.Context)
+
+func (f FuncContextJob) Run() { f(context.Background()) }
+
+func (f FuncContextJob) RunWithContext(ctx context.Context) { f(ctx) }
+
+// FuncContextErrorJob is a wrapper that turns a func(context.Context) error
+// into a cron.ContextErrorJob. Run calls the func with context.Background()
+// and drops the error.
+type FuncContextErrorJob func(ctx context.Context) error
+
+func (f FuncContextErrorJob) Run() { _ = f(context.Background()) }
+
+func (f FuncContextErrorJob) RunWithContext(ctx context.Context) { _ = f(ctx) }
+
+func (f FuncContextErrorJob) RunWithError() error { return f(context.Background()) }
+
+func (f FuncContextErrorJob) RunWithContextError(ctx context.Context) error { return f(ctx) }
+
+// FuncErrorJob is a wrapper that turns a func() error into a cron.ErrorJob.
+// Run calls the func and drops the error.
+type FuncErrorJob func() error
+
+func (f FuncErrorJob) Run() { _ = f() }
+
+func (f FuncErrorJob) RunWithError() error { return f() }
+
+// runJob runs the job, and returns the error if it is an ErrorJob.
+func runJob(j Job) error {
+	if ej, ok := j.(ErrorJob); ok {
+		return ej.RunWithError()
+	}
+	j.Run()
+	return nil
+}
+
 // AddFunc adds a func to the Cron to be run on the given schedule.
 // The spec is parsed using the time zone of this Cron instance as the default.
 // An opaque ID is returned that can be used to later remove it.
@@ -155,17 +322,156 @@ func (c *Cron) AddJob(spec string, cmd Job) (EntryID, error) {
 	return c.Schedule(schedule, cmd), nil
 }
 
+// AddJobWithLocation adds a Job to the Cron to be run on the given schedule,
+// the spec is interpreted in loc instead of the time zone of this Cron instance.
+// The spec must not have a TZ= or CRON_TZ= prefix.
+func (c *Cron) AddJobWithLocation(spec string, loc *time.Location, cmd Job) (EntryID, error) {
+	schedule, err := c.parseWithLocation(spec, loc)
+	if err != nil {
+		return 0, err
+	}
+	return c.Schedule(schedule, cmd), nil
+}
+
+// AddJobWithChain is the same as AddJobWithLocation, but the Job is
+// decorated by chain instead of the chain of this Cron instance, e.g. to
+// let a critical job panic without the Recover of the global chain. The
+// recover wrapper set by WithPanicPolicy is not applied either.
+func (c *Cron) AddJobWithChain(spec string, loc *time.Location, chain Chain, cmd Job) (EntryID, error) {
+	schedule, err := c.parseWithLocation(spec, loc)
+	if err != nil {
+		return 0, err
+	}
+	return c.schedule(schedule, cmd, &chain), nil
+}
+
+// ReplaceJobWithLocation replaces the schedule and the Job of the entry id
+// in one step, so there is no moment in which the entry is missing or
+// duplicated. The entry keeps its ID and Prev, its Next is computed by the
+// new schedule, and the chain of it if it is added by AddJobWithChain.
+// A run of the old Job which is in-flight is not affected.
+// loc is the same as AddJobWithLocation, nil means the time zone of this
+// Cron instance. ErrEntryNotFound is returned if there is no entry of id.
+func (c *Cron) ReplaceJobWithLocation(id EntryID, spec string, loc *time.Location, cmd Job) error {
+	schedule, err := c.parseWithLocation(spec, loc)
+	if err != nil {
+		return err
+	}
+	entry := &Entry{
+		ID:         id,
+		Schedule:   schedule,
+		WrappedJob: c.wrap(cmd),
+		Job:        cmd,
+	}
+	c.runningMu.Lock()
+	defer c.runningMu.Unlock()
+	var found bool
+	if c.running {
+		req := replaceRequest{entry: entry, found: make(chan bool, 1)}
+		c.replace <- req
+		found = <-req.found
+	} else {
+		found = c.replaceEntry(entry, false)
+	}
+	if !found {
+		return ErrEntryNotFound
+	}
+	return nil
+}
+
+// RescheduleOnce overrides the next time of the entry id with next once,
+// the runs after it follow the schedule again. A zero or past next runs the
+// entry as soon as possible. The next times are computed by the schedules
+// when the Cron starts, so it only takes effect while the Cron is running.
+// ErrEntryNotFound is returned if there is no entry of id.
+func (c *Cron) RescheduleOnce(id EntryID, next time.Time) error {
+	c.runningMu.Lock()
+	defer c.runningMu.Unlock()
+	var found bool
+	if c.running {
+		req := nextOnceRequest{id: id, next: next, found: make(chan bool, 1)}
+		c.nextOnce <- req
+		found = <-req.found
+	} else {
+		found = c.setNextOnce(id, next, c.now())
+	}
+	if !found {
+		return ErrEntryNotFound
+	}
+	return nil
+}
+
+// parseWithLocation parses spec to be interpreted in loc,
+// nil means the time zone of this Cron instance.
+func (c *Cron) parseWithLocation(spec string, loc *time.Location) (Schedule, error) {
+	if loc == nil {
+		return c.parser.Parse(spec)
+	}
+	if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		return nil, fmt.Errorf("spec has a time zone already: %v", spec)
+	}
+	schedule, err := c.parser.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	return inLocation(schedule, loc), nil
+}
+
+// Parse parses spec by the parser of this Cron, it returns the same
+// error as AddJob for an invalid spec.
+func (c *Cron) Parse(spec string) (Schedule, error) {
+	return c.parser.Parse(spec)
+}
+
+// ParseWithLocation parses spec to be interpreted in loc as
+// AddJobWithLocation does, it returns the same error for an invalid spec.
+func (c *Cron) ParseWithLocation(spec string, loc *time.Location) (Schedule, error) {
+	return c.parseWithLocation(spec, loc)
+}
+
+// Parser returns the parser of this Cron, set by WithParser or WithSeconds.
+func (c *Cron) Parser() ScheduleParser {
+	return c.parser
+}
+
 // Schedule adds a Job to the Cron to be run on the given schedule.
 // The job is wrapped with the configured Chain.
 func (c *Cron) Schedule(schedule Schedule, cmd Job) EntryID {
+	return c.schedule(schedule, cmd, nil)
+}
+
+// WrapJob decorates j as the job of the entry of id, by the chain of the
+// entry if it is added by AddJobWithChain, otherwise by the chain of this
+// Cron instance, e.g. to run j outside of the schedule of the entry. j is
+// decorated by a chain of its own, so the wrappers which keep a state,
+// like SkipIfStillRunning, do not see the runs of the entry. It returns
+// false if the entry is not found.
+func (c *Cron) WrapJob(id EntryID, j Job) (Job, bool) {
+	entry := c.Entry(id)
+	if !entry.Valid() {
+		return nil, false
+	}
+	if entry.chain != nil {
+		return entry.chain.Then(j), true
+	}
+	return c.wrap(j), true
+}
+
+// schedule adds the Job decorated by chain, nil means the chain of this
+// Cron instance.
+func (c *Cron) schedule(schedule Schedule, cmd Job, chain *Chain) EntryID {
 	c.runningMu.Lock()
 	defer c.runningMu.Unlock()
 	c.nextID++
 	entry := &Entry{
 		ID:         c.nextID,
 		Schedule:   schedule,
-		WrappedJob: c.chain.Then(cmd),
+		WrappedJob: c.wrap(cmd),
 		Job:        cmd,
+		chain:      chain,
+	}
+	if chain != nil {
+		entry.WrappedJob = chain.Then(cmd)
 	}
 	if !c.running {
 		c.entries = append(c.entries, entry)
@@ -252,18 +558,18 @@ func (c *Cron) run() {
 		// Determine the next entry to run.
 		sort.Sort(byTime(c.entries))
 
-		var timer *time.Timer
+		var timer Timer
 		if len(c.entries) == 0 || c.entries[0].Next.IsZero() {
 			// If there are no entries yet, just sleep - it still handles new entries
 			// and stop requests.
-			timer = time.NewTimer(100000 * time.Hour)
+			timer = c.clock.NewTimer(100000 * time.Hour)
 		} else {
-			timer = time.NewTimer(c.entries[0].Next.Sub(now))
+			timer = c.clock.NewTimer(c.entries[0].Next.Sub(now))
 		}
 
 		for {
 			select {
-			case now = <-timer.C:
+			case now = <-timer.C():
 				now = now.In(c.location)
 				c.logger.Infof("wake|now=%v", now)
 
@@ -299,6 +605,18 @@ func (c *Cron) run() {
 				now = c.now()
 				c.removeEntry(id)
 				c.logger.Infof("removed|entry=%v", id)
+
+			case req := <-c.replace:
+				timer.Stop()
+				now = c.now()
+				req.found <- c.replaceEntry(req.entry, true)
+				c.logger.Infof("replaced|now=%v, entry=%v, next=%v", now, req.entry.ID, req.entry.Next)
+
+			case req := <-c.nextOnce:
+				timer.Stop()
+				now = c.now()
+				req.found <- c.setNextOnce(req.id, req.next, now)
+				c.logger.Infof("rescheduled|now=%v, entry=%v, next=%v", now, req.id, req.next)
 			}
 
 			break
@@ -315,9 +633,18 @@ func (c *Cron) startJob(j Job) {
 	}()
 }
 
+// wrap decorates the job with the chain, and the recover wrapper
+// set by WithPanicPolicy outside of the chain.
+func (c *Cron) wrap(j Job) Job {
+	if c.recoverWrapper == nil {
+		return c.chain.Then(j)
+	}
+	return NewChain(c.recoverWrapper(c.logger)).Then(c.chain.Then(j))
+}
+
 // now returns current time in c location
 func (c *Cron) now() time.Time {
-	return time.Now().In(c.location)
+	return c.clock.Now().In(c.location)
 }
 
 // Stop stops the cron scheduler if it is running; otherwise it does nothing.
@@ -346,6 +673,44 @@ func (c *Cron) entrySnapshot() []Entry {
 	return entries
 }
 
+// replaceEntry replaces the schedule and the job of the entry of the same ID,
+// the next time is computed if the cron is running.
+func (c *Cron) replaceEntry(entry *Entry, running bool) bool {
+	for _, e := range c.entries {
+		if e.ID != entry.ID {
+			continue
+		}
+		e.Schedule = entry.Schedule
+		e.WrappedJob = entry.WrappedJob
+		if e.chain != nil {
+			e.WrappedJob = e.chain.Then(entry.Job)
+		}
+		e.Job = entry.Job
+		if running {
+			e.Next = e.Schedule.Next(c.now())
+		}
+		entry.Next = e.Next
+		return true
+	}
+	return false
+}
+
+// setNextOnce sets the next time of the entry of id, a next before now is
+// set to now, so the entry runs in the next wake.
+func (c *Cron) setNextOnce(id EntryID, next, now time.Time) bool {
+	for _, e := range c.entries {
+		if e.ID != id {
+			continue
+		}
+		if next.Before(now) {
+			next = now
+		}
+		e.Next = next.In(c.location)
+		return true
+	}
+	return false
+}
+
 func (c *Cron) removeEntry(id EntryID) {
 	var entries []*Entry
 	for _, e := range c.entries {
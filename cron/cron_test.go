@@ -195,6 +195,124 @@ func TestRemoveWhileRunning(t *testing.T) {
 	}
 }
 
+// Replace a job before and while running, expect only the new job runs.
+func TestReplaceJob(t *testing.T) {
+	for _, running := range []bool{false, true} {
+		var oldRuns, newRuns int32
+		cron := newWithSeconds()
+		if running {
+			cron.Start()
+		}
+		id, _ := cron.AddFunc("* * * * * ?", func() { atomic.AddInt32(&oldRuns, 1) })
+		if err := cron.ReplaceJobWithLocation(id, "* * * * * ?", nil, FuncJob(func() { atomic.AddInt32(&newRuns, 1) })); err != nil {
+			t.Fatal(err)
+		}
+		if err := cron.ReplaceJobWithLocation(id+1, "* * * * * ?", nil, FuncJob(func() {})); err != ErrEntryNotFound {
+			t.Errorf("expected ErrEntryNotFound, got %v", err)
+		}
+		if err := cron.ReplaceJobWithLocation(id, "bad spec", nil, FuncJob(func() {})); err == nil {
+			t.Error("expected an error of the bad spec")
+		}
+		if !running {
+			cron.Start()
+		}
+		<-time.After(OneSecond)
+		cron.Stop()
+		if len(cron.Entries()) != 1 || cron.Entries()[0].ID != id {
+			t.Errorf("expected the entry %v only, got %v", id, cron.Entries())
+		}
+		if atomic.LoadInt32(&oldRuns) != 0 || atomic.LoadInt32(&newRuns) == 0 {
+			t.Errorf("running=%v, expected the new job runs only, old=%d, new=%d", running, oldRuns, newRuns)
+		}
+	}
+}
+
+// Add a job with its own chain, expect the global chain is not applied, and
+// the chain is kept after the job is replaced.
+func TestAddJobWithChain(t *testing.T) {
+	cron := New(WithChain(Recover(DiscardLogger)), WithPanicPolicy(PanicRecover, nil))
+	panics := func(id EntryID) (panicked bool) {
+		defer func() { panicked = recover() != nil }()
+		cron.Entry(id).WrappedJob.Run()
+		return
+	}
+	var wrapped int32
+	chain := NewChain(func(j Job) Job {
+		return FuncJob(func() { atomic.AddInt32(&wrapped, 1); j.Run() })
+	})
+	id, err := cron.AddJobWithChain("* * * * *", nil, chain, FuncJob(func() { panic("YOLO") }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	global, _ := cron.AddFunc("* * * * *", func() { panic("YOLO") })
+	if !panics(id) || panics(global) {
+		t.Error("expected the job with its own chain panics only")
+	}
+	if err = cron.ReplaceJobWithLocation(id, "* * * * *", nil, FuncJob(func() { panic("YOLO") })); err != nil {
+		t.Fatal(err)
+	}
+	if !panics(id) {
+		t.Error("expected the replaced job keeps its own chain")
+	}
+	if atomic.LoadInt32(&wrapped) != 2 {
+		t.Errorf("expected the chain wraps 2 runs, got %d", wrapped)
+	}
+	if _, err = cron.AddJobWithChain("bad spec", nil, chain, FuncJob(func() {})); err == nil {
+		t.Error("expected an error of the bad spec")
+	}
+}
+
+func TestWrapJob(t *testing.T) {
+	cron := New(WithChain(Recover(DiscardLogger)))
+	var wrapped int32
+	chain := NewChain(func(j Job) Job {
+		return FuncJob(func() { atomic.AddInt32(&wrapped, 1); j.Run() })
+	})
+	id, _ := cron.AddJobWithChain("* * * * *", nil, chain, FuncJob(func() {}))
+	global, _ := cron.AddFunc("* * * * *", func() {})
+
+	j, ok := cron.WrapJob(id, FuncJob(func() {}))
+	if !ok {
+		t.Fatal("expected the entry found")
+	}
+	j.Run()
+	if atomic.LoadInt32(&wrapped) != 1 {
+		t.Error("expected the job wrapped by the chain of the entry")
+	}
+	j, ok = cron.WrapJob(global, FuncJob(func() { panic("YOLO") }))
+	if !ok {
+		t.Fatal("expected the entry found")
+	}
+	j.Run()
+	if _, ok = cron.WrapJob(EntryID(100), FuncJob(func() {})); ok {
+		t.Error("expected the entry not found")
+	}
+}
+
+// Reschedule an entry once, expect it runs at the override and then
+// follows the schedule again.
+func TestRescheduleOnce(t *testing.T) {
+	var runs int32
+	cron := newWithSeconds()
+	id, _ := cron.AddFunc("0 0 0 1 1 ?", func() { atomic.AddInt32(&runs, 1) })
+	cron.Start()
+	defer cron.Stop()
+
+	if err := cron.RescheduleOnce(id, time.Now().Add(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cron.RescheduleOnce(id+1, time.Now()); err != ErrEntryNotFound {
+		t.Errorf("expected ErrEntryNotFound, got %v", err)
+	}
+	<-time.After(OneSecond)
+	if atomic.LoadInt32(&runs) != 1 {
+		t.Errorf("expected 1 run, got %d", runs)
+	}
+	if next := cron.Entry(id).Next; next.Sub(time.Now()) < time.Hour {
+		t.Errorf("expected the next run follows the schedule, got %v", next)
+	}
+}
+
 // Test timing with Entries.
 func TestSnapshotEntries(t *testing.T) {
 	wg := &sync.WaitGroup{}
@@ -349,6 +467,46 @@ func TestNonLocalTimezone(t *testing.T) {
 	}
 }
 
+func TestAddJobWithLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load time zone America/New_York: %+v", err)
+	}
+
+	tests := []struct {
+		spec     string
+		from     string
+		expected string
+	}{
+		// 9am before and after the spring-forward on 2024-03-10.
+		{"0 9 * * *", "2024-03-09T10:00:00Z", "2024-03-09T14:00:00Z"},
+		{"0 9 * * *", "2024-03-09T14:00:00Z", "2024-03-10T13:00:00Z"},
+		// 2:30am does not exist on 2024-03-10.
+		{"30 2 * * *", "2024-03-10T05:00:00Z", "2024-03-11T06:30:00Z"},
+		{"@daily", "2024-03-09T06:00:00Z", "2024-03-10T05:00:00Z"},
+		{"@every 1h", "2024-03-10T06:30:00Z", "2024-03-10T07:30:00Z"},
+	}
+
+	for _, c := range tests {
+		cron := New(WithLocation(time.UTC))
+		id, err := cron.AddJobWithLocation(c.spec, loc, FuncJob(func() {}))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.spec, err)
+		}
+		from, _ := time.Parse(time.RFC3339, c.from)
+		expected, _ := time.Parse(time.RFC3339, c.expected)
+		actual := cron.Entry(id).Schedule.Next(from)
+		if !actual.Equal(expected) {
+			t.Errorf("%s, from %s: expected %v, got %v", c.spec, c.from, expected, actual)
+		}
+	}
+
+	cron := New()
+	if _, err := cron.AddJobWithLocation("CRON_TZ=UTC 0 9 * * *", loc, FuncJob(func() {})); err == nil {
+		t.Error("expected an error for a spec with a time zone")
+	}
+}
+
 // Test that calling stop before start silently returns without
 // blocking the stop channel.
 func TestStopWithoutStart(t *testing.T) {
@@ -130,6 +130,10 @@ where "duration" is a string accepted by time.ParseDuration
 For example, "@every 1h30m10s" would indicate a schedule that activates after
 1 hour, 30 minutes, 10 seconds, and then every interval after that.
 
+An interval of less than a second, e.g. "@every 100ms", activates at the
+multiples of the interval, see SubSecondDelaySchedule. The intervals of a
+second or more are truncated to seconds.
+
 Note: The interval does not take the job runtime into account.  For example,
 if a job takes 3 minutes to run, and it is scheduled to run every 5 minutes,
 it will have only 2 minutes of idle time between each run.
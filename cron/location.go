@@ -0,0 +1,29 @@
+package cron
+
+import "time"
+
+// locationSchedule interprets the wrapped schedule in a fixed time zone.
+type locationSchedule struct {
+	schedule Schedule
+	location *time.Location
+}
+
+// inLocation returns a Schedule which interprets schedule in loc.
+func inLocation(schedule Schedule, loc *time.Location) Schedule {
+	if spec, ok := schedule.(*SpecSchedule); ok {
+		s := *spec
+		s.Location = loc
+		return &s
+	}
+	return locationSchedule{schedule: schedule, location: loc}
+}
+
+// Next returns the next activation time of the wrapped schedule in the
+// time zone, converted back to the time zone of t.
+func (s locationSchedule) Next(t time.Time) time.Time {
+	next := s.schedule.Next(t.In(s.location))
+	if next.IsZero() {
+		return next
+	}
+	return next.In(t.Location())
+}
@@ -13,3 +13,55 @@ var DefaultLogger dlog.Logger = dlog.DefaultPrintfLogger(log.New(os.Stdout, "cro
 
 // DiscardLogger can be used by callers to discard all log messages.
 var DiscardLogger dlog.Logger = dlog.DefaultPrintfLogger(log.New(io.Discard, "", 0))
+
+// LogCategory is a set of the categories of the messages logged by the
+// wrappers in this package, see SuppressLogs.
+type LogCategory uint
+
+const (
+	// LogDelay is the delays logged at Info level by DelayIfStillRunning.
+	LogDelay LogCategory = 1 << iota
+	// LogSkip is the skips logged at Info level by SkipIfStillRunning, the
+	// errors logged by SkipIfStillRunningWarnAfter are not in it.
+	LogSkip
+	// LogPanic is the panics and the errors of the jobs logged by Recover.
+	LogPanic
+	// LogRetry is the retries logged at Info level by RetryIfFailed, the
+	// exhausted retries are not in it.
+	LogRetry
+)
+
+// SuppressLogs returns a Logger which passes all the messages to logger,
+// but the wrappers in this package do not log the messages of categories
+// with it, e.g. SuppressLogs(logger, LogDelay|LogSkip) keeps the panics
+// while muting the expected delays and skips of the long running jobs.
+// It must be the outermost wrapper of the logger passed to the wrappers.
+func SuppressLogs(logger dlog.Logger, categories LogCategory) dlog.Logger {
+	if sl, ok := logger.(*suppressedLogger); ok {
+		return &suppressedLogger{Logger: sl.Logger, categories: sl.categories | categories}
+	}
+	return &suppressedLogger{Logger: logger, categories: categories}
+}
+
+type suppressedLogger struct {
+	dlog.Logger
+	categories LogCategory
+}
+
+func (l *suppressedLogger) Infow(msg string, keysAndValues ...any) {
+	dlog.Infow(l.Logger, msg, keysAndValues...)
+}
+
+func (l *suppressedLogger) Warnw(msg string, keysAndValues ...any) {
+	dlog.Warnw(l.Logger, msg, keysAndValues...)
+}
+
+func (l *suppressedLogger) Errorw(msg string, keysAndValues ...any) {
+	dlog.Errorw(l.Logger, msg, keysAndValues...)
+}
+
+// logs returns false if the messages of category are suppressed in logger.
+func logs(logger dlog.Logger, category LogCategory) bool {
+	sl, ok := logger.(*suppressedLogger)
+	return !ok || sl.categories&category == 0
+}
@@ -39,6 +39,25 @@ func WithChain(wrappers ...JobWrapper) Option {
 	}
 }
 
+// WithPanicPolicy recovers the panics of all jobs by RecoverWithPolicy with
+// the logger of the cron, outside of the wrappers set by WithChain, so a
+// Recover in the chain must not be used with it.
+func WithPanicPolicy(policy PanicPolicy, handler PanicHandler) Option {
+	return func(c *Cron) {
+		c.recoverWrapper = func(logger dlog.Logger) JobWrapper {
+			return RecoverWithPolicy(logger, policy, handler)
+		}
+	}
+}
+
+// WithClock overrides the source of time of the scheduler,
+// e.g. to drive it by a fake clock in the tests.
+func WithClock(clock Clock) Option {
+	return func(c *Cron) {
+		c.clock = clock
+	}
+}
+
 // WithLogger uses the provided logger.
 func WithLogger(logger dlog.Logger) Option {
 	return func(c *Cron) {
@@ -35,6 +35,45 @@ var places = []ParseOption{
 	Dow,
 }
 
+var fieldNames = []string{
+	"second",
+	"minute",
+	"hour",
+	"dom",
+	"month",
+	"dow",
+}
+
+// FieldError is returned by Parser.Parse if a field of the spec is invalid,
+// Field is the name of the field, one of "second", "minute", "hour", "dom",
+// "month" and "dow", and Value is the field in the spec.
+type FieldError struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("invalid %s field '%s': %v", e.Field, e.Value, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// descriptors are the descriptors accepted by a Parser with Descriptor,
+// the duration of "@every" is parsed by time.ParseDuration.
+var descriptors = []string{
+	"@yearly",
+	"@annually",
+	"@monthly",
+	"@weekly",
+	"@daily",
+	"@midnight",
+	"@hourly",
+	"@every <duration>",
+}
+
 var defaults = []string{
 	"0",
 	"0",
@@ -83,7 +122,8 @@ func NewParser(options ParseOption) Parser {
 }
 
 // Parse returns a new crontab schedule representing the given spec.
-// It returns a descriptive error if the spec is not valid.
+// It returns a descriptive error if the spec is not valid, which is a
+// *FieldError if a field of the spec is invalid.
 // It accepts crontab specs and features configured by NewParser.
 func (p Parser) Parse(spec string) (Schedule, error) {
 	if len(spec) == 0 {
@@ -120,22 +160,24 @@ func (p Parser) Parse(spec string) (Schedule, error) {
 		return nil, err
 	}
 
-	field := func(field string, r bounds) uint64 {
+	field := func(i int, r bounds) uint64 {
 		if err != nil {
 			return 0
 		}
 		var bits uint64
-		bits, err = getField(field, r)
+		if bits, err = getField(fields[i], r); err != nil {
+			err = &FieldError{Field: fieldNames[i], Value: fields[i], Err: err}
+		}
 		return bits
 	}
 
 	var (
-		second     = field(fields[0], seconds)
-		minute     = field(fields[1], minutes)
-		hour       = field(fields[2], hours)
-		dayofmonth = field(fields[3], dom)
-		month      = field(fields[4], months)
-		dayofweek  = field(fields[5], dow)
+		second     = field(0, seconds)
+		minute     = field(1, minutes)
+		hour       = field(2, hours)
+		dayofmonth = field(3, dom)
+		month      = field(4, months)
+		dayofweek  = field(5, dow)
 	)
 	if err != nil {
 		return nil, err
@@ -152,6 +194,34 @@ func (p Parser) Parse(spec string) (Schedule, error) {
 	}, nil
 }
 
+// Options returns the options the Parser is created with.
+func (p Parser) Options() ParseOption {
+	return p.options
+}
+
+// Fields returns the names of the fields of a spec in order, including the
+// optional one, see FieldError for the names.
+func (p Parser) Fields() []string {
+	fields := make([]string, 0, len(places))
+	for i, place := range places {
+		if p.options&place > 0 ||
+			(place == Second && p.options&SecondOptional > 0) ||
+			(place == Dow && p.options&DowOptional > 0) {
+			fields = append(fields, fieldNames[i])
+		}
+	}
+	return fields
+}
+
+// Descriptors returns the descriptors the Parser accepts, e.g. "@hourly",
+// or nil if it is created without Descriptor.
+func (p Parser) Descriptors() []string {
+	if p.options&Descriptor == 0 {
+		return nil
+	}
+	return append([]string(nil), descriptors...)
+}
+
 // normalizeFields takes a subset set of the time fields and returns the full set
 // with defaults (zeroes) populated for unset fields.
 //
@@ -427,7 +497,7 @@ func parseDescriptor(descriptor string, loc *time.Location) (Schedule, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse duration %s: %s", descriptor, err)
 		}
-		return Every(duration), nil
+		return EverySubSecond(duration), nil
 	}
 
 	return nil, fmt.Errorf("unrecognized descriptor: %s", descriptor)
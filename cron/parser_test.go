@@ -1,6 +1,7 @@
 package cron
 
 import (
+	"errors"
 	"reflect"
 	"strings"
 	"testing"
@@ -150,6 +151,7 @@ func TestParseSchedule(t *testing.T) {
 		{standardParser, "CRON_TZ=UTC  5 * * * *", every5min(time.UTC)},
 		{secondParser, "CRON_TZ=Asia/Tokyo 0 5 * * * *", every5min(tokyo)},
 		{secondParser, "@every 5m", ConstantDelaySchedule{5 * time.Minute}},
+		{secondParser, "@every 100ms", SubSecondDelaySchedule{100 * time.Millisecond}},
 		{secondParser, "@midnight", midnight(time.Local)},
 		{secondParser, "TZ=UTC  @midnight", midnight(time.UTC)},
 		{secondParser, "TZ=Asia/Tokyo @midnight", midnight(tokyo)},
@@ -381,3 +383,35 @@ func annual(loc *time.Location) *SpecSchedule {
 		Location: loc,
 	}
 }
+
+func TestParseFieldError(t *testing.T) {
+	_, err := secondParser.Parse("0 61 * * * *")
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("expected a FieldError, got %v", err)
+	}
+	if fieldErr.Field != "minute" || fieldErr.Value != "61" {
+		t.Errorf("expected the minute field '61', got the %s field '%s'", fieldErr.Field, fieldErr.Value)
+	}
+
+	// the errors of the whole spec are not field errors.
+	if _, err = secondParser.Parse("* * *"); errors.As(err, &fieldErr) {
+		t.Errorf("expected not a FieldError, got %v", err)
+	}
+}
+
+func TestParserFields(t *testing.T) {
+	fields := []string{"second", "minute", "hour", "dom", "month", "dow"}
+	if got := secondParser.Fields(); !reflect.DeepEqual(got, fields) {
+		t.Errorf("expected %v, got %v", fields, got)
+	}
+	if got := NewParser(Dom | Month | DowOptional).Fields(); !reflect.DeepEqual(got, fields[3:]) {
+		t.Errorf("expected %v, got %v", fields[3:], got)
+	}
+	if got := secondParser.Descriptors(); len(got) == 0 || got[0] != "@yearly" {
+		t.Errorf("expected the descriptors, got %v", got)
+	}
+	if got := NewParser(Minute | Hour).Descriptors(); got != nil {
+		t.Errorf("expected no descriptors, got %v", got)
+	}
+}
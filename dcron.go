@@ -3,12 +3,16 @@ package dcron
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
+	"reflect"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/libi/dcron/consistenthash"
 	"github.com/libi/dcron/cron"
 	"github.com/libi/dcron/dlog"
 	"github.com/libi/dcron/driver"
@@ -28,7 +32,28 @@ const (
 var (
 	ErrJobExist     = errors.New("jobName already exist")
 	ErrJobNotExist  = errors.New("jobName not exist")
+	ErrEmptyJobName = errors.New("jobName is empty")
 	ErrJobWrongNode = errors.New("job is not running in this node")
+	ErrNilLocation  = errors.New("location is nil")
+	// ErrInvalidCronSpec is wrapped by the error returned when adding a job
+	// with a spec the parser does not accept, e.g. a 6 fields spec without
+	// WithSeconds. The error names the job and the spec, and wraps the
+	// *cron.FieldError of the invalid field if there is one.
+	ErrInvalidCronSpec = errors.New("invalid cron spec")
+
+	ErrJobsFrozen      = errors.New("jobs are frozen after dcron started")
+	ErrRunningLocally  = errors.New("dcron is running locally")
+	ErrDcronNotRunning = errors.New("dcron is not running")
+	// ErrUnsafeNodeUpdateDuration is wrapped by Err if the node update
+	// duration can not keep the membership of the nodes, see
+	// ValidateNodeUpdateDuration.
+	ErrUnsafeNodeUpdateDuration = errors.New("unsafe node update duration")
+	// ErrNilParser is returned by Err if WithParser is given a nil parser.
+	ErrNilParser = errors.New("cron parser is nil")
+
+	// ErrJobNotReplaceable is returned by ReplaceJob if the job is not
+	// added as a plain func, e.g. by AddJobWithContext or AddJobWithMaxRuns.
+	ErrJobNotReplaceable = errors.New("job is not a plain func, it can not be replaced")
 )
 
 type RecoverFuncType func(d *Dcron)
@@ -38,63 +63,318 @@ type Dcron struct {
 	jobs      map[string]*JobWarpper
 	jobsRWMut sync.RWMutex
 
+	// jobs run once in the cluster, see AddOnceJob.
+	onceJobs     map[string]func()
+	onceJobEpoch string
+
+	// paused jobs in this node, used when the driver is not a KVDriver.
+	pausedJobs sync.Map
+
+	// the nodeIDs which the jobs are pinned to, see AddPinnedJob.
+	pinnedJobs sync.Map
+	// the jobs which run once on start, see AddJobRunOnStart.
+	runOnStartJobs sync.Map
+	// the jobs which run in every node, see AddBroadcastJob.
+	broadcastJobs sync.Map
+	// the groups of the jobs, see AddJobToGroup, and the paused groups
+	// in this node, used when the driver is not a KVDriver.
+	jobGroups    sync.Map
+	pausedGroups sync.Map
+	// the selectors of the jobs, see AddJobWithSelector, and the labels
+	// of this node and the other nodes, see WithNodeLabels.
+	selectorJobs   sync.Map
+	nodeLabels     map[string]string
+	peerLabels     map[string]map[string]string
+	peerLabelsMut  sync.RWMutex
+	labelsWatching int32
+	// the max runs of the jobs, see AddJobWithMaxRuns, and the run counts
+	// of them used when the driver is not a KVDriver.
+	maxRunsJobs     sync.Map
+	maxRunsCounts   sync.Map
+	maxRunsWatching int32
+
+	// the latest results of the jobs in this node, see JobStatus
+	// and JobHistory.
+	jobStatus    map[string]*JobStatus
+	jobHistory   map[string]*executionRing
+	historySize  int
+	jobStatusMut sync.RWMutex
+
 	ServerName string
+	driver     driver.DriverV2
 	nodePool   INodePool
 	running    int32
 
-	logger dlog.Logger
+	logger         dlog.Logger
+	logLevel       dlog.Level
+	suppressedLogs cron.LogCategory
+
+	// optionErr is the error of the options, dcron refuses to start if
+	// it is not nil.
+	optionErr error
 
 	nodeUpdateDuration time.Duration
 	hashReplicas       int
+	hashFn             consistenthash.Hash
+	nodeWeight         int
+	jobJitter          time.Duration
+	executionLockTTL   time.Duration
+	keyPrefix          string
+	nodeName           string
+	freezeJobsOnStart  bool
+	verboseOwnership   bool
+	driverRetry        int
+	isolationPolicy    IsolationPolicy
+	driverRetryDelay   time.Duration
+	pinFallback        PinFallback
+	// the heartbeat TTL set by WithHeartbeatTTL, see heartbeatTTL.
+	heartbeatTTLOverride time.Duration
+	dedupKeyFunc         DedupKeyFunc
+	scanBatchSize        int
+	driverTimeout        time.Duration
+	rejectShortIntervals bool
+
+	// see WithMaintenanceWindow, maintenanceMissed is the jobs
+	// skipped in a maintenance window which will catch up.
+	maintenanceWindows []maintenanceWindow
+	maintenanceCatchUp bool
+	maintenanceMissed  sync.Map
+	// the max missed runs of a job to catch up, see WithCatchUp.
+	catchUpMax int
+
+	nodeChangeCallback    NodeChangeCallback
+	jobRebalancedCallback atomic.Value
+	poolUpdateObserver    PoolUpdateObserver
+	poolUpdateDebounce    time.Duration
+	jobSetCheck           bool
+	jobSetDivergedHandler JobSetDivergedHandler
+	clockSkewThreshold    time.Duration
+	clockSkewCallback     ClockSkewCallback
+	onRegister            func(nodeID string)
+	onDeregister          func(nodeID string)
+	lateRunThreshold      time.Duration
+	lateRunObserver       LateRunObserver
+	expiredJobHandler     func(jobName string)
+	removeExpiredJobs     bool
+	gracefulHandover      bool
+	// the handover locks held by the runs in this node, see
+	// WithGracefulHandover.
+	handoverHolds map[string]*handoverHold
+	handoverMut   sync.Mutex
+	// see WithNodeGeneration, generation is claimed by this incarnation,
+	// staleGeneration is 1 once a newer incarnation is advertised.
+	nodeGeneration  bool
+	generation      int64
+	staleGeneration int32
+	// cordoned is 1 if this node is cordoned, see Cordon.
+	cordoned int32
+	// see WithOwnershipConflictDetection.
+	ownershipConflictInterval time.Duration
+	ownershipConflictCallback OwnershipConflictCallback
+	// the goroutines advertising this node in the driver, like
+	// checkJobSets, Stop waits for them to delete what they advertised.
+	advertisers sync.WaitGroup
 
 	cr        *cron.Cron
 	crOptions []cron.Option
+	clock     cron.Clock
 
 	RecoverFunc RecoverFuncType
 
+	metrics MetricsCollector
+
 	recentJobs IRecentJobPacker
 	state      atomic.Value
 
 	runningLocally bool
+
+	// lifecycleCtx is set by NewDcronWithContext,
+	// dcron is stopped when it is done.
+	lifecycleCtx context.Context
+
+	// this context is used to define
+	// the lifetime of the running dcron.
+	runtimeCtx    context.Context
+	runtimeCancel context.CancelFunc
+	runtimeMut    sync.Mutex
+	stopMut       sync.Mutex
+
+	// jobs running in this node, jobName -> running count.
+	runningJobs    map[string]int
+	runningJobsMut sync.Mutex
+	// jobWaiter counts the runs from the start of their pipeline, no run
+	// starts once stopping is set by Stop, see runStarted.
+	jobWaiter sync.WaitGroup
+	stopping  bool
+
+	// the background goroutines and the pending timers of dcron,
+	// see DebugStats.
+	goroutines int32
+	timers     int32
+
+	// the channel of Events, it is created by the first call of Events.
+	events        atomic.Value
+	eventsOnce    sync.Once
+	droppedEvents atomic.Uint64
 }
 
 // NewDcron create a Dcron
 func NewDcron(serverName string, driver driver.DriverV2, cronOpts ...cron.Option) *Dcron {
 	dcron := newDcron(serverName)
+	dcron.driver = driver
 	dcron.crOptions = cronOpts
 	dcron.cr = cron.New(cronOpts...)
 	dcron.running = dcronStopped
-	dcron.nodePool = NewNodePool(serverName, driver, dcron.nodeUpdateDuration, dcron.hashReplicas, dcron.logger)
+	if dcron.optionErr = validateServiceName(serverName); dcron.optionErr != nil {
+		dcron.logger.Errorf("invalid dcron options, err=%v", dcron.optionErr)
+	}
+	dcron.nodePool = NewNodePool(serverName, driver, dcron.nodeUpdateDuration, dcron.hashReplicas, dcron.logger,
+		dcron.nodePoolOptions()...)
 	return dcron
 }
 
 // NewDcronWithOption create a Dcron with Dcron Option
 func NewDcronWithOption(serverName string, driver driver.DriverV2, dcronOpts ...Option) *Dcron {
 	dcron := newDcron(serverName)
+	dcron.driver = driver
 	for _, opt := range dcronOpts {
 		opt(dcron)
 	}
-
+	if dcron.logLevel > dlog.LevelInfo {
+		dcron.logger = dlog.WithLevel(dcron.logger, dcron.logLevel)
+		dcron.crOptions = append(dcron.crOptions, cron.WithLogger(dcron.logger))
+	}
+	if dcron.suppressedLogs != 0 {
+		dcron.logger = cron.SuppressLogs(dcron.logger, dcron.suppressedLogs)
+		dcron.crOptions = append(dcron.crOptions, cron.WithLogger(dcron.logger))
+	}
+	dcron.nodeUpdateDuration = clampNodeUpdateDuration(dcron.nodeUpdateDuration, dcron.logger)
+	if dcron.optionErr == nil && !dcron.runningLocally {
+		dcron.optionErr = validateServiceName(serverName)
+	}
+	if dcron.optionErr == nil {
+		dcron.optionErr = dcron.validateHeartbeatTTL()
+	}
+	if dcron.optionErr == nil {
+		dcron.optionErr = ValidateNodeUpdateDuration(dcron.nodeUpdateDuration, dcron.heartbeatTTL())
+	}
 	dcron.cr = cron.New(dcron.crOptions...)
+	if dcron.optionErr == nil {
+		dcron.optionErr = dcron.parseMaintenanceWindows()
+	}
+	if dcron.optionErr != nil {
+		dcron.logger.Errorf("invalid dcron options, err=%v", dcron.optionErr)
+	}
+
 	if !dcron.runningLocally {
-		dcron.nodePool = NewNodePool(serverName, driver, dcron.nodeUpdateDuration, dcron.hashReplicas, dcron.logger)
+		dcron.nodePool = NewNodePool(serverName, driver, dcron.nodeUpdateDuration, dcron.hashReplicas, dcron.logger,
+			dcron.nodePoolOptions()...)
 	}
 	return dcron
 }
 
+// NewDcronWithContext create a Dcron with Dcron Option, whose lifecycle is
+// tied to ctx: once ctx is done, the running dcron is stopped as Stop does,
+// and the node is deregistered from the driver, so it leaves the cluster
+// promptly. The contexts of the running jobs are canceled with it, use
+// StopWait to drain them. A dcron whose ctx is done can not be started.
+func NewDcronWithContext(ctx context.Context, serverName string, driver driver.DriverV2, dcronOpts ...Option) *Dcron {
+	dcron := NewDcronWithOption(serverName, driver, dcronOpts...)
+	dcron.lifecycleCtx = ctx
+	return dcron
+}
+
 func newDcron(serverName string) *Dcron {
 	return &Dcron{
-		ServerName: serverName,
-		logger: &dlog.StdLogger{
-			Log: log.New(os.Stdout, "[dcron] ", log.LstdFlags),
-		},
+		ServerName:         serverName,
+		logger:             dlog.DefaultPrintfLogger(log.New(os.Stdout, "[dcron] ", log.LstdFlags)),
 		jobs:               make(map[string]*JobWarpper),
+		onceJobs:           make(map[string]func()),
+		jobStatus:          make(map[string]*JobStatus),
+		jobHistory:         make(map[string]*executionRing),
+		runningJobs:        make(map[string]int),
 		crOptions:          make([]cron.Option, 0),
+		clock:              cron.DefaultClock,
 		nodeUpdateDuration: defaultDuration,
 		hashReplicas:       defaultReplicas,
 	}
 }
 
+func (d *Dcron) nodePoolOptions() []NodePoolOption {
+	opts := []NodePoolOption{
+		NodePoolJobRebalancedCallback(d.rebalancedJobNames, d.onJobRebalanced),
+	}
+	if d.hashFn != nil {
+		opts = append(opts, NodePoolHashFn(d.hashFn))
+	}
+	opts = append(opts, NodePoolNodeChangeCallback(d.onNodeChanged))
+	if _, ok := d.driver.(driver.KVDriver); ok {
+		opts = append(opts, NodePoolNodeFilter(d.uncordonedNodes))
+	}
+	if d.poolUpdateObserver != nil {
+		opts = append(opts, NodePoolUpdateObserver(d.poolUpdateObserver))
+	}
+	if d.poolUpdateDebounce > 0 {
+		opts = append(opts, NodePoolUpdateDebounce(d.poolUpdateDebounce))
+	}
+	if d.driverRetry > 1 {
+		opts = append(opts, NodePoolDriverRetry(d.driverRetry, d.driverRetryDelay))
+	}
+	opts = append(opts, NodePoolDriverTimeout(d.driverOpTimeout()))
+	if d.nodeWeight > 0 {
+		opts = append(opts, NodePoolDriverOptions(driver.NewWeightOption(d.nodeWeight)))
+	}
+	if d.keyPrefix != "" {
+		opts = append(opts, NodePoolDriverOptions(driver.NewKeyPrefixOption(d.keyPrefix)))
+	}
+	if d.nodeName != "" {
+		opts = append(opts, NodePoolDriverOptions(driver.NewNodeNameOption(d.nodeName)))
+		if d.nodeGeneration {
+			// a new incarnation takes over the nodeID, the generation
+			// retires the old one.
+			opts = append(opts, NodePoolDriverOptions(driver.NewTakeOverOption()))
+		}
+	}
+	if d.scanBatchSize > 0 {
+		opts = append(opts, NodePoolDriverOptions(driver.NewScanBatchSizeOption(d.scanBatchSize)))
+	}
+	if d.heartbeatTTLOverride != 0 {
+		// it overrides the TimeoutOption of the node update duration.
+		opts = append(opts, NodePoolDriverOptions(driver.NewTimeoutOption(d.heartbeatTTLOverride)))
+	}
+	return opts
+}
+
+// OnJobRebalanced register the callback which is called when the owner
+// of a job changed because nodes joined or left the cluster.
+// The callback runs in the NodePool update loop, so it must not block.
+func (d *Dcron) OnJobRebalanced(fn JobRebalancedCallback) {
+	d.jobRebalancedCallback.Store(fn)
+}
+
+// rebalancedJobNames returns the jobs to check the owners of, if there is
+// a callback set by OnJobRebalanced or a consumer of Events.
+func (d *Dcron) rebalancedJobNames() []string {
+	fn, _ := d.jobRebalancedCallback.Load().(JobRebalancedCallback)
+	if events, _ := d.events.Load().(chan Event); fn == nil && events == nil {
+		return nil
+	}
+	d.jobsRWMut.RLock()
+	defer d.jobsRWMut.RUnlock()
+	jobNames := make([]string, 0, len(d.jobs))
+	for jobName := range d.jobs {
+		jobNames = append(jobNames, jobName)
+	}
+	return jobNames
+}
+
+func (d *Dcron) onJobRebalanced(jobName, oldOwner, newOwner string) {
+	d.emit(Event{Type: EventRebalanced, JobName: jobName, OldOwner: oldOwner, NewOwner: newOwner})
+	if fn, _ := d.jobRebalancedCallback.Load().(JobRebalancedCallback); fn != nil {
+		fn(jobName, oldOwner, newOwner)
+	}
+}
+
 // SetLogger set dcron logger
 func (d *Dcron) SetLogger(logger dlog.Logger) {
 	d.logger = logger
@@ -105,47 +385,321 @@ func (d *Dcron) GetLogger() dlog.Logger {
 	return d.logger
 }
 
-// AddJob  add a job
-func (d *Dcron) AddJob(jobName, cronStr string, job Job) (err error) {
-	return d.addJob(jobName, cronStr, job)
+// AddJob  add a job, it returns the EntryID of the job in the cron,
+// which can be used to get the Entry by Entry.
+// The job names are unique, if jobName is added already, ErrJobExist is
+// returned and nothing is added, use ReplaceJob to update the job. An empty
+// jobName returns ErrEmptyJobName, and a nil job or func returns
+// ErrNilJobFunc, so the mistake fails here instead of when the job fires.
+//
+// The jobs can be added before or after Start, the owner of a job is
+// computed from its name when it fires, so a job added after Start is
+// distributed in the same way. Use WithFreezeJobsOnStart to reject the
+// jobs added after Start.
+func (d *Dcron) AddJob(jobName, cronStr string, job Job) (cron.EntryID, error) {
+	return d.addJob(jobName, cronStr, nil, job)
 }
 
-// AddFunc add a cron func
+// AddFunc add a cron func, use AddJob with cron.FuncJob(cmd)
+// to get the EntryID of the job.
 func (d *Dcron) AddFunc(jobName, cronStr string, cmd func()) (err error) {
-	return d.addJob(jobName, cronStr, cron.FuncJob(cmd))
+	_, err = d.addJob(jobName, cronStr, nil, cron.FuncJob(cmd))
+	return
+}
+
+// AddFuncWithError add a cron func which returns an error.
+// The error is passed to the cron wrappers like cron.Recover and
+// cron.RetryIfFailed, and counted by the MetricsCollector.
+func (d *Dcron) AddFuncWithError(jobName, cronStr string, cmd func() error) (err error) {
+	_, err = d.addJob(jobName, cronStr, nil, cron.FuncErrorJob(cmd))
+	return
+}
+
+// AddJobWithContext add a cron func which receives a context.
+// The context is canceled when dcron is stopped, or when this node
+// loses the ownership of this job in the middle of the run.
+// Use JobNameFromContext and ScheduledTimeFromContext to get
+// the job name and the scheduled time from the context.
+func (d *Dcron) AddJobWithContext(jobName, cronStr string, cmd func(ctx context.Context)) (err error) {
+	_, err = d.addJob(jobName, cronStr, nil, cron.FuncContextJob(cmd))
+	return
+}
+
+// AddJobWithTime add a cron func which receives the time the scheduler
+// fired it, e.g. 14:00 for "0 14 * * *" while it runs at 14:03 because of
+// the jitter, to process the data of the 14:00 slot. It is the
+// ScheduledTimeFromContext of AddJobWithContext, so a run delayed by
+// cron.DelayIfStillRunning past the next fire time receives the later one,
+// and a run by TriggerJob receives the time of calling.
+func (d *Dcron) AddJobWithTime(jobName, cronStr string, cmd func(scheduled time.Time)) error {
+	if err := validateJob(jobName, cmd); err != nil {
+		return err
+	}
+	return d.AddJobWithContext(jobName, cronStr, func(ctx context.Context) {
+		scheduled, _ := ScheduledTimeFromContext(ctx)
+		cmd(scheduled)
+	})
+}
+
+// AddJobWithWrappers add a cron func decorated by wrappers only for this job,
+// e.g. cron.SkipIfStillRunning for a job which may overlap. The wrappers
+// are applied inside the global chain set by CronOptionChain, and after the
+// node is checked, so they only run in the node which runs the job:
+//
+//	global wrappers(node check(wrappers(cmd)))
+func (d *Dcron) AddJobWithWrappers(jobName, cronStr string, cmd func(), wrappers ...cron.JobWrapper) (err error) {
+	if err = validateJob(jobName, cmd); err != nil {
+		return
+	}
+	job := cron.NewChain(wrappers...).Then(cron.NewNamedJob(jobName, cron.FuncJob(cmd)))
+	_, err = d.addJob(jobName, cronStr, nil, job)
+	return
+}
+
+// AddJobWithLogger add a cron func whose wrappers log to logger instead of
+// the logger they are created with, e.g. to route a noisy job to its own
+// logger or level. It applies to the wrappers of cron which log, like
+// cron.Recover, cron.SkipIfStillRunning and cron.DelayIfStillRunning, in
+// the chain set by CronOptionChain and by WithPanicPolicy. The logs of
+// dcron itself about the job still go to the logger of dcron.
+func (d *Dcron) AddJobWithLogger(jobName, cronStr string, cmd func(), logger dlog.Logger) (err error) {
+	if err = validateJob(jobName, cmd); err != nil {
+		return
+	}
+	_, err = d.addJob(jobName, cronStr, nil, cron.NewLoggedJob(cron.FuncJob(cmd), logger))
+	return
+}
+
+// AddJobWithChainOverride add a cron func decorated by chain instead of the
+// global chain set by CronOptionChain, e.g. to let a critical job crash the
+// process on panic while the other jobs are recovered. The recover wrapper
+// set by WithPanicPolicy is not applied either, add cron.Recover to chain
+// to keep it. The chain is kept by ReplaceJob.
+func (d *Dcron) AddJobWithChainOverride(jobName, cronStr string, cmd func(), chain cron.Chain) (err error) {
+	_, err = d.addJobWithChain(jobName, cronStr, nil, cron.FuncJob(cmd), &chain, nil, nil)
+	return
+}
+
+// AddJobWithTimezone add a cron func whose cronStr is interpreted in loc,
+// e.g. "0 9 * * *" means 9am in loc regardless of the time zone of the server.
+// Daylight saving time transitions are handled by the cron schedule.
+// cronStr must not have a TZ= or CRON_TZ= prefix.
+func (d *Dcron) AddJobWithTimezone(jobName, cronStr string, loc *time.Location, cmd func()) (err error) {
+	if loc == nil {
+		return ErrNilLocation
+	}
+	_, err = d.addJob(jobName, cronStr, loc, cron.FuncJob(cmd))
+	return
+}
+
+func (d *Dcron) addJob(jobName, cronStr string, loc *time.Location, job Job) (cron.EntryID, error) {
+	return d.addJobWithChain(jobName, cronStr, loc, job, nil, nil, nil)
+}
+
+// addMarkedJob adds the job and stores mark of it in marks, e.g. the
+// broadcast or pinned jobs. mark is stored under jobsRWMut before the job
+// is scheduled, so no run of the job misses it, and it is not stored if
+// the job is not added.
+func (d *Dcron) addMarkedJob(jobName, cronStr string, job Job, marks *sync.Map, mark any) error {
+	_, err := d.addJobWithChain(jobName, cronStr, nil, job, nil, marks, mark)
+	return err
 }
-func (d *Dcron) addJob(jobName, cronStr string, job Job) (err error) {
+
+// addJobWithChain adds the job decorated by chain, nil means the global
+// chain. mark is stored in marks if marks is not nil, see addMarkedJob.
+func (d *Dcron) addJobWithChain(jobName, cronStr string, loc *time.Location, job Job,
+	chain *cron.Chain, marks *sync.Map, mark any) (cron.EntryID, error) {
+	if err := validateJob(jobName, job); err != nil {
+		return 0, err
+	}
 	d.logger.Infof("addJob '%s' : %s", jobName, cronStr)
+	// read before holding jobsRWMut, as it may wait for the driver.
+	paused := d.persistedPaused(jobName)
 
 	d.jobsRWMut.Lock()
 	defer d.jobsRWMut.Unlock()
+	if d.jobsFrozen() {
+		return 0, ErrJobsFrozen
+	}
 	if _, ok := d.jobs[jobName]; ok {
-		return ErrJobExist
+		return 0, ErrJobExist
+	}
+	if schedule, err := d.cr.Parse(cronStr); err == nil {
+		if err = d.checkInterval(jobName, schedule); err != nil {
+			return 0, err
+		}
 	}
 	innerJob := &JobWarpper{
-		Name:    jobName,
-		CronStr: cronStr,
-		Job:     job,
-		Dcron:   d,
+		Name:     jobName,
+		CronStr:  cronStr,
+		Location: loc,
+		Job:      job,
+		Dcron:    d,
+	}
+	if marks != nil {
+		marks.Store(jobName, mark)
+	}
+	var entryID cron.EntryID
+	var err error
+	if chain != nil {
+		entryID, err = d.cr.AddJobWithChain(cronStr, loc, *chain, innerJob)
+	} else {
+		entryID, err = d.cr.AddJobWithLocation(cronStr, loc, innerJob)
 	}
-	entryID, err := d.cr.AddJob(cronStr, innerJob)
 	if err != nil {
-		return err
+		if marks != nil {
+			marks.Delete(jobName)
+		}
+		return 0, invalidJobCronSpec(jobName, cronStr, err)
 	}
 	innerJob.ID = entryID
 	d.jobs[jobName] = innerJob
+	if paused {
+		d.logger.Warnf("job '%s' is paused in the driver, it does not run until ResumeJob", jobName)
+	}
+	return entryID, nil
+}
+
+// validateServiceName returns the error of a serverName which can not be
+// used in the driver, see driver.CheckServiceName.
+func validateServiceName(serverName string) error {
+	return driver.CheckServiceName(serverName)
+}
+
+// validateJob returns the error of adding cmd as jobName which can be found
+// before the job fires: an empty jobName or a nil cmd, which is a Job or a
+// func, e.g. cron.FuncJob(nil).
+func validateJob(jobName string, cmd any) error {
+	if jobName == "" {
+		return ErrEmptyJobName
+	}
+	if cmd == nil {
+		return ErrNilJobFunc
+	}
+	if v := reflect.ValueOf(cmd); (v.Kind() == reflect.Func || v.Kind() == reflect.Ptr) && v.IsNil() {
+		return ErrNilJobFunc
+	}
+	return nil
+}
+
+// ReplaceJob replaces the schedule and the func of the job at once, the
+// job is never missing or duplicated in the scheduler while it is replaced,
+// and it keeps its EntryID and time zone. The next run is computed by the
+// new cronStr, a run of the old func which is in-flight is not affected.
+// If this jobName not exist, ErrJobNotExist is returned.
+//
+// Only the job of a plain func can be replaced, e.g. added by AddFunc or
+// AddJob with a cron.FuncJob, otherwise ErrJobNotReplaceable is returned,
+// since cmd would drop the context, the logger, the wrappers or the max
+// runs of the job. Remove it and add it again instead.
+func (d *Dcron) ReplaceJob(jobName, cronStr string, cmd func()) error {
+	if err := validateJob(jobName, cmd); err != nil {
+		return err
+	}
+	d.jobsRWMut.Lock()
+	defer d.jobsRWMut.Unlock()
+	job, ok := d.jobs[jobName]
+	if !ok {
+		return ErrJobNotExist
+	}
+	if !job.replaceable() {
+		return ErrJobNotReplaceable
+	}
+	if schedule, err := d.cr.Parse(cronStr); err == nil {
+		if err = d.checkInterval(jobName, schedule); err != nil {
+			return err
+		}
+	}
+	innerJob := &JobWarpper{
+		ID:       job.ID,
+		Name:     jobName,
+		CronStr:  cronStr,
+		Location: job.Location,
+		Job:      cron.FuncJob(cmd),
+		Dcron:    d,
+	}
+	err := d.cr.ReplaceJobWithLocation(job.ID, cronStr, job.Location, innerJob)
+	if errors.Is(err, cron.ErrEntryNotFound) {
+		return ErrJobNotExist
+	}
+	if err != nil {
+		return invalidJobCronSpec(jobName, cronStr, err)
+	}
+	d.jobs[jobName] = innerJob
+	d.logger.Infof("replaceJob '%s' : %s", jobName, cronStr)
 	return nil
 }
 
+// jobsFrozen returns true if no more jobs can be added,
+// see WithFreezeJobsOnStart.
+func (d *Dcron) jobsFrozen() bool {
+	return d.freezeJobsOnStart && atomic.LoadInt32(&d.running) == dcronRunning
+}
+
+// Entry returns a snapshot of the cron entry of id returned by AddJob,
+// e.g. to get the Next and Prev run time of the job. The Entry is not
+// Valid if the job is removed.
+func (d *Dcron) Entry(id cron.EntryID) cron.Entry {
+	return d.cr.Entry(id)
+}
+
+// Cron returns the underlying scheduler of dcron, for the features dcron
+// does not expose, e.g. Entries and Location, which are safe to be called.
+// The jobs added to it directly are not checked by the node pool, so they
+// run in every node, and the jobs of dcron removed from it are still in the
+// job list of dcron, use the methods of dcron to change the jobs instead.
+// Do not Start or Stop it, they are called by Start and Stop of dcron.
+func (d *Dcron) Cron() *cron.Cron {
+	return d.cr
+}
+
 // Remove Job by jobName
+//
+// Deprecated: use RemoveJob instead.
 func (d *Dcron) Remove(jobName string) {
+	_ = d.RemoveJob(jobName)
+}
+
+// RemoveJob removes the job by jobName, it is safe to be called
+// while dcron is running. A run of this job which is in-flight
+// will be finished, but it will never be triggered again.
+// If this jobName not exist, ErrJobNotExist is returned.
+func (d *Dcron) RemoveJob(jobName string) error {
 	d.jobsRWMut.Lock()
 	defer d.jobsRWMut.Unlock()
 
-	if job, ok := d.jobs[jobName]; ok {
-		delete(d.jobs, jobName)
-		d.cr.Remove(job.ID)
+	job, ok := d.jobs[jobName]
+	if !ok {
+		return ErrJobNotExist
+	}
+	d.removeJob(job)
+	return nil
+}
+
+// removeJob removes the added job, the caller must hold jobsRWMut.
+func (d *Dcron) removeJob(job *JobWarpper) {
+	delete(d.jobs, job.Name)
+	d.pausedJobs.Delete(job.Name)
+	d.pinnedJobs.Delete(job.Name)
+	d.runOnStartJobs.Delete(job.Name)
+	d.jobGroups.Delete(job.Name)
+	d.selectorJobs.Delete(job.Name)
+	d.broadcastJobs.Delete(job.Name)
+	d.maxRunsJobs.Delete(job.Name)
+	if removed, ok := d.maintenanceMissed.LoadAndDelete(job.Name); ok {
+		close(removed.(chan struct{}))
 	}
+	d.removeJobStatus(job.Name)
+	d.cr.Remove(job.ID)
+	d.logger.Infof("removeJob '%s'", job.Name)
+}
+
+// HasJob returns true if the job of jobName is added to dcron.
+func (d *Dcron) HasJob(jobName string) bool {
+	d.jobsRWMut.RLock()
+	defer d.jobsRWMut.RUnlock()
+	_, ok := d.jobs[jobName]
+	return ok
 }
 
 // Get job by jobName
@@ -166,7 +720,7 @@ func (d *Dcron) GetJob(jobName string, thisNodeOnly bool) (*JobWarpper, error) {
 	if !thisNodeOnly {
 		return job, nil
 	}
-	isRunningHere, err := d.nodePool.CheckJobAvailable(jobName)
+	isRunningHere, err := d.checkJobAvailable(jobName)
 	if err != nil {
 		return nil, err
 	}
@@ -196,7 +750,7 @@ func (d *Dcron) GetJobs(thisNodeOnly bool) []*JobWarpper {
 			err           error
 		)
 		if thisNodeOnly {
-			isRunningHere, err = d.nodePool.CheckJobAvailable(v.Name)
+			isRunningHere, err = d.checkJobAvailable(v.Name)
 			if err != nil {
 				continue
 			}
@@ -209,11 +763,97 @@ func (d *Dcron) GetJobs(thisNodeOnly bool) []*JobWarpper {
 	return ret
 }
 
+// TriggerJob runs the job immediately and synchronously, outside of its schedule.
+// The job is run through the wrapper chain of cron, so wrappers like
+// Recover and RetryIfFailed still apply, and its scheduled time is the time
+// of calling, so it does not share the execution lock of a scheduled run.
+// If this jobName not exist, ErrJobNotExist is returned.
+// If this job is not available in this node, ErrJobWrongNode is returned,
+// the caller should trigger it on the node which owns it.
+// If the job is a cron.ErrorJob, the error returned by it is returned.
+func (d *Dcron) TriggerJob(jobName string) error {
+	d.jobsRWMut.RLock()
+	job, ok := d.jobs[jobName]
+	d.jobsRWMut.RUnlock()
+	if !ok {
+		return ErrJobNotExist
+	}
+	if !d.runningLocally {
+		isRunningHere, err := d.checkJobAvailable(jobName)
+		if err != nil {
+			return err
+		}
+		if !isRunningHere {
+			return ErrJobWrongNode
+		}
+	}
+	d.logger.Infof("trigger job '%s'", jobName)
+	return job.runAt(d.clock.Now())
+}
+
+// JobMeta is the meta information of a job.
+type JobMeta struct {
+	Name    string
+	CronStr string
+	// Next is the next scheduled time of this job, it is
+	// the zero time if dcron has not been started.
+	Next time.Time
+	// Owned is true if this job is available in this node.
+	Owned bool
+	// Paused is true if this job is paused by PauseJob.
+	Paused bool
+	// Group is the group of this job, see AddJobToGroup.
+	Group string
+}
+
+// ListJobs returns the meta information of all jobs added to dcron.
+// The returned slice is a copy, it is sorted by the job name.
+func (d *Dcron) ListJobs() []JobMeta {
+	ret := d.listJobs()
+	// the pause states may be in the driver, they are read without
+	// holding jobsRWMut, so adding or removing the jobs is not blocked.
+	for i := range ret {
+		ret[i].Paused, _ = d.IsJobPaused(ret[i].Name)
+	}
+	return ret
+}
+
+// listJobs returns the meta information of all jobs without the pause
+// states, sorted by the job name.
+func (d *Dcron) listJobs() []JobMeta {
+	d.jobsRWMut.RLock()
+	defer d.jobsRWMut.RUnlock()
+
+	nexts := make(map[cron.EntryID]time.Time, len(d.jobs))
+	for _, entry := range d.cr.Entries() {
+		nexts[entry.ID] = entry.Next
+	}
+	ret := make([]JobMeta, 0, len(d.jobs))
+	for _, job := range d.jobs {
+		owned := d.runningLocally
+		if !owned {
+			owned, _ = d.checkJobAvailable(job.Name)
+		}
+		ret = append(ret, JobMeta{
+			Name:    job.Name,
+			CronStr: job.CronStr,
+			Next:    nexts[job.ID],
+			Owned:   owned,
+			Group:   d.jobGroup(job.Name),
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
 func (d *Dcron) allowThisNodeRun(jobName string) (ok bool) {
-	if d.runningLocally {
+	if d.runningLocally || d.isBroadcastJob(jobName) {
 		return true
 	}
-	ok, err := d.nodePool.CheckJobAvailable(jobName)
+	if ok, decided := d.allowIsolatedRun(jobName); decided {
+		return ok
+	}
+	ok, err := d.checkJobAvailable(jobName)
 	if err != nil {
 		d.logger.Errorf("allow this node run error, err=%v", err)
 		ok = false
@@ -221,7 +861,8 @@ func (d *Dcron) allowThisNodeRun(jobName string) (ok bool) {
 	} else {
 		d.state.Store(dcronStateSteady)
 		if d.recentJobs != nil {
-			go d.reRunRecentJobs(d.recentJobs.PopAllJobs())
+			jobs := d.recentJobs.PopAllJobs()
+			d.goTracked(func() { d.reRunRecentJobs(jobs) })
 		}
 	}
 	if d.recentJobs != nil {
@@ -232,80 +873,395 @@ func (d *Dcron) allowThisNodeRun(jobName string) (ok bool) {
 	return
 }
 
-// Start job
-func (d *Dcron) Start() {
-	// recover jobs before starting
-	if d.RecoverFunc != nil {
-		d.RecoverFunc(d)
+// logOwnership logs whether this node runs the fired job,
+// see WithVerboseOwnershipLogging.
+func (d *Dcron) logOwnership(jobName string, allowed bool) {
+	if !d.verboseOwnership {
+		return
 	}
-	if atomic.CompareAndSwapInt32(&d.running, dcronStopped, dcronRunning) {
-		if !d.runningLocally {
-			if err := d.startNodePool(); err != nil {
-				atomic.StoreInt32(&d.running, dcronStopped)
-				return
-			}
-			d.logger.Infof("dcron started, nodeID is %s", d.nodePool.GetNodeID())
+	decision := "run"
+	if !allowed {
+		decision = "skip"
+	}
+	var nodeID, owner string
+	if !d.runningLocally {
+		nodeID = d.nodePool.GetNodeID()
+		var err error
+		if owner, err = d.jobOwner(jobName); err != nil {
+			owner = err.Error()
 		}
-		d.cr.Start()
-	} else {
+	}
+	dlog.Infow(d.logger, "job fired", "job_name", jobName, "decision", decision, "node_id", nodeID, "owner", owner)
+}
+
+// Start starts dcron in the background. It returns an error if the options
+// passed to NewDcronWithOption are invalid, the context passed to
+// NewDcronWithContext is done, or this node can not be registered in the
+// driver or the first sync of the nodes fails, e.g. the driver is down.
+// A nil error means this node is registered and has a view of the nodes,
+// or dcron is already running.
+func (d *Dcron) Start() error {
+	started, err := d.start()
+	if err != nil {
+		d.logger.Errorf("dcron can not start, err=%v", err)
+		return err
+	}
+	if !started {
 		d.logger.Infof("dcron have started")
+		return nil
 	}
+	d.cr.Start()
+	return nil
 }
 
-// Run Job
+// Run runs dcron in the current goroutine until it is stopped, it returns
+// immediately if dcron can not start, see Start, the error is logged.
 func (d *Dcron) Run() {
+	started, err := d.start()
+	if err != nil {
+		d.logger.Errorf("dcron can not run, err=%v", err)
+		return
+	}
+	if !started {
+		d.logger.Infof("dcron already running")
+		return
+	}
+	d.cr.Run()
+}
+
+// start starts everything of dcron but the cron, started is false if
+// dcron is already running.
+func (d *Dcron) start() (started bool, err error) {
+	if d.optionErr != nil {
+		return false, d.optionErr
+	}
+	if d.lifecycleCtx != nil && d.lifecycleCtx.Err() != nil {
+		return false, d.lifecycleCtx.Err()
+	}
 	// recover jobs before starting
 	if d.RecoverFunc != nil {
 		d.RecoverFunc(d)
 	}
-	if atomic.CompareAndSwapInt32(&d.running, dcronStopped, dcronRunning) {
-		if !d.runningLocally {
-			if err := d.startNodePool(); err != nil {
-				atomic.StoreInt32(&d.running, dcronStopped)
-				return
-			}
-			d.logger.Infof("dcron running, nodeID is %s", d.nodePool.GetNodeID())
+	if !atomic.CompareAndSwapInt32(&d.running, dcronStopped, dcronRunning) {
+		return false, nil
+	}
+	d.runningJobsMut.Lock()
+	d.stopping = false
+	d.runningJobsMut.Unlock()
+	startedAt := d.clock.Now()
+	d.startRuntime()
+	if !d.runningLocally {
+		if err = d.startNodePool(); err != nil {
+			d.stopRuntime()
+			atomic.StoreInt32(&d.running, dcronStopped)
+			return false, err
 		}
-		d.cr.Run()
-	} else {
-		d.logger.Infof("dcron already running")
+		d.logger.Infof("dcron started, nodeID is %s", d.nodePool.GetNodeID())
+		d.clearStaleCordon()
+		if d.onRegister != nil {
+			d.onRegister(d.nodePool.GetNodeID())
+		}
+		if d.nodeGeneration {
+			d.claimGeneration()
+		}
+	}
+	if d.metrics != nil {
+		d.goTracked(d.watchOwnedJobs)
+	}
+	d.goTracked(d.runOnceJobs)
+	d.goTracked(d.runJobsOnStart)
+	if d.catchUpMax > 0 && !d.runningLocally {
+		d.goTracked(func() { d.catchUpMissedRuns(startedAt) })
+	}
+	if d.expiredJobHandler != nil || d.removeExpiredJobs {
+		d.goTracked(d.watchExpiredJobs)
+	}
+	if d.hasMaxRunsJobs() {
+		d.watchMaxRunsJobs()
+	}
+	d.startAdvertisers()
+	d.goTracked(d.stopOnLifecycleDone)
+	return true, nil
+}
+
+// Err returns the error of the options passed to NewDcronWithOption,
+// e.g. an unsafe node update duration. Start returns it and Run refuses
+// to run the dcron if it is not nil.
+func (d *Dcron) Err() error {
+	return d.optionErr
+}
+
+// startAdvertisers starts the goroutines enabled by the options
+// which advertise this node in the driver.
+func (d *Dcron) startAdvertisers() {
+	if d.runningLocally {
+		return
+	}
+	if d.jobSetCheck {
+		d.advertisers.Add(1)
+		d.goTracked(d.checkJobSets)
+	}
+	if d.clockSkewThreshold > 0 {
+		d.advertisers.Add(1)
+		d.goTracked(d.checkClockSkew)
+	}
+	if d.nodeGeneration {
+		d.advertisers.Add(1)
+		d.goTracked(d.checkGeneration)
+	}
+	if d.ownershipConflictInterval > 0 {
+		d.advertisers.Add(1)
+		d.goTracked(d.checkOwnershipConflicts)
+	}
+	hasSelector := false
+	d.selectorJobs.Range(func(_, _ any) bool {
+		hasSelector = true
+		return false
+	})
+	if len(d.nodeLabels) > 0 || hasSelector {
+		d.watchNodeLabels()
 	}
 }
 
 func (d *Dcron) startNodePool() error {
 	if err := d.nodePool.Start(context.Background()); err != nil {
-		d.logger.Errorf("dcron start node pool error %+v", err)
-		return err
+		return fmt.Errorf("start node pool: %w", err)
+	}
+	if d.executionLockTTL > 0 {
+		if _, ok := d.driver.(driver.LockDriver); !ok {
+			d.logger.Warnf("driver is not a LockDriver, execution lock is disabled")
+		}
 	}
 	return nil
 }
 
-// Stop job
+func (d *Dcron) startRuntime() {
+	d.runtimeMut.Lock()
+	defer d.runtimeMut.Unlock()
+	parent := d.lifecycleCtx
+	if parent == nil {
+		parent = context.Background()
+	}
+	d.runtimeCtx, d.runtimeCancel = context.WithCancel(parent)
+}
+
+// stopOnLifecycleDone stops dcron once the context passed to
+// NewDcronWithContext is done, it returns if dcron is stopped before.
+func (d *Dcron) stopOnLifecycleDone() {
+	if d.lifecycleCtx == nil {
+		return
+	}
+	<-d.runtimeContext().Done()
+	if d.lifecycleCtx.Err() != nil {
+		d.logger.Infof("dcron context is done, err=%v", d.lifecycleCtx.Err())
+		d.Stop()
+	}
+}
+
+func (d *Dcron) stopRuntime() {
+	d.runtimeMut.Lock()
+	defer d.runtimeMut.Unlock()
+	if d.runtimeCancel != nil {
+		d.runtimeCancel()
+	}
+}
+
+// runtimeContext returns the context of the running dcron.
+// If dcron has never been started, context.Background() is returned.
+func (d *Dcron) runtimeContext() context.Context {
+	d.runtimeMut.Lock()
+	defer d.runtimeMut.Unlock()
+	if d.runtimeCtx == nil {
+		return context.Background()
+	}
+	return d.runtimeCtx
+}
+
+// Stop job, it returns immediately if dcron is not running,
+// so it is safe to be called more than once.
 func (d *Dcron) Stop() {
+	d.stopMut.Lock()
+	defer d.stopMut.Unlock()
+	if atomic.LoadInt32(&d.running) != dcronRunning {
+		return
+	}
+	d.runningJobsMut.Lock()
+	d.stopping = true
+	d.runningJobsMut.Unlock()
 	tick := time.NewTicker(time.Millisecond)
 	if !d.runningLocally {
-		d.nodePool.Stop(context.Background())
+		// deregister this node, so the other nodes take its jobs in their
+		// next sync. If the driver is unreachable, its heartbeat expires.
+		d.clearCordon()
+		ctx, cancel := context.WithTimeout(context.Background(), d.nodeUpdateDuration)
+		_ = d.nodePool.Stop(ctx)
+		cancel()
+		if d.onDeregister != nil {
+			d.onDeregister(d.nodePool.GetNodeID())
+		}
 	}
 	for range tick.C {
 		if atomic.CompareAndSwapInt32(&d.running, dcronRunning, dcronStopped) {
 			d.cr.Stop()
+			d.stopRuntime()
+			d.advertisers.Wait()
 			d.logger.Infof("dcron stopped")
 			return
 		}
 	}
 }
 
+// StopWait stops dcron, and waits for all jobs running in this node
+// to be finished, including the runs which have not started the job yet,
+// e.g. in the jitter. No run starts once dcron is stopping. If ctx is
+// done before that, an error wraps ctx.Err() with the names of the still
+// running jobs is returned.
+func (d *Dcron) StopWait(ctx context.Context) error {
+	d.Stop()
+	done := make(chan struct{})
+	go func() {
+		d.jobWaiter.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w, running jobs: %v", ctx.Err(), d.runningJobNames())
+	}
+}
+
+// runStarted counts a run from the start of its pipeline, so StopWait
+// waits for the runs in the jitter or waiting for a lock too. It returns
+// false once dcron is stopping, then the run is dropped. runFinished must
+// be called if it returns true.
+func (d *Dcron) runStarted() bool {
+	d.runningJobsMut.Lock()
+	defer d.runningJobsMut.Unlock()
+	if d.stopping {
+		return false
+	}
+	d.jobWaiter.Add(1)
+	return true
+}
+
+func (d *Dcron) runFinished() {
+	d.jobWaiter.Done()
+}
+
+func (d *Dcron) jobStarted(jobName string) {
+	d.runningJobsMut.Lock()
+	defer d.runningJobsMut.Unlock()
+	d.runningJobs[jobName]++
+}
+
+func (d *Dcron) jobFinished(jobName string) {
+	d.runningJobsMut.Lock()
+	defer d.runningJobsMut.Unlock()
+	if d.runningJobs[jobName]--; d.runningJobs[jobName] <= 0 {
+		delete(d.runningJobs, jobName)
+	}
+}
+
+func (d *Dcron) runningJobNames() []string {
+	d.runningJobsMut.Lock()
+	defer d.runningJobsMut.Unlock()
+	names := make([]string, 0, len(d.runningJobs))
+	for name := range d.runningJobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (d *Dcron) reRunRecentJobs(jobNames []string) {
 	d.logger.Infof("reRunRecentJobs: length=%d", len(jobNames))
 	for _, jobName := range jobNames {
-		if job, ok := d.jobs[jobName]; ok {
-			if ok, _ := d.nodePool.CheckJobAvailable(jobName); ok {
+		d.jobsRWMut.RLock()
+		job, ok := d.jobs[jobName]
+		d.jobsRWMut.RUnlock()
+		if ok {
+			if ok, _ := d.checkJobAvailable(jobName); ok {
 				job.Execute()
 			}
 		}
 	}
 }
 
+// GetJobOwnerNode returns the nodeID of the node which the job will be run in now.
+// If the node pool has not been synced, an error is returned.
+// The broadcast job runs in every node, this node is returned for it.
+func (d *Dcron) GetJobOwnerNode(jobName string) (nodeID string, err error) {
+	if d.runningLocally {
+		return "", ErrRunningLocally
+	}
+	return d.jobOwner(jobName)
+}
+
 func (d *Dcron) NodeID() string {
 	return d.nodePool.GetNodeID()
 }
+
+// Nodes returns the sorted IDs of the nodes in the node pool as of the last
+// sync with the driver. The returned slice is a copy.
+// Nil is returned when running locally.
+func (d *Dcron) Nodes() []string {
+	if d.runningLocally {
+		return nil
+	}
+	return d.nodePool.GetNodes()
+}
+
+// NodeRingStat is the share of the hash ring of a node, see RingStats.
+type NodeRingStat struct {
+	NodeID string
+	// VirtualNodes is the number of the virtual nodes of the node, which is
+	// the hash replicas times the weight of the node.
+	VirtualNodes int
+	// Share is the fraction of the hash space owned by the node, in [0, 1],
+	// which is the expected fraction of the jobs it runs.
+	Share float64
+}
+
+// RingStats returns a snapshot of the hash ring as of the last sync with
+// the driver, sorted by the nodeID, e.g. to check the hash replicas set by
+// WithHashReplicas balance the jobs before many jobs are added. The pinned
+// jobs and the jobs with selectors are not counted in the shares.
+// Nil is returned when running locally.
+func (d *Dcron) RingStats() []NodeRingStat {
+	if d.runningLocally {
+		return nil
+	}
+	stats := d.nodePool.RingStats()
+	ret := make([]NodeRingStat, 0, len(stats))
+	for nodeID, stat := range stats {
+		ret = append(ret, NodeRingStat{NodeID: nodeID, VirtualNodes: stat.VirtualNodes, Share: stat.Share})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].NodeID < ret[j].NodeID })
+	return ret
+}
+
+// SetHashReplicas changes the hash replicas set by WithHashReplicas at
+// runtime, e.g. if RingStats shows the jobs are not balanced, the hash ring
+// is rebuilt and the owners of the jobs are recomputed, which rebalances
+// the jobs as a membership change does: this node does not run jobs until
+// the next sync, and the callback registered by OnJobRebalanced is called
+// for the jobs whose owner changed. Set the same replicas in all the nodes,
+// the nodes with different replicas do not agree on the owners, use
+// WithExecutionLock to dedupe the runs in the meantime.
+// It returns ErrInvalidHashReplicas if replicas is not positive.
+func (d *Dcron) SetHashReplicas(replicas int) error {
+	if replicas <= 0 {
+		return ErrInvalidHashReplicas
+	}
+	if d.runningLocally {
+		return nil
+	}
+	d.nodePool.SetHashReplicas(replicas)
+	return nil
+}
+
+// NodeCount returns the number of the nodes returned by Nodes.
+func (d *Dcron) NodeCount() int {
+	return len(d.Nodes())
+}
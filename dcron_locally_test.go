@@ -1,11 +1,21 @@
 package dcron_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/libi/dcron"
 	"github.com/libi/dcron/cron"
+	"github.com/libi/dcron/dlog"
+	"github.com/libi/dcron/testclock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -46,6 +56,1675 @@ func (s *DcronLocallyTestSuite) TestNormal() {
 	dcr.Stop()
 }
 
+func (s *DcronLocallyTestSuite) TestAddJobWithContext() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionSeconds())
+
+	started := make(chan struct{}, 1)
+	canceled := make(chan struct{}, 1)
+	err := dcr.AddJobWithContext("job1", "* * * * * *", func(ctx context.Context) {
+		jobName, ok := dcron.JobNameFromContext(ctx)
+		s.Assert().True(ok)
+		s.Assert().Equal("job1", jobName)
+		scheduledTime, ok := dcron.ScheduledTimeFromContext(ctx)
+		s.Assert().True(ok)
+		s.Assert().False(scheduledTime.IsZero())
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		select {
+		case canceled <- struct{}{}:
+		default:
+		}
+	})
+	s.Require().Nil(err)
+	dcr.Start()
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		s.FailNow("job not started")
+	}
+	dcr.Stop()
+	select {
+	case <-canceled:
+	case <-time.After(3 * time.Second):
+		s.FailNow("job context not canceled after stop")
+	}
+}
+
+func (s *DcronLocallyTestSuite) TestStopWait() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionSeconds())
+
+	started := make(chan struct{}, 1)
+	var finished atomic.Bool
+	err := dcr.AddFunc("job1", "* * * * * *", func() {
+		select {
+		case started <- struct{}{}:
+		default:
+			return
+		}
+		<-time.After(2 * time.Second)
+		finished.Store(true)
+	})
+	s.Require().Nil(err)
+	dcr.Start()
+	<-started
+	s.Require().Nil(dcr.StopWait(context.Background()))
+	s.Assert().True(finished.Load())
+}
+
+func (s *DcronLocallyTestSuite) TestStopWaitRefusesNewRuns() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally())
+
+	var called atomic.Int32
+	s.Require().Nil(dcr.AddFunc("job1", "0 0 1 1 *", func() {
+		called.Add(1)
+	}))
+	dcr.Start()
+	s.Require().Nil(dcr.TriggerJob("job1"))
+	s.Require().Nil(dcr.StopWait(context.Background()))
+	// no run starts once dcron is stopping.
+	s.Require().Nil(dcr.TriggerJob("job1"))
+	s.Assert().Equal(int32(1), called.Load())
+	// until it is started again.
+	dcr.Start()
+	defer dcr.Stop()
+	s.Require().Nil(dcr.TriggerJob("job1"))
+	s.Assert().Equal(int32(2), called.Load())
+}
+
+func (s *DcronLocallyTestSuite) TestStopWaitTimeout() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionSeconds())
+
+	started := make(chan struct{}, 1)
+	err := dcr.AddFunc("job1", "* * * * * *", func() {
+		select {
+		case started <- struct{}{}:
+		default:
+			return
+		}
+		<-time.After(5 * time.Second)
+	})
+	s.Require().Nil(err)
+	dcr.Start()
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	err = dcr.StopWait(ctx)
+	s.Require().NotNil(err)
+	s.Assert().ErrorIs(err, context.DeadlineExceeded)
+	s.Assert().Contains(err.Error(), "job1")
+}
+
+func (s *DcronLocallyTestSuite) TestTriggerJob() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionChain(cron.Recover(cron.DiscardLogger)))
+
+	var called atomic.Int32
+	s.Require().Nil(dcr.AddFunc("job1", "0 0 1 1 *", func() {
+		called.Add(1)
+	}))
+	s.Require().Nil(dcr.AddFunc("panic", "0 0 1 1 *", func() {
+		panic("test panic")
+	}))
+	s.Assert().Nil(dcr.TriggerJob("job1"))
+	s.Assert().Equal(int32(1), called.Load())
+	// the panic is recovered by the wrapper chain.
+	s.Assert().Nil(dcr.TriggerJob("panic"))
+	s.Assert().Equal(dcron.ErrJobNotExist, dcr.TriggerJob("not_exist"))
+}
+
+func (s *DcronLocallyTestSuite) TestTimeoutJobCancelsContext() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionChain(cron.TimeoutJob(20*time.Millisecond, cron.DiscardLogger)))
+
+	canceled := make(chan struct{})
+	s.Require().Nil(dcr.AddJobWithContext("job", "0 0 1 1 *", func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	}))
+	s.Assert().Equal(cron.ErrJobTimeout, dcr.TriggerJob("job"))
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		s.Fail("expected the context of the job canceled by the timeout")
+	}
+}
+
+func (s *DcronLocallyTestSuite) TestAddFuncWithHandle() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally())
+
+	var called atomic.Int32
+	handle, err := dcr.AddFuncWithHandle("job1", "0 0 1 1 *", func() {
+		called.Add(1)
+	})
+	s.Require().Nil(err)
+	s.Assert().Equal("job1", handle.Name())
+	s.Assert().Nil(handle.Trigger())
+	s.Assert().Equal(int32(1), called.Load())
+
+	s.Require().Nil(handle.Pause())
+	paused, err := handle.IsPaused()
+	s.Require().Nil(err)
+	s.Assert().True(paused)
+	s.Require().Nil(handle.Resume())
+	paused, err = handle.IsPaused()
+	s.Require().Nil(err)
+	s.Assert().False(paused)
+
+	s.Require().Nil(handle.Remove())
+	s.Assert().False(dcr.HasJob("job1"))
+	s.Assert().Equal(dcron.ErrJobNotExist, handle.Trigger())
+	s.Assert().Equal(dcron.ErrJobNotExist, handle.Remove())
+
+	_, err = dcr.AddFuncWithHandle("job2", "invalid", func() {})
+	s.Assert().ErrorIs(err, dcron.ErrInvalidCronSpec)
+}
+
+func (s *DcronLocallyTestSuite) TestAuditJob() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionChain(cron.Recover(cron.DiscardLogger)))
+
+	var buf bytes.Buffer
+	sink := dcron.NewJSONLineAuditSink(&buf)
+	s.Require().Nil(dcr.AddJobWithWrappers("job1", "0 0 1 1 *", func() {
+		time.Sleep(10 * time.Millisecond)
+	}, dcr.AuditJob(sink)))
+	s.Require().Nil(dcr.AddJobWithWrappers("panic", "0 0 1 1 *", func() {
+		panic("test panic")
+	}, dcr.AuditJob(sink)))
+	s.Assert().Nil(dcr.TriggerJob("job1"))
+	// the panic is recorded, and then recovered by the global chain.
+	s.Assert().Nil(dcr.TriggerJob("panic"))
+	s.Require().Nil(sink.Err())
+
+	dec := json.NewDecoder(&buf)
+	var event dcron.AuditEvent
+	s.Require().Nil(dec.Decode(&event))
+	s.Assert().Equal("job1", event.JobName)
+	s.Assert().Empty(event.Error)
+	s.Assert().False(event.Panicked)
+	s.Assert().GreaterOrEqual(event.Duration(), 10*time.Millisecond)
+	s.Require().Nil(dec.Decode(&event))
+	s.Assert().Equal("panic", event.JobName)
+	s.Assert().Equal("test panic", event.Error)
+	s.Assert().True(event.Panicked)
+	s.Assert().False(dec.More())
+}
+
+type errorCountingCollector struct {
+	dcron.MetricsCollector
+	errors atomic.Int32
+}
+
+func (c *errorCountingCollector) IncJobRuns(jobName string)                          {}
+func (c *errorCountingCollector) ObserveJobDuration(jobName string, d time.Duration) {}
+func (c *errorCountingCollector) IncJobErrors(jobName string)                        { c.errors.Add(1) }
+
+func (s *DcronLocallyTestSuite) TestAddFuncWithError() {
+	collector := &errorCountingCollector{}
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithMetrics(collector),
+		dcron.CronOptionChain(cron.Recover(cron.DiscardLogger)))
+
+	errFailed := errors.New("failed")
+	s.Require().Nil(dcr.AddFuncWithError("failed", "0 0 1 1 *", func() error {
+		return errFailed
+	}))
+	s.Require().Nil(dcr.AddFuncWithError("succeeded", "0 0 1 1 *", func() error {
+		return nil
+	}))
+	s.Require().Nil(dcr.AddFunc("plain", "0 0 1 1 *", func() {}))
+	s.Assert().Equal(errFailed, dcr.TriggerJob("failed"))
+	s.Assert().Nil(dcr.TriggerJob("succeeded"))
+	s.Assert().Nil(dcr.TriggerJob("plain"))
+	s.Assert().Equal(int32(1), collector.errors.Load())
+}
+
+func (s *DcronLocallyTestSuite) TestRecoverWithHandler() {
+	var gotName string
+	var gotPanic interface{}
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionChain(
+			cron.RecoverWithHandler(cron.DiscardLogger, func(jobName string, recovered interface{}, stack []byte) {
+				gotName, gotPanic = jobName, recovered
+			}),
+			cron.SkipIfStillRunning(cron.DiscardLogger)))
+
+	s.Require().Nil(dcr.AddFunc("panic", "0 0 1 1 *", func() {
+		panic("test panic")
+	}))
+	s.Assert().Nil(dcr.TriggerJob("panic"))
+	s.Assert().Equal("panic", gotName)
+	s.Assert().Equal("test panic", gotPanic)
+}
+
+func (s *DcronLocallyTestSuite) TestAddJobEntry() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithSeconds())
+	id, err := dcr.AddJob("job", "* * * * * *", cron.FuncJob(func() {}))
+	s.Require().Nil(err)
+	s.Assert().True(dcr.Entry(id).Valid())
+	_, err = dcr.AddJob("job", "* * * * * *", cron.FuncJob(func() {}))
+	s.Assert().Equal(dcron.ErrJobExist, err)
+
+	dcr.Start()
+	defer dcr.Stop()
+	<-time.After(1500 * time.Millisecond)
+	entry := dcr.Entry(id)
+	s.Assert().False(entry.Prev.IsZero())
+	s.Assert().Equal(time.Second, entry.Next.Sub(entry.Prev))
+
+	s.Require().Nil(dcr.RemoveJob("job"))
+	s.Assert().False(dcr.Entry(id).Valid())
+}
+
+func (s *DcronLocallyTestSuite) TestAddJobBeforeAndAfterStart() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithSeconds())
+	var before, after atomic.Int32
+	s.Require().Nil(dcr.AddFunc("before", "* * * * * *", func() { before.Add(1) }))
+	dcr.Start()
+	defer dcr.Stop()
+	s.Require().Nil(dcr.AddFunc("after", "* * * * * *", func() { after.Add(1) }))
+	<-time.After(2500 * time.Millisecond)
+	s.Assert().GreaterOrEqual(before.Load(), int32(2))
+	s.Assert().GreaterOrEqual(after.Load(), int32(2))
+}
+
+func (s *DcronLocallyTestSuite) TestFreezeJobsOnStart() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithSeconds(),
+		dcron.WithFreezeJobsOnStart())
+	var runs atomic.Int32
+	s.Require().Nil(dcr.AddFunc("before", "* * * * * *", func() { runs.Add(1) }))
+	dcr.Start()
+	s.Assert().Equal(dcron.ErrJobsFrozen, dcr.AddFunc("after", "* * * * * *", func() {}))
+	s.Assert().Equal(dcron.ErrJobsFrozen, dcr.AddOnceJob("once", func() {}))
+	<-time.After(1500 * time.Millisecond)
+	s.Assert().GreaterOrEqual(runs.Load(), int32(1))
+	s.Assert().Len(dcr.ListJobs(), 1)
+
+	// the jobs can be changed again after stop.
+	dcr.Stop()
+	s.Assert().Nil(dcr.AddFunc("after", "* * * * * *", func() {}))
+}
+
+type printfRecorder struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (p *printfRecorder) Printf(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lines = append(p.lines, fmt.Sprintf(format, args...))
+}
+
+// reset drops the recorded lines.
+func (p *printfRecorder) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lines = nil
+}
+
+// count returns the number of the lines containing all of substrs.
+func (p *printfRecorder) count(substrs ...string) (n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, line := range p.lines {
+		matched := true
+		for _, substr := range substrs {
+			matched = matched && strings.Contains(line, substr)
+		}
+		if matched {
+			n++
+		}
+	}
+	return
+}
+
+func (s *DcronLocallyTestSuite) TestLogLevel() {
+	recorder := &printfRecorder{}
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithLogger(dlog.VerbosePrintfLogger(recorder)),
+		dcron.WithLogLevel(dlog.LevelError))
+	s.Require().Nil(dcr.AddFunc("job", "* * * * *", func() {}))
+	s.Assert().Empty(recorder.lines)
+	dcr.GetLogger().Errorf("error")
+	s.Assert().Equal([]string{"[ERROR] error"}, recorder.lines)
+}
+
+func (s *DcronLocallyTestSuite) TestSuppressedLogs() {
+	newDcron := func(recorder *printfRecorder, opts ...dcron.Option) *dcron.Dcron {
+		opts = append([]dcron.Option{
+			dcron.RunningLocally(),
+			dcron.WithLogger(dlog.VerbosePrintfLogger(recorder)),
+			dcron.WithPanicPolicy(cron.PanicRecover, nil),
+		}, opts...)
+		dcr := dcron.NewDcronWithOption("not a necessary servername", nil, opts...)
+		s.Require().Nil(dcr.AddFunc("job", "* * * * *", func() {
+			panic("job panics")
+		}))
+		return dcr
+	}
+	recorder := &printfRecorder{}
+	dcr := newDcron(recorder)
+	_ = dcr.TriggerJob("job")
+	s.Assert().Equal(1, recorder.count("panic", "job panics"))
+
+	recorder = &printfRecorder{}
+	dcr = newDcron(recorder, dcron.WithSuppressedLogs(cron.LogPanic))
+	_ = dcr.TriggerJob("job")
+	s.Assert().Equal(0, recorder.count("panic"))
+	dcr.GetLogger().Errorf("error")
+	s.Assert().Equal(1, recorder.count("[ERROR] error"))
+}
+
+func (s *DcronLocallyTestSuite) TestSubSecondInterval() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithRejectShortIntervals(),
+		dcron.CronOptionSeconds())
+	var runs int32
+	s.Require().Nil(dcr.AddFunc("fast", "@every 100ms", func() {
+		atomic.AddInt32(&runs, 1)
+	}))
+	s.Require().Nil(dcr.Start())
+	defer dcr.Stop()
+	s.Assert().Eventually(func() bool {
+		return atomic.LoadInt32(&runs) >= 5
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func (s *DcronLocallyTestSuite) TestLateRunObserver() {
+	var mut sync.Mutex
+	var late []time.Duration
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionSeconds(),
+		dcron.CronOptionChain(cron.DelayIfStillRunning(cron.DiscardLogger)),
+		dcron.WithLateRunObserver(200*time.Millisecond, func(jobName string, scheduled, actual time.Time) {
+			s.Assert().Equal("slow", jobName)
+			mut.Lock()
+			late = append(late, actual.Sub(scheduled))
+			mut.Unlock()
+		}))
+	var runs int32
+	// each run delays the next one by about 500ms.
+	s.Require().Nil(dcr.AddFunc("slow", "* * * * * *", func() {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			time.Sleep(1500 * time.Millisecond)
+		}
+	}))
+	s.Require().Nil(dcr.Start())
+	defer dcr.Stop()
+	s.Require().Eventually(func() bool {
+		return atomic.LoadInt32(&runs) >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+	mut.Lock()
+	defer mut.Unlock()
+	s.Require().Len(late, 1)
+	s.Assert().Greater(late[0], 200*time.Millisecond)
+}
+
+func (s *DcronLocallyTestSuite) TestAddJobs() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithSeconds())
+	s.Require().Nil(dcr.AddFunc("added", "* * * * * *", func() {}))
+
+	err := dcr.AddJobs([]dcron.JobSpec{
+		{Name: "ok", CronSpec: "* * * * * *", Func: func() {}},
+		{Name: "bad", CronSpec: "* * * * *", Func: func() {}},
+		{Name: "added", CronSpec: "* * * * * *", Func: func() {}},
+		{Name: "nil", CronSpec: "* * * * * *"},
+		{Name: "ok", CronSpec: "@hourly", Func: func() {}},
+	})
+	var addErr *dcron.AddJobsError
+	s.Require().ErrorAs(err, &addErr)
+	s.Assert().ErrorIs(err, dcron.ErrInvalidCronSpec)
+	s.Assert().ErrorIs(err, dcron.ErrJobExist)
+	s.Assert().ErrorIs(err, dcron.ErrNilJobFunc)
+	s.Require().Len(addErr.Errors, 4)
+	for i, name := range []string{"bad", "added", "nil", "ok"} {
+		s.Assert().Equal(name, addErr.Errors[i].Name)
+	}
+	// no job is added.
+	s.Assert().Len(dcr.ListJobs(), 1)
+
+	var runs atomic.Int32
+	s.Require().Nil(dcr.AddJobs([]dcron.JobSpec{
+		{Name: "job1", CronSpec: "* * * * * *", Func: func() { runs.Add(1) }},
+		{Name: "job2", CronSpec: "@every 1s", Func: func() { runs.Add(1) }},
+	}))
+	s.Assert().Len(dcr.ListJobs(), 3)
+	dcr.Start()
+	defer dcr.Stop()
+	<-time.After(1500 * time.Millisecond)
+	s.Assert().GreaterOrEqual(runs.Load(), int32(2))
+}
+
+func (s *DcronLocallyTestSuite) TestReloadJobs() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithSeconds())
+	var calls sync.Map
+	record := func(name string) func() {
+		return func() { calls.Store(name, true) }
+	}
+	s.Require().Nil(dcr.AddFunc("kept", "0 0 0 1 1 *", record("kept-old")))
+	s.Require().Nil(dcr.AddFunc("removed", "0 0 0 1 1 *", record("removed")))
+	s.Require().Nil(dcr.PauseJob("kept"))
+
+	// an invalid set changes nothing.
+	err := dcr.ReloadJobs([]dcron.JobSpec{
+		{Name: "kept", CronSpec: "* * * * * *", Func: record("kept")},
+		{Name: "bad", CronSpec: "* * * * *", Func: record("bad")},
+		{Name: "kept", CronSpec: "@hourly", Func: record("kept")},
+	})
+	var addErr *dcron.AddJobsError
+	s.Require().ErrorAs(err, &addErr)
+	s.Assert().ErrorIs(err, dcron.ErrInvalidCronSpec)
+	s.Assert().ErrorIs(err, dcron.ErrJobExist)
+	s.Require().Len(dcr.ListJobs(), 2)
+	s.Assert().Equal("0 0 0 1 1 *", dcr.ListJobs()[0].CronStr)
+
+	s.Require().Nil(dcr.ReloadJobs([]dcron.JobSpec{
+		{Name: "kept", CronSpec: "@every 1s", Func: record("kept")},
+		{Name: "new", CronSpec: "0 0 0 1 1 *", Func: record("new")},
+	}))
+	jobs := dcr.ListJobs()
+	s.Require().Len(jobs, 2)
+	s.Assert().Equal("kept", jobs[0].Name)
+	s.Assert().Equal("@every 1s", jobs[0].CronStr)
+	// the state of a replaced job is kept.
+	s.Assert().True(jobs[0].Paused)
+	s.Assert().Equal("new", jobs[1].Name)
+	s.Assert().False(dcr.HasJob("removed"))
+
+	s.Require().Nil(dcr.ResumeJob("kept"))
+	s.Require().Nil(dcr.TriggerJob("kept"))
+	s.Require().Nil(dcr.TriggerJob("new"))
+	_, ok := calls.Load("kept")
+	s.Assert().True(ok)
+	_, ok = calls.Load("kept-old")
+	s.Assert().False(ok)
+	_, ok = calls.Load("new")
+	s.Assert().True(ok)
+}
+
+func (s *DcronLocallyTestSuite) TestReloadJobsKeepsTimezone() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionLocation(time.UTC))
+	shanghai := time.FixedZone("UTC+8", 8*60*60)
+	s.Require().Nil(dcr.AddJobWithTimezone("tz", "0 9 * * *", shanghai, func() {}))
+	s.Require().Nil(dcr.AddJobWithContext("context", "0 9 * * *", func(ctx context.Context) {}))
+
+	// a job which ReplaceJob rejects is not reloaded either.
+	err := dcr.ReloadJobs([]dcron.JobSpec{
+		{Name: "tz", CronSpec: "0 10 * * *", Func: func() {}},
+		{Name: "context", CronSpec: "0 10 * * *", Func: func() {}},
+	})
+	s.Assert().ErrorIs(err, dcron.ErrJobNotReplaceable)
+	s.Assert().Equal("0 9 * * *", dcr.ListJobs()[1].CronStr)
+	// the spec of a job with a time zone can not have its own.
+	err = dcr.ReloadJobs([]dcron.JobSpec{
+		{Name: "tz", CronSpec: "CRON_TZ=UTC 0 10 * * *", Func: func() {}},
+	})
+	s.Assert().ErrorIs(err, dcron.ErrInvalidCronSpec)
+
+	s.Require().Nil(dcr.ReloadJobs([]dcron.JobSpec{
+		{Name: "tz", CronSpec: "0 10 * * *", Func: func() {}},
+	}))
+	entries := dcr.Cron().Entries()
+	s.Require().Len(entries, 1)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	next := entries[0].Schedule.Next(from)
+	s.Assert().True(time.Date(2024, 1, 1, 10, 0, 0, 0, shanghai).Equal(next), next)
+}
+
+func (s *DcronLocallyTestSuite) TestCron() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionLocation(time.UTC))
+	id, err := dcr.AddJob("job1", "@hourly", cron.FuncJob(func() {}))
+	s.Require().Nil(err)
+	entries := dcr.Cron().Entries()
+	s.Require().Len(entries, 1)
+	s.Assert().Equal(id, entries[0].ID)
+	s.Assert().Equal("job1", cron.JobName(entries[0].Job))
+	s.Assert().Equal(time.UTC, dcr.Cron().Location())
+}
+
+func (s *DcronLocallyTestSuite) TestMaintenanceWindow() {
+	clock := testclock.New(time.Date(2024, 1, 1, 2, 30, 0, 0, time.UTC))
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithClock(clock),
+		dcron.CronOptionLocation(time.UTC),
+		dcron.WithMaintenanceWindow("0 2 * * *", time.Hour),
+		dcron.WithMaintenanceCatchUp())
+	s.Require().Nil(dcr.Err())
+	runs := make(chan time.Time, 10)
+	s.Require().Nil(dcr.AddJobWithTime("job1", "0 0 1 1 *", func(scheduled time.Time) {
+		runs <- scheduled
+	}))
+	dcr.Start()
+	defer dcr.Stop()
+
+	// the runs in the window are skipped, and catch up once after it.
+	s.Require().Nil(dcr.TriggerJob("job1"))
+	s.Require().Nil(dcr.TriggerJob("job1"))
+	s.Assert().Len(runs, 0)
+	// the scheduler and the catch-up are waiting.
+	clock.BlockUntil(2)
+	clock.Advance(29 * time.Minute)
+	s.Assert().Len(runs, 0)
+	clock.Advance(time.Minute)
+	select {
+	case scheduled := <-runs:
+		// the catch-up has the scheduled time of the first skipped run.
+		s.Assert().True(scheduled.Equal(time.Date(2024, 1, 1, 2, 30, 0, 0, time.UTC)), scheduled)
+	case <-time.After(time.Second):
+		s.FailNow("the skipped run does not catch up")
+	}
+	s.Require().Nil(dcr.TriggerJob("job1"))
+	s.Assert().Len(runs, 1)
+
+	dcr = dcron.NewDcronWithOption("not a necessary servername", nil,
+		dcron.RunningLocally(), dcron.WithMaintenanceWindow("0 2 * * *", 0))
+	s.Assert().ErrorIs(dcr.Err(), dcron.ErrInvalidMaintenanceWindow)
+	dcr = dcron.NewDcronWithOption("not a necessary servername", nil,
+		dcron.RunningLocally(), dcron.WithMaintenanceWindow("0 0 2 * * *", time.Hour))
+	s.Assert().ErrorIs(dcr.Err(), dcron.ErrInvalidCronSpec)
+}
+
+func (s *DcronLocallyTestSuite) TestJobGroups() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally())
+	var reports, cleanups atomic.Int32
+	s.Require().Nil(dcr.AddJobToGroup("reporting", "report1", "0 0 1 1 *", func() { reports.Add(1) }))
+	s.Require().Nil(dcr.AddJobToGroup("reporting", "report2", "0 0 1 1 *", func() { reports.Add(1) }))
+	s.Require().Nil(dcr.AddJobToGroup("cleanup", "cleanup1", "0 0 1 1 *", func() { cleanups.Add(1) }))
+	s.Require().Nil(dcr.AddFunc("plain", "0 0 1 1 *", func() {}))
+	s.Assert().Equal(dcron.ErrEmptyGroup, dcr.AddJobToGroup("", "job", "0 0 1 1 *", func() {}))
+	// the failed adds do not tag any job.
+	s.Assert().Equal(dcron.ErrJobExist, dcr.AddJobToGroup("other", "plain", "0 0 1 1 *", func() {}))
+	s.Assert().ErrorIs(dcr.AddJobToGroup("broken", "bad", "invalid", func() {}), dcron.ErrInvalidCronSpec)
+	s.Assert().Equal([]string{"cleanup", "reporting"}, dcr.ListGroups())
+	// cleanup1, plain, report1 and report2.
+	s.Assert().Equal("", dcr.ListJobs()[1].Group)
+	s.Assert().Equal("reporting", dcr.ListJobs()[2].Group)
+
+	s.Require().Nil(dcr.PauseGroup("reporting"))
+	paused, err := dcr.IsGroupPaused("reporting")
+	s.Require().Nil(err)
+	s.Assert().True(paused)
+	for _, jobName := range []string{"report1", "report2", "cleanup1"} {
+		s.Require().Nil(dcr.TriggerJob(jobName))
+	}
+	s.Assert().Equal(int32(0), reports.Load())
+	s.Assert().Equal(int32(1), cleanups.Load())
+
+	s.Require().Nil(dcr.ResumeGroup("reporting"))
+	s.Require().Nil(dcr.TriggerJob("report1"))
+	s.Assert().Equal(int32(1), reports.Load())
+
+	s.Assert().Equal(dcron.ErrGroupNotExist, dcr.PauseGroup("not_exist"))
+	s.Require().Nil(dcr.RemoveJob("cleanup1"))
+	s.Assert().Equal([]string{"reporting"}, dcr.ListGroups())
+	s.Assert().Equal(dcron.ErrGroupNotExist, dcr.ResumeGroup("cleanup"))
+}
+
+func (s *DcronLocallyTestSuite) TestJobStatus() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionChain(cron.Recover(cron.DiscardLogger)))
+
+	errFailed := errors.New("failed")
+	var fail atomic.Bool
+	fail.Store(true)
+	s.Require().Nil(dcr.AddFuncWithError("job", "0 0 1 1 *", func() error {
+		time.Sleep(10 * time.Millisecond)
+		if fail.Load() {
+			return errFailed
+		}
+		return nil
+	}))
+	s.Require().Nil(dcr.AddFunc("panic", "0 0 1 1 *", func() { panic("test panic") }))
+	_, ok := dcr.JobStatus("job")
+	s.Assert().False(ok)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		s.Assert().Equal(errFailed, dcr.TriggerJob("job"))
+	}
+	status, ok := dcr.JobStatus("job")
+	s.Require().True(ok)
+	s.Assert().Equal(errFailed, status.LastError)
+	s.Assert().Equal(3, status.ConsecutiveFailures)
+	s.Assert().True(status.LastStartTime.After(start))
+	s.Assert().GreaterOrEqual(status.LastDuration, 10*time.Millisecond)
+
+	fail.Store(false)
+	s.Assert().Nil(dcr.TriggerJob("job"))
+	status, _ = dcr.JobStatus("job")
+	s.Assert().Nil(status.LastError)
+	s.Assert().Equal(0, status.ConsecutiveFailures)
+
+	s.Assert().Nil(dcr.TriggerJob("panic"))
+	status, ok = dcr.JobStatus("panic")
+	s.Require().True(ok)
+	s.Assert().ErrorIs(status.LastError, dcron.ErrJobPanic)
+	s.Assert().Equal(1, status.ConsecutiveFailures)
+
+	s.Require().Nil(dcr.RemoveJob("job"))
+	_, ok = dcr.JobStatus("job")
+	s.Assert().False(ok)
+}
+
+func (s *DcronLocallyTestSuite) TestJobHistory() {
+	newDcron := func(opts ...dcron.Option) *dcron.Dcron {
+		dcr := dcron.NewDcronWithOption(
+			"not a necessary servername",
+			nil,
+			append(opts, dcron.RunningLocally())...)
+		var runs int
+		s.Require().Nil(dcr.AddFuncWithError("job", "0 0 1 1 *", func() error {
+			runs++
+			return fmt.Errorf("run %d", runs)
+		}))
+		return dcr
+	}
+
+	dcr := newDcron()
+	s.Assert().NotNil(dcr.TriggerJob("job"))
+	s.Assert().Nil(dcr.JobHistory("job"))
+
+	dcr = newDcron(dcron.WithHistorySize(3))
+	s.Assert().Nil(dcr.JobHistory("job"))
+	s.Assert().NotNil(dcr.TriggerJob("job"))
+	history := dcr.JobHistory("job")
+	s.Require().Len(history, 1)
+	s.Assert().EqualError(history[0].Error, "run 1")
+
+	for i := 0; i < 4; i++ {
+		s.Assert().NotNil(dcr.TriggerJob("job"))
+	}
+	history = dcr.JobHistory("job")
+	s.Require().Len(history, 3)
+	for i, e := range history {
+		s.Assert().EqualError(e.Error, fmt.Sprintf("run %d", i+3))
+		s.Assert().False(e.StartTime.IsZero())
+		if i > 0 {
+			s.Assert().False(e.StartTime.Before(history[i-1].StartTime))
+		}
+	}
+
+	// the returned history is a copy.
+	history[0].Error = nil
+	s.Assert().NotNil(dcr.JobHistory("job")[0].Error)
+}
+
+func (s *DcronLocallyTestSuite) TestCronSpecs() {
+	cases := []struct {
+		spec        string
+		withSeconds bool
+		valid       bool
+	}{
+		{"@every 1m", false, true},
+		{"@every 1m", true, true},
+		{"@every 30s", false, true},
+		{"@hourly", true, true},
+		{"@midnight", false, true},
+		{"@midnight", true, true},
+		{"*/5 * * * * *", true, true},
+		{"*/5 * * * * *", false, false},
+		{"*/5 * * * *", false, true},
+		{"*/5 * * * *", true, false},
+	}
+	for _, c := range cases {
+		opts := []dcron.Option{dcron.RunningLocally()}
+		if c.withSeconds {
+			opts = append(opts, dcron.WithSeconds())
+		}
+		dcr := dcron.NewDcronWithOption("not a necessary servername", nil, opts...)
+		err := dcr.AddFunc("job", c.spec, func() {})
+		if c.valid {
+			s.Assert().Nil(err, "spec=%q, withSeconds=%v", c.spec, c.withSeconds)
+		} else {
+			s.Assert().ErrorIs(err, dcron.ErrInvalidCronSpec, "spec=%q, withSeconds=%v", c.spec, c.withSeconds)
+		}
+	}
+}
+
+func TestSimulateSchedule(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		spec string
+		opts []dcron.Option
+		want []time.Time
+	}{
+		{"@every 1m", nil, []time.Time{from.Add(time.Minute), from.Add(2 * time.Minute)}},
+		{"@midnight", []dcron.Option{dcron.CronOptionLocation(time.UTC)}, []time.Time{from.Add(14 * time.Hour), from.Add(38 * time.Hour)}},
+		{"*/5 * * * * *", []dcron.Option{dcron.WithSeconds()},
+			[]time.Time{from.Add(5 * time.Second), from.Add(10 * time.Second)}},
+		{"0 30 9 * * *", []dcron.Option{dcron.WithSeconds(), dcron.CronOptionLocation(time.UTC)},
+			[]time.Time{from.Add(23*time.Hour + 30*time.Minute), from.Add(47*time.Hour + 30*time.Minute)}},
+		{"0 0 30 2 *", nil, []time.Time{}},
+	}
+	for _, c := range cases {
+		got, err := dcron.SimulateSchedule(c.spec, from, 2, c.opts...)
+		require.Nil(t, err, c.spec)
+		require.Len(t, got, len(c.want), c.spec)
+		for i := range got {
+			require.True(t, c.want[i].Equal(got[i]), "spec=%q, want %v, got %v", c.spec, c.want[i], got[i])
+		}
+	}
+	// the spec fires in the location of the Dcron, not of from.
+	shanghai := time.FixedZone("UTC+8", 8*60*60)
+	got, err := dcron.SimulateSchedule("0 3 * * *", from.In(shanghai), 2, dcron.WithLocation(time.UTC))
+	require.Nil(t, err)
+	require.Len(t, got, 2)
+	require.True(t, from.Add(17*time.Hour).Equal(got[0]), got[0])
+	require.True(t, from.Add(41*time.Hour).Equal(got[1]), got[1])
+	next, err := dcron.NextRun("0 3 * * *", from.In(shanghai), dcron.WithLocation(time.UTC))
+	require.Nil(t, err)
+	require.True(t, next.Equal(got[0]), next)
+
+	_, err = dcron.SimulateSchedule("*/5 * * * * *", from, 2)
+	require.ErrorIs(t, err, dcron.ErrInvalidCronSpec)
+	dcr := dcron.NewDcronWithOption("not a necessary servername", nil, dcron.RunningLocally())
+	require.Equal(t, "invalid cron spec '*/5 * * * * *' of job 'job': expected exactly 5 fields, found 6: [*/5 * * * * *]",
+		dcr.AddFunc("job", "*/5 * * * * *", func() {}).Error())
+	require.Equal(t, "invalid cron spec '*/5 * * * * *': expected exactly 5 fields, found 6: [*/5 * * * * *]", err.Error())
+}
+
+func TestNextRun(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	shanghai := time.FixedZone("UTC+8", 8*60*60)
+
+	next, err := dcron.NextRun("*/5 * * * * *", from, dcron.WithSeconds())
+	require.Nil(t, err)
+	require.True(t, from.Add(5*time.Second).Equal(next), next)
+	// the midnight of the location of the Dcron, not of from.
+	next, err = dcron.NextRun("@midnight", from, dcron.CronOptionLocation(shanghai))
+	require.Nil(t, err)
+	require.True(t, from.Add(6*time.Hour).Equal(next), next)
+	next, err = dcron.NextRun("0 0 30 2 *", from)
+	require.Nil(t, err)
+	require.True(t, next.IsZero())
+	_, err = dcron.NextRun("*/5 * * * * *", from)
+	require.ErrorIs(t, err, dcron.ErrInvalidCronSpec)
+
+	dcr := dcron.NewDcronWithOption("not a necessary servername", nil,
+		dcron.RunningLocally(), dcron.WithSeconds(), dcron.CronOptionLocation(shanghai))
+	next, err = dcr.NextRun("0 0 0 * * *", from)
+	require.Nil(t, err)
+	require.True(t, from.Add(6*time.Hour).Equal(next), next)
+	_, err = dcr.NextRun("0 0 * * *", from)
+	require.ErrorIs(t, err, dcron.ErrInvalidCronSpec)
+}
+
+func TestWithLocation(t *testing.T) {
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, local := range []*time.Location{time.FixedZone("UTC-5", -5*60*60), time.FixedZone("UTC+8", 8*60*60)} {
+		// the time zone of the host.
+		time.Local = local
+		next, err := dcron.NextRun("0 3 * * *", from, dcron.WithLocation(time.UTC))
+		require.Nil(t, err)
+		require.True(t, from.Add(17*time.Hour).Equal(next), next)
+
+		dcr := dcron.NewDcronWithOption("not a necessary servername", nil,
+			dcron.RunningLocally(), dcron.WithLocation(time.UTC))
+		require.Nil(t, dcr.AddFunc("job", "0 3 * * *", func() {}))
+		require.Nil(t, dcr.Start())
+		next = dcr.ListJobs()[0].Next.In(time.UTC)
+		dcr.Stop()
+		require.Equal(t, 3, next.Hour(), next)
+		require.Equal(t, 0, next.Minute(), next)
+	}
+	require.Equal(t, dcron.ErrNilLocation,
+		dcron.NewDcronWithOption("not a necessary servername", nil, dcron.RunningLocally(), dcron.WithLocation(nil)).Err())
+}
+
+func TestTruncatedDedupKey(t *testing.T) {
+	keyFn := dcron.TruncatedDedupKey(time.Minute)
+	at := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	require.Equal(t, keyFn("job", at), keyFn("job", at.Add(59*time.Second)))
+	require.NotEqual(t, keyFn("job", at), keyFn("job", at.Add(time.Minute)))
+	require.NotEqual(t, keyFn("job", at), keyFn("job2", at))
+}
+
+func TestInvalidCronSpecField(t *testing.T) {
+	dcr := dcron.NewDcronWithOption("not a necessary servername", nil, dcron.RunningLocally())
+	for _, c := range []struct {
+		spec, field string
+	}{
+		{"61 * * * *", "minute"},
+		{"* 24 * * *", "hour"},
+		{"* * 0 * *", "dom"},
+		{"* * * 13 *", "month"},
+		{"* * * * 8", "dow"},
+		{"* * * * foo", "dow"},
+	} {
+		err := dcr.AddFunc("job", c.spec, func() {})
+		require.ErrorIs(t, err, dcron.ErrInvalidCronSpec, c.spec)
+		var fieldErr *cron.FieldError
+		require.ErrorAs(t, err, &fieldErr, c.spec)
+		require.Equal(t, c.field, fieldErr.Field, c.spec)
+		require.Contains(t, err.Error(), "of job 'job'", c.spec)
+		require.Contains(t, err.Error(), "invalid "+c.field+" field '"+fieldErr.Value+"'", c.spec)
+	}
+	require.Equal(t, "invalid cron spec '61 * * * *' of job 'job': invalid minute field '61': end of range (61) above maximum (59): 61",
+		dcr.AddFunc("job", "61 * * * *", func() {}).Error())
+}
+
+func TestValidateSpec(t *testing.T) {
+	require.Nil(t, dcron.ValidateSpec("*/5 * * * *"))
+	require.Nil(t, dcron.ValidateSpec("@every 30s"))
+	require.Nil(t, dcron.ValidateSpec("*/5 * * * * *", dcron.WithSeconds()))
+	require.ErrorIs(t, dcron.ValidateSpec("*/5 * * * * *"), dcron.ErrInvalidCronSpec)
+	require.ErrorIs(t, dcron.ValidateSpec("61 * * * *"), dcron.ErrInvalidCronSpec)
+
+	dcr := dcron.NewDcronWithOption("not a necessary servername", nil, dcron.RunningLocally(), dcron.WithSeconds())
+	require.Nil(t, dcr.ValidateSpec("*/5 * * * * *"))
+	err := dcr.ValidateSpec("*/5 * * * *")
+	require.ErrorIs(t, err, dcron.ErrInvalidCronSpec)
+	require.ErrorIs(t, dcr.AddFunc("job", "*/5 * * * *", func() {}), dcron.ErrInvalidCronSpec)
+	require.Empty(t, dcr.ListJobs())
+}
+
+// everyMinuteParser is a custom parser, which is not a cron.Parser.
+type everyMinuteParser struct{}
+
+func (everyMinuteParser) Parse(spec string) (cron.Schedule, error) {
+	return cron.Every(time.Minute), nil
+}
+
+func TestParserInfo(t *testing.T) {
+	info := dcron.NewDcronWithOption("not a necessary servername", nil, dcron.RunningLocally()).ParserInfo()
+	require.Equal(t, []string{"minute", "hour", "dom", "month", "dow"}, info.Fields)
+	require.False(t, info.Seconds)
+	require.Empty(t, info.Optional)
+	require.Contains(t, info.Descriptors, "@every <duration>")
+	require.False(t, info.Custom)
+
+	info = dcron.NewDcronWithOption("not a necessary servername", nil, dcron.RunningLocally(), dcron.WithSeconds()).ParserInfo()
+	require.Len(t, info.Fields, 6)
+	require.True(t, info.Seconds)
+
+	parser := cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	info = dcron.NewDcronWithOption("not a necessary servername", nil, dcron.RunningLocally(), dcron.WithParser(parser)).ParserInfo()
+	require.Equal(t, dcron.ParserInfo{
+		Fields:   []string{"second", "minute", "hour", "dom", "month", "dow"},
+		Optional: "second",
+		Seconds:  true,
+	}, info)
+
+	info = dcron.NewDcronWithOption("not a necessary servername", nil, dcron.RunningLocally(), dcron.WithParser(everyMinuteParser{})).ParserInfo()
+	require.Equal(t, dcron.ParserInfo{Custom: true}, info)
+}
+
+func (s *DcronLocallyTestSuite) TestAddOnceJob() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithNodeUpdateDuration(50*time.Millisecond))
+
+	var runs atomic.Int32
+	s.Require().Nil(dcr.AddOnceJob("init", func() { runs.Add(1) }))
+	s.Require().Nil(dcr.AddOnceJob("panic", func() { panic("test panic") }))
+	dcr.Start()
+	<-time.After(200 * time.Millisecond)
+	dcr.Stop()
+	s.Assert().Equal(int32(1), runs.Load())
+}
+
+func (s *DcronLocallyTestSuite) TestJobJitterInterruptedByStop() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionSeconds(),
+		dcron.WithJobJitter(10*time.Second))
+
+	var runs atomic.Int32
+	// the jitter of "jitter" is 9.7s.
+	s.Require().Nil(dcr.AddFunc("jitter", "* * * * * *", func() { runs.Add(1) }))
+	dcr.Start()
+	<-time.After(2 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Assert().Nil(dcr.StopWait(ctx))
+	<-time.After(100 * time.Millisecond)
+	s.Assert().Equal(int32(0), runs.Load())
+}
+
+func (s *DcronLocallyTestSuite) TestRemoveJob() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionSeconds())
+
+	var called atomic.Int32
+	s.Require().Nil(dcr.AddFunc("job1", "* * * * * *", func() {
+		called.Add(1)
+	}))
+	s.Assert().True(dcr.HasJob("job1"))
+	dcr.Start()
+	defer dcr.Stop()
+	<-time.After(1500 * time.Millisecond)
+	s.Require().Nil(dcr.RemoveJob("job1"))
+	s.Assert().False(dcr.HasJob("job1"))
+	s.Assert().Equal(dcron.ErrJobNotExist, dcr.RemoveJob("job1"))
+	calledAfterRemove := called.Load()
+	<-time.After(2 * time.Second)
+	s.Assert().Equal(calledAfterRemove, called.Load())
+	// the job name can be added again after it is removed.
+	s.Assert().Nil(dcr.AddFunc("job1", "* * * * * *", func() {}))
+}
+
+func (s *DcronLocallyTestSuite) TestListJobs() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionSeconds())
+
+	s.Require().Nil(dcr.AddFunc("job2", "*/5 * * * * *", func() {}))
+	s.Require().Nil(dcr.AddFunc("job1", "* * * * * *", func() {}))
+	jobs := dcr.ListJobs()
+	s.Require().Len(jobs, 2)
+	s.Assert().Equal("job1", jobs[0].Name)
+	s.Assert().Equal("* * * * * *", jobs[0].CronStr)
+	s.Assert().True(jobs[0].Next.IsZero())
+	s.Assert().Equal("job2", jobs[1].Name)
+
+	dcr.Start()
+	defer dcr.Stop()
+	jobs = dcr.ListJobs()
+	s.Require().Len(jobs, 2)
+	for _, job := range jobs {
+		s.Assert().True(job.Owned)
+		s.Assert().True(job.Next.After(time.Now()))
+	}
+}
+
+func (s *DcronLocallyTestSuite) TestAddJobWithTimezone() {
+	loc, err := time.LoadLocation("America/New_York")
+	s.Require().Nil(err)
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionLocation(time.UTC))
+
+	s.Require().Nil(dcr.AddJobWithTimezone("job1", "0 9 * * *", loc, func() {}))
+	s.Assert().Equal(dcron.ErrNilLocation, dcr.AddJobWithTimezone("job2", "0 9 * * *", nil, func() {}))
+	s.Assert().NotNil(dcr.AddJobWithTimezone("job3", "CRON_TZ=UTC 0 9 * * *", loc, func() {}))
+	s.Assert().False(dcr.HasJob("job3"))
+
+	dcr.Start()
+	defer dcr.Stop()
+	jobs := dcr.ListJobs()
+	s.Require().Len(jobs, 1)
+	next := jobs[0].Next.In(loc)
+	s.Assert().Equal(9, next.Hour())
+	s.Assert().Equal(0, next.Minute())
+}
+
+func (s *DcronLocallyTestSuite) TestPauseAndResumeJob() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionSeconds())
+
+	var called atomic.Int32
+	s.Require().Nil(dcr.AddFunc("job1", "* * * * * *", func() {
+		called.Add(1)
+	}))
+	s.Require().Nil(dcr.PauseJob("job1"))
+	s.Assert().Equal(dcron.ErrJobNotExist, dcr.PauseJob("not_exist"))
+	paused, err := dcr.IsJobPaused("job1")
+	s.Require().Nil(err)
+	s.Assert().True(paused)
+	s.Assert().True(dcr.ListJobs()[0].Paused)
+
+	dcr.Start()
+	defer dcr.Stop()
+	<-time.After(2 * time.Second)
+	s.Assert().Equal(int32(0), called.Load())
+
+	s.Require().Nil(dcr.ResumeJob("job1"))
+	<-time.After(2 * time.Second)
+	s.Assert().Greater(called.Load(), int32(0))
+}
+
+func (s *DcronLocallyTestSuite) TestNodeUpdateDurationValidation() {
+	for _, tc := range []struct {
+		updateDuration, heartbeatTTL time.Duration
+		ok                           bool
+	}{
+		{time.Second, time.Second, true},
+		{time.Second, 3 * time.Second, true},
+		{0, time.Second, false},
+		{-time.Second, time.Second, false},
+		{time.Second, time.Nanosecond, false},
+		{3 * time.Second, time.Second, false},
+	} {
+		err := dcron.ValidateNodeUpdateDuration(tc.updateDuration, tc.heartbeatTTL)
+		if tc.ok {
+			s.Assert().Nil(err, "%v/%v", tc.updateDuration, tc.heartbeatTTL)
+		} else {
+			s.Assert().ErrorIs(err, dcron.ErrUnsafeNodeUpdateDuration, "%v/%v", tc.updateDuration, tc.heartbeatTTL)
+		}
+	}
+	s.Assert().Equal(5*time.Second, dcron.FailoverWindow(time.Second, 3*time.Second))
+
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithNodeUpdateDuration(0))
+	s.Assert().ErrorIs(dcr.Err(), dcron.ErrUnsafeNodeUpdateDuration)
+	s.Assert().ErrorIs(dcr.Start(), dcron.ErrUnsafeNodeUpdateDuration)
+	s.Assert().ErrorIs(dcr.HealthCheck(), dcron.ErrDcronNotRunning)
+
+	// a too short duration is raised to the minimum.
+	dcr = dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithNodeUpdateDuration(time.Nanosecond))
+	s.Assert().Nil(dcr.Err())
+}
+
+func (s *DcronLocallyTestSuite) TestHeartbeatTTLValidation() {
+	for _, tc := range []struct {
+		updateDuration, heartbeatTTL time.Duration
+		ok                           bool
+	}{
+		{2 * time.Second, 15 * time.Second, true},
+		{2 * time.Second, 2 * time.Second, false},
+		{2 * time.Second, time.Second, false},
+		{2 * time.Second, -time.Second, false},
+	} {
+		dcr := dcron.NewDcronWithOption(
+			"not a necessary servername",
+			nil,
+			dcron.RunningLocally(),
+			dcron.WithNodeUpdateDuration(tc.updateDuration),
+			dcron.WithHeartbeatTTL(tc.heartbeatTTL))
+		if tc.ok {
+			s.Assert().Nil(dcr.Err(), "%v/%v", tc.updateDuration, tc.heartbeatTTL)
+		} else {
+			s.Assert().ErrorIs(dcr.Err(), dcron.ErrUnsafeNodeUpdateDuration, "%v/%v", tc.updateDuration, tc.heartbeatTTL)
+		}
+	}
+}
+
+func (s *DcronLocallyTestSuite) TestAddJobWithWrappers() {
+	var mu sync.Mutex
+	calls := make([]string, 0)
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+	wrapper := func(name string) cron.JobWrapper {
+		return func(j cron.Job) cron.Job {
+			return cron.FuncErrorJob(func() error {
+				record(name + ":" + cron.JobName(j))
+				j.Run()
+				return nil
+			})
+		}
+	}
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionChain(wrapper("global")))
+	s.Require().Nil(dcr.AddJobWithWrappers("job1", "* * * * *", func() { record("run") },
+		wrapper("job"), cron.Recover(cron.DefaultLogger)))
+	s.Require().Nil(dcr.AddJobWithWrappers("job2", "* * * * *", func() { panic("job2 panic") },
+		cron.Recover(cron.DefaultLogger)))
+	s.Require().Nil(dcr.AddFunc("job3", "* * * * *", func() { record("run") }))
+	s.Assert().Equal(dcron.ErrJobExist, dcr.AddJobWithWrappers("job1", "* * * * *", func() {}))
+
+	s.Require().Nil(dcr.TriggerJob("job1"))
+	s.Assert().Equal([]string{"global:job1", "job:job1", "run"}, calls)
+	calls = calls[:0]
+	s.Assert().NotPanics(func() { _ = dcr.TriggerJob("job2") })
+	s.Assert().Equal([]string{"global:job2"}, calls)
+	calls = calls[:0]
+	s.Require().Nil(dcr.TriggerJob("job3"))
+	s.Assert().Equal([]string{"global:job3", "run"}, calls)
+}
+
+func (s *DcronLocallyTestSuite) TestWithClock() {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := testclock.New(start)
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithClock(clock),
+		dcron.WithJobJitter(10*time.Second),
+		dcron.CronOptionLocation(time.UTC))
+	fired := make(chan time.Time, 1)
+	s.Require().Nil(dcr.AddFunc("job", "* * * * *", func() { fired <- clock.Now() }))
+	dcr.Start()
+	defer dcr.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	// the scheduler waits for the next minute, and the job for its jitter.
+	clock.BlockUntil(2)
+	select {
+	case <-fired:
+		s.FailNow("fired before the jitter")
+	case <-time.After(50 * time.Millisecond):
+	}
+	clock.Advance(10 * time.Second)
+	select {
+	case now := <-fired:
+		s.Assert().Equal(start.Add(70*time.Second), now)
+	case <-time.After(time.Second):
+		s.FailNow("not fired after the jitter")
+	}
+	s.Require().Eventually(func() bool {
+		_, ok := dcr.JobStatus("job")
+		return ok
+	}, time.Second, 10*time.Millisecond)
+	status, _ := dcr.JobStatus("job")
+	s.Assert().Equal(start.Add(70*time.Second), status.LastStartTime)
+}
+
+func (s *DcronLocallyTestSuite) TestReplaceJob() {
+	start := time.Date(2024, 1, 1, 0, 0, 30, 0, time.UTC)
+	clock := testclock.New(start)
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithClock(clock),
+		dcron.CronOptionLocation(time.UTC))
+	fired := make(chan string, 2)
+	id, err := dcr.AddJob("job", "* * * * *", cron.FuncJob(func() { fired <- "old" }))
+	s.Require().Nil(err)
+	// the same name is rejected, instead of running twice.
+	_, err = dcr.AddJob("job", "* * * * *", cron.FuncJob(func() { fired <- "duplicated" }))
+	s.Assert().Equal(dcron.ErrJobExist, err)
+	s.Assert().Len(dcr.ListJobs(), 1)
+
+	dcr.Start()
+	defer dcr.Stop()
+	clock.BlockUntil(1)
+	s.Require().Nil(dcr.ReplaceJob("job", "*/2 * * * *", func() { fired <- "new" }))
+	s.Assert().ErrorIs(dcr.ReplaceJob("job", "bad spec", func() {}), dcron.ErrInvalidCronSpec)
+	s.Assert().Equal(dcron.ErrJobNotExist, dcr.ReplaceJob("not_exist", "* * * * *", func() {}))
+	s.Assert().Equal("*/2 * * * *", dcr.ListJobs()[0].CronStr)
+	s.Assert().Equal(start.Add(90*time.Second), dcr.Entry(id).Next)
+
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Second)
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	select {
+	case run := <-fired:
+		s.Assert().Equal("new", run)
+	case <-time.After(time.Second):
+		s.FailNow("the replaced job is not fired")
+	}
+	s.Assert().Empty(fired)
+}
+
+func (s *DcronLocallyTestSuite) TestReplaceJobNotPlain() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally())
+	s.Require().Nil(dcr.AddJobWithContext("context", "* * * * *", func(ctx context.Context) {}))
+	s.Require().Nil(dcr.AddJobWithTime("time", "* * * * *", func(time.Time) {}))
+	s.Require().Nil(dcr.AddJobWithLogger("logger", "* * * * *", func() {}, dlog.NewLoggerForTest(s.T())))
+	s.Require().Nil(dcr.AddJobWithWrappers("wrappers", "* * * * *", func() {}, cron.SkipIfStillRunning(cron.DiscardLogger)))
+	s.Require().Nil(dcr.AddJobWithMaxRuns("maxruns", "* * * * *", 1, func() {}))
+	s.Require().Nil(dcr.AddAdaptiveJob("adaptive", "* * * * *", func() *time.Duration { return nil }))
+	for _, jobName := range []string{"context", "time", "logger", "wrappers", "maxruns", "adaptive"} {
+		s.Assert().Equal(dcron.ErrJobNotReplaceable, dcr.ReplaceJob(jobName, "*/2 * * * *", func() {}), jobName)
+		job, err := dcr.GetJob(jobName, false)
+		s.Require().Nil(err)
+		s.Assert().Equal("* * * * *", job.CronStr, jobName)
+	}
+	s.Require().Nil(dcr.AddFunc("plain", "* * * * *", func() {}))
+	s.Assert().Nil(dcr.ReplaceJob("plain", "*/2 * * * *", func() {}))
+}
+
+func (s *DcronLocallyTestSuite) TestNewDcronWithContext() {
+	ctx, cancel := context.WithCancel(context.Background())
+	dcr := dcron.NewDcronWithContext(ctx,
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithSeconds())
+	started := make(chan struct{}, 1)
+	s.Require().Nil(dcr.AddJobWithContext("job", "* * * * * *", func(ctx context.Context) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+	}))
+	dcr.Start()
+	<-started
+	cancel()
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Second)
+	defer stopCancel()
+	s.Require().Nil(dcr.StopWait(stopCtx))
+	s.Assert().Equal(dcron.ErrDcronNotRunning, dcr.HealthCheck())
+}
+
+func (s *DcronLocallyTestSuite) TestWithParser() {
+	parser := cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithParser(parser))
+	s.Require().Nil(dcr.Err())
+	s.Assert().Nil(dcr.AddFunc("minutely", "* * * * *", func() {}))
+	s.Assert().Nil(dcr.AddFunc("secondly", "*/5 * * * * *", func() {}))
+	// the parser does not accept the descriptors.
+	s.Assert().ErrorIs(dcr.AddFunc("hourly", "@hourly", func() {}), dcron.ErrInvalidCronSpec)
+	s.Assert().ErrorIs(dcron.ValidateSpec("@hourly", dcron.WithParser(parser)), dcron.ErrInvalidCronSpec)
+
+	dcr = dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithParser(nil))
+	s.Assert().Equal(dcron.ErrNilParser, dcr.Err())
+	dcr.Start()
+	s.Assert().ErrorIs(dcr.HealthCheck(), dcron.ErrDcronNotRunning)
+	s.Assert().Equal(dcron.ErrNilParser, dcron.ValidateSpec("* * * * *", dcron.WithParser(nil)))
+}
+
+func (s *DcronLocallyTestSuite) TestAddJobRunOnStart() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally())
+	ran := make(chan string, 2)
+	s.Require().Nil(dcr.AddJobRunOnStart("onStart", "0 0 1 1 *", func() { ran <- "onStart" }))
+	s.Require().Nil(dcr.AddFunc("scheduled", "0 0 1 1 *", func() { ran <- "scheduled" }))
+	s.Assert().Equal(dcron.ErrJobExist, dcr.AddJobRunOnStart("scheduled", "0 0 1 1 *", func() {}))
+	dcr.Start()
+	defer dcr.Stop()
+	select {
+	case name := <-ran:
+		s.Assert().Equal("onStart", name)
+	case <-time.After(time.Second):
+		s.FailNow("not run on start")
+	}
+	select {
+	case name := <-ran:
+		s.FailNow("run again", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (s *DcronLocallyTestSuite) TestWithPanicPolicy() {
+	var handled string
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithPanicPolicy(cron.PanicCallback, func(jobName string, recovered interface{}, stack []byte) {
+			handled = fmt.Sprintf("%s: %v", jobName, recovered)
+		}))
+	s.Require().Nil(dcr.AddFunc("panic", "0 0 1 1 *", func() { panic("oops") }))
+	s.Assert().Nil(dcr.TriggerJob("panic"))
+	s.Assert().Equal("panic: oops", handled)
+	status, ok := dcr.JobStatus("panic")
+	s.Require().True(ok)
+	s.Assert().ErrorContains(status.LastError, "oops")
+}
+
+func (s *DcronLocallyTestSuite) TestAddJobWithTime() {
+	start := time.Date(2024, 1, 1, 13, 59, 0, 0, time.UTC)
+	clock := testclock.New(start)
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithClock(clock),
+		dcron.WithJobJitter(3*time.Minute),
+		dcron.CronOptionLocation(time.UTC))
+	type run struct{ scheduled, now time.Time }
+	runs := make(chan run, 1)
+	s.Require().Nil(dcr.AddJobWithTime("job", "0 14 * * *", func(scheduled time.Time) {
+		runs <- run{scheduled, clock.Now()}
+	}))
+	dcr.Start()
+	defer dcr.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	// the job waits for its jitter.
+	clock.BlockUntil(2)
+	clock.Advance(3 * time.Minute)
+	select {
+	case r := <-runs:
+		s.Assert().Equal(start.Add(time.Minute), r.scheduled)
+		s.Assert().True(r.now.After(r.scheduled))
+	case <-time.After(time.Second):
+		s.FailNow("not fired")
+	}
+}
+
+func (s *DcronLocallyTestSuite) TestAddAdaptiveJob() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.CronOptionSeconds())
+	var runs int32
+	s.Require().Nil(dcr.AddAdaptiveJob("drain", "0 0 0 1 1 *", func() *time.Duration {
+		// there is a backlog in the first runs.
+		if atomic.AddInt32(&runs, 1) >= 3 {
+			return nil
+		}
+		delay := 100 * time.Millisecond
+		return &delay
+	}))
+	dcr.Start()
+	defer dcr.Stop()
+	s.Require().Nil(dcr.TriggerJob("drain"))
+	s.Require().Eventually(func() bool {
+		return atomic.LoadInt32(&runs) == 3
+	}, 3*time.Second, 10*time.Millisecond)
+	// it follows the schedule after the backlog is drained.
+	time.Sleep(300 * time.Millisecond)
+	s.Assert().EqualValues(3, atomic.LoadInt32(&runs))
+}
+
+func (s *DcronLocallyTestSuite) TestDebugStats() {
+	clock := testclock.New(time.Date(2024, 1, 1, 2, 30, 0, 0, time.UTC))
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithClock(clock),
+		dcron.CronOptionLocation(time.UTC),
+		dcron.WithMaintenanceWindow("0 2 * * *", time.Hour),
+		dcron.WithMaintenanceCatchUp())
+	dcr.Start()
+	// the checker of the once jobs runs until Stop.
+	baseline := dcron.DebugStats{Goroutines: 1}
+	s.Require().Eventually(func() bool {
+		return dcr.DebugStats() == baseline
+	}, time.Second, 10*time.Millisecond)
+
+	N := 10
+	for i := 0; i < N; i++ {
+		jobName := fmt.Sprintf("job%d", i)
+		s.Require().Nil(dcr.AddFunc(jobName, "0 0 1 1 *", func() {}))
+		// skipped in the window, it waits for the catch-up.
+		s.Require().Nil(dcr.TriggerJob(jobName))
+	}
+	s.Require().Eventually(func() bool {
+		return dcr.DebugStats() == dcron.DebugStats{Jobs: N, Entries: N, Goroutines: N + 1, Timers: N}
+	}, time.Second, 10*time.Millisecond)
+	for i := 0; i < N; i++ {
+		s.Require().Nil(dcr.RemoveJob(fmt.Sprintf("job%d", i)))
+	}
+	s.Require().Eventually(func() bool {
+		return dcr.DebugStats() == baseline
+	}, time.Second, 10*time.Millisecond)
+	dcr.Stop()
+	s.Require().Eventually(func() bool {
+		return dcr.DebugStats() == dcron.DebugStats{}
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (s *DcronLocallyTestSuite) TestExpiredJobs() {
+	for _, remove := range []bool{false, true} {
+		expired := make(chan string, 10)
+		opts := []dcron.Option{
+			dcron.RunningLocally(),
+			dcron.WithExpiredJobHandler(func(jobName string) { expired <- jobName }),
+		}
+		if remove {
+			opts = append(opts, dcron.WithRemoveExpiredJobs())
+		}
+		dcr := dcron.NewDcronWithOption("not a necessary servername", nil, opts...)
+		s.Require().Nil(dcr.AddFunc("february30", "0 0 30 2 *", func() {}))
+		s.Require().Nil(dcr.AddFunc("daily", "@daily", func() {}))
+		dcr.Start()
+		select {
+		case jobName := <-expired:
+			s.Assert().Equal("february30", jobName)
+		case <-time.After(time.Second):
+			s.FailNow("the expired job is not handled")
+		}
+		s.Assert().Equal(!remove, dcr.HasJob("february30"))
+		s.Assert().True(dcr.HasJob("daily"))
+		dcr.Stop()
+	}
+}
+
+func (s *DcronLocallyTestSuite) TestAddInvalidJob() {
+	dcr := dcron.NewDcronWithOption("not a necessary servername", nil, dcron.RunningLocally())
+	s.Assert().Equal(dcron.ErrEmptyJobName, dcr.AddFunc("", "* * * * *", func() {}))
+	s.Assert().Equal(dcron.ErrNilJobFunc, dcr.AddFunc("job", "* * * * *", nil))
+	_, err := dcr.AddJob("job", "* * * * *", nil)
+	s.Assert().Equal(dcron.ErrNilJobFunc, err)
+	s.Assert().Equal(dcron.ErrNilJobFunc, dcr.AddFuncWithError("job", "* * * * *", nil))
+	s.Assert().Equal(dcron.ErrNilJobFunc, dcr.AddJobWithTime("job", "* * * * *", nil))
+	s.Assert().Equal(dcron.ErrNilJobFunc, dcr.AddJobWithWrappers("job", "* * * * *", nil, cron.SkipIfStillRunning(cron.DiscardLogger)))
+	s.Assert().Equal(dcron.ErrNilJobFunc, dcr.AddJobWithSelector("job", "* * * * *", map[string]string{"region": "eu"}, nil))
+	s.Assert().Equal(dcron.ErrNilJobFunc, dcr.AddOnceJob("job", nil))
+	s.Assert().Equal(dcron.ErrEmptyJobName, dcr.AddOnceJob("", func() {}))
+	err = dcr.AddJobs([]dcron.JobSpec{{Name: "", CronSpec: "* * * * *", Func: func() {}}})
+	s.Assert().ErrorIs(err, dcron.ErrEmptyJobName)
+	s.Assert().Empty(dcr.ListJobs())
+	s.Assert().Nil(dcr.AddFunc("job", "* * * * *", func() {}))
+	s.Assert().Equal(dcron.ErrNilJobFunc, dcr.ReplaceJob("job", "* * * * *", nil))
+	s.Assert().Equal(dcron.ErrEmptyJobName, dcr.ReplaceJob("", "* * * * *", func() {}))
+	s.Assert().NotPanics(func() { _ = dcr.TriggerJob("job") })
+}
+
+func (s *DcronLocallyTestSuite) TestAddJobWithMaxRuns() {
+	expired := make(chan string, 10)
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithExpiredJobHandler(func(jobName string) { expired <- jobName }))
+	s.Assert().Equal(dcron.ErrInvalidMaxRuns, dcr.AddJobWithMaxRuns("job", "* * * * *", 0, func() {}))
+	var runs int32
+	s.Require().Nil(dcr.AddJobWithMaxRuns("job", "* * * * *", 2, func() { atomic.AddInt32(&runs, 1) }))
+	s.Require().Nil(dcr.TriggerJob("job"))
+	s.Assert().True(dcr.HasJob("job"))
+	s.Assert().Len(expired, 0)
+	s.Require().Nil(dcr.TriggerJob("job"))
+	s.Assert().False(dcr.HasJob("job"))
+	s.Assert().Equal(int32(2), atomic.LoadInt32(&runs))
+	s.Assert().Equal("job", <-expired)
+	s.Assert().Len(expired, 0)
+
+	// the count is not reset by adding the job again.
+	s.Require().Nil(dcr.AddJobWithMaxRuns("job", "* * * * *", 2, func() { atomic.AddInt32(&runs, 1) }))
+	s.Require().Nil(dcr.TriggerJob("job"))
+	s.Assert().False(dcr.HasJob("job"))
+	s.Assert().Equal(int32(2), atomic.LoadInt32(&runs))
+	s.Assert().Len(expired, 0)
+}
+
+func (s *DcronLocallyTestSuite) TestAddJobWithLogger() {
+	global, own := &printfRecorder{}, &printfRecorder{}
+	globalLogger := dlog.VerbosePrintfLogger(global)
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithLogger(globalLogger),
+		dcron.CronOptionChain(cron.Recover(globalLogger)))
+	s.Require().Nil(dcr.AddJobWithLogger("noisy", "* * * * *", func() {
+		panic("noisy panics")
+	}, dlog.VerbosePrintfLogger(own)))
+	s.Require().Nil(dcr.AddFunc("job", "* * * * *", func() {
+		panic("job panics")
+	}))
+	_ = dcr.TriggerJob("noisy")
+	_ = dcr.TriggerJob("job")
+	s.Assert().Equal(1, own.count("panic", "noisy panics"))
+	s.Assert().Equal(0, own.count("job panics"))
+	s.Assert().Equal(0, global.count("noisy panics"))
+	s.Assert().Equal(1, global.count("panic", "job panics"))
+}
+
+func (s *DcronLocallyTestSuite) TestAddJobWithChainOverride() {
+	logs := &printfRecorder{}
+	logger := dlog.VerbosePrintfLogger(logs)
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithLogger(logger),
+		dcron.CronOptionChain(cron.Recover(logger)))
+	var wrapped int32
+	s.Require().Nil(dcr.AddJobWithChainOverride("critical", "* * * * *", func() {
+		panic("critical panics")
+	}, cron.NewChain(func(j cron.Job) cron.Job {
+		return cron.FuncJob(func() { atomic.AddInt32(&wrapped, 1); j.Run() })
+	})))
+	s.Require().Nil(dcr.AddFunc("job", "* * * * *", func() {
+		panic("job panics")
+	}))
+	s.Assert().PanicsWithValue("critical panics", func() { _ = dcr.TriggerJob("critical") })
+	s.Assert().NotPanics(func() { _ = dcr.TriggerJob("job") })
+	s.Assert().Equal(int32(1), atomic.LoadInt32(&wrapped))
+	s.Assert().Equal(0, logs.count("critical panics"))
+	s.Assert().Equal(1, logs.count("panic", "job panics"))
+
+	s.Require().Nil(dcr.ReplaceJob("critical", "* * * * *", func() { panic("replaced panics") }))
+	s.Assert().PanicsWithValue("replaced panics", func() { _ = dcr.TriggerJob("critical") })
+}
+
+func (s *DcronLocallyTestSuite) TestEvents() {
+	dcr := dcron.NewDcronWithOption(
+		"not a necessary servername",
+		nil,
+		dcron.RunningLocally(),
+		dcron.WithLogger(dlog.VerbosePrintfLogger(&printfRecorder{})),
+		dcron.WithPanicPolicy(cron.PanicRecover, nil))
+	s.Require().Nil(dcr.AddFuncWithError("job", "* * * * *", func() error { return errors.New("failed") }))
+	s.Require().Nil(dcr.AddFunc("panic", "* * * * *", func() { panic("oops") }))
+	// the events before Events is called are not emitted.
+	_ = dcr.TriggerJob("job")
+	events := dcr.Events()
+	s.Assert().Len(events, 0)
+
+	_ = dcr.TriggerJob("job")
+	_ = dcr.TriggerJob("panic")
+	expected := []dcron.EventType{dcron.EventJobStarted, dcron.EventJobFinished, dcron.EventJobStarted, dcron.EventJobPanicked}
+	for i, typ := range expected {
+		event := <-events
+		s.Assert().Equal(typ, event.Type, "event %d", i)
+		s.Assert().False(event.Time.IsZero())
+		switch typ {
+		case dcron.EventJobFinished:
+			s.Assert().Equal("job", event.JobName)
+			s.Assert().EqualError(event.Err, "failed")
+		case dcron.EventJobPanicked:
+			s.Assert().Equal("panic", event.JobName)
+			s.Assert().ErrorIs(event.Err, dcron.ErrJobPanic)
+		}
+	}
+
+	// the events are dropped once the buffer is full.
+	N := 200
+	for i := 0; i < N; i++ {
+		_ = dcr.TriggerJob("job")
+	}
+	s.Assert().Equal(cap(events), len(events))
+	s.Assert().EqualValues(2*N-cap(events), dcr.DroppedEvents())
+	s.Assert().Equal("JobStarted", (<-events).Type.String())
+}
+
 func TestDcronLocallyTestSuite(t *testing.T) {
 	suite.Run(t, &DcronLocallyTestSuite{})
 }
@@ -1,9 +1,14 @@
 package dcron_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -14,6 +19,7 @@ import (
 	"github.com/libi/dcron/cron"
 	"github.com/libi/dcron/dlog"
 	"github.com/libi/dcron/driver"
+	"github.com/libi/dcron/testclock"
 	redis "github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -125,7 +131,7 @@ func runNode(
 
 	var err error
 	for _, job := range testJobs {
-		if err = dcron.AddJob(job.Name, "* * * * *", job); err != nil {
+		if _, err = dcron.AddJob(job.Name, "* * * * *", job); err != nil {
 			t.Error("add job error")
 		}
 	}
@@ -346,12 +352,10 @@ func (s *testDcronTestSuite) Test_GetJobs_ThisNodeOnlyFalse() {
 	)
 	n := 10
 	for i := 0; i < n; i++ {
-		assert.Nil(
-			t,
-			dcr.AddJob(fmt.Sprintf("job_%d", i), "* * * * * *", &testGetJob{
-				Name: fmt.Sprintf("job_%d", i),
-			}),
-		)
+		_, err := dcr.AddJob(fmt.Sprintf("job_%d", i), "* * * * * *", &testGetJob{
+			Name: fmt.Sprintf("job_%d", i),
+		})
+		assert.Nil(t, err)
 	}
 
 	jobs := dcr.GetJobs(false)
@@ -383,12 +387,10 @@ func (s *testDcronTestSuite) Test_GetJob_ThisNodeOnlyFalse() {
 	)
 	n := 10
 	for i := 0; i < n; i++ {
-		assert.Nil(
-			t,
-			dcr.AddJob(fmt.Sprintf("job_%d", i), "* * * * * *", &testGetJob{
-				Name: fmt.Sprintf("job_%d", i),
-			}),
-		)
+		_, err := dcr.AddJob(fmt.Sprintf("job_%d", i), "* * * * * *", &testGetJob{
+			Name: fmt.Sprintf("job_%d", i),
+		})
+		assert.Nil(t, err)
 	}
 
 	for i := 0; i < n; i++ {
@@ -425,12 +427,10 @@ func (s *testDcronTestSuite) Test_GetJobs_ThisNodeOnlyTrue() {
 	)
 	n := 10
 	for i := 0; i < n; i++ {
-		assert.Nil(
-			t,
-			dcr.AddJob(fmt.Sprintf("job_%d", i), "* * * * * *", &testGetJob{
-				Name: fmt.Sprintf("job_%d", i),
-			}),
-		)
+		_, err := dcr.AddJob(fmt.Sprintf("job_%d", i), "* * * * * *", &testGetJob{
+			Name: fmt.Sprintf("job_%d", i),
+		})
+		assert.Nil(t, err)
 	}
 	result := make(chan bool, 1)
 	dcr.Start()
@@ -462,12 +462,10 @@ func (s *testDcronTestSuite) Test_GetJob_ThisNodeOnlyTrue() {
 	)
 	n := 10
 	for i := 0; i < n; i++ {
-		assert.Nil(
-			t,
-			dcr.AddJob(fmt.Sprintf("job_%d", i), "* * * * * *", &testGetJob{
-				Name: fmt.Sprintf("job_%d", i),
-			}),
-		)
+		_, err := dcr.AddJob(fmt.Sprintf("job_%d", i), "* * * * * *", &testGetJob{
+			Name: fmt.Sprintf("job_%d", i),
+		})
+		assert.Nil(t, err)
 	}
 	result := make(chan bool, 1)
 	dcr.Start()
@@ -508,8 +506,2037 @@ func (s *testDcronTestSuite) Test_AddJob_JobName_Duplicate() {
 			log.Default(),
 		)),
 	)
-	s.Assert().Nil(dcr.AddJob("test1", "* * * * * *", &testGetJob{}))
-	s.Assert().Equal(dcron.ErrJobExist, dcr.AddJob("test1", "* * * * * *", &testGetJob{}))
+	_, err := dcr.AddJob("test1", "* * * * * *", &testGetJob{})
+	s.Assert().Nil(err)
+	_, err = dcr.AddJob("test1", "* * * * * *", &testGetJob{})
+	s.Assert().Equal(dcron.ErrJobExist, err)
+}
+
+func (s *testDcronTestSuite) Test_TriggerJob_WrongNode() {
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return []string{"other-node"}, nil
+		},
+	}
+	dcr := dcron.NewDcronWithOption(
+		s.T().Name(),
+		md,
+		dcron.WithNodeUpdateDuration(100*time.Millisecond),
+	)
+	called := false
+	s.Require().Nil(dcr.AddFunc("job1", "* * * * *", func() {
+		called = true
+	}))
+	dcr.Start()
+	defer dcr.Stop()
+	s.Assert().Equal(dcron.ErrJobWrongNode, dcr.TriggerJob("job1"))
+	s.Assert().False(called)
+}
+
+func (s *testDcronTestSuite) Test_StartError() {
+	errDown := errors.New("driver is down")
+	md := &MockDriver{
+		StartFunc: func(ctx context.Context) error {
+			return errDown
+		},
+	}
+	dcr := dcron.NewDcronWithOption(s.T().Name(), md,
+		dcron.WithNodeUpdateDuration(100*time.Millisecond))
+	s.Assert().ErrorIs(dcr.Start(), errDown)
+	s.Assert().ErrorIs(dcr.HealthCheck(), dcron.ErrDcronNotRunning)
+
+	// the node is deregistered if the first sync fails.
+	stopped := false
+	md = &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return nil, errDown
+		},
+		StopFunc: func(ctx context.Context) error {
+			stopped = true
+			return nil
+		},
+	}
+	dcr = dcron.NewDcronWithOption(s.T().Name(), md,
+		dcron.WithNodeUpdateDuration(100*time.Millisecond))
+	s.Assert().ErrorIs(dcr.Start(), errDown)
+	s.Assert().True(stopped)
+	s.Assert().ErrorIs(dcr.HealthCheck(), dcron.ErrDcronNotRunning)
+
+	md.GetNodesFunc = func(ctx context.Context) ([]string, error) {
+		return []string{""}, nil
+	}
+	s.Require().Nil(dcr.Start())
+	defer dcr.Stop()
+	s.Assert().Nil(dcr.Start())
+}
+
+func (s *testDcronTestSuite) Test_DriverTimeout() {
+	var hung atomic.Bool
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			if hung.Load() {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return []string{""}, nil
+		},
+	}
+	var mut sync.Mutex
+	var syncErrs []error
+	dcr := dcron.NewDcronWithOption(s.T().Name(), md,
+		dcron.WithNodeUpdateDuration(100*time.Millisecond),
+		dcron.WithDriverTimeout(50*time.Millisecond),
+		dcron.WithPoolUpdateObserver(func(d time.Duration, memberCount int, err error) {
+			if err != nil {
+				s.Assert().Less(d, time.Second)
+				mut.Lock()
+				syncErrs = append(syncErrs, err)
+				mut.Unlock()
+			}
+		}))
+	ran := make(chan struct{}, 1)
+	s.Require().Nil(dcr.AddFunc("job1", "* * * * *", func() {
+		ran <- struct{}{}
+	}))
+	s.Require().Nil(dcr.Start())
+	defer dcr.Stop()
+
+	// the hung syncs time out, and the last synced nodes are kept.
+	hung.Store(true)
+	s.Require().Eventually(func() bool {
+		return errors.Is(dcr.HealthCheck(), dcron.ErrNodePoolNotSynced)
+	}, 5*time.Second, 10*time.Millisecond)
+	mut.Lock()
+	s.Require().NotEmpty(syncErrs)
+	s.Assert().ErrorIs(syncErrs[0], context.DeadlineExceeded)
+	mut.Unlock()
+	s.Require().Nil(dcr.TriggerJob("job1"))
+	<-ran
+
+	hung.Store(false)
+	s.Require().Eventually(func() bool {
+		return dcr.HealthCheck() == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *testDcronTestSuite) Test_PauseJob_PropagatedByDriver() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	defer rds.Close()
+	newDcron := func() *dcron.Dcron {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli))
+		s.Require().Nil(dcr.AddFunc("job1", "* * * * *", func() {}))
+		return dcr
+	}
+	dcr1, dcr2 := newDcron(), newDcron()
+
+	s.Require().Nil(dcr1.PauseJob("job1"))
+	paused, err := dcr2.IsJobPaused("job1")
+	s.Require().Nil(err)
+	s.Assert().True(paused)
+
+	s.Require().Nil(dcr2.ResumeJob("job1"))
+	paused, err = dcr1.IsJobPaused("job1")
+	s.Require().Nil(err)
+	s.Assert().False(paused)
+}
+
+func (s *testDcronTestSuite) Test_PauseGroup_PropagatedByDriver() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	defer rds.Close()
+	newDcron := func() *dcron.Dcron {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli))
+		s.Require().Nil(dcr.AddJobToGroup("reporting", "job1", "* * * * *", func() {}))
+		return dcr
+	}
+	dcr1, dcr2 := newDcron(), newDcron()
+
+	s.Require().Nil(dcr1.PauseGroup("reporting"))
+	paused, err := dcr2.IsGroupPaused("reporting")
+	s.Require().Nil(err)
+	s.Assert().True(paused)
+
+	s.Require().Nil(dcr2.ResumeGroup("reporting"))
+	paused, err = dcr1.IsGroupPaused("reporting")
+	s.Require().Nil(err)
+	s.Assert().False(paused)
+}
+
+func (s *testDcronTestSuite) Test_AddOnceJob() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	defer rds.Close()
+	var runs atomic.Int32
+	newDcron := func(epoch string) *dcron.Dcron {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithOnceJobEpoch(epoch))
+		s.Require().Nil(dcr.AddOnceJob("init", func() { runs.Add(1) }))
+		s.Require().Equal(dcron.ErrJobExist, dcr.AddOnceJob("init", func() {}))
+		return dcr
+	}
+
+	dcr1, dcr2 := newDcron("v1"), newDcron("v1")
+	dcr1.Start()
+	dcr2.Start()
+	<-time.After(3 * time.Second)
+	s.Assert().Equal(int32(1), runs.Load())
+	dcr1.Stop()
+	dcr2.Stop()
+
+	// restarted in the same epoch.
+	dcr3 := newDcron("v1")
+	dcr3.Start()
+	<-time.After(2 * time.Second)
+	s.Assert().Equal(int32(1), runs.Load())
+	dcr3.Stop()
+
+	// a new epoch.
+	dcr4 := newDcron("v2")
+	dcr4.Start()
+	<-time.After(2 * time.Second)
+	s.Assert().Equal(int32(2), runs.Load())
+	dcr4.Stop()
+}
+
+func (s *testDcronTestSuite) Test_HealthCheck() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	redisCli := redis.NewClient(&redis.Options{
+		Addr: rds.Addr(),
+	})
+	dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+		dcron.WithLogger(dlog.NewLoggerForTest(t)),
+		dcron.WithNodeUpdateDuration(time.Second))
+	s.Assert().Equal(dcron.ErrDcronNotRunning, dcr.HealthCheck())
+	s.Assert().False(dcr.IsSteady())
+
+	dcr.Start()
+	defer dcr.Stop()
+	s.Assert().True(dcr.IsSteady())
+	s.Assert().Nil(dcr.HealthCheck())
+
+	rds.Close()
+	s.Assert().ErrorIs(dcr.HealthCheck(), dcron.ErrDriverUnhealthy)
+}
+
+func (s *testDcronTestSuite) Test_AddJobAfterStart() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	var mu sync.Mutex
+	// the runs of each second.
+	runs := make(map[int64]int)
+	dcrs := make([]*dcron.Dcron, 0, 2)
+	for i := 0; i < 2; i++ {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+			dcron.WithSeconds(),
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second))
+		dcr.Start()
+		defer dcr.Stop()
+		dcrs = append(dcrs, dcr)
+	}
+	// wait for the nodes to see each other.
+	<-time.After(2 * time.Second)
+	for _, dcr := range dcrs {
+		s.Require().Nil(dcr.AddFunc("job", "* * * * * *", func() {
+			mu.Lock()
+			defer mu.Unlock()
+			runs[time.Now().Unix()]++
+		}))
+	}
+	<-time.After(3 * time.Second)
+	mu.Lock()
+	defer mu.Unlock()
+	s.Assert().GreaterOrEqual(len(runs), 2)
+	for _, n := range runs {
+		s.Assert().Equal(1, n)
+	}
+}
+
+func (s *testDcronTestSuite) Test_JobHistoryNodeID() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	redisCli := redis.NewClient(&redis.Options{
+		Addr: rds.Addr(),
+	})
+	dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+		dcron.WithLogger(dlog.NewLoggerForTest(t)),
+		dcron.WithNodeUpdateDuration(time.Second),
+		dcron.WithHistorySize(2))
+	s.Require().Nil(dcr.AddFunc("job", "0 0 1 1 *", func() {}))
+	dcr.Start()
+	defer dcr.Stop()
+	s.Require().Nil(dcr.TriggerJob("job"))
+	history := dcr.JobHistory("job")
+	s.Require().Len(history, 1)
+	s.Assert().Equal(dcr.NodeID(), history[0].NodeID)
+}
+
+func (s *testDcronTestSuite) Test_IsolationPolicy() {
+	t := s.T()
+	for policy, wantRuns := range map[dcron.IsolationPolicy]bool{
+		dcron.IsolationKeepLast:   true,
+		dcron.IsolationRunNothing: false,
+		dcron.IsolationRunAll:     true,
+	} {
+		rds := miniredis.RunT(t)
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+			dcron.WithSeconds(),
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithIsolationPolicy(policy))
+		var runs atomic.Int32
+		s.Require().Nil(dcr.AddFunc("job", "* * * * * *", func() { runs.Add(1) }))
+		dcr.Start()
+		<-time.After(1500 * time.Millisecond)
+		s.Assert().Greater(runs.Load(), int32(0), policy.String())
+
+		// isolated after 2 update durations.
+		rds.Close()
+		<-time.After(2500 * time.Millisecond)
+		runs.Store(0)
+		<-time.After(2 * time.Second)
+		if wantRuns {
+			s.Assert().Greater(runs.Load(), int32(0), policy.String())
+		} else {
+			s.Assert().Equal(int32(0), runs.Load(), policy.String())
+		}
+		dcr.Stop()
+	}
+}
+
+// followWallClock fast forwards rds with the wall clock until the returned
+// func is called, miniredis only expires the keys when it is fast forwarded.
+func followWallClock(rds *miniredis.Miniredis) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		tick := time.NewTicker(50 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				rds.FastForward(50 * time.Millisecond)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (s *testDcronTestSuite) Test_NodeDeathFailoverWindow() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	redisCli := redis.NewClient(&redis.Options{
+		Addr: rds.Addr(),
+	})
+	updateDuration := time.Second
+	// the heartbeat of a node which is going to die.
+	deadNodeID := driver.GetKeyPre(t.Name()) + "dead"
+	rds.Set(deadNodeID, deadNodeID)
+	rds.SetTTL(deadNodeID, updateDuration)
+
+	dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+		dcron.WithLogger(dlog.NewLoggerForTest(t)),
+		dcron.WithNodeUpdateDuration(updateDuration))
+	s.Require().Nil(dcr.Err())
+	jobNames := make([]string, 0)
+	for i := 0; i < 20; i++ {
+		jobName := fmt.Sprintf("job%d", i)
+		jobNames = append(jobNames, jobName)
+		s.Require().Nil(dcr.AddFunc(jobName, "* * * * *", func() {}))
+	}
+	dcr.Start()
+	defer dcr.Stop()
+
+	ownedByDead := 0
+	for _, jobName := range jobNames {
+		if owner, err := dcr.GetJobOwnerNode(jobName); err == nil && owner == deadNodeID {
+			ownedByDead++
+		}
+	}
+	s.Require().Greater(ownedByDead, 0)
+
+	// the last heartbeat of the dead node.
+	rds.SetTTL(deadNodeID, updateDuration)
+	died := time.Now()
+	defer followWallClock(rds)()
+
+	allOwned := func() bool {
+		for _, jobName := range jobNames {
+			if owner, err := dcr.GetJobOwnerNode(jobName); err != nil || owner != dcr.NodeID() {
+				return false
+			}
+		}
+		return true
+	}
+	for !allOwned() {
+		<-time.After(50 * time.Millisecond)
+		s.Require().Less(time.Since(died), 2*dcron.FailoverWindow(updateDuration, updateDuration))
+	}
+	// a tick of slack for the polling above.
+	s.Assert().LessOrEqual(time.Since(died), dcron.FailoverWindow(updateDuration, updateDuration)+100*time.Millisecond)
+}
+
+func (s *testDcronTestSuite) Test_VerboseOwnershipLogging() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	recorders := make([]*printfRecorder, 2)
+	dcrs := make([]*dcron.Dcron, 2)
+	for i := range dcrs {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		recorders[i] = &printfRecorder{}
+		dcrs[i] = dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+			dcron.WithSeconds(),
+			dcron.WithLogger(dlog.VerbosePrintfLogger(recorders[i])),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithVerboseOwnershipLogging())
+		s.Require().Nil(dcrs[i].AddFunc("job", "* * * * * *", func() {}))
+	}
+	for _, dcr := range dcrs {
+		dcr.Start()
+	}
+	var owner string
+	s.Require().Eventually(func() bool {
+		owner0, err0 := dcrs[0].GetJobOwnerNode("job")
+		owner1, err1 := dcrs[1].GetJobOwnerNode("job")
+		owner = owner0
+		return err0 == nil && err1 == nil && owner0 == owner1 && len(dcrs[0].Nodes()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	// the first node started runs the job alone until the other joined.
+	for _, recorder := range recorders {
+		recorder.reset()
+	}
+	<-time.After(3 * time.Second)
+	for _, dcr := range dcrs {
+		dcr.Stop()
+	}
+
+	for i, dcr := range dcrs {
+		ownerKV := "owner=" + owner
+		if dcr.NodeID() == owner {
+			s.Assert().Greater(recorders[i].count("job fired", "decision=run", ownerKV), 0)
+		} else {
+			s.Assert().Greater(recorders[i].count("job fired", "decision=skip", ownerKV), 0)
+			s.Assert().Equal(0, recorders[i].count("job fired", "decision=run"))
+		}
+	}
+}
+
+func (s *testDcronTestSuite) Test_LastRunTime() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	newDcron := func() *dcron.Dcron {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+			dcron.WithSeconds(),
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second))
+		s.Require().Nil(dcr.AddFunc("job", "* * * * * *", func() {}))
+		s.Require().Nil(dcr.AddFuncWithError("failed", "* * * * * *", func() error { return errors.New("failed") }))
+		return dcr
+	}
+	dcrs := []*dcron.Dcron{newDcron(), newDcron()}
+	for _, dcr := range dcrs {
+		dcr.Start()
+	}
+	<-time.After(2 * time.Second)
+	owner, err := dcrs[0].GetJobOwnerNode("job")
+	s.Require().Nil(err)
+	for _, dcr := range dcrs {
+		dcr.Stop()
+	}
+
+	// a new node sees the run after the nodes which ran it stopped.
+	dcr := newDcron()
+	lastRun, nodeID, err := dcr.LastRunTime("job")
+	s.Require().Nil(err)
+	s.Assert().Equal(owner, nodeID)
+	s.Assert().WithinDuration(time.Now(), lastRun, 3*time.Second)
+	lastRun, nodeID, err = dcr.LastRunTime("failed")
+	s.Require().Nil(err)
+	s.Assert().True(lastRun.IsZero())
+	s.Assert().Empty(nodeID)
+	_, _, err = dcr.LastRunTime("not_exist")
+	s.Assert().Equal(dcron.ErrJobNotExist, err)
+
+	local := dcron.NewDcronWithOption(t.Name(), nil, dcron.RunningLocally())
+	s.Require().Nil(local.AddFunc("job", "* * * * *", func() {}))
+	_, _, err = local.LastRunTime("job")
+	s.Assert().Equal(dcron.ErrNotKVDriver, err)
+}
+
+func (s *testDcronTestSuite) Test_NewDcronWithContext() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	redisCli := redis.NewClient(&redis.Options{
+		Addr: rds.Addr(),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	dcr := dcron.NewDcronWithContext(ctx, t.Name(), driver.NewRedisDriver(redisCli),
+		dcron.WithLogger(dlog.NewLoggerForTest(t)),
+		dcron.WithNodeUpdateDuration(time.Second))
+	s.Require().Nil(dcr.AddFunc("job", "* * * * *", func() {}))
+	dcr.Start()
+	s.Require().Nil(dcr.HealthCheck())
+	s.Require().True(rds.Exists(dcr.NodeID()))
+
+	cancel()
+	s.Require().Eventually(func() bool {
+		return errors.Is(dcr.HealthCheck(), dcron.ErrDcronNotRunning)
+	}, 3*time.Second, 10*time.Millisecond)
+	// deregistered from the driver, instead of waiting for the heartbeat to expire.
+	s.Assert().Eventually(func() bool {
+		return !rds.Exists(dcr.NodeID())
+	}, time.Second, 10*time.Millisecond)
+
+	// can not be started again with the done ctx.
+	dcr.Start()
+	s.Assert().Equal(dcron.ErrDcronNotRunning, dcr.HealthCheck())
+}
+
+func (s *testDcronTestSuite) Test_Nodes() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	nodes := make([]*dcron.Dcron, 0, 3)
+	ids := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second))
+		s.Require().Nil(dcr.AddFunc("job", "* * * * *", func() {}))
+		dcr.Start()
+		nodes = append(nodes, dcr)
+		ids = append(ids, dcr.NodeID())
+	}
+	sort.Strings(ids)
+	s.Require().Eventually(func() bool {
+		return nodes[0].NodeCount() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	got := nodes[0].Nodes()
+	s.Assert().Equal(ids, got)
+
+	// the returned slice is a copy.
+	got[0] = "modified"
+	s.Assert().Equal(ids, nodes[0].Nodes())
+
+	nodes[2].Stop()
+	s.Assert().Eventually(func() bool {
+		return nodes[0].NodeCount() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	nodes[0].Stop()
+	nodes[1].Stop()
+}
+
+func (s *testDcronTestSuite) Test_PinnedJob() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	newDcron := func(name string, opts ...dcron.Option) *dcron.Dcron {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		opts = append(opts,
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithNodeID(name))
+		return dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli), opts...)
+	}
+	dcrA, dcrB := newDcron("a"), newDcron("b")
+	dcrA.Start()
+	dcrB.Start()
+	N := 10
+	for _, dcr := range []*dcron.Dcron{dcrA, dcrB} {
+		for i := 0; i < N; i++ {
+			s.Require().Nil(dcr.AddPinnedJob(fmt.Sprintf("job%d", i), "* * * * *", dcrB.NodeID(), func() {}))
+		}
+		// the failed add does not change the pinned node of the added job.
+		s.Assert().Equal(dcron.ErrJobExist, dcr.AddPinnedJob("job0", "* * * * *", dcrA.NodeID(), func() {}))
+		s.Assert().Equal(dcron.ErrEmptyPinnedNode, dcr.AddPinnedJob("empty", "* * * * *", "", func() {}))
+	}
+	s.Require().Eventually(func() bool {
+		_, errA := dcrA.GetJobOwnerNode("job0")
+		_, errB := dcrB.GetJobOwnerNode("job0")
+		return dcrA.NodeCount() == 2 && dcrB.NodeCount() == 2 && errA == nil && errB == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	for i := 0; i < N; i++ {
+		jobName := fmt.Sprintf("job%d", i)
+		for _, dcr := range []*dcron.Dcron{dcrA, dcrB} {
+			owner, err := dcr.GetJobOwnerNode(jobName)
+			s.Require().Nil(err)
+			s.Assert().Equal(dcrB.NodeID(), owner)
+		}
+		s.Assert().Equal(dcron.ErrJobWrongNode, dcrA.TriggerJob(jobName))
+	}
+
+	// falls back to the hash owner if the pinned node is absent.
+	dcrB.Stop()
+	s.Require().Eventually(func() bool {
+		owner, err := dcrA.GetJobOwnerNode("job0")
+		return err == nil && owner == dcrA.NodeID()
+	}, 5*time.Second, 10*time.Millisecond)
+	for i := 0; i < N; i++ {
+		owner, err := dcrA.GetJobOwnerNode(fmt.Sprintf("job%d", i))
+		s.Require().Nil(err)
+		s.Assert().Equal(dcrA.NodeID(), owner)
+	}
+	dcrA.Stop()
+
+	// runs nowhere with PinFallbackSkip.
+	dcrC := newDcron("c", dcron.WithPinFallback(dcron.PinFallbackSkip))
+	dcrC.Start()
+	defer dcrC.Stop()
+	s.Require().Nil(dcrC.AddPinnedJob("job", "* * * * *", dcrB.NodeID(), func() {}))
+	s.Require().Eventually(func() bool {
+		_, err := dcrC.GetJobOwnerNode("job")
+		return err == dcron.ErrPinnedNodeAbsent
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Assert().False(dcrC.ListJobs()[0].Owned)
+	s.Assert().Equal(dcron.ErrJobWrongNode, dcrC.TriggerJob("job"))
+}
+
+func (s *testDcronTestSuite) Test_JobRunOnStart() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	N := 10
+	var mu sync.Mutex
+	ranOn := make(map[string][]string)
+	nodes := make([]*dcron.Dcron, 0, 2)
+	for i := 0; i < 2; i++ {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second))
+		for j := 0; j < N; j++ {
+			jobName := fmt.Sprintf("job%d", j)
+			s.Require().Nil(dcr.AddJobRunOnStart(jobName, "0 0 1 1 *", func() {
+				mu.Lock()
+				defer mu.Unlock()
+				ranOn[jobName] = append(ranOn[jobName], dcr.NodeID())
+			}))
+		}
+		nodes = append(nodes, dcr)
+	}
+	// start the nodes together, Start returns after the node pool is
+	// steady, so a node started after it is not seen by the initial runs.
+	var wg sync.WaitGroup
+	for _, dcr := range nodes {
+		dcr := dcr
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Assert().Nil(dcr.Start())
+		}()
+		defer dcr.Stop()
+	}
+	wg.Wait()
+
+	// each job runs once, on its owner.
+	s.Require().Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(ranOn) == N
+	}, 10*time.Second, 10*time.Millisecond)
+	<-time.After(2 * time.Second)
+	mu.Lock()
+	defer mu.Unlock()
+	for jobName, ids := range ranOn {
+		s.Require().Len(ids, 1, jobName)
+		owner, err := nodes[0].GetJobOwnerNode(jobName)
+		s.Require().Nil(err)
+		s.Assert().Equal(owner, ids[0], jobName)
+	}
+}
+
+func (s *testDcronTestSuite) Test_IsLeader() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	nodes := make([]*dcron.Dcron, 0, 3)
+	for i := 0; i < 3; i++ {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second))
+		s.Assert().False(dcr.IsLeader())
+		dcr.Start()
+		nodes = append(nodes, dcr)
+	}
+	leaders := func(nodes []*dcron.Dcron) (leader *dcron.Dcron, count int) {
+		for _, dcr := range nodes {
+			if dcr.IsLeader() {
+				leader, count = dcr, count+1
+			}
+		}
+		return
+	}
+	var leader *dcron.Dcron
+	s.Require().Eventually(func() bool {
+		var count int
+		leader, count = leaders(nodes)
+		return count == 1 && nodes[0].NodeCount() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// another node becomes the leader once the leader leaves.
+	rest := make([]*dcron.Dcron, 0, 2)
+	for _, dcr := range nodes {
+		if dcr != leader {
+			rest = append(rest, dcr)
+		}
+	}
+	leader.Stop()
+	s.Assert().False(leader.IsLeader())
+	s.Require().Eventually(func() bool {
+		_, count := leaders(rest)
+		return count == 1 && rest[0].NodeCount() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, dcr := range rest {
+		dcr.Stop()
+	}
+}
+
+func (s *testDcronTestSuite) Test_JobWithSelector() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	var euRuns, usRuns, apRuns int32
+	nodes := make([]*dcron.Dcron, 0, 3)
+	regions := []string{"eu", "us", "us"}
+	for _, region := range regions {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithNodeLabels(map[string]string{"region": region}),
+			dcron.CronOptionSeconds())
+		eu := region == "eu"
+		s.Require().Nil(dcr.AddJobWithSelector("eu", "* * * * * *", map[string]string{"region": "eu"}, func() {
+			s.Assert().True(eu)
+			atomic.AddInt32(&euRuns, 1)
+		}))
+		s.Require().Nil(dcr.AddJobWithSelector("us", "* * * * * *", map[string]string{"region": "us"}, func() {
+			s.Assert().False(eu)
+			atomic.AddInt32(&usRuns, 1)
+		}))
+		s.Require().Nil(dcr.AddJobWithSelector("ap", "* * * * * *", map[string]string{"region": "ap"}, func() {
+			atomic.AddInt32(&apRuns, 1)
+		}))
+		dcr.Start()
+		nodes = append(nodes, dcr)
+	}
+	defer func() {
+		for _, dcr := range nodes {
+			dcr.Stop()
+		}
+	}()
+
+	s.Require().Eventually(func() bool {
+		for _, dcr := range nodes {
+			owner, err := dcr.GetJobOwnerNode("eu")
+			if err != nil || owner != nodes[0].NodeID() {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+	for _, dcr := range nodes {
+		owner, err := dcr.GetJobOwnerNode("us")
+		s.Require().Nil(err)
+		s.Assert().NotEqual(nodes[0].NodeID(), owner)
+		_, err = dcr.GetJobOwnerNode("ap")
+		s.Assert().ErrorIs(err, dcron.ErrNoMatchingNode)
+	}
+	s.Require().Eventually(func() bool {
+		return atomic.LoadInt32(&euRuns) > 0 && atomic.LoadInt32(&usRuns) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Assert().Equal(int32(0), atomic.LoadInt32(&apRuns))
+}
+
+func (s *testDcronTestSuite) Test_MemoryDriverFailover() {
+	t := s.T()
+	registry := driver.NewMemoryRegistry()
+	nodes := make([]*dcron.Dcron, 0, 3)
+	for i := 0; i < 3; i++ {
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewMemoryDriver(registry),
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second))
+		s.Require().Nil(dcr.AddFunc("job", "* * * * *", func() {}))
+		dcr.Start()
+		nodes = append(nodes, dcr)
+	}
+	defer func() {
+		for _, dcr := range nodes {
+			dcr.Stop()
+		}
+	}()
+	owners := func(nodes []*dcron.Dcron) map[string]struct{} {
+		owners := make(map[string]struct{})
+		for _, dcr := range nodes {
+			owner, err := dcr.GetJobOwnerNode("job")
+			if err == nil {
+				owners[owner] = struct{}{}
+			}
+		}
+		return owners
+	}
+	var owner string
+	s.Require().Eventually(func() bool {
+		for _, dcr := range nodes {
+			if dcr.NodeCount() != 3 {
+				return false
+			}
+		}
+		found := owners(nodes)
+		for owner = range found {
+		}
+		return len(found) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the job is taken over by another node once the owner died.
+	registry.ExpireNode(owner)
+	rest := make([]*dcron.Dcron, 0, 2)
+	for _, dcr := range nodes {
+		if dcr.NodeID() != owner {
+			rest = append(rest, dcr)
+		}
+	}
+	s.Require().Eventually(func() bool {
+		found := owners(rest)
+		_, old := found[owner]
+		return len(found) == 1 && !old && rest[0].NodeCount() == 2 && rest[1].NodeCount() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *testDcronTestSuite) Test_BroadcastJob() {
+	t := s.T()
+	registry := driver.NewMemoryRegistry()
+	nodes := make([]*dcron.Dcron, 0, 3)
+	broadcastRuns := make([]int32, 3)
+	var ownedRuns int32
+	for i := 0; i < 3; i++ {
+		i := i
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewMemoryDriver(registry),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithExecutionLock(time.Minute),
+			dcron.CronOptionSeconds())
+		s.Require().Nil(dcr.AddBroadcastJob("broadcast", "* * * * * *", func() {
+			atomic.AddInt32(&broadcastRuns[i], 1)
+		}))
+		s.Require().Nil(dcr.AddFunc("owned", "* * * * * *", func() {
+			atomic.AddInt32(&ownedRuns, 1)
+		}))
+		nodes = append(nodes, dcr)
+	}
+	for _, dcr := range nodes {
+		dcr.Start()
+	}
+	defer func() {
+		for _, dcr := range nodes {
+			dcr.Stop()
+		}
+	}()
+
+	s.Require().Eventually(func() bool {
+		for i := range nodes {
+			if atomic.LoadInt32(&broadcastRuns[i]) < 2 {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+	for _, dcr := range nodes {
+		owner, err := dcr.GetJobOwnerNode("broadcast")
+		s.Require().Nil(err)
+		s.Assert().Equal(dcr.NodeID(), owner)
+	}
+	// the other jobs still run once per scheduled time.
+	var broadcast int32
+	for i := range nodes {
+		broadcast += atomic.LoadInt32(&broadcastRuns[i])
+	}
+	s.Assert().Less(atomic.LoadInt32(&ownedRuns), broadcast)
+}
+
+func (s *testDcronTestSuite) Test_ShortInterval() {
+	newDcron := func(recorder *printfRecorder, opts ...dcron.Option) *dcron.Dcron {
+		opts = append([]dcron.Option{
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithLogger(dlog.WarnPrintfLogger(recorder)),
+			dcron.CronOptionSeconds(),
+		}, opts...)
+		return dcron.NewDcronWithOption(s.T().Name(), driver.NewMemoryDriver(driver.NewMemoryRegistry()), opts...)
+	}
+	recorder := &printfRecorder{}
+	dcr := newDcron(recorder, dcron.WithRejectShortIntervals())
+	s.Assert().ErrorIs(dcr.AddFunc("fast", "@every 100ms", func() {}), dcron.ErrIntervalTooShort)
+	s.Assert().ErrorIs(dcr.AddJobs([]dcron.JobSpec{
+		{Name: "fast", CronSpec: "@every 500ms", Func: func() {}},
+	}), dcron.ErrIntervalTooShort)
+	s.Assert().Nil(dcr.AddFunc("every second", "* * * * * *", func() {}))
+	s.Assert().ErrorIs(dcr.ReplaceJob("every second", "@every 100ms", func() {}), dcron.ErrIntervalTooShort)
+	s.Assert().Nil(dcr.AddFunc("hourly", "@hourly", func() {}))
+
+	recorder = &printfRecorder{}
+	dcr = newDcron(recorder)
+	s.Assert().Nil(dcr.AddFunc("fast", "@every 100ms", func() {}))
+	s.Assert().Equal(1, recorder.count("job 'fast' fires every 100ms"))
+	s.Assert().Nil(dcr.AddFunc("every second", "* * * * * *", func() {}))
+	s.Assert().Equal(0, recorder.count("job 'every second'"))
+}
+
+func (s *testDcronTestSuite) Test_PausedJobAfterRestart() {
+	registry := driver.NewMemoryRegistry()
+	dcr := dcron.NewDcronWithOption(s.T().Name(), driver.NewMemoryDriver(registry),
+		dcron.WithNodeUpdateDuration(time.Second),
+		dcron.CronOptionSeconds())
+	s.Require().Nil(dcr.AddFunc("job", "* * * * * *", func() {}))
+	s.Require().Nil(dcr.PauseJob("job"))
+	s.Require().Nil(dcr.Start())
+	dcr.Stop()
+
+	// the whole cluster is restarted.
+	recorder := &printfRecorder{}
+	var runs int32
+	dcr = dcron.NewDcronWithOption(s.T().Name(), driver.NewMemoryDriver(registry),
+		dcron.WithNodeUpdateDuration(time.Second),
+		dcron.WithLogger(dlog.WarnPrintfLogger(recorder)),
+		dcron.CronOptionSeconds())
+	s.Require().Nil(dcr.AddFunc("job", "* * * * * *", func() {
+		atomic.AddInt32(&runs, 1)
+	}))
+	s.Assert().Equal(1, recorder.count("job 'job' is paused"))
+	paused, err := dcr.IsJobPaused("job")
+	s.Require().Nil(err)
+	s.Assert().True(paused)
+	s.Require().Nil(dcr.Start())
+	defer dcr.Stop()
+	time.Sleep(3 * time.Second)
+	s.Assert().Equal(int32(0), atomic.LoadInt32(&runs))
+
+	s.Require().Nil(dcr.ResumeJob("job"))
+	s.Assert().Eventually(func() bool {
+		return atomic.LoadInt32(&runs) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *testDcronTestSuite) Test_RegistrationHooks() {
+	registry := driver.NewMemoryRegistry()
+	newDcron := func(ctx context.Context, registered, deregistered chan string) *dcron.Dcron {
+		return dcron.NewDcronWithContext(ctx, s.T().Name(), driver.NewMemoryDriver(registry),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithOnRegister(func(nodeID string) {
+				// the node is registered when the hook is called.
+				s.Assert().Contains(registry.Nodes(), nodeID)
+				registered <- nodeID
+			}),
+			dcron.WithOnDeregister(func(nodeID string) {
+				s.Assert().NotContains(registry.Nodes(), nodeID)
+				deregistered <- nodeID
+			}))
+	}
+
+	registered, deregistered := make(chan string, 1), make(chan string, 1)
+	dcr := newDcron(context.Background(), registered, deregistered)
+	s.Require().Nil(dcr.Start())
+	s.Assert().Equal(dcr.NodeID(), <-registered)
+	dcr.Stop()
+	s.Assert().Equal(dcr.NodeID(), <-deregistered)
+	// Stop again does not call it.
+	dcr.Stop()
+	s.Assert().Len(deregistered, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dcr = newDcron(ctx, registered, deregistered)
+	s.Require().Nil(dcr.Start())
+	s.Assert().Equal(dcr.NodeID(), <-registered)
+	cancel()
+	select {
+	case nodeID := <-deregistered:
+		s.Assert().Equal(dcr.NodeID(), nodeID)
+	case <-time.After(5 * time.Second):
+		s.Fail("OnDeregister is not called after the context is done")
+	}
+}
+
+func (s *testDcronTestSuite) Test_CoalescedJob() {
+	t := s.T()
+	registry := driver.NewMemoryRegistry()
+	nodes := make([]*dcron.Dcron, 0, 3)
+	var computed int32
+	consumed := make([]int32, 3)
+	var mut sync.Mutex
+	results := make(map[string]string)
+	for i := 0; i < 3; i++ {
+		i := i
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewMemoryDriver(registry),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.CronOptionSeconds())
+		s.Require().Nil(dcr.AddCoalescedJob("coalesced", "* * * * * *", func(ctx context.Context) (string, error) {
+			atomic.AddInt32(&computed, 1)
+			scheduled, _ := dcron.ScheduledTimeFromContext(ctx)
+			return scheduled.Format(time.RFC3339), nil
+		}, func(ctx context.Context, result string, err error) {
+			s.Assert().Nil(err)
+			scheduled, _ := dcron.ScheduledTimeFromContext(ctx)
+			mut.Lock()
+			results[fmt.Sprintf("%d-%s", i, scheduled.Format(time.RFC3339))] = result
+			mut.Unlock()
+			atomic.AddInt32(&consumed[i], 1)
+		}))
+		nodes = append(nodes, dcr)
+	}
+	for _, dcr := range nodes {
+		s.Require().Nil(dcr.Start())
+	}
+	defer func() {
+		for _, dcr := range nodes {
+			dcr.Stop()
+		}
+	}()
+
+	s.Require().Eventually(func() bool {
+		for i := range nodes {
+			if atomic.LoadInt32(&consumed[i]) < 3 {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+	var total int32
+	for i := range nodes {
+		total += atomic.LoadInt32(&consumed[i])
+	}
+	s.Assert().Less(atomic.LoadInt32(&computed), total)
+	// the locks of the runs are released, not left to the driver.
+	for _, dcr := range nodes {
+		dcr.Stop()
+	}
+	s.Assert().Eventually(func() bool {
+		return len(registry.LockKeys()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	mut.Lock()
+	defer mut.Unlock()
+	for key, result := range results {
+		// every node consumes the result of its own run.
+		s.Assert().Equal(key[2:], result)
+	}
+
+	dcr := dcron.NewDcronWithOption(t.Name(), &MockDriver{})
+	s.Assert().ErrorIs(dcr.AddCoalescedJob("coalesced", "@hourly", func(ctx context.Context) (string, error) {
+		return "", nil
+	}, func(ctx context.Context, result string, err error) {}), dcron.ErrCoalesceUnsupported)
+}
+
+func (s *testDcronTestSuite) Test_ServicesIsolated() {
+	registry := driver.NewMemoryRegistry()
+	// the key prefix of "tenant" would be a prefix of "tenant:billing", so
+	// ':' is rejected in the service names.
+	invalid := dcron.NewDcronWithOption("tenant:billing", driver.NewMemoryDriver(registry))
+	s.Assert().ErrorIs(invalid.Err(), driver.ErrInvalidServiceName)
+	s.Assert().ErrorIs(invalid.Start(), driver.ErrInvalidServiceName)
+	services := map[string]int{"tenant": 2, "tenant-billing": 1}
+	nodes := make(map[string][]*dcron.Dcron)
+	runs := make(map[string]*int32)
+	for service, count := range services {
+		runs[service] = new(int32)
+		for i := 0; i < count; i++ {
+			runs := runs[service]
+			dcr := dcron.NewDcronWithOption(service, driver.NewMemoryDriver(registry),
+				dcron.WithNodeUpdateDuration(time.Second),
+				dcron.CronOptionSeconds())
+			s.Require().Nil(dcr.AddFunc("job", "* * * * * *", func() {
+				atomic.AddInt32(runs, 1)
+			}))
+			for j := 0; j < 10; j++ {
+				s.Require().Nil(dcr.AddFunc(fmt.Sprintf("job-%d", j), "@hourly", func() {}))
+			}
+			s.Require().Nil(dcr.Start())
+			defer dcr.Stop()
+			nodes[service] = append(nodes[service], dcr)
+		}
+	}
+
+	for service, dcrs := range nodes {
+		nodeIDs := make([]string, 0, len(dcrs))
+		for _, dcr := range dcrs {
+			nodeIDs = append(nodeIDs, dcr.NodeID())
+		}
+		for _, dcr := range dcrs {
+			// the nodes started earlier see the later ones in their next sync.
+			s.Require().Eventually(func() bool {
+				_, err := dcr.GetJobOwnerNode("job-0")
+				return len(dcr.Nodes()) == len(nodeIDs) && err == nil
+			}, 5*time.Second, 10*time.Millisecond, service)
+			s.Assert().ElementsMatch(nodeIDs, dcr.Nodes(), service)
+			for j := 0; j < 10; j++ {
+				owner, err := dcr.GetJobOwnerNode(fmt.Sprintf("job-%d", j))
+				s.Require().Nil(err)
+				s.Assert().Contains(nodeIDs, owner, service)
+			}
+		}
+	}
+	// each service runs its own job once per second.
+	s.Require().Eventually(func() bool {
+		return atomic.LoadInt32(runs["tenant"]) >= 2 && atomic.LoadInt32(runs["tenant-billing"]) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *testDcronTestSuite) Test_RecentlyRanLocally() {
+	registry := driver.NewMemoryRegistry()
+	nodes := make([]*dcron.Dcron, 0, 2)
+	for i := 0; i < 2; i++ {
+		dcr := dcron.NewDcronWithOption(s.T().Name(), driver.NewMemoryDriver(registry),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.CronOptionSeconds())
+		s.Require().Nil(dcr.AddFunc("job", "* * * * * *", func() {}))
+		_, ok := dcr.RecentlyRanLocally("job")
+		s.Assert().False(ok)
+		nodes = append(nodes, dcr)
+	}
+	for _, dcr := range nodes {
+		s.Require().Nil(dcr.Start())
+		defer dcr.Stop()
+	}
+	var owner, other *dcron.Dcron
+	s.Require().Eventually(func() bool {
+		owner0, err0 := nodes[0].GetJobOwnerNode("job")
+		owner1, err1 := nodes[1].GetJobOwnerNode("job")
+		if err0 != nil || err1 != nil || owner0 != owner1 || len(nodes[0].Nodes()) != 2 {
+			return false
+		}
+		owner, other = nodes[0], nodes[1]
+		if owner0 == nodes[1].NodeID() {
+			owner, other = nodes[1], nodes[0]
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	// the first node started may have run it before the other joined.
+	agreed := time.Now()
+	s.Require().Eventually(func() bool {
+		ranAt, ok := owner.RecentlyRanLocally("job")
+		return ok && ranAt.After(agreed)
+	}, 5*time.Second, 10*time.Millisecond)
+	if ranAt, ok := other.RecentlyRanLocally("job"); ok {
+		s.Assert().True(ranAt.Before(agreed))
+	}
+}
+
+func (s *testDcronTestSuite) Test_RingStats() {
+	t := s.T()
+	registry := driver.NewMemoryRegistry()
+	nodes := make([]*dcron.Dcron, 0, 2)
+	for i := 1; i <= 2; i++ {
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewMemoryDriver(registry),
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithHashReplicas(10),
+			dcron.WithNodeWeight(i))
+		s.Assert().Empty(dcr.RingStats())
+		s.Require().Nil(dcr.Start())
+		nodes = append(nodes, dcr)
+	}
+	defer func() {
+		for _, dcr := range nodes {
+			dcr.Stop()
+		}
+	}()
+	s.Require().Eventually(func() bool {
+		return len(nodes[0].RingStats()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stats := nodes[0].RingStats()
+	s.Assert().Less(stats[0].NodeID, stats[1].NodeID)
+	var total float64
+	for _, stat := range stats {
+		weight := 1
+		if stat.NodeID == nodes[1].NodeID() {
+			weight = 2
+		}
+		s.Assert().Equal(10*weight, stat.VirtualNodes)
+		total += stat.Share
+	}
+	s.Assert().InDelta(1, total, 1e-6)
+}
+
+func (s *testDcronTestSuite) Test_SetHashReplicas() {
+	t := s.T()
+	registry := driver.NewMemoryRegistry()
+	nodes := make([]*dcron.Dcron, 0, 2)
+	var rebalanced int32
+	for i := 0; i < 2; i++ {
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewMemoryDriver(registry),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithHashReplicas(1))
+		for j := 0; j < 20; j++ {
+			s.Require().Nil(dcr.AddFunc(fmt.Sprintf("job-%d", j), "@hourly", func() {}))
+		}
+		dcr.OnJobRebalanced(func(jobName, oldOwner, newOwner string) {
+			atomic.AddInt32(&rebalanced, 1)
+		})
+		s.Require().Nil(dcr.Start())
+		nodes = append(nodes, dcr)
+	}
+	defer func() {
+		for _, dcr := range nodes {
+			dcr.Stop()
+		}
+	}()
+	agreed := func() bool {
+		owner0, err0 := nodes[0].GetJobOwnerNode("job-0")
+		owner1, err1 := nodes[1].GetJobOwnerNode("job-0")
+		return err0 == nil && err1 == nil && owner0 == owner1 &&
+			len(nodes[0].RingStats()) == 2 && len(nodes[1].RingStats()) == 2
+	}
+	s.Require().Eventually(agreed, 5*time.Second, 10*time.Millisecond)
+	atomic.StoreInt32(&rebalanced, 0)
+
+	s.Assert().ErrorIs(nodes[0].SetHashReplicas(0), dcron.ErrInvalidHashReplicas)
+	for _, dcr := range nodes {
+		s.Require().Nil(dcr.SetHashReplicas(100))
+		for _, stat := range dcr.RingStats() {
+			s.Assert().Equal(100, stat.VirtualNodes)
+		}
+	}
+	s.Assert().Greater(atomic.LoadInt32(&rebalanced), int32(0))
+	// the owners are not decided until the next sync.
+	_, err := nodes[0].GetJobOwnerNode("job-0")
+	s.Assert().ErrorIs(err, dcron.ErrNodePoolIsUpgrading)
+	s.Assert().Eventually(agreed, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *testDcronTestSuite) Test_JobSetCheck() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	var mut sync.Mutex
+	var reports [][]string
+	newNode := func(opts ...dcron.Option) *dcron.Dcron {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		opts = append(opts,
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second))
+		return dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli), opts...)
+	}
+	dcrA := newNode(dcron.WithJobSetCheck(func(diverged []string) {
+		mut.Lock()
+		defer mut.Unlock()
+		reports = append(reports, diverged)
+	}))
+	dcrB := newNode(dcron.WithJobSetCheck(nil))
+	s.Require().Nil(dcrA.AddFunc("a", "* * * * *", func() {}))
+	s.Require().Nil(dcrA.AddFunc("b", "* * * * *", func() {}))
+	s.Require().Nil(dcrB.AddFunc("a", "* * * * *", func() {}))
+	dcrA.Start()
+	dcrB.Start()
+	defer dcrA.Stop()
+	defer dcrB.Stop()
+
+	lastReport := func() (diverged []string, ok bool) {
+		mut.Lock()
+		defer mut.Unlock()
+		if len(reports) == 0 {
+			return nil, false
+		}
+		return reports[len(reports)-1], true
+	}
+	s.Require().Eventually(func() bool {
+		diverged, ok := lastReport()
+		return ok && len(diverged) == 1 && diverged[0] == dcrB.NodeID()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.Require().Nil(dcrB.AddFunc("b", "* * * * *", func() {}))
+	s.Require().Eventually(func() bool {
+		diverged, ok := lastReport()
+		return ok && len(diverged) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *testDcronTestSuite) Test_ClockSkewWarning() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	newNode := func(opts ...dcron.Option) *dcron.Dcron {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		opts = append(opts,
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithClockSkewWarning(time.Minute))
+		return dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli), opts...)
+	}
+	type report struct {
+		nodeID string
+		skew   time.Duration
+	}
+	reports := make(chan report, 10)
+	dcrA := newNode(dcron.WithClockSkewCallback(func(nodeID string, skew time.Duration) {
+		reports <- report{nodeID, skew}
+	}))
+	dcrB := newNode(dcron.WithClock(testclock.New(time.Now().Add(time.Hour))))
+	dcrA.Start()
+	dcrB.Start()
+	defer dcrA.Stop()
+	defer dcrB.Stop()
+
+	select {
+	case r := <-reports:
+		s.Assert().Equal(dcrB.NodeID(), r.nodeID)
+		s.Assert().InDelta(time.Hour, r.skew, float64(time.Minute))
+	case <-time.After(5 * time.Second):
+		s.FailNow("the clock skew is not reported")
+	}
+	// it is reported once while the node is skewed.
+	select {
+	case r := <-reports:
+		s.Failf("reported again", "%v", r)
+	case <-time.After(2 * time.Second):
+	}
+}
+
+func (s *testDcronTestSuite) Test_OwnershipConflictDetection() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	type report struct {
+		jobName string
+		nodeIDs []string
+	}
+	reports := make(chan report, 100)
+	newNode := func(opts ...dcron.Option) *dcron.Dcron {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		opts = append(opts,
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithOwnershipConflictDetection(500*time.Millisecond),
+			dcron.WithOwnershipConflictCallback(func(jobName string, nodeIDs []string) {
+				reports <- report{jobName, nodeIDs}
+			}))
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli), opts...)
+		for i := 0; i < 20; i++ {
+			s.Require().Nil(dcr.AddFunc(fmt.Sprintf("job-%d", i), "* * * * *", func() {}))
+		}
+		s.Require().Nil(dcr.AddBroadcastJob("broadcast", "* * * * *", func() {}))
+		return dcr
+	}
+	dcrA := newNode()
+	// B hashes the jobs differently from A, so they both claim some jobs.
+	dcrB := newNode(dcron.WithHashFn(func(data []byte) uint32 {
+		h := fnv.New32a()
+		h.Write(data)
+		return h.Sum32()
+	}))
+	dcrA.Start()
+	dcrB.Start()
+	defer dcrA.Stop()
+	defer dcrB.Stop()
+
+	nodeIDs := []string{dcrA.NodeID(), dcrB.NodeID()}
+	sort.Strings(nodeIDs)
+	select {
+	case r := <-reports:
+		s.Assert().NotEqual("broadcast", r.jobName)
+		s.Assert().Equal(nodeIDs, r.nodeIDs)
+		ownerA, err := dcrA.GetJobOwnerNode(r.jobName)
+		s.Require().Nil(err)
+		ownerB, err := dcrB.GetJobOwnerNode(r.jobName)
+		s.Require().Nil(err)
+		s.Assert().Equal(dcrA.NodeID(), ownerA)
+		s.Assert().Equal(dcrB.NodeID(), ownerB)
+	case <-time.After(5 * time.Second):
+		s.FailNow("the ownership conflict is not reported")
+	}
+}
+
+func (s *testDcronTestSuite) Test_HeartbeatTTL() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	redisCli := redis.NewClient(&redis.Options{
+		Addr: rds.Addr(),
+	})
+	dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+		dcron.WithLogger(dlog.NewLoggerForTest(t)),
+		dcron.WithNodeUpdateDuration(time.Second),
+		dcron.WithHeartbeatTTL(15*time.Second))
+	s.Require().Nil(dcr.Err())
+	dcr.Start()
+	defer dcr.Stop()
+	s.Assert().Equal(15*time.Second, rds.TTL(dcr.NodeID()))
+	s.Assert().Equal(1, dcr.NodeCount())
+}
+
+func (s *testDcronTestSuite) Test_CatchUp() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	clock := testclock.New(time.Date(2024, 1, 1, 10, 0, 30, 0, time.UTC))
+	var mut sync.Mutex
+	var runs []time.Time
+	runCount := func() int {
+		mut.Lock()
+		defer mut.Unlock()
+		return len(runs)
+	}
+	newNode := func(opts ...dcron.Option) *dcron.Dcron {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		opts = append(opts,
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithClock(clock),
+			dcron.CronOptionLocation(time.UTC))
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli), opts...)
+		s.Require().Nil(dcr.AddJobWithTime("hourly", "0 * * * *", func(scheduled time.Time) {
+			mut.Lock()
+			defer mut.Unlock()
+			runs = append(runs, scheduled)
+		}))
+		return dcr
+	}
+
+	// the last run is recorded at 10:00:30.
+	dcr1 := newNode()
+	dcr1.Start()
+	s.Require().Eventually(func() bool {
+		return dcr1.TriggerJob("hourly") == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	dcr1.Stop()
+	s.Require().Equal(1, runCount())
+
+	// 11:00, 12:00 and 13:00 are missed, the last 2 of them catch up, each
+	// with its own scheduled time, so they do not share an execution lock.
+	clock.Advance(3 * time.Hour)
+	dcr2 := newNode(dcron.WithCatchUp(2), dcron.WithExecutionLock(5*time.Second))
+	dcr2.Start()
+	defer dcr2.Stop()
+	s.Require().Eventually(func() bool {
+		return runCount() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	lastRun, _, err := dcr2.LastRunTime("hourly")
+	s.Require().Nil(err)
+	s.Assert().True(clock.Now().Equal(lastRun))
+	<-time.After(2 * time.Second)
+	mut.Lock()
+	defer mut.Unlock()
+	s.Require().Len(runs, 3)
+	s.Assert().True(runs[1].Equal(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)), runs[1])
+	s.Assert().True(runs[2].Equal(time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC)), runs[2])
+}
+
+// splitBrainDriver only sees itself, so all the nodes own all the jobs.
+type splitBrainDriver struct {
+	*driver.RedisDriver
+}
+
+func (sd splitBrainDriver) GetNodes(ctx context.Context) ([]string, error) {
+	return []string{sd.NodeID()}, nil
+}
+
+func (s *testDcronTestSuite) Test_ExecutionLock() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	var mu sync.Mutex
+	// the runs of each scheduled second.
+	runs := make(map[int64]int)
+	newDcron := func(opts ...dcron.Option) *dcron.Dcron {
+		redisCli := redis.NewClient(&redis.Options{
+			Addr: rds.Addr(),
+		})
+		drv := splitBrainDriver{driver.NewRedisDriver(redisCli).(*driver.RedisDriver)}
+		dcr := dcron.NewDcronWithOption(t.Name(), drv, append(opts,
+			dcron.CronOptionSeconds(),
+			dcron.WithLogger(dlog.NewLoggerForTest(t)),
+			dcron.WithNodeUpdateDuration(time.Second))...)
+		s.Require().Nil(dcr.AddFunc("job", "* * * * * *", func() {
+			mu.Lock()
+			defer mu.Unlock()
+			runs[time.Now().Unix()]++
+		}))
+		return dcr
+	}
+	maxRuns := func() (max int) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, n := range runs {
+			if n > max {
+				max = n
+			}
+		}
+		runs = make(map[int64]int)
+		return
+	}
+
+	// without the lock, both nodes run the job.
+	dcr1, dcr2 := newDcron(), newDcron()
+	dcr1.Start()
+	dcr2.Start()
+	<-time.After(3 * time.Second)
+	dcr1.Stop()
+	dcr2.Stop()
+	s.Assert().Equal(2, maxRuns())
+
+	dcr3 := newDcron(dcron.WithExecutionLock(5 * time.Second))
+	dcr4 := newDcron(dcron.WithExecutionLock(5 * time.Second))
+	dcr3.Start()
+	dcr4.Start()
+	<-time.After(3 * time.Second)
+	dcr3.Stop()
+	dcr4.Stop()
+	s.Assert().Equal(1, maxRuns())
+}
+
+func (s *testDcronTestSuite) Test_TriggerJobExecutionLock() {
+	t := s.T()
+	drv := driver.NewMemoryDriver(driver.NewMemoryRegistry())
+	dcr := dcron.NewDcronWithOption(t.Name(), drv,
+		dcron.CronOptionSeconds(),
+		dcron.WithLogger(dlog.NewLoggerForTest(t)),
+		dcron.WithNodeUpdateDuration(time.Second),
+		dcron.WithExecutionLock(5*time.Second))
+	runs := make(chan time.Time, 10)
+	s.Require().Nil(dcr.AddJobWithTime("job", "* * * * * *", func(scheduled time.Time) {
+		runs <- scheduled
+	}))
+	s.Require().Nil(dcr.Start())
+	defer dcr.Stop()
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		s.FailNow("the job is not scheduled")
+	}
+	// the trigger in the hold of the execution lock of the scheduled run
+	// runs with the time of calling.
+	before := time.Now()
+	s.Require().Nil(dcr.TriggerJob("job"))
+	for {
+		select {
+		case scheduled := <-runs:
+			if scheduled.Before(before) || scheduled.Nanosecond() == 0 {
+				continue
+			}
+			s.Assert().WithinDuration(before, scheduled, time.Second)
+			return
+		default:
+			s.FailNow("the triggered run is skipped")
+		}
+	}
+}
+
+// blockingLockDriver blocks in AcquireLock until ctx is done.
+type blockingLockDriver struct {
+	*driver.MemoryDriver
+	acquiring chan struct{}
+}
+
+func (bd blockingLockDriver) AcquireLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	bd.acquiring <- struct{}{}
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func (s *testDcronTestSuite) Test_ExecutionLockStopped() {
+	t := s.T()
+	drv := blockingLockDriver{
+		MemoryDriver: driver.NewMemoryDriver(driver.NewMemoryRegistry()).(*driver.MemoryDriver),
+		acquiring:    make(chan struct{}, 1),
+	}
+	dcr := dcron.NewDcronWithOption(t.Name(), drv,
+		dcron.WithLogger(dlog.NewLoggerForTest(t)),
+		dcron.WithNodeUpdateDuration(time.Second),
+		dcron.WithDriverTimeout(time.Minute),
+		dcron.WithExecutionLock(5*time.Second))
+	var called atomic.Int32
+	s.Require().Nil(dcr.AddFunc("job", "0 0 1 1 *", func() {
+		called.Add(1)
+	}))
+	s.Require().Nil(dcr.Start())
+	triggered := make(chan error, 1)
+	go func() {
+		triggered <- dcr.TriggerJob("job")
+	}()
+	<-drv.acquiring
+	dcr.Stop()
+	select {
+	case err := <-triggered:
+		s.Assert().Nil(err)
+	case <-time.After(5 * time.Second):
+		s.FailNow("the run is not dropped")
+	}
+	// the run waiting for the lock is dropped by Stop, not run unlocked.
+	s.Assert().Equal(int32(0), called.Load())
+}
+
+// blockingGetDriver blocks in Get of the keys of the job named slow
+// while block is set, until release is closed.
+type blockingGetDriver struct {
+	*driver.MemoryDriver
+	block   *atomic.Bool
+	getting chan struct{}
+	release chan struct{}
+}
+
+func (bd blockingGetDriver) Get(ctx context.Context, key string) (string, bool, error) {
+	if bd.block.Load() && strings.HasSuffix(key, "slow") {
+		bd.getting <- struct{}{}
+		<-bd.release
+	}
+	return bd.MemoryDriver.Get(ctx, key)
+}
+
+func (s *testDcronTestSuite) Test_ListJobsSlowDriver() {
+	t := s.T()
+	drv := blockingGetDriver{
+		MemoryDriver: driver.NewMemoryDriver(driver.NewMemoryRegistry()).(*driver.MemoryDriver),
+		block:        &atomic.Bool{},
+		getting:      make(chan struct{}, 1),
+		release:      make(chan struct{}),
+	}
+	dcr := dcron.NewDcronWithOption(t.Name(), drv,
+		dcron.WithLogger(dlog.NewLoggerForTest(t)),
+		dcron.WithNodeUpdateDuration(time.Second),
+		dcron.WithDriverTimeout(time.Minute))
+	s.Require().Nil(dcr.AddFunc("slow", "0 0 1 1 *", func() {}))
+	s.Require().Nil(dcr.Start())
+	defer dcr.Stop()
+	drv.block.Store(true)
+	listed := make(chan []dcron.JobMeta, 1)
+	go func() {
+		listed <- dcr.ListJobs()
+	}()
+	<-drv.getting
+	// the jobs can be added while ListJobs waits for the driver.
+	added := make(chan error, 1)
+	go func() {
+		added <- dcr.AddFunc("job", "0 0 1 1 *", func() {})
+	}()
+	select {
+	case err := <-added:
+		s.Assert().Nil(err)
+	case <-time.After(5 * time.Second):
+		s.Fail("AddFunc is blocked by ListJobs")
+	}
+	close(drv.release)
+	jobs := <-listed
+	s.Require().Len(jobs, 1)
+	s.Assert().Equal("slow", jobs[0].Name)
+	s.Assert().False(jobs[0].Paused)
+}
+
+func (s *testDcronTestSuite) Test_DedupKeyFunc() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	redisCli := redis.NewClient(&redis.Options{
+		Addr: rds.Addr(),
+	})
+	scheduled := make(chan time.Time, 10)
+	running, release := make(chan struct{}, 10), make(chan struct{})
+	dcr := dcron.NewDcronWithOption(t.Name(), driver.NewRedisDriver(redisCli),
+		dcron.CronOptionSeconds(),
+		dcron.WithLogger(dlog.NewLoggerForTest(t)),
+		dcron.WithNodeUpdateDuration(time.Second),
+		dcron.WithExecutionLock(5*time.Second),
+		dcron.WithDedupKeyFunc(func(jobName string, t time.Time) string {
+			select {
+			case scheduled <- t:
+			default:
+			}
+			return "custom:" + jobName
+		}))
+	s.Require().Nil(dcr.AddFunc("job", "* * * * * *", func() {
+		running <- struct{}{}
+		<-release
+	}))
+	dcr.Start()
+	defer dcr.Stop()
+	defer close(release)
+
+	select {
+	case <-running:
+	case <-time.After(5 * time.Second):
+		s.FailNow("the job is not run")
+	}
+	s.Assert().Zero((<-scheduled).Nanosecond())
+	locked := false
+	for _, key := range rds.Keys() {
+		locked = locked || strings.HasSuffix(key, "lock:custom:job")
+	}
+	s.Assert().True(locked, "%v", rds.Keys())
+}
+
+func (s *testDcronTestSuite) Test_MaxConcurrency() {
+	t := s.T()
+	for _, max := range []int{1, 2} {
+		rds := miniredis.RunT(t)
+		stopClock := followWallClock(rds)
+		var running, maxRunning atomic.Int32
+		job := func() {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			// longer than the heartbeat TTL, the permit must be refreshed.
+			<-time.After(2500 * time.Millisecond)
+		}
+		dcrs := make([]*dcron.Dcron, 0)
+		for i := 0; i < 3; i++ {
+			redisCli := redis.NewClient(&redis.Options{
+				Addr: rds.Addr(),
+			})
+			dcr := dcron.NewDcronWithOption(t.Name(), splitBrainDriver{driver.NewRedisDriver(redisCli).(*driver.RedisDriver)},
+				dcron.WithSeconds(),
+				dcron.WithLogger(dlog.NewLoggerForTest(t)),
+				dcron.WithNodeUpdateDuration(time.Second))
+			s.Require().Nil(dcr.AddJobWithMaxConcurrency("job", "* * * * * *", job, max))
+			dcrs = append(dcrs, dcr)
+		}
+		s.Assert().Equal(dcron.ErrInvalidMaxConcurrency, dcrs[0].AddJobWithMaxConcurrency("job0", "* * * * * *", job, 0))
+		for _, dcr := range dcrs {
+			dcr.Start()
+		}
+		<-time.After(6 * time.Second)
+		for _, dcr := range dcrs {
+			dcr.Stop()
+		}
+		stopClock()
+		s.Assert().Equal(int32(max), maxRunning.Load())
+	}
+}
+
+func (s *testDcronTestSuite) Test_GracefulHandover() {
+	t := s.T()
+	registry := driver.NewMemoryRegistry()
+	newDcron := func(name string) *dcron.Dcron {
+		return dcron.NewDcronWithOption(t.Name(), driver.NewMemoryDriver(registry),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.CronOptionSeconds(),
+			dcron.WithNodeID(name),
+			dcron.WithGracefulHandover())
+	}
+	type run struct {
+		nodeID     string
+		start, end time.Time
+	}
+	var runsMut sync.Mutex
+	runs := make([]run, 0)
+	dcrA, dcrB := newDcron("a"), newDcron("b")
+	for _, dcr := range []*dcron.Dcron{dcrA, dcrB} {
+		dcr := dcr
+		// the job moves to B once it joins.
+		s.Require().Nil(dcr.AddPinnedJob("job", "* * * * * *", driver.GetNodeIdWithName(t.Name(), "b", 0), func() {
+			start := time.Now()
+			// longer than the heartbeat TTL, the lock must be refreshed.
+			<-time.After(4 * time.Second)
+			runsMut.Lock()
+			defer runsMut.Unlock()
+			runs = append(runs, run{dcr.NodeID(), start, time.Now()})
+		}))
+	}
+	s.Require().Nil(dcrA.Start())
+	defer dcrA.Stop()
+	<-time.After(1500 * time.Millisecond)
+	s.Require().Nil(dcrB.Start())
+	defer dcrB.Stop()
+	<-time.After(11 * time.Second)
+
+	runsMut.Lock()
+	defer runsMut.Unlock()
+	var runsA, runsB []run
+	for _, r := range runs {
+		if r.nodeID == dcrA.NodeID() {
+			runsA = append(runsA, r)
+		} else {
+			runsB = append(runsB, r)
+		}
+	}
+	s.Require().NotEmpty(runsA)
+	s.Require().NotEmpty(runsB)
+	for _, a := range runsA {
+		for _, b := range runsB {
+			s.Assert().False(a.start.Before(b.end) && b.start.Before(a.end),
+				"the run in A %v-%v overlaps the run in B %v-%v", a.start, a.end, b.start, b.end)
+		}
+	}
+}
+
+func (s *testDcronTestSuite) Test_GracefulHandoverDeadOwner() {
+	t := s.T()
+	registry := driver.NewMemoryRegistry()
+	// the lock of the run in a node which died.
+	dead := driver.NewMemoryDriver(registry)
+	dead.Init(t.Name())
+	locked := time.Now()
+	ok, err := dead.(driver.LockDriver).AcquireLock(context.Background(), "handover:job", 2*time.Second)
+	s.Require().Nil(err)
+	s.Require().True(ok)
+
+	dcr := dcron.NewDcronWithOption(t.Name(), driver.NewMemoryDriver(registry),
+		dcron.WithNodeUpdateDuration(time.Second),
+		dcron.CronOptionSeconds(),
+		dcron.WithGracefulHandover())
+	started := make(chan time.Time, 10)
+	s.Require().Nil(dcr.AddFunc("job", "* * * * * *", func() { started <- time.Now() }))
+	s.Require().Nil(dcr.Start())
+	defer dcr.Stop()
+	select {
+	case start := <-started:
+		s.Assert().GreaterOrEqual(start.Sub(locked), 2*time.Second)
+	case <-time.After(5 * time.Second):
+		s.FailNow("the job does not run after the lock expired")
+	}
+}
+
+func (s *testDcronTestSuite) Test_NodeEvents() {
+	t := s.T()
+	registry := driver.NewMemoryRegistry()
+	newDcron := func(name string) *dcron.Dcron {
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewMemoryDriver(registry),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithNodeID(name))
+		for i := 0; i < 10; i++ {
+			s.Require().Nil(dcr.AddFunc(fmt.Sprintf("job%d", i), "0 0 1 1 *", func() {}))
+		}
+		return dcr
+	}
+	dcrA, dcrB := newDcron("a"), newDcron("b")
+	events := dcrA.Events()
+	waitFor := func(typ dcron.EventType, nodeID string) dcron.Event {
+		for {
+			select {
+			case event := <-events:
+				if event.Type == typ && (nodeID == "" || event.NodeID == nodeID) {
+					return event
+				}
+			case <-time.After(5 * time.Second):
+				s.FailNow("no event", typ.String())
+			}
+		}
+	}
+	s.Require().Nil(dcrA.Start())
+	defer dcrA.Stop()
+	waitFor(dcron.EventNodeJoined, dcrA.NodeID())
+
+	s.Require().Nil(dcrB.Start())
+	waitFor(dcron.EventNodeJoined, dcrB.NodeID())
+	rebalanced := waitFor(dcron.EventRebalanced, "")
+	s.Assert().Equal(dcrA.NodeID(), rebalanced.OldOwner)
+	s.Assert().Equal(dcrB.NodeID(), rebalanced.NewOwner)
+	s.Assert().True(strings.HasPrefix(rebalanced.JobName, "job"))
+
+	dcrB.Stop()
+	waitFor(dcron.EventNodeLeft, dcrB.NodeID())
+	s.Assert().Zero(dcrA.DroppedEvents())
+}
+
+func (s *testDcronTestSuite) Test_NodeGeneration() {
+	t := s.T()
+	rds := miniredis.RunT(t)
+	registry := driver.NewMemoryRegistry()
+	drivers := map[string]func() driver.DriverV2{
+		"memory": func() driver.DriverV2 {
+			return driver.NewMemoryDriver(registry)
+		},
+		"redis": func() driver.DriverV2 {
+			return driver.NewRedisDriver(redis.NewClient(&redis.Options{Addr: rds.Addr()}))
+		},
+	}
+	for name, newDriver := range drivers {
+		s.Run(name, func() {
+			newDcron := func(runs *int32) *dcron.Dcron {
+				dcr := dcron.NewDcronWithOption(t.Name(), newDriver(),
+					dcron.WithLogger(dlog.NewLoggerForTest(t)),
+					dcron.WithNodeUpdateDuration(time.Second),
+					dcron.WithNodeID("pod-0"),
+					dcron.WithNodeGeneration(),
+					dcron.CronOptionSeconds())
+				s.Require().Nil(dcr.AddFunc("job", "* * * * * *", func() { atomic.AddInt32(runs, 1) }))
+				return dcr
+			}
+			var oldRuns, newRuns int32
+			oldDcr := newDcron(&oldRuns)
+			s.Require().Nil(oldDcr.Start())
+			defer oldDcr.Stop()
+			s.Require().NotZero(oldDcr.Generation())
+
+			// the old incarnation is still alive when the new one
+			// registers the same nodeID, e.g. in a rolling restart.
+			newDcr := newDcron(&newRuns)
+			s.Require().Nil(newDcr.Start())
+			defer newDcr.Stop()
+			s.Require().Equal(oldDcr.NodeID(), newDcr.NodeID())
+			s.Require().Greater(newDcr.Generation(), oldDcr.Generation())
+
+			s.Require().Eventually(func() bool {
+				return errors.Is(oldDcr.HealthCheck(), dcron.ErrStaleGeneration)
+			}, 3*time.Second, 10*time.Millisecond)
+			s.Assert().False(oldDcr.IsLeader())
+			s.Assert().True(newDcr.IsLeader())
+			stale, fresh := atomic.LoadInt32(&oldRuns), atomic.LoadInt32(&newRuns)
+			<-time.After(3 * time.Second)
+			s.Assert().Equal(stale, atomic.LoadInt32(&oldRuns))
+			s.Assert().Greater(atomic.LoadInt32(&newRuns), fresh)
+
+			// the stale one does not delete the generation of the new one,
+			// and the new one registers the nodeID again by its heartbeat.
+			oldDcr.Stop()
+			s.Assert().Eventually(func() bool {
+				return newDcr.HealthCheck() == nil && newDcr.IsLeader()
+			}, 5*time.Second, 10*time.Millisecond)
+			<-time.After(2 * time.Second)
+			s.Assert().Nil(newDcr.HealthCheck())
+		})
+	}
+}
+
+func (s *testDcronTestSuite) Test_MaxRuns() {
+	t := s.T()
+	registry := driver.NewMemoryRegistry()
+	var runs, handled int32
+	newDcron := func() *dcron.Dcron {
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewMemoryDriver(registry),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithExpiredJobHandler(func(string) { atomic.AddInt32(&handled, 1) }),
+			dcron.CronOptionSeconds())
+		s.Require().Nil(dcr.AddJobWithMaxRuns("job", "* * * * * *", 3, func() { atomic.AddInt32(&runs, 1) }))
+		return dcr
+	}
+	nodes := []*dcron.Dcron{newDcron(), newDcron()}
+	for _, dcr := range nodes {
+		s.Require().Nil(dcr.Start())
+		defer dcr.Stop()
+	}
+	s.Require().Eventually(func() bool {
+		return !nodes[0].HasJob("job") && !nodes[1].HasJob("job")
+	}, 10*time.Second, 10*time.Millisecond)
+	s.Assert().Equal(int32(3), atomic.LoadInt32(&runs))
+	s.Assert().Equal(int32(1), atomic.LoadInt32(&handled))
+
+	// the count is kept in the driver, a new node removes the job.
+	late := newDcron()
+	s.Require().Nil(late.Start())
+	defer late.Stop()
+	s.Require().Eventually(func() bool {
+		return !late.HasJob("job")
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Assert().Equal(int32(3), atomic.LoadInt32(&runs))
+	s.Assert().Equal(int32(1), atomic.LoadInt32(&handled))
+}
+
+func (s *testDcronTestSuite) Test_Cordon() {
+	t := s.T()
+	registry := driver.NewMemoryRegistry()
+	var completed, canceled int32
+	runs := make([]int32, 2)
+	nodes := make([]*dcron.Dcron, 0, 2)
+	for i := 0; i < 2; i++ {
+		i := i
+		dcr := dcron.NewDcronWithOption(t.Name(), driver.NewMemoryDriver(registry),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.CronOptionSeconds())
+		for j := 0; j < 10; j++ {
+			s.Require().Nil(dcr.AddJobWithContext(fmt.Sprintf("job%d", j), "* * * * * *", func(ctx context.Context) {
+				atomic.AddInt32(&runs[i], 1)
+				select {
+				case <-time.After(1500 * time.Millisecond):
+					atomic.AddInt32(&completed, 1)
+				case <-ctx.Done():
+					atomic.AddInt32(&canceled, 1)
+				}
+			}))
+		}
+		s.Require().Nil(dcr.Start())
+		defer dcr.Stop()
+		nodes = append(nodes, dcr)
+	}
+	a, b := nodes[0], nodes[1]
+	s.Require().Eventually(func() bool {
+		return a.NodeCount() == 2 && b.NodeCount() == 2 &&
+			atomic.LoadInt32(&runs[0]) > 0 && atomic.LoadInt32(&runs[1]) > 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	s.Require().Nil(a.Cordon())
+	s.Assert().True(a.IsCordoned())
+	s.Assert().False(a.IsLeader())
+	s.Require().Eventually(func() bool {
+		return a.NodeCount() == 1 && b.NodeCount() == 1 && b.IsLeader()
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, job := range b.ListJobs() {
+		s.Assert().True(job.Owned, job.Name)
+	}
+	// the runs in flight in a are not canceled.
+	stopped := atomic.LoadInt32(&runs[0])
+	<-time.After(3 * time.Second)
+	s.Assert().Equal(stopped, atomic.LoadInt32(&runs[0]))
+	s.Assert().Zero(atomic.LoadInt32(&canceled))
+	s.Assert().NotZero(atomic.LoadInt32(&completed))
+
+	s.Require().Nil(a.Uncordon())
+	s.Assert().False(a.IsCordoned())
+	s.Require().Eventually(func() bool {
+		return a.NodeCount() == 2 && b.NodeCount() == 2 && atomic.LoadInt32(&runs[0]) > stopped
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// the cordon is cleared when the node is stopped.
+	s.Require().Nil(b.Cordon())
+	b.Stop()
+	s.Require().Nil(b.Start())
+	s.Require().Eventually(func() bool {
+		return a.NodeCount() == 2 && b.NodeCount() == 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// the cordon of a crashed node is cleared when it is started again.
+	newNode := func() *dcron.Dcron {
+		return dcron.NewDcronWithOption(t.Name(), driver.NewMemoryDriver(registry),
+			dcron.WithNodeUpdateDuration(time.Second),
+			dcron.WithNodeID("c"))
+	}
+	crashed := newNode()
+	s.Require().Nil(crashed.Start())
+	defer crashed.Stop()
+	s.Require().Nil(crashed.Cordon())
+	registry.ExpireNode(crashed.NodeID())
+	restarted := newNode()
+	s.Require().Nil(restarted.Start())
+	defer restarted.Stop()
+	s.Assert().Equal(crashed.NodeID(), restarted.NodeID())
+	s.Require().Eventually(func() bool {
+		return a.NodeCount() == 3 && b.NodeCount() == 3 && restarted.NodeCount() == 3
+	}, 10*time.Second, 10*time.Millisecond)
+
+	local := dcron.NewDcronWithOption(t.Name(), nil, dcron.RunningLocally())
+	s.Assert().Equal(dcron.ErrRunningLocally, local.Cordon())
 }
 
 func TestDcronTestMain(t *testing.T) {
@@ -0,0 +1,61 @@
+package dcron
+
+import "sync/atomic"
+
+// DebugStats is the counts of the resources held by dcron in this node,
+// see Dcron.DebugStats.
+type DebugStats struct {
+	// Jobs is the number of the jobs added to dcron.
+	Jobs int
+	// Entries is the number of the entries in the cron scheduler.
+	Entries int
+	// RunningJobs is the number of the runs in-flight in this node.
+	RunningJobs int
+	// Goroutines is the number of the background goroutines of dcron,
+	// e.g. the catch-up of a run skipped in a maintenance window. The runs
+	// of the jobs and the goroutines of the node pool and the driver are
+	// not counted.
+	Goroutines int
+	// Timers is the number of the pending timers of dcron, e.g. the
+	// jitter of a run.
+	Timers int
+}
+
+// DebugStats returns the counts of the resources held by dcron, to find
+// the leaks, e.g. a test asserts the counts return to the baseline after
+// adding and removing jobs. RemoveJob releases everything of the job but
+// the runs in-flight, and Stop stops the background goroutines and the
+// timers, some of them return shortly after Stop.
+func (d *Dcron) DebugStats() DebugStats {
+	d.jobsRWMut.RLock()
+	jobs := len(d.jobs)
+	d.jobsRWMut.RUnlock()
+	stats := DebugStats{
+		Jobs:       jobs,
+		Entries:    len(d.cr.Entries()),
+		Goroutines: int(atomic.LoadInt32(&d.goroutines)),
+		Timers:     int(atomic.LoadInt32(&d.timers)),
+	}
+	d.runningJobsMut.Lock()
+	for _, count := range d.runningJobs {
+		stats.RunningJobs += count
+	}
+	d.runningJobsMut.Unlock()
+	return stats
+}
+
+// goTracked runs fn in a new goroutine which is counted by DebugStats.
+func (d *Dcron) goTracked(fn func()) {
+	atomic.AddInt32(&d.goroutines, 1)
+	go func() {
+		defer atomic.AddInt32(&d.goroutines, -1)
+		fn()
+	}()
+}
+
+// trackTimer counts a pending timer in DebugStats until the returned
+// func is called.
+func (d *Dcron) trackTimer() (untrack func()) {
+	atomic.AddInt32(&d.timers, 1)
+	return func() { atomic.AddInt32(&d.timers, -1) }
+}
@@ -0,0 +1,116 @@
+package dlog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvLogLevel is the environment variable of the level of the default
+// loggers, e.g. DCRON_LOG_LEVEL=error, see ParseLevel for the values.
+const EnvLogLevel = "DCRON_LOG_LEVEL"
+
+// Level is the minimum level of the messages a Logger logs.
+type Level int8
+
+const (
+	LevelInfo Level = iota
+	LevelWarn
+	LevelError
+	// LevelOff logs nothing.
+	LevelOff
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	case LevelOff:
+		return "off"
+	}
+	return fmt.Sprintf("Level(%d)", int8(l))
+}
+
+// ParseLevel parses "info", "warn", "error" or "off", case-insensitively.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	case "off":
+		return LevelOff, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+// LevelFromEnv returns the level set by EnvLogLevel, ok is false
+// if it is not set or invalid.
+func LevelFromEnv() (level Level, ok bool) {
+	s, ok := os.LookupEnv(EnvLogLevel)
+	if !ok {
+		return LevelInfo, false
+	}
+	level, err := ParseLevel(s)
+	return level, err == nil
+}
+
+// NewLevelLogger returns a Logger which logs the messages of level
+// and above to l.
+func NewLevelLogger(l PrintfLogger, level Level) Logger {
+	return &StdLogger{Log: l, LogVerbose: true, Level: level}
+}
+
+// LevelFilter drops the messages below Level, and passes the others
+// to Logger. The Printf messages are not filtered.
+type LevelFilter struct {
+	Logger
+	Level Level
+}
+
+// WithLevel returns a Logger which drops the messages of l below level.
+func WithLevel(l Logger, level Level) Logger {
+	return &LevelFilter{Logger: l, Level: level}
+}
+
+func (f *LevelFilter) Infof(format string, args ...any) {
+	if f.Level <= LevelInfo {
+		f.Logger.Infof(format, args...)
+	}
+}
+
+func (f *LevelFilter) Warnf(format string, args ...any) {
+	if f.Level <= LevelWarn {
+		f.Logger.Warnf(format, args...)
+	}
+}
+
+func (f *LevelFilter) Errorf(format string, args ...any) {
+	if f.Level <= LevelError {
+		f.Logger.Errorf(format, args...)
+	}
+}
+
+func (f *LevelFilter) Infow(msg string, keysAndValues ...any) {
+	if f.Level <= LevelInfo {
+		Infow(f.Logger, msg, keysAndValues...)
+	}
+}
+
+func (f *LevelFilter) Warnw(msg string, keysAndValues ...any) {
+	if f.Level <= LevelWarn {
+		Warnw(f.Logger, msg, keysAndValues...)
+	}
+}
+
+func (f *LevelFilter) Errorw(msg string, keysAndValues ...any) {
+	if f.Level <= LevelError {
+		Errorw(f.Logger, msg, keysAndValues...)
+	}
+}
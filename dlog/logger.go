@@ -1,6 +1,8 @@
 package dlog
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -19,23 +21,91 @@ type Logger interface {
 	Errorf(string, ...any)
 }
 
+// KVLogger is a Logger which logs the key-value pairs as fields,
+// e.g. the logger returned by NewSlogLogger. keysAndValues are
+// alternating keys and values, like log/slog.
+type KVLogger interface {
+	Logger
+	Infow(msg string, keysAndValues ...any)
+	Warnw(msg string, keysAndValues ...any)
+	Errorw(msg string, keysAndValues ...any)
+}
+
+// Infow logs msg and keysAndValues by l.Infow if l is a KVLogger,
+// otherwise by l.Infof as "msg k1=v1, k2=v2".
+func Infow(l Logger, msg string, keysAndValues ...any) {
+	if kl, ok := l.(KVLogger); ok {
+		kl.Infow(msg, keysAndValues...)
+		return
+	}
+	l.Infof("%s", formatKV(msg, keysAndValues))
+}
+
+// Warnw is the same as Infow at the warn level.
+func Warnw(l Logger, msg string, keysAndValues ...any) {
+	if kl, ok := l.(KVLogger); ok {
+		kl.Warnw(msg, keysAndValues...)
+		return
+	}
+	l.Warnf("%s", formatKV(msg, keysAndValues))
+}
+
+// Errorw is the same as Infow at the error level.
+func Errorw(l Logger, msg string, keysAndValues ...any) {
+	if kl, ok := l.(KVLogger); ok {
+		kl.Errorw(msg, keysAndValues...)
+		return
+	}
+	l.Errorf("%s", formatKV(msg, keysAndValues))
+}
+
+func formatKV(msg string, keysAndValues []any) string {
+	if len(keysAndValues) == 0 {
+		return msg
+	}
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(", ")
+		}
+		if i+1 < len(keysAndValues) {
+			fmt.Fprintf(&b, "%v=%v", keysAndValues[i], keysAndValues[i+1])
+		} else {
+			fmt.Fprintf(&b, "%v", keysAndValues[i])
+		}
+	}
+	return b.String()
+}
+
 type StdLogger struct {
-	Log        PrintfLogger
+	Log PrintfLogger
+	// LogVerbose must be true to log the Info messages.
 	LogVerbose bool
+	// Level is the minimum level of the messages to log.
+	Level Level
 }
 
 func (l *StdLogger) Infof(format string, args ...any) {
-	if !l.LogVerbose {
+	if !l.LogVerbose || l.Level > LevelInfo {
 		return
 	}
 	l.Log.Printf("[INFO] "+format, args...)
 }
 
 func (l *StdLogger) Warnf(format string, args ...any) {
+	if l.Level > LevelWarn {
+		return
+	}
 	l.Log.Printf("[WARN] "+format, args...)
 }
 
 func (l *StdLogger) Errorf(format string, args ...any) {
+	if l.Level > LevelError {
+		return
+	}
 	l.Log.Printf("[ERROR] "+format, args...)
 }
 
@@ -73,6 +143,10 @@ func VerbosePrintfLogger(l PrintfLogger) Logger {
 }
 
 // 默认的Logger构造函数，会打印出所有WARN level以上的LOG
+// 如果设置了环境变量 DCRON_LOG_LEVEL，则按照其指定的 level 打印
 func DefaultPrintfLogger(l PrintfLogger) Logger {
+	if level, ok := LevelFromEnv(); ok {
+		return NewLevelLogger(l, level)
+	}
 	return WarnPrintfLogger(l)
 }
@@ -0,0 +1,75 @@
+package dlog_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libi/dcron/dlog"
+	"github.com/stretchr/testify/require"
+)
+
+type printfRecorder struct {
+	lines []string
+}
+
+func (p *printfRecorder) Printf(format string, args ...any) {
+	p.lines = append(p.lines, fmt.Sprintf(format, args...))
+}
+
+func TestKVFallback(t *testing.T) {
+	recorder := &printfRecorder{}
+	logger := dlog.VerbosePrintfLogger(recorder)
+	dlog.Infow(logger, "skip")
+	dlog.Infow(logger, "delay", "job_name", "job1", "duration", time.Second)
+	dlog.Errorw(logger, "odd", "key")
+	require.Equal(t, []string{
+		"[INFO] skip",
+		"[INFO] delay job_name=job1, duration=1s",
+		"[ERROR] odd key",
+	}, recorder.lines)
+}
+
+func TestLevel(t *testing.T) {
+	recorder := &printfRecorder{}
+	logger := dlog.NewLevelLogger(recorder, dlog.LevelWarn)
+	logger.Infof("info")
+	logger.Warnf("warn")
+	logger.Errorf("error")
+	require.Equal(t, []string{"[WARN] warn", "[ERROR] error"}, recorder.lines)
+
+	recorder.lines = nil
+	filtered := dlog.WithLevel(dlog.VerbosePrintfLogger(recorder), dlog.LevelError)
+	filtered.Infof("info")
+	filtered.Warnf("warn")
+	dlog.Warnw(filtered, "warn")
+	dlog.Errorw(filtered, "error", "key", "value")
+	require.Equal(t, []string{"[ERROR] error key=value"}, recorder.lines)
+
+	for s, want := range map[string]dlog.Level{
+		"info": dlog.LevelInfo, "WARN": dlog.LevelWarn, "error": dlog.LevelError, " off ": dlog.LevelOff,
+	} {
+		level, err := dlog.ParseLevel(s)
+		require.Nil(t, err)
+		require.Equal(t, want, level)
+	}
+	_, err := dlog.ParseLevel("debug")
+	require.NotNil(t, err)
+}
+
+func TestDefaultPrintfLoggerLevelFromEnv(t *testing.T) {
+	recorder := &printfRecorder{}
+	dlog.DefaultPrintfLogger(recorder).Infof("info")
+	require.Empty(t, recorder.lines)
+
+	t.Setenv(dlog.EnvLogLevel, "info")
+	dlog.DefaultPrintfLogger(recorder).Infof("info")
+	require.Equal(t, []string{"[INFO] info"}, recorder.lines)
+
+	recorder.lines = nil
+	t.Setenv(dlog.EnvLogLevel, "error")
+	logger := dlog.DefaultPrintfLogger(recorder)
+	logger.Warnf("warn")
+	logger.Errorf("error")
+	require.Equal(t, []string{"[ERROR] error"}, recorder.lines)
+}
@@ -0,0 +1,57 @@
+//go:build go1.21
+
+package dlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SlogLogger is a KVLogger which logs by log/slog. The printf-style
+// messages are formatted and logged as the message without fields.
+type SlogLogger struct {
+	Log *slog.Logger
+}
+
+// NewSlogLogger returns a Logger which logs by l, Infof, Warnf and Errorf
+// are logged at the levels of slog, and Printf is logged at Info.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return &SlogLogger{Log: l}
+}
+
+func (l *SlogLogger) logf(level slog.Level, format string, args ...any) {
+	// skip formatting if the level is disabled.
+	if !l.Log.Enabled(context.Background(), level) {
+		return
+	}
+	l.Log.Log(context.Background(), level, fmt.Sprintf(format, args...))
+}
+
+func (l *SlogLogger) Printf(format string, args ...any) {
+	l.logf(slog.LevelInfo, format, args...)
+}
+
+func (l *SlogLogger) Infof(format string, args ...any) {
+	l.logf(slog.LevelInfo, format, args...)
+}
+
+func (l *SlogLogger) Warnf(format string, args ...any) {
+	l.logf(slog.LevelWarn, format, args...)
+}
+
+func (l *SlogLogger) Errorf(format string, args ...any) {
+	l.logf(slog.LevelError, format, args...)
+}
+
+func (l *SlogLogger) Infow(msg string, keysAndValues ...any) {
+	l.Log.Info(msg, keysAndValues...)
+}
+
+func (l *SlogLogger) Warnw(msg string, keysAndValues ...any) {
+	l.Log.Warn(msg, keysAndValues...)
+}
+
+func (l *SlogLogger) Errorw(msg string, keysAndValues ...any) {
+	l.Log.Error(msg, keysAndValues...)
+}
@@ -0,0 +1,37 @@
+//go:build go1.21
+
+package dlog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/libi/dcron/dlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := dlog.NewSlogLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	logger.Infof("job '%s' is skipped", "job1")
+	require.Zero(t, buf.Len(), "info should be filtered by the level")
+
+	logger.Errorf("job '%s' failed", "job1")
+	var record map[string]any
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "ERROR", record["level"])
+	require.Equal(t, "job 'job1' failed", record["msg"])
+
+	buf.Reset()
+	dlog.Warnw(logger, "delay", "job_name", "job1", "duration", time.Second)
+	record = nil
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "WARN", record["level"])
+	require.Equal(t, "delay", record["msg"])
+	require.Equal(t, "job1", record["job_name"])
+	require.Equal(t, float64(time.Second), record["duration"])
+}
@@ -0,0 +1,333 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/libi/dcron/dlog"
+)
+
+const (
+	consulDefaultTimeout = 10 * time.Second
+	// consul requires the session TTL is in [10s, 86400s].
+	consulMinSessionTTL = 10 * time.Second
+)
+
+type ConsulDriver struct {
+	c           *api.Client
+	serviceName string
+	nodeID      string
+	timeout     time.Duration
+	logger      dlog.Logger
+	weight      int
+	keyPrefix   string
+	nodeName    string
+	takeOver    bool
+	started     bool
+
+	sessionID string
+	// locks held by this node, key -> sessionID.
+	locks sync.Map
+
+	// this context is used to define
+	// the lifetime of this driver.
+	runtimeCtx    context.Context
+	runtimeCancel context.CancelFunc
+
+	sync.Mutex
+}
+
+func newConsulDriver(client *api.Client) *ConsulDriver {
+	cd := &ConsulDriver{
+		c:       client,
+		logger:  dlog.DefaultPrintfLogger(log.Default()),
+		timeout: consulDefaultTimeout,
+	}
+	cd.started = false
+	return cd
+}
+
+func (cd *ConsulDriver) Init(serviceName string, opts ...Option) {
+	cd.serviceName = serviceName
+	for _, opt := range opts {
+		cd.WithOption(opt)
+	}
+	cd.nodeID = cd.keyPrefix + GetNodeIdWithName(serviceName, cd.nodeName, cd.weight)
+}
+
+func (cd *ConsulDriver) NodeID() string {
+	return cd.nodeID
+}
+
+func (cd *ConsulDriver) GetNodes(ctx context.Context) (nodes []string, err error) {
+	q := (&api.QueryOptions{}).WithContext(ctx)
+	keys, _, err := cd.c.KV().Keys(cd.keyPrefix+GetKeyPre(cd.serviceName), "", q)
+	if err != nil {
+		return nil, err
+	}
+	nodes = make([]string, len(keys))
+	copy(nodes, keys)
+	return
+}
+
+func (cd *ConsulDriver) Start(ctx context.Context) (err error) {
+	cd.Lock()
+	defer cd.Unlock()
+	if cd.started {
+		err = errors.New("this driver is started")
+		return
+	}
+	if cd.nodeName != "" {
+		if err = CheckNodeName(cd.nodeName); err != nil {
+			return
+		}
+	}
+	// register
+	if err = cd.registerServiceNode(ctx, cd.nodeName != "" && cd.takeOver); err != nil {
+		cd.logger.Errorf("register service error=%v", err)
+		return
+	}
+	cd.runtimeCtx, cd.runtimeCancel = context.WithCancel(context.TODO())
+	cd.started = true
+	// heartbeat timer
+	go cd.heartBeat(cd.runtimeCtx, cd.sessionID)
+	return
+}
+
+func (cd *ConsulDriver) Stop(ctx context.Context) (err error) {
+	cd.Lock()
+	defer cd.Unlock()
+	if !cd.started {
+		return
+	}
+	cd.runtimeCancel()
+	cd.started = false
+	// the node key is deleted with the session.
+	_, err = cd.c.Session().Destroy(cd.sessionID, (&api.WriteOptions{}).WithContext(ctx))
+	if err != nil {
+		cd.logger.Errorf("unregister service node error %+v", err)
+	}
+	return
+}
+
+func (cd *ConsulDriver) WithOption(opt Option) (err error) {
+	switch opt.Type() {
+	case OptionTypeTimeout:
+		{
+			cd.timeout = opt.(TimeoutOption).timeout
+		}
+	case OptionTypeLogger:
+		{
+			cd.logger = opt.(LoggerOption).logger
+		}
+	case OptionTypeWeight:
+		{
+			cd.weight = opt.(WeightOption).weight
+		}
+	case OptionTypeKeyPrefix:
+		{
+			cd.keyPrefix = opt.(KeyPrefixOption).prefix
+		}
+	case OptionTypeNodeName:
+		{
+			cd.nodeName = opt.(NodeNameOption).name
+		}
+	case OptionTypeTakeOver:
+		{
+			cd.takeOver = true
+		}
+	}
+	return
+}
+
+// HealthCheck implements HealthChecker, it checks
+// the session of this node is still alive.
+func (cd *ConsulDriver) HealthCheck(ctx context.Context) error {
+	cd.Lock()
+	sessionID := cd.sessionID
+	cd.Unlock()
+	entry, _, err := cd.c.Session().Info(sessionID, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return errors.New("session of this node is expired")
+	}
+	return nil
+}
+
+func (cd *ConsulDriver) Get(ctx context.Context, key string) (value string, ok bool, err error) {
+	pair, _, err := cd.c.KV().Get(cd.keyPrefix+GetStoreKey(cd.serviceName, key), (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return "", false, err
+	}
+	if pair == nil {
+		return "", false, nil
+	}
+	return string(pair.Value), true, nil
+}
+
+func (cd *ConsulDriver) Set(ctx context.Context, key, value string) (err error) {
+	_, err = cd.c.KV().Put(&api.KVPair{
+		Key:   cd.keyPrefix + GetStoreKey(cd.serviceName, key),
+		Value: []byte(value),
+	}, (&api.WriteOptions{}).WithContext(ctx))
+	return
+}
+
+func (cd *ConsulDriver) Del(ctx context.Context, key string) (err error) {
+	_, err = cd.c.KV().Delete(cd.keyPrefix+GetStoreKey(cd.serviceName, key), (&api.WriteOptions{}).WithContext(ctx))
+	return
+}
+
+// private function
+
+func (cd *ConsulDriver) sessionTTL() time.Duration {
+	if cd.timeout < consulMinSessionTTL {
+		return consulMinSessionTTL
+	}
+	return cd.timeout
+}
+
+// registerServiceNode creates a session with TTL, and acquires the node key
+// with this session, the node key is deleted when the session is expired.
+// If takeOver is true, the session holding the node key is destroyed, so
+// the key can be acquired, see TakeOverOption.
+func (cd *ConsulDriver) registerServiceNode(ctx context.Context, takeOver bool) (err error) {
+	wopt := (&api.WriteOptions{}).WithContext(ctx)
+	cd.sessionID, _, err = cd.c.Session().Create(&api.SessionEntry{
+		Name:      cd.nodeID,
+		TTL:       cd.sessionTTL().String(),
+		Behavior:  api.SessionBehaviorDelete,
+		LockDelay: time.Millisecond,
+	}, wopt)
+	if err != nil {
+		return
+	}
+	if takeOver {
+		if err = cd.releaseNodeKey(ctx); err != nil {
+			_, _ = cd.c.Session().Destroy(cd.sessionID, wopt)
+			return
+		}
+	}
+	acquired, _, err := cd.c.KV().Acquire(&api.KVPair{
+		Key:     cd.nodeID,
+		Value:   []byte(cd.nodeID),
+		Session: cd.sessionID,
+	}, wopt)
+	if err != nil {
+		return
+	}
+	if !acquired {
+		_, _ = cd.c.Session().Destroy(cd.sessionID, wopt)
+		if cd.nodeName != "" {
+			return ErrNodeIDExist
+		}
+		return errors.New("acquire the node key failed")
+	}
+	return
+}
+
+// releaseNodeKey destroys the session of another node holding the node key.
+func (cd *ConsulDriver) releaseNodeKey(ctx context.Context) error {
+	pair, _, err := cd.c.KV().Get(cd.nodeID, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil || pair == nil || pair.Session == "" || pair.Session == cd.sessionID {
+		return err
+	}
+	cd.logger.Warnf("take over the node key %s from session %s", cd.nodeID, pair.Session)
+	_, err = cd.c.Session().Destroy(pair.Session, (&api.WriteOptions{}).WithContext(ctx))
+	return err
+}
+
+func (cd *ConsulDriver) heartBeat(ctx context.Context, sessionID string) {
+	tick := time.NewTicker(cd.sessionTTL() / 2)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			{
+				entry, _, err := cd.c.Session().Renew(sessionID, (&api.WriteOptions{}).WithContext(ctx))
+				if err != nil {
+					cd.logger.Errorf("renew session error %+v", err)
+					continue
+				}
+				if entry == nil {
+					// the session is expired, register this node again.
+					cd.logger.Warnf("session expired, register service node again")
+					cd.Lock()
+					if err = cd.registerServiceNode(ctx, false); err != nil {
+						cd.logger.Errorf("register service node error %+v", err)
+					} else {
+						sessionID = cd.sessionID
+					}
+					cd.Unlock()
+				}
+			}
+		case <-ctx.Done():
+			{
+				return
+			}
+		}
+	}
+}
+
+// AcquireLock acquires the key with a session whose TTL is ttl,
+// consul does not support a TTL less than 10s.
+func (cd *ConsulDriver) AcquireLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	if ttl < consulMinSessionTTL {
+		ttl = consulMinSessionTTL
+	}
+	wopt := (&api.WriteOptions{}).WithContext(ctx)
+	sessionID, _, err := cd.c.Session().Create(&api.SessionEntry{
+		Name:      cd.nodeID + ":" + key,
+		TTL:       ttl.String(),
+		Behavior:  api.SessionBehaviorDelete,
+		LockDelay: time.Millisecond,
+	}, wopt)
+	if err != nil {
+		return false, err
+	}
+	ok, _, err = cd.c.KV().Acquire(&api.KVPair{
+		Key:     cd.keyPrefix + GetStoreKey(cd.serviceName, key),
+		Value:   []byte(cd.nodeID),
+		Session: sessionID,
+	}, wopt)
+	if err != nil || !ok {
+		_, _ = cd.c.Session().Destroy(sessionID, wopt)
+		return false, err
+	}
+	cd.locks.Store(key, sessionID)
+	return true, nil
+}
+
+// ReleaseLock destroys the session of the lock, then the key is deleted.
+func (cd *ConsulDriver) ReleaseLock(ctx context.Context, key string) (err error) {
+	sessionID, ok := cd.locks.LoadAndDelete(key)
+	if !ok {
+		return nil
+	}
+	_, err = cd.c.Session().Destroy(sessionID.(string), (&api.WriteOptions{}).WithContext(ctx))
+	return
+}
+
+// RefreshLock renews the session of the lock for its ttl,
+// the ttl passed in is ignored.
+func (cd *ConsulDriver) RefreshLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	sessionID, ok := cd.locks.Load(key)
+	if !ok {
+		return false, nil
+	}
+	entry, _, err := cd.c.Session().Renew(sessionID.(string), (&api.WriteOptions{}).WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		cd.locks.Delete(key)
+		return false, nil
+	}
+	return true, nil
+}
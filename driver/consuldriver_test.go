@@ -0,0 +1,86 @@
+package driver_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/libi/dcron/dlog"
+	"github.com/libi/dcron/driver"
+	"github.com/stretchr/testify/require"
+)
+
+// consul has no embedded server for testing, these tests
+// run only if the address of a consul agent is set in CONSUL_ADDR.
+func testFuncNewConsulDriver(t *testing.T) driver.DriverV2 {
+	addr := os.Getenv("CONSUL_ADDR")
+	if addr == "" {
+		t.Skip("CONSUL_ADDR is not set")
+	}
+	cfg := api.DefaultConfig()
+	cfg.Address = addr
+	cli, err := api.NewClient(cfg)
+	require.Nil(t, err)
+	return driver.NewConsulDriver(cli)
+}
+
+func TestConsulDriver_GetNodes(t *testing.T) {
+	drvs := make([]driver.DriverV2, 0)
+	N := 10
+	for i := 0; i < N; i++ {
+		drv := testFuncNewConsulDriver(t)
+		drv.Init(
+			t.Name(),
+			driver.NewTimeoutOption(10*time.Second),
+			driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+		err := drv.Start(context.Background())
+		require.Nil(t, err)
+		drvs = append(drvs, drv)
+	}
+
+	for _, v := range drvs {
+		nodes, err := v.GetNodes(context.Background())
+		require.Nil(t, err)
+		require.Equal(t, N, len(nodes))
+	}
+
+	for _, v := range drvs {
+		v.Stop(context.Background())
+	}
+}
+
+func TestConsulDriver_Stop(t *testing.T) {
+	var err error
+	var nodes []string
+	drv1 := testFuncNewConsulDriver(t)
+	drv1.Init(t.Name(),
+		driver.NewTimeoutOption(10*time.Second),
+		driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+
+	drv2 := testFuncNewConsulDriver(t)
+	drv2.Init(t.Name(),
+		driver.NewTimeoutOption(10*time.Second),
+		driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+
+	require.Nil(t, drv2.Start(context.Background()))
+	require.Nil(t, drv1.Start(context.Background()))
+
+	nodes, err = drv1.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Len(t, nodes, 2)
+
+	require.Nil(t, drv1.Stop(context.Background()))
+	nodes, err = drv2.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Len(t, nodes, 1)
+
+	require.Nil(t, drv1.Start(context.Background()))
+	nodes, err = drv2.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Len(t, nodes, 2)
+
+	drv2.Stop(context.Background())
+	drv1.Stop(context.Background())
+}
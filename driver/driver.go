@@ -2,7 +2,11 @@ package driver
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
+	"github.com/go-zookeeper/zk"
+	"github.com/hashicorp/consul/api"
 	redis "github.com/redis/go-redis/v9"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
@@ -30,14 +34,99 @@ type DriverV2 interface {
 	WithOption(opt Option) (err error)
 }
 
+// KVDriver is an optional interface which can be implemented by a DriverV2.
+// It is a key-value store shared by all nodes of the same service,
+// dcron uses it to propagate the state of jobs over the cluster.
+// The keys are isolated by the service name.
+type KVDriver interface {
+	// get the value of key, ok is false if the key not exist.
+	Get(ctx context.Context, key string) (value string, ok bool, err error)
+	Set(ctx context.Context, key, value string) (err error)
+	Del(ctx context.Context, key string) (err error)
+}
+
+// LockDriver is an optional interface which can be implemented by a DriverV2.
+// It provides the distributed locks shared by all nodes of the same service,
+// the keys are isolated by the service name.
+type LockDriver interface {
+	// AcquireLock acquires the lock of key for ttl, ok is false
+	// if the lock is held by another node.
+	AcquireLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error)
+	// ReleaseLock releases the lock of key if it is held by this node.
+	ReleaseLock(ctx context.Context, key string) (err error)
+}
+
+// LockRefresher is an optional interface which can be implemented by a
+// LockDriver, it keeps a lock alive while the work guarded by it runs.
+type LockRefresher interface {
+	// RefreshLock resets the ttl of the lock of key, ok is false if the
+	// lock is not held by this node anymore, e.g. it is expired.
+	RefreshLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error)
+}
+
+// HealthChecker is an optional interface which can be implemented by a DriverV2,
+// it checks if the driver can reach its storage.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// NewRedisDriver create a redis driver, the redisClient can be
+// a single node client, a sentinel (failover) client or a cluster client.
 func NewRedisDriver(redisClient redis.UniversalClient) DriverV2 {
 	return newRedisDriver(redisClient)
 }
 
+// NewRedisDriverFromSentinel create a redis driver with a failover client.
+// After the master is failed over, the node is registered to the new master
+// in the next heartbeat.
+func NewRedisDriverFromSentinel(opts *redis.FailoverOptions) DriverV2 {
+	return newRedisDriver(redis.NewFailoverClient(opts))
+}
+
+// NewRedisClusterDriver create a redis driver with a cluster client.
+// Each node has its own key, so there is no multi-key operation
+// which may fail with CROSSSLOT.
+func NewRedisClusterDriver(opts *redis.ClusterOptions) DriverV2 {
+	return newRedisDriver(redis.NewClusterClient(opts))
+}
+
+// NewEtcdDriver create an etcd driver with a client configured by the caller,
+// e.g. with clientv3.Config.TLS and Username for a secured cluster.
+// The node is registered with a lease which is kept alive until Stop.
 func NewEtcdDriver(etcdCli *clientv3.Client) DriverV2 {
 	return newEtcdDriver(etcdCli)
 }
 
+// NewRedisZSetDriver create a redis driver which saves the nodes in a zset,
+// all nodes are saved in one key, so it also works in cluster mode.
 func NewRedisZSetDriver(redisClient redis.UniversalClient) DriverV2 {
 	return newRedisZSetDriver(redisClient)
 }
+
+// NewDualDriver create a driver which registers the node in both drivers
+// and reads the nodes from primary, to migrate from a driver to another,
+// see DualDriver.
+func NewDualDriver(primary, secondary DriverV2) DriverV2 {
+	return newDualDriver(primary, secondary)
+}
+
+// NewMemoryDriver create a driver which registers the node in registry in
+// the same process, to simulate a cluster in tests, see MemoryDriver.
+func NewMemoryDriver(registry *MemoryRegistry) DriverV2 {
+	return newMemoryDriver(registry)
+}
+
+// NewSQLDriver create a driver which registers the node in a table of the
+// database of dialect, e.g. a *sql.DB opened with a PostgreSQL or MySQL
+// driver, see SQLDriver.
+func NewSQLDriver(db *sql.DB, dialect SQLDialect) DriverV2 {
+	return newSQLDriver(db, dialect)
+}
+
+func NewConsulDriver(client *api.Client) DriverV2 {
+	return newConsulDriver(client)
+}
+
+func NewZookeeperDriver(conn *zk.Conn) DriverV2 {
+	return newZookeeperDriver(conn)
+}
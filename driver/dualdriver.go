@@ -0,0 +1,111 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// DualDriver registers the node in two drivers and reads the nodes from the
+// primary one, to migrate a service from a driver to another without
+// downtime. The node has the same nodeID in both drivers, so the nodes
+// reading either driver build the same hash ring once all of them are
+// registered in both:
+//
+//  1. Deploy all the nodes with NewDualDriver(old, new). They read the old
+//     driver, so they agree on the ownership with the nodes not deployed yet.
+//  2. Deploy all the nodes with NewDualDriver(new, old). All the nodes are
+//     registered in both, so the nodes reading either driver agree.
+//  3. Deploy all the nodes with the new driver only.
+//
+// During each deployment, the nodes see the membership changes the same way
+// as in a normal rolling deployment. A node whose registration in the
+// secondary driver expired, e.g. the secondary is unreachable, is missing
+// from the nodes reading it, so do not start the next step until all the
+// nodes are healthy.
+//
+// The optional interfaces like KVDriver and LockDriver are not implemented,
+// the features depending on them are disabled in the migration.
+type DualDriver struct {
+	primary   DriverV2
+	secondary DriverV2
+}
+
+func newDualDriver(primary, secondary DriverV2) *DualDriver {
+	return &DualDriver{
+		primary:   primary,
+		secondary: secondary,
+	}
+}
+
+// Init inits both drivers with opts. If opts has no NodeNameOption, a random
+// node name is added, so the node has the same nodeID in both drivers.
+func (dd *DualDriver) Init(serviceName string, opts ...Option) {
+	named := false
+	for _, opt := range opts {
+		if opt.Type() == OptionTypeNodeName {
+			named = true
+		}
+	}
+	if !named {
+		opts = append(opts, NewNodeNameOption(uuid.New().String()))
+	}
+	dd.primary.Init(serviceName, opts...)
+	dd.secondary.Init(serviceName, opts...)
+}
+
+func (dd *DualDriver) NodeID() string {
+	return dd.primary.NodeID()
+}
+
+// GetNodes returns the nodes in the primary driver.
+func (dd *DualDriver) GetNodes(ctx context.Context) (nodes []string, err error) {
+	return dd.primary.GetNodes(ctx)
+}
+
+// Start registers the node in both drivers, if the secondary one fails,
+// the primary one is stopped.
+func (dd *DualDriver) Start(ctx context.Context) (err error) {
+	if err = dd.primary.Start(ctx); err != nil {
+		return err
+	}
+	if err = dd.secondary.Start(ctx); err != nil {
+		_ = dd.primary.Stop(ctx)
+		return fmt.Errorf("start secondary driver: %w", err)
+	}
+	return nil
+}
+
+// Stop stops both drivers, it returns the error of the primary one if
+// both fail.
+func (dd *DualDriver) Stop(ctx context.Context) (err error) {
+	err = dd.primary.Stop(ctx)
+	if serr := dd.secondary.Stop(ctx); serr != nil && err == nil {
+		err = fmt.Errorf("stop secondary driver: %w", serr)
+	}
+	return err
+}
+
+func (dd *DualDriver) WithOption(opt Option) (err error) {
+	if err = dd.primary.WithOption(opt); err != nil {
+		return err
+	}
+	return dd.secondary.WithOption(opt)
+}
+
+// HealthCheck implements HealthChecker, it checks both drivers which
+// implement HealthChecker.
+func (dd *DualDriver) HealthCheck(ctx context.Context) error {
+	if hc, ok := dd.primary.(HealthChecker); ok {
+		if err := hc.HealthCheck(ctx); err != nil {
+			return err
+		}
+	}
+	if hc, ok := dd.secondary.(HealthChecker); ok {
+		if err := hc.HealthCheck(ctx); err != nil {
+			return fmt.Errorf("secondary driver: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package driver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/libi/dcron/dlog"
+	"github.com/libi/dcron/driver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDualDriver(t *testing.T) {
+	oldRds, newRds := miniredis.RunT(t), miniredis.RunT(t)
+	initDriver := func(drv driver.DriverV2) driver.DriverV2 {
+		drv.Init(t.Name(),
+			driver.NewTimeoutOption(5*time.Second),
+			driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+		require.Nil(t, drv.Start(context.Background()))
+		return drv
+	}
+	getNodes := func(drv driver.DriverV2) []string {
+		nodes, err := drv.GetNodes(context.Background())
+		require.Nil(t, err)
+		return nodes
+	}
+
+	// a node not deployed yet, which only knows the old driver.
+	oldOnly := initDriver(testFuncNewRedisDriver(oldRds.Addr()))
+	// the node of step 1, reading the old driver.
+	readOld := initDriver(driver.NewDualDriver(
+		testFuncNewRedisDriver(oldRds.Addr()), testFuncNewRedisDriver(newRds.Addr())))
+	// the node of step 2, reading the new driver.
+	readNew := initDriver(driver.NewDualDriver(
+		testFuncNewRedisDriver(newRds.Addr()), testFuncNewRedisDriver(oldRds.Addr())))
+
+	// the same nodeID is registered in both drivers.
+	require.True(t, oldRds.Exists(readOld.NodeID()))
+	require.True(t, newRds.Exists(readOld.NodeID()))
+	require.True(t, oldRds.Exists(readNew.NodeID()))
+	require.True(t, newRds.Exists(readNew.NodeID()))
+
+	// step 1: the nodes reading the old driver agree.
+	require.ElementsMatch(t, getNodes(oldOnly), getNodes(readOld))
+	require.Len(t, getNodes(readOld), 3)
+
+	// step 2: without the old only nodes, the nodes reading either driver agree.
+	require.Nil(t, oldOnly.Stop(context.Background()))
+	require.ElementsMatch(t, []string{readOld.NodeID(), readNew.NodeID()}, getNodes(readOld))
+	require.ElementsMatch(t, getNodes(readOld), getNodes(readNew))
+	require.Nil(t, readOld.(driver.HealthChecker).HealthCheck(context.Background()))
+
+	// the node is deregistered from both drivers.
+	require.Nil(t, readOld.Stop(context.Background()))
+	require.False(t, oldRds.Exists(readOld.NodeID()))
+	require.False(t, newRds.Exists(readOld.NodeID()))
+
+	// the secondary driver is checked too.
+	newRds.Close()
+	require.NotNil(t, readNew.(driver.HealthChecker).HealthCheck(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NotNil(t, readNew.Stop(ctx))
+}
@@ -3,11 +3,13 @@ package driver
 import (
 	"context"
 	"log"
+	"math"
 	"sync"
 	"time"
 
 	"github.com/libi/dcron/dlog"
 	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
@@ -15,32 +17,43 @@ const (
 	etcdDefaultLease    = 5 // min lease time
 	etcdDialTimeout     = 3 * time.Second
 	etcdBusinessTimeout = 5 * time.Second
+	// the delays between the attempts to re-establish the lease.
+	etcdRenewMinDelay = 500 * time.Millisecond
+	etcdRenewMaxDelay = 5 * time.Second
 )
 
 type EtcdDriver struct {
 	nodeID      string
 	serviceName string
 
-	cli    *clientv3.Client
-	nodes  *sync.Map
-	logger dlog.Logger
+	cli       *clientv3.Client
+	nodes     *sync.Map
+	logger    dlog.Logger
+	weight    int
+	keyPrefix string
+	nodeName  string
+	takeOver  bool
 
 	lease   int64
+	leaseMu sync.Mutex
 	leaseID clientv3.LeaseID
 	leaseCh <-chan *clientv3.LeaseKeepAliveResponse
 
+	// locks held by this node, key -> leaseID.
+	locks sync.Map
+
 	ctx    context.Context
 	cancel context.CancelFunc
+	// closed when keepHeartBeat returned.
+	heartBeatDone chan struct{}
 }
 
 // NewEtcdDriver
 func newEtcdDriver(cli *clientv3.Client) *EtcdDriver {
 	ser := &EtcdDriver{
-		cli:   cli,
-		nodes: &sync.Map{},
-		logger: &dlog.StdLogger{
-			Log: log.Default(),
-		},
+		cli:    cli,
+		nodes:  &sync.Map{},
+		logger: dlog.DefaultPrintfLogger(log.Default()),
 	}
 
 	return ser
@@ -60,6 +73,21 @@ func (e *EtcdDriver) putKeyWithLease(ctx context.Context, key, val string) (clie
 		return 0, err
 	}
 	//注册服务并绑定租约
+	if e.nodeName != "" && !e.takeOver {
+		// the named node must not be registered by another node.
+		txnResp, err := e.cli.Txn(subCtx).
+			If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+			Then(clientv3.OpPut(key, val, clientv3.WithLease(resp.ID))).
+			Commit()
+		if err != nil {
+			return 0, err
+		}
+		if !txnResp.Succeeded {
+			_, _ = e.cli.Revoke(subCtx, resp.ID)
+			return 0, ErrNodeIDExist
+		}
+		return resp.ID, nil
+	}
 	_, err = e.cli.Put(subCtx, key, val, clientv3.WithLease(resp.ID))
 	if err != nil {
 		return 0, err
@@ -70,7 +98,7 @@ func (e *EtcdDriver) putKeyWithLease(ctx context.Context, key, val string) (clie
 
 // WatchService 初始化服务列表和监视
 func (e *EtcdDriver) watchService(ctx context.Context, serviceName string) error {
-	prefix := GetKeyPre(serviceName)
+	prefix := e.keyPrefix + GetKeyPre(serviceName)
 	// 根据前缀获取现有的key
 	resp, err := e.cli.Get(ctx, prefix, clientv3.WithPrefix())
 	if err != nil {
@@ -88,7 +116,7 @@ func (e *EtcdDriver) watchService(ctx context.Context, serviceName string) error
 
 // watcher 监听前缀
 func (e *EtcdDriver) watcher(serviceName string) {
-	prefix := GetKeyPre(serviceName)
+	prefix := e.keyPrefix + GetKeyPre(serviceName)
 	rch := e.cli.Watch(context.Background(), prefix, clientv3.WithPrefix())
 	for wresp := range rch {
 		for _, ev := range wresp.Events {
@@ -125,30 +153,37 @@ func (e *EtcdDriver) getServices() []string {
 }
 
 func (e *EtcdDriver) keepAlive(ctx context.Context, nodeID string) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
-	var err error
-	e.leaseID, err = e.putKeyWithLease(ctx, nodeID, nodeID)
+	leaseID, err := e.putKeyWithLease(ctx, nodeID, nodeID)
 	if err != nil {
 		e.logger.Errorf("putKeyWithLease error: %v", err)
 		return nil, err
 	}
+	e.leaseMu.Lock()
+	e.leaseID = leaseID
+	e.leaseMu.Unlock()
 
-	return e.cli.KeepAlive(ctx, e.leaseID)
+	// the keepalive lives until the driver is stopped,
+	// not the ctx passed to Start.
+	return e.cli.KeepAlive(e.ctx, leaseID)
 }
 
 func (e *EtcdDriver) revoke(ctx context.Context) {
-	_, err := e.cli.Lease.Revoke(ctx, e.leaseID)
+	e.leaseMu.Lock()
+	leaseID := e.leaseID
+	e.leaseMu.Unlock()
+	_, err := e.cli.Lease.Revoke(ctx, leaseID)
 	if err != nil {
 		e.logger.Infof("lease revoke error: %v", err)
 	}
 }
 
-func (e *EtcdDriver) startHeartBeat(ctx context.Context) {
-	var err error
+func (e *EtcdDriver) startHeartBeat(ctx context.Context) (err error) {
 	e.leaseCh, err = e.keepAlive(ctx, e.nodeID)
 	if err != nil {
 		e.logger.Errorf("keep alive error, %v", err)
 		return
 	}
+	return
 }
 
 func (e *EtcdDriver) keepHeartBeat() {
@@ -162,20 +197,49 @@ func (e *EtcdDriver) keepHeartBeat() {
 		case _, ok := <-e.leaseCh:
 			{
 				if !ok {
-					e.logger.Warnf("lease channel stop, driver stopped")
-					return
+					e.logger.Warnf("lease channel stop, re-establish the lease")
+					if !e.renewLease() {
+						return
+					}
 				}
 			}
 		}
 	}
 }
 
+// renewLease registers this node with a new lease after the keepalive
+// stopped, e.g. the lease expired during a network loss. It retries until
+// it succeeds or the driver is stopped, which returns false.
+func (e *EtcdDriver) renewLease() bool {
+	delay := etcdRenewMinDelay
+	for {
+		// the old lease may be still alive if the keepalive stopped
+		// before it expired, revoke it so the key can be put again.
+		revokeCtx, cancel := context.WithTimeout(e.ctx, etcdBusinessTimeout)
+		e.revoke(revokeCtx)
+		cancel()
+		err := e.startHeartBeat(e.ctx)
+		if err == nil {
+			e.logger.Infof("lease re-established, nodeID=%s", e.nodeID)
+			return true
+		}
+		select {
+		case <-e.ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > etcdRenewMaxDelay {
+			delay = etcdRenewMaxDelay
+		}
+	}
+}
+
 func (e *EtcdDriver) Init(serverName string, opts ...Option) {
 	e.serviceName = serverName
-	e.nodeID = GetNodeId(serverName)
 	for _, opt := range opts {
 		e.WithOption(opt)
 	}
+	e.nodeID = e.keyPrefix + GetNodeIdWithName(serverName, e.nodeName, e.weight)
 }
 
 func (e *EtcdDriver) NodeID() string {
@@ -189,18 +253,34 @@ func (e *EtcdDriver) GetNodes(ctx context.Context) (nodes []string, err error) {
 func (e *EtcdDriver) Start(ctx context.Context) (err error) {
 	// renew a global ctx when start every time
 	e.ctx, e.cancel = context.WithCancel(context.TODO())
-	e.startHeartBeat(ctx)
+	if e.nodeName != "" {
+		if err = CheckNodeName(e.nodeName); err != nil {
+			return
+		}
+	}
+	if err = e.startHeartBeat(ctx); err != nil {
+		return
+	}
 	err = e.watchService(ctx, e.serviceName)
 	if err != nil {
 		return
 	}
-	go e.keepHeartBeat()
+	e.heartBeatDone = make(chan struct{})
+	go func() {
+		defer close(e.heartBeatDone)
+		e.keepHeartBeat()
+	}()
 	return
 }
 
+// Stop stops keeping the lease alive and revokes it,
+// so the node is removed without waiting for the lease to expire.
 func (e *EtcdDriver) Stop(ctx context.Context) (err error) {
-	e.revoke(ctx)
 	e.cancel()
+	if e.heartBeatDone != nil {
+		<-e.heartBeatDone
+	}
+	e.revoke(ctx)
 	return
 }
 
@@ -208,12 +288,102 @@ func (e *EtcdDriver) WithOption(opt Option) (err error) {
 	switch opt.Type() {
 	case OptionTypeTimeout:
 		{
-			e.lease = int64(opt.(TimeoutOption).timeout.Seconds())
+			e.lease = int64(math.Ceil(opt.(TimeoutOption).timeout.Seconds()))
 		}
 	case OptionTypeLogger:
 		{
 			e.logger = opt.(LoggerOption).logger
 		}
+	case OptionTypeWeight:
+		{
+			e.weight = opt.(WeightOption).weight
+		}
+	case OptionTypeKeyPrefix:
+		{
+			e.keyPrefix = opt.(KeyPrefixOption).prefix
+		}
+	case OptionTypeNodeName:
+		{
+			e.nodeName = opt.(NodeNameOption).name
+		}
+	case OptionTypeTakeOver:
+		{
+			e.takeOver = true
+		}
 	}
 	return
 }
+
+// HealthCheck implements HealthChecker, the nodes are cached by watching,
+// so it reads the key of this node to check the connectivity.
+func (e *EtcdDriver) HealthCheck(ctx context.Context) error {
+	subCtx, cancel := context.WithTimeout(ctx, etcdBusinessTimeout)
+	defer cancel()
+	_, err := e.cli.Get(subCtx, e.nodeID, clientv3.WithCountOnly())
+	return err
+}
+
+func (e *EtcdDriver) Get(ctx context.Context, key string) (value string, ok bool, err error) {
+	resp, err := e.cli.Get(ctx, e.keyPrefix+GetStoreKey(e.serviceName, key))
+	if err != nil {
+		return "", false, err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", false, nil
+	}
+	return string(resp.Kvs[0].Value), true, nil
+}
+
+func (e *EtcdDriver) Set(ctx context.Context, key, value string) (err error) {
+	_, err = e.cli.Put(ctx, e.keyPrefix+GetStoreKey(e.serviceName, key), value)
+	return
+}
+
+func (e *EtcdDriver) Del(ctx context.Context, key string) (err error) {
+	_, err = e.cli.Delete(ctx, e.keyPrefix+GetStoreKey(e.serviceName, key))
+	return
+}
+
+// AcquireLock puts the key with a lease of ttl if it does not exist,
+// the ttl is rounded up to seconds.
+func (e *EtcdDriver) AcquireLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	lease, err := e.cli.Grant(ctx, int64(math.Ceil(ttl.Seconds())))
+	if err != nil {
+		return false, err
+	}
+	lockKey := e.keyPrefix + GetStoreKey(e.serviceName, key)
+	resp, err := e.cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(lockKey), "=", 0)).
+		Then(clientv3.OpPut(lockKey, e.nodeID, clientv3.WithLease(lease.ID))).
+		Commit()
+	if err != nil || !resp.Succeeded {
+		_, _ = e.cli.Revoke(ctx, lease.ID)
+		return false, err
+	}
+	e.locks.Store(key, lease.ID)
+	return true, nil
+}
+
+func (e *EtcdDriver) ReleaseLock(ctx context.Context, key string) (err error) {
+	leaseID, ok := e.locks.LoadAndDelete(key)
+	if !ok {
+		return nil
+	}
+	_, err = e.cli.Revoke(ctx, leaseID.(clientv3.LeaseID))
+	return
+}
+
+// RefreshLock keeps the lease of the lock alive for its granted ttl,
+// the ttl passed in is ignored.
+func (e *EtcdDriver) RefreshLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	leaseID, ok := e.locks.Load(key)
+	if !ok {
+		return false, nil
+	}
+	_, err = e.cli.KeepAliveOnce(ctx, leaseID.(clientv3.LeaseID))
+	if err == rpctypes.ErrLeaseNotFound {
+		e.locks.Delete(key)
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -96,3 +96,79 @@ func TestEtcdDriver_Stop(t *testing.T) {
 	drv2.Stop(context.Background())
 	drv1.Stop(context.Background())
 }
+
+func TestEtcdDriver_NodeName(t *testing.T) {
+	etcdsvr := integration.NewLazyCluster()
+	defer etcdsvr.Terminate()
+	newNamedDriver := func(nodeName string, opts ...driver.Option) driver.DriverV2 {
+		drv := testFuncNewEtcdDriver(clientv3.Config{
+			Endpoints:   etcdsvr.EndpointsV3(),
+			DialTimeout: 3 * time.Second,
+		})
+		drv.Init(t.Name(), append([]driver.Option{
+			driver.NewNodeNameOption(nodeName),
+			driver.NewTimeoutOption(5 * time.Second),
+			driver.NewLoggerOption(dlog.NewLoggerForTest(t)),
+		}, opts...)...)
+		return drv
+	}
+	drv1 := newNamedDriver("pod-0")
+	require.Equal(t, driver.GetKeyPre(t.Name())+"pod-0", drv1.NodeID())
+	require.Nil(t, drv1.Start(context.Background()))
+	defer drv1.Stop(context.Background())
+
+	drv2 := newNamedDriver("pod-0")
+	require.Equal(t, driver.ErrNodeIDExist, drv2.Start(context.Background()))
+
+	// the name is taken over while drv1 is alive.
+	drv3 := newNamedDriver("pod-0", driver.NewTakeOverOption())
+	require.Nil(t, drv3.Start(context.Background()))
+	defer drv3.Stop(context.Background())
+}
+
+func TestEtcdDriver_Lease(t *testing.T) {
+	etcdsvr := integration.NewLazyCluster()
+	defer etcdsvr.Terminate()
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   etcdsvr.EndpointsV3(),
+		DialTimeout: 3 * time.Second,
+	})
+	require.Nil(t, err)
+	defer cli.Close()
+	leaseOf := func(nodeID string) (clientv3.LeaseID, bool) {
+		resp, err := cli.Get(context.Background(), nodeID)
+		require.Nil(t, err)
+		if len(resp.Kvs) == 0 {
+			return 0, false
+		}
+		return clientv3.LeaseID(resp.Kvs[0].Lease), true
+	}
+
+	drv := testFuncNewEtcdDriver(clientv3.Config{
+		Endpoints:   etcdsvr.EndpointsV3(),
+		DialTimeout: 3 * time.Second,
+	})
+	drv.Init(t.Name(), driver.NewTimeoutOption(5500*time.Millisecond), driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+	require.Nil(t, drv.Start(context.Background()))
+	leaseID, ok := leaseOf(drv.NodeID())
+	require.True(t, ok)
+	ttl, err := cli.TimeToLive(context.Background(), leaseID)
+	require.Nil(t, err)
+	require.Equal(t, int64(6), ttl.GrantedTTL)
+
+	// the lease is lost, e.g. expired during a network loss.
+	_, err = cli.Revoke(context.Background(), leaseID)
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		newLeaseID, ok := leaseOf(drv.NodeID())
+		return ok && newLeaseID != leaseID
+	}, 5*time.Second, 100*time.Millisecond)
+
+	leaseID, _ = leaseOf(drv.NodeID())
+	require.Nil(t, drv.Stop(context.Background()))
+	_, ok = leaseOf(drv.NodeID())
+	require.False(t, ok)
+	ttl, err = cli.TimeToLive(context.Background(), leaseID)
+	require.Nil(t, err)
+	require.Equal(t, int64(-1), ttl.TTL)
+}
@@ -0,0 +1,362 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libi/dcron/dlog"
+)
+
+const (
+	memoryDefaultTimeout = 5 * time.Second
+)
+
+// MemoryRegistry is an in-process registry shared by the MemoryDrivers of
+// a simulated cluster, e.g. the nodes of a test in one binary. It keeps the
+// heartbeats of the nodes, the keys of KVDriver and the locks of LockDriver.
+type MemoryRegistry struct {
+	mu sync.Mutex
+	// nodeID -> the expiration of the heartbeat.
+	nodes map[string]time.Time
+	// the nodes expired by ExpireNode, the heartbeats of them are
+	// ignored until they are started again.
+	expired map[string]struct{}
+	store   map[string]string
+	locks   map[string]memoryLock
+}
+
+type memoryLock struct {
+	owner    string
+	deadline time.Time
+}
+
+// NewMemoryRegistry create an empty registry, pass it to NewMemoryDriver
+// of all the nodes of the same cluster.
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		nodes:   make(map[string]time.Time),
+		expired: make(map[string]struct{}),
+		store:   make(map[string]string),
+		locks:   make(map[string]memoryLock),
+	}
+}
+
+// ExpireNode expires the heartbeat of the node now, as if the node died,
+// the other nodes do not get it from GetNodes anymore. The heartbeat of
+// the node is ignored until its driver is stopped and started again, the
+// locks held by the node are kept until they expire.
+func (r *MemoryRegistry) ExpireNode(nodeID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.nodes, nodeID)
+	r.expired[nodeID] = struct{}{}
+}
+
+// Nodes returns the nodes whose heartbeat is not expired, of all services.
+func (r *MemoryRegistry) Nodes() []string {
+	return r.nodesWithPrefix("")
+}
+
+func (r *MemoryRegistry) nodesWithPrefix(prefix string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	nodes := make([]string, 0)
+	for nodeID, deadline := range r.nodes {
+		if !deadline.After(now) {
+			delete(r.nodes, nodeID)
+			continue
+		}
+		if strings.HasPrefix(nodeID, prefix) {
+			nodes = append(nodes, nodeID)
+		}
+	}
+	return nodes
+}
+
+// start registers the node, if unique is true, it returns ErrNodeIDExist
+// if the heartbeat of the node is not expired. The expiration by
+// ExpireNode is cleared.
+func (r *MemoryRegistry) start(nodeID string, ttl time.Duration, unique bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if deadline, ok := r.nodes[nodeID]; unique && ok && deadline.After(now) {
+		return ErrNodeIDExist
+	}
+	delete(r.expired, nodeID)
+	r.nodes[nodeID] = now.Add(ttl)
+	return nil
+}
+
+// heartbeat refreshes the heartbeat of the node unless it is expired by
+// ExpireNode.
+func (r *MemoryRegistry) heartbeat(nodeID string, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.expired[nodeID]; ok {
+		return
+	}
+	r.nodes[nodeID] = time.Now().Add(ttl)
+}
+
+func (r *MemoryRegistry) deregister(nodeID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.nodes, nodeID)
+}
+
+func (r *MemoryRegistry) get(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.store[key]
+	return value, ok
+}
+
+func (r *MemoryRegistry) set(key, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store[key] = value
+}
+
+func (r *MemoryRegistry) del(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.store, key)
+}
+
+// lock acquires or refreshes the lock of key for owner, if refresh is
+// true, the lock must be held by owner.
+func (r *MemoryRegistry) lock(key, owner string, ttl time.Duration, refresh bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	l, ok := r.locks[key]
+	held := ok && l.deadline.After(now)
+	// the expired locks are not released by their owners always.
+	for k, other := range r.locks {
+		if !other.deadline.After(now) {
+			delete(r.locks, k)
+		}
+	}
+	if refresh && (!held || l.owner != owner) {
+		return false
+	}
+	if !refresh && held {
+		return false
+	}
+	r.locks[key] = memoryLock{owner: owner, deadline: now.Add(ttl)}
+	return true
+}
+
+// LockKeys returns the sorted keys of the locks kept in the registry, of
+// all services, e.g. to check that no lock is left behind in a test.
+func (r *MemoryRegistry) LockKeys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]string, 0, len(r.locks))
+	for key := range r.locks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *MemoryRegistry) unlock(key, owner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.locks[key]; ok && l.owner == owner {
+		delete(r.locks, key)
+	}
+}
+
+// MemoryDriver registers the node in a MemoryRegistry in the same process,
+// to run a simulated cluster of dcron in tests without redis or etcd.
+// It implements KVDriver, LockDriver and LockRefresher, so all the
+// features of dcron work with it. Use MemoryRegistry.ExpireNode to
+// simulate the death of a node.
+type MemoryDriver struct {
+	registry    *MemoryRegistry
+	serviceName string
+	nodeID      string
+	timeout     time.Duration
+	logger      dlog.Logger
+	weight      int
+	keyPrefix   string
+	nodeName    string
+	takeOver    bool
+	started     bool
+
+	// this context is used to define
+	// the lifetime of this driver.
+	runtimeCtx    context.Context
+	runtimeCancel context.CancelFunc
+	// closed when heartBeat returned.
+	heartBeatDone chan struct{}
+
+	sync.Mutex
+}
+
+func newMemoryDriver(registry *MemoryRegistry) *MemoryDriver {
+	return &MemoryDriver{
+		registry: registry,
+		logger:   dlog.DefaultPrintfLogger(log.Default()),
+		timeout:  memoryDefaultTimeout,
+	}
+}
+
+func (md *MemoryDriver) Init(serviceName string, opts ...Option) {
+	md.serviceName = serviceName
+	for _, opt := range opts {
+		md.WithOption(opt)
+	}
+	md.nodeID = md.keyPrefix + GetNodeIdWithName(md.serviceName, md.nodeName, md.weight)
+}
+
+func (md *MemoryDriver) NodeID() string {
+	return md.nodeID
+}
+
+func (md *MemoryDriver) Start(ctx context.Context) (err error) {
+	md.Lock()
+	defer md.Unlock()
+	if md.started {
+		err = errors.New("this driver is started")
+		return
+	}
+	if md.nodeName != "" {
+		if err = CheckNodeName(md.nodeName); err != nil {
+			md.logger.Errorf("register service error=%v", err)
+			return
+		}
+	}
+	if err = md.registry.start(md.nodeID, md.timeout, md.nodeName != "" && !md.takeOver); err != nil {
+		md.logger.Errorf("register service error=%v", err)
+		return
+	}
+	md.runtimeCtx, md.runtimeCancel = context.WithCancel(context.TODO())
+	md.started = true
+	md.heartBeatDone = make(chan struct{})
+	go md.heartBeat()
+	return
+}
+
+// Stop stops the heartbeat and deregisters this node.
+func (md *MemoryDriver) Stop(ctx context.Context) (err error) {
+	md.Lock()
+	defer md.Unlock()
+	if !md.started {
+		return
+	}
+	md.runtimeCancel()
+	md.started = false
+	<-md.heartBeatDone
+	md.registry.deregister(md.nodeID)
+	return
+}
+
+func (md *MemoryDriver) GetNodes(ctx context.Context) (nodes []string, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	return md.registry.nodesWithPrefix(md.keyPrefix + GetKeyPre(md.serviceName)), nil
+}
+
+func (md *MemoryDriver) heartBeat() {
+	tick := time.NewTicker(md.timeout / 2)
+	defer tick.Stop()
+	defer close(md.heartBeatDone)
+	for {
+		select {
+		case <-tick.C:
+			md.registry.heartbeat(md.nodeID, md.timeout)
+		case <-md.runtimeCtx.Done():
+			return
+		}
+	}
+}
+
+func (md *MemoryDriver) WithOption(opt Option) (err error) {
+	switch opt.Type() {
+	case OptionTypeTimeout:
+		{
+			md.timeout = opt.(TimeoutOption).timeout
+		}
+	case OptionTypeLogger:
+		{
+			md.logger = opt.(LoggerOption).logger
+		}
+	case OptionTypeWeight:
+		{
+			md.weight = opt.(WeightOption).weight
+		}
+	case OptionTypeKeyPrefix:
+		{
+			md.keyPrefix = opt.(KeyPrefixOption).prefix
+		}
+	case OptionTypeNodeName:
+		{
+			md.nodeName = opt.(NodeNameOption).name
+		}
+	case OptionTypeTakeOver:
+		{
+			md.takeOver = true
+		}
+	}
+	return
+}
+
+func (md *MemoryDriver) storeKey(key string) string {
+	return md.keyPrefix + GetStoreKey(md.serviceName, key)
+}
+
+func (md *MemoryDriver) Get(ctx context.Context, key string) (value string, ok bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", false, err
+	}
+	value, ok = md.registry.get(md.storeKey(key))
+	return value, ok, nil
+}
+
+func (md *MemoryDriver) Set(ctx context.Context, key, value string) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	md.registry.set(md.storeKey(key), value)
+	return nil
+}
+
+func (md *MemoryDriver) Del(ctx context.Context, key string) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	md.registry.del(md.storeKey(key))
+	return nil
+}
+
+func (md *MemoryDriver) AcquireLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return false, err
+	}
+	return md.registry.lock(md.storeKey(key), md.nodeID, ttl, false), nil
+}
+
+func (md *MemoryDriver) ReleaseLock(ctx context.Context, key string) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	md.registry.unlock(md.storeKey(key), md.nodeID)
+	return nil
+}
+
+func (md *MemoryDriver) RefreshLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return false, err
+	}
+	return md.registry.lock(md.storeKey(key), md.nodeID, ttl, true), nil
+}
@@ -0,0 +1,154 @@
+package driver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libi/dcron/dlog"
+	"github.com/libi/dcron/driver"
+	"github.com/stretchr/testify/require"
+)
+
+func testFuncNewMemoryDriver(t *testing.T, registry *driver.MemoryRegistry, opts ...driver.Option) driver.DriverV2 {
+	drv := driver.NewMemoryDriver(registry)
+	opts = append([]driver.Option{
+		driver.NewTimeoutOption(time.Second),
+		driver.NewLoggerOption(dlog.NewLoggerForTest(t)),
+	}, opts...)
+	drv.Init(t.Name(), opts...)
+	return drv
+}
+
+func TestMemoryDriver_GetNodes(t *testing.T) {
+	registry := driver.NewMemoryRegistry()
+	drvs := make([]driver.DriverV2, 0)
+	N := 10
+	for i := 0; i < N; i++ {
+		drv := testFuncNewMemoryDriver(t, registry)
+		require.Nil(t, drv.Start(context.Background()))
+		drvs = append(drvs, drv)
+	}
+	// the nodes of another service are not returned.
+	other := driver.NewMemoryDriver(registry)
+	other.Init(t.Name() + "-other")
+	require.Nil(t, other.Start(context.Background()))
+	defer other.Stop(context.Background())
+
+	for _, v := range drvs {
+		nodes, err := v.GetNodes(context.Background())
+		require.Nil(t, err)
+		require.Len(t, nodes, N)
+	}
+	require.Len(t, registry.Nodes(), N+1)
+
+	for _, v := range drvs {
+		require.Nil(t, v.Stop(context.Background()))
+	}
+	nodes, err := other.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, []string{other.NodeID()}, nodes)
+}
+
+func TestMemoryDriver_ExpireNode(t *testing.T) {
+	registry := driver.NewMemoryRegistry()
+	drv1 := testFuncNewMemoryDriver(t, registry)
+	drv2 := testFuncNewMemoryDriver(t, registry)
+	require.Nil(t, drv1.Start(context.Background()))
+	require.Nil(t, drv2.Start(context.Background()))
+	defer drv1.Stop(context.Background())
+	defer drv2.Stop(context.Background())
+
+	registry.ExpireNode(drv2.NodeID())
+	nodes, err := drv1.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, []string{drv1.NodeID()}, nodes)
+	// the heartbeat of the expired node does not register it again.
+	time.Sleep(time.Second)
+	nodes, err = drv1.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, []string{drv1.NodeID()}, nodes)
+
+	// the node is back once it is restarted.
+	require.Nil(t, drv2.Stop(context.Background()))
+	require.Nil(t, drv2.Start(context.Background()))
+	nodes, err = drv1.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Len(t, nodes, 2)
+}
+
+func TestMemoryDriver_HeartbeatExpires(t *testing.T) {
+	registry := driver.NewMemoryRegistry()
+	drv := testFuncNewMemoryDriver(t, registry)
+	require.Nil(t, drv.Start(context.Background()))
+	// the heartbeat keeps the node alive.
+	time.Sleep(2 * time.Second)
+	nodes, err := drv.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Len(t, nodes, 1)
+	require.Nil(t, drv.Stop(context.Background()))
+	nodes, err = drv.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Len(t, nodes, 0)
+}
+
+func TestMemoryDriver_NodeName(t *testing.T) {
+	registry := driver.NewMemoryRegistry()
+	drv1 := testFuncNewMemoryDriver(t, registry, driver.NewNodeNameOption("node-0"))
+	drv2 := testFuncNewMemoryDriver(t, registry, driver.NewNodeNameOption("node-0"))
+	require.Nil(t, drv1.Start(context.Background()))
+	defer drv1.Stop(context.Background())
+	require.ErrorIs(t, drv2.Start(context.Background()), driver.ErrNodeIDExist)
+	drv3 := testFuncNewMemoryDriver(t, registry, driver.NewNodeNameOption("node-0"), driver.NewTakeOverOption())
+	require.Nil(t, drv3.Start(context.Background()))
+	defer drv3.Stop(context.Background())
+}
+
+func TestMemoryDriver_KVAndLock(t *testing.T) {
+	registry := driver.NewMemoryRegistry()
+	drv1 := testFuncNewMemoryDriver(t, registry)
+	drv2 := testFuncNewMemoryDriver(t, registry)
+	ctx := context.Background()
+
+	kv1, kv2 := drv1.(driver.KVDriver), drv2.(driver.KVDriver)
+	require.Nil(t, kv1.Set(ctx, "k", "v"))
+	value, ok, err := kv2.Get(ctx, "k")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "v", value)
+	require.Nil(t, kv2.Del(ctx, "k"))
+	_, ok, err = kv1.Get(ctx, "k")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	lock1, lock2 := drv1.(driver.LockDriver), drv2.(driver.LockDriver)
+	ok, err = lock1.AcquireLock(ctx, "lock", time.Minute)
+	require.Nil(t, err)
+	require.True(t, ok)
+	ok, err = lock2.AcquireLock(ctx, "lock", time.Minute)
+	require.Nil(t, err)
+	require.False(t, ok)
+	ok, err = drv2.(driver.LockRefresher).RefreshLock(ctx, "lock", time.Minute)
+	require.Nil(t, err)
+	require.False(t, ok)
+	// the lock is not released by another node.
+	require.Nil(t, lock2.ReleaseLock(ctx, "lock"))
+	ok, err = drv1.(driver.LockRefresher).RefreshLock(ctx, "lock", time.Minute)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Nil(t, lock1.ReleaseLock(ctx, "lock"))
+	ok, err = lock2.AcquireLock(ctx, "lock", time.Minute)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// the expired locks are pruned from the registry.
+	ok, err = lock1.AcquireLock(ctx, "expired", time.Millisecond)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Len(t, registry.LockKeys(), 2)
+	<-time.After(10 * time.Millisecond)
+	ok, err = lock1.AcquireLock(ctx, "other", time.Minute)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Len(t, registry.LockKeys(), 2)
+}
@@ -7,8 +7,13 @@ import (
 )
 
 const (
-	OptionTypeTimeout = 0x600
-	OptionTypeLogger  = 0x601
+	OptionTypeTimeout   = 0x600
+	OptionTypeLogger    = 0x601
+	OptionTypeWeight    = 0x602
+	OptionTypeKeyPrefix = 0x603
+	OptionTypeNodeName  = 0x604
+	OptionTypeScanBatch = 0x605
+	OptionTypeTakeOver  = 0x606
 )
 
 type Option interface {
@@ -24,3 +29,46 @@ type LoggerOption struct{ logger dlog.Logger }
 
 func (to LoggerOption) Type() int                     { return OptionTypeLogger }
 func NewLoggerOption(logger dlog.Logger) LoggerOption { return LoggerOption{logger: logger} }
+
+type WeightOption struct{ weight int }
+
+func (to WeightOption) Type() int             { return OptionTypeWeight }
+func NewWeightOption(weight int) WeightOption { return WeightOption{weight: weight} }
+
+// KeyPrefixOption namespaces all keys read or written by the driver,
+// so that multiple clusters can share one redis/etcd.
+// For the zookeeper driver, the prefix must not contain '/'.
+type KeyPrefixOption struct{ prefix string }
+
+func (to KeyPrefixOption) Type() int                   { return OptionTypeKeyPrefix }
+func NewKeyPrefixOption(prefix string) KeyPrefixOption { return KeyPrefixOption{prefix: prefix} }
+
+// NodeNameOption sets a stable name of the node, e.g. the pod name of
+// a StatefulSet, which is used in the nodeID instead of a random uuid.
+// The name must be unique in the service, the driver returns
+// ErrNodeIDExist from Start if another node registered the same name.
+type NodeNameOption struct{ name string }
+
+func (to NodeNameOption) Type() int                { return OptionTypeNodeName }
+func NewNodeNameOption(name string) NodeNameOption { return NodeNameOption{name: name} }
+
+// TakeOverOption makes the driver take over the nodeID of NodeNameOption
+// from another node which registered it, instead of returning
+// ErrNodeIDExist from Start, e.g. a new incarnation of a pod whose old
+// incarnation has not expired yet in a rolling restart. The old node is not
+// told, dcron retires it by WithNodeGeneration. Until the old node stops,
+// it may register the nodeID again by its heartbeat, and deregister it by
+// its Stop until the next heartbeat of the new node.
+type TakeOverOption struct{}
+
+func (to TakeOverOption) Type() int     { return OptionTypeTakeOver }
+func NewTakeOverOption() TakeOverOption { return TakeOverOption{} }
+
+// ScanBatchSizeOption sets the COUNT of each SCAN of the redis driver to
+// list the nodes, a larger batch takes fewer round trips in a redis shared
+// with many keys. The default is the default of redis. The other drivers
+// ignore it.
+type ScanBatchSizeOption struct{ size int }
+
+func (to ScanBatchSizeOption) Type() int                  { return OptionTypeScanBatch }
+func NewScanBatchSizeOption(size int) ScanBatchSizeOption { return ScanBatchSizeOption{size: size} }
@@ -22,22 +22,27 @@ type RedisDriver struct {
 	nodeID      string
 	timeout     time.Duration
 	logger      dlog.Logger
+	weight      int
+	keyPrefix   string
+	nodeName    string
+	takeOver    bool
+	scanBatch   int
 	started     bool
 
 	// this context is used to define
 	// the lifetime of this driver.
 	runtimeCtx    context.Context
 	runtimeCancel context.CancelFunc
+	// closed when heartBeat returned.
+	heartBeatDone chan struct{}
 
 	sync.Mutex
 }
 
 func newRedisDriver(redisClient redis.UniversalClient) *RedisDriver {
 	rd := &RedisDriver{
-		c: redisClient,
-		logger: &dlog.StdLogger{
-			Log: log.Default(),
-		},
+		c:       redisClient,
+		logger:  dlog.DefaultPrintfLogger(log.Default()),
 		timeout: redisDefaultTimeout,
 	}
 	rd.started = false
@@ -46,11 +51,10 @@ func newRedisDriver(redisClient redis.UniversalClient) *RedisDriver {
 
 func (rd *RedisDriver) Init(serviceName string, opts ...Option) {
 	rd.serviceName = serviceName
-	rd.nodeID = GetNodeId(rd.serviceName)
-
 	for _, opt := range opts {
 		rd.WithOption(opt)
 	}
+	rd.nodeID = rd.keyPrefix + GetNodeIdWithName(rd.serviceName, rd.nodeName, rd.weight)
 }
 
 func (rd *RedisDriver) NodeID() string {
@@ -64,29 +68,50 @@ func (rd *RedisDriver) Start(ctx context.Context) (err error) {
 		err = errors.New("this driver is started")
 		return
 	}
-	rd.runtimeCtx, rd.runtimeCancel = context.WithCancel(context.TODO())
-	rd.started = true
+	if rd.nodeName != "" {
+		if err = rd.registerUniqueServiceNode(ctx); err != nil {
+			rd.logger.Errorf("register service error=%v", err)
+			return
+		}
+	}
 	// register
-	err = rd.registerServiceNode()
+	err = rd.registerServiceNode(ctx)
 	if err != nil {
 		rd.logger.Errorf("register service error=%v", err)
 		return
 	}
+	rd.runtimeCtx, rd.runtimeCancel = context.WithCancel(context.TODO())
+	rd.started = true
 	// heartbeat timer
+	rd.heartBeatDone = make(chan struct{})
 	go rd.heartBeat()
 	return
 }
 
+// Stop stops the heartbeat and deregisters this node, so the other nodes
+// see it is gone in their next GetNodes, without waiting for the heartbeat
+// to expire. If the node can not be deregistered, the error is logged and
+// returned, the heartbeat expires in the timeout.
 func (rd *RedisDriver) Stop(ctx context.Context) (err error) {
 	rd.Lock()
 	defer rd.Unlock()
+	if !rd.started {
+		return
+	}
 	rd.runtimeCancel()
 	rd.started = false
+	if rd.heartBeatDone != nil {
+		// the heartbeat must not register the node again after it is deleted.
+		<-rd.heartBeatDone
+	}
+	if err = rd.c.Del(ctx, rd.nodeID).Err(); err != nil {
+		rd.logger.Errorf("unregister service node error %+v", err)
+	}
 	return
 }
 
 func (rd *RedisDriver) GetNodes(ctx context.Context) (nodes []string, err error) {
-	mathStr := fmt.Sprintf("%s*", GetKeyPre(rd.serviceName))
+	mathStr := fmt.Sprintf("%s*", rd.keyPrefix+GetKeyPre(rd.serviceName))
 	return rd.scan(ctx, mathStr)
 }
 
@@ -98,35 +123,85 @@ func (rd *RedisDriver) heartBeat() {
 		select {
 		case <-tick.C:
 			{
-				if err := rd.registerServiceNode(); err != nil {
+				if err := rd.registerServiceNode(context.Background()); err != nil {
 					rd.logger.Errorf("register service node error %+v", err)
 				}
 			}
 		case <-rd.runtimeCtx.Done():
 			{
-				if err := rd.c.Del(context.Background(), rd.nodeID, rd.nodeID).Err(); err != nil {
-					rd.logger.Errorf("unregister service node error %+v", err)
-				}
+				tick.Stop()
+				close(rd.heartBeatDone)
 				return
 			}
 		}
 	}
 }
 
-func (rd *RedisDriver) registerServiceNode() error {
-	return rd.c.SetEx(context.Background(), rd.nodeID, rd.nodeID, rd.timeout).Err()
+func (rd *RedisDriver) registerServiceNode(ctx context.Context) error {
+	return rd.c.SetEx(ctx, rd.nodeID, rd.nodeID, rd.timeout).Err()
+}
+
+// registerUniqueServiceNode registers the named node only if
+// no other node has registered the same nodeID, unless it takes over the
+// nodeID by TakeOverOption.
+func (rd *RedisDriver) registerUniqueServiceNode(ctx context.Context) error {
+	if err := CheckNodeName(rd.nodeName); err != nil {
+		return err
+	}
+	if rd.takeOver {
+		return rd.c.SetEx(ctx, rd.nodeID, rd.nodeID, rd.timeout).Err()
+	}
+	ok, err := rd.c.SetNX(ctx, rd.nodeID, rd.nodeID, rd.timeout).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNodeIDExist
+	}
+	return nil
 }
 
 func (rd *RedisDriver) scan(ctx context.Context, matchStr string) ([]string, error) {
-	ret := make([]string, 0)
-	iter := rd.c.Scan(ctx, 0, matchStr, -1).Iterator()
-	for iter.Next(ctx) {
-		err := iter.Err()
+	// in cluster mode, the keys of nodes are spread over all masters,
+	// SCAN only iterates the keys in one of them.
+	if cc, ok := rd.c.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		ret := make([]string, 0)
+		err := cc.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			keys, err := scanKeys(ctx, client, matchStr, rd.scanBatch)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			ret = append(ret, keys...)
+			mu.Unlock()
+			return nil
+		})
 		if err != nil {
 			return nil, err
 		}
+		return ret, nil
+	}
+	return scanKeys(ctx, rd.c, matchStr, rd.scanBatch)
+}
+
+// scanKeys iterates the keys by SCAN with count in each batch, count <= 0
+// means the default of redis. SCAN may return a key more than once if the
+// keys are changed during the iteration, so the duplicates are dropped.
+func scanKeys(ctx context.Context, c redis.Cmdable, matchStr string, count int) ([]string, error) {
+	ret := make([]string, 0)
+	seen := make(map[string]struct{})
+	iter := c.Scan(ctx, 0, matchStr, int64(count)).Iterator()
+	for iter.Next(ctx) {
+		if _, ok := seen[iter.Val()]; ok {
+			continue
+		}
+		seen[iter.Val()] = struct{}{}
 		ret = append(ret, iter.Val())
 	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
 	return ret, nil
 }
 
@@ -140,6 +215,83 @@ func (rd *RedisDriver) WithOption(opt Option) (err error) {
 		{
 			rd.logger = opt.(LoggerOption).logger
 		}
+	case OptionTypeWeight:
+		{
+			rd.weight = opt.(WeightOption).weight
+		}
+	case OptionTypeKeyPrefix:
+		{
+			rd.keyPrefix = opt.(KeyPrefixOption).prefix
+		}
+	case OptionTypeNodeName:
+		{
+			rd.nodeName = opt.(NodeNameOption).name
+		}
+	case OptionTypeTakeOver:
+		{
+			rd.takeOver = true
+		}
+	case OptionTypeScanBatch:
+		{
+			rd.scanBatch = opt.(ScanBatchSizeOption).size
+		}
 	}
 	return
 }
+
+// HealthCheck implements HealthChecker.
+func (rd *RedisDriver) HealthCheck(ctx context.Context) error {
+	return rd.c.Ping(ctx).Err()
+}
+
+func (rd *RedisDriver) Get(ctx context.Context, key string) (value string, ok bool, err error) {
+	value, err = rd.c.Get(ctx, rd.keyPrefix+GetStoreKey(rd.serviceName, key)).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (rd *RedisDriver) Set(ctx context.Context, key, value string) (err error) {
+	return rd.c.Set(ctx, rd.keyPrefix+GetStoreKey(rd.serviceName, key), value, 0).Err()
+}
+
+func (rd *RedisDriver) Del(ctx context.Context, key string) (err error) {
+	return rd.c.Del(ctx, rd.keyPrefix+GetStoreKey(rd.serviceName, key)).Err()
+}
+
+// unlockScript deletes the lock only if it is held by this node.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// refreshScript resets the ttl of the lock only if it is held by this node.
+var refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+func (rd *RedisDriver) lockKey(key string) string {
+	return rd.keyPrefix + GetStoreKey(rd.serviceName, key)
+}
+
+func (rd *RedisDriver) AcquireLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	return rd.c.SetNX(ctx, rd.lockKey(key), rd.nodeID, ttl).Result()
+}
+
+func (rd *RedisDriver) ReleaseLock(ctx context.Context, key string) (err error) {
+	return unlockScript.Run(ctx, rd.c, []string{rd.lockKey(key)}, rd.nodeID).Err()
+}
+
+func (rd *RedisDriver) RefreshLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	n, err := refreshScript.Run(ctx, rd.c, []string{rd.lockKey(key)}, rd.nodeID, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
@@ -2,7 +2,9 @@ package driver_test
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"strings"
 	"testing"
 	"time"
 
@@ -47,6 +49,40 @@ func TestRedisDriver_GetNodes(t *testing.T) {
 	}
 }
 
+func TestRedisDriver_ScanBatchSize(t *testing.T) {
+	rds := miniredis.RunT(t)
+	// the other keys in the same redis.
+	for i := 0; i < 1000; i++ {
+		require.Nil(t, rds.Set(fmt.Sprintf("other:%d", i), "1"))
+	}
+	drvs := make([]driver.DriverV2, 0)
+	N := 10
+	for i := 0; i < N; i++ {
+		drv := testFuncNewRedisDriver(rds.Addr())
+		drv.Init(
+			t.Name(),
+			driver.NewTimeoutOption(5*time.Second),
+			driver.NewLoggerOption(dlog.NewLoggerForTest(t)),
+			driver.NewScanBatchSizeOption(7))
+		require.Nil(t, drv.Start(context.Background()))
+		drvs = append(drvs, drv)
+	}
+	defer func() {
+		for _, v := range drvs {
+			v.Stop(context.Background())
+		}
+	}()
+
+	nodes, err := drvs[0].GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Len(t, nodes, N)
+	unique := make(map[string]struct{})
+	for _, node := range nodes {
+		unique[node] = struct{}{}
+	}
+	require.Len(t, unique, N)
+}
+
 func TestRedisDriver_Stop(t *testing.T) {
 	var err error
 	var nodes []string
@@ -92,3 +128,236 @@ func TestRedisDriver_Stop(t *testing.T) {
 	drv2.Stop(context.Background())
 	drv1.Stop(context.Background())
 }
+
+func TestRedisDriver_StopDeregisters(t *testing.T) {
+	rds := miniredis.RunT(t)
+	drv1 := testFuncNewRedisDriver(rds.Addr())
+	drv1.Init(t.Name(),
+		driver.NewTimeoutOption(5*time.Second),
+		driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+	drv2 := testFuncNewRedisDriver(rds.Addr())
+	drv2.Init(t.Name(),
+		driver.NewTimeoutOption(5*time.Second),
+		driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+	require.Nil(t, drv1.Start(context.Background()))
+	require.Nil(t, drv2.Start(context.Background()))
+
+	// the node is gone as soon as Stop returns, without waiting for the TTL.
+	require.Nil(t, drv1.Stop(context.Background()))
+	nodes, err := drv2.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, []string{drv2.NodeID()}, nodes)
+
+	// an unreachable server makes Stop return the error instead of hanging.
+	rds.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NotNil(t, drv2.Stop(ctx))
+}
+
+func TestRedisDriver_StartCanceled(t *testing.T) {
+	rds := miniredis.RunT(t)
+	for _, drv := range []driver.DriverV2{testFuncNewRedisDriver(rds.Addr()), testFuncNewRedisZSetDriver(rds.Addr())} {
+		drv.Init(t.Name(),
+			driver.NewTimeoutOption(5*time.Second),
+			driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+		// the ctx of Start bounds the registration.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, drv.Start(ctx), context.Canceled)
+		nodes, err := drv.GetNodes(context.Background())
+		require.Nil(t, err)
+		require.Empty(t, nodes)
+		// the driver is not started by the failed Start.
+		require.Nil(t, drv.Start(context.Background()))
+		require.Nil(t, drv.Stop(context.Background()))
+	}
+}
+
+func TestRedisDriver_KV(t *testing.T) {
+	rds := miniredis.RunT(t)
+	drv := testFuncNewRedisDriver(rds.Addr())
+	drv.Init(t.Name(), driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+	kv, ok := drv.(driver.KVDriver)
+	require.True(t, ok)
+
+	ctx := context.Background()
+	_, exist, err := kv.Get(ctx, "key")
+	require.Nil(t, err)
+	require.False(t, exist)
+
+	require.Nil(t, kv.Set(ctx, "key", "value"))
+	value, exist, err := kv.Get(ctx, "key")
+	require.Nil(t, err)
+	require.True(t, exist)
+	require.Equal(t, "value", value)
+
+	// the keys of KVDriver are not nodes.
+	require.Nil(t, drv.Start(ctx))
+	defer drv.Stop(ctx)
+	nodes, err := drv.GetNodes(ctx)
+	require.Nil(t, err)
+	require.Len(t, nodes, 1)
+
+	require.Nil(t, kv.Del(ctx, "key"))
+	_, exist, err = kv.Get(ctx, "key")
+	require.Nil(t, err)
+	require.False(t, exist)
+}
+
+func TestRedisDriver_Lock(t *testing.T) {
+	rds := miniredis.RunT(t)
+	ctx := context.Background()
+	for name, newDriver := range map[string]func(addr string) driver.DriverV2{
+		"redis":     testFuncNewRedisDriver,
+		"redisZSet": testFuncNewRedisZSetDriver,
+	} {
+		t.Run(name, func(t *testing.T) {
+			drv1, drv2 := newDriver(rds.Addr()), newDriver(rds.Addr())
+			drv1.Init(t.Name(), driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+			drv2.Init(t.Name(), driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+			lock1, ok := drv1.(driver.LockDriver)
+			require.True(t, ok)
+			lock2 := drv2.(driver.LockDriver)
+
+			ok, err := lock1.AcquireLock(ctx, "key", time.Minute)
+			require.Nil(t, err)
+			require.True(t, ok)
+			ok, err = lock2.AcquireLock(ctx, "key", time.Minute)
+			require.Nil(t, err)
+			require.False(t, ok)
+
+			// the lock held by another node is not released.
+			require.Nil(t, lock2.ReleaseLock(ctx, "key"))
+			ok, err = lock2.AcquireLock(ctx, "key", time.Minute)
+			require.Nil(t, err)
+			require.False(t, ok)
+
+			require.Nil(t, lock1.ReleaseLock(ctx, "key"))
+			ok, err = lock2.AcquireLock(ctx, "key", time.Minute)
+			require.Nil(t, err)
+			require.True(t, ok)
+
+			// expired by ttl.
+			rds.FastForward(time.Minute)
+			ok, err = lock1.AcquireLock(ctx, "key", time.Minute)
+			require.Nil(t, err)
+			require.True(t, ok)
+
+			// refreshed only by the node which holds it.
+			refresher1, ok := drv1.(driver.LockRefresher)
+			require.True(t, ok)
+			refresher2 := drv2.(driver.LockRefresher)
+			ok, err = refresher2.RefreshLock(ctx, "key", 2*time.Minute)
+			require.Nil(t, err)
+			require.False(t, ok)
+			rds.FastForward(30 * time.Second)
+			ok, err = refresher1.RefreshLock(ctx, "key", time.Minute)
+			require.Nil(t, err)
+			require.True(t, ok)
+			rds.FastForward(45 * time.Second)
+			ok, err = lock2.AcquireLock(ctx, "key", time.Minute)
+			require.Nil(t, err)
+			require.False(t, ok)
+			require.Nil(t, lock1.ReleaseLock(ctx, "key"))
+			ok, err = refresher1.RefreshLock(ctx, "key", time.Minute)
+			require.Nil(t, err)
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestRedisClusterDriver_GetNodes(t *testing.T) {
+	rds := miniredis.RunT(t)
+	drvs := make([]driver.DriverV2, 0)
+	N := 5
+	for i := 0; i < N; i++ {
+		drv := driver.NewRedisClusterDriver(&redis.ClusterOptions{
+			Addrs: []string{rds.Addr()},
+		})
+		drv.Init(
+			t.Name(),
+			driver.NewTimeoutOption(5*time.Second),
+			driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+		err := drv.Start(context.Background())
+		require.Nil(t, err)
+		drvs = append(drvs, drv)
+	}
+
+	for _, v := range drvs {
+		nodes, err := v.GetNodes(context.Background())
+		require.Nil(t, err)
+		require.Equal(t, N, len(nodes))
+	}
+
+	for _, v := range drvs {
+		v.Stop(context.Background())
+	}
+}
+
+func TestRedisDriver_KeyPrefix(t *testing.T) {
+	rds := miniredis.RunT(t)
+	ctx := context.Background()
+	newDriver := func(opts ...driver.Option) driver.DriverV2 {
+		drv := testFuncNewRedisDriver(rds.Addr())
+		drv.Init(t.Name(), append(opts, driver.NewLoggerOption(dlog.NewLoggerForTest(t)))...)
+		require.Nil(t, drv.Start(ctx))
+		return drv
+	}
+	drvDefault := newDriver()
+	defer drvDefault.Stop(ctx)
+	drvStaging := newDriver(driver.NewKeyPrefixOption("staging:"))
+	defer drvStaging.Stop(ctx)
+	drvStaging2 := newDriver(driver.NewKeyPrefixOption("staging:"))
+	defer drvStaging2.Stop(ctx)
+
+	require.True(t, strings.HasPrefix(drvDefault.NodeID(), driver.GetKeyPre(t.Name())))
+	require.True(t, strings.HasPrefix(drvStaging.NodeID(), "staging:"+driver.GetKeyPre(t.Name())))
+
+	nodes, err := drvDefault.GetNodes(ctx)
+	require.Nil(t, err)
+	require.Equal(t, []string{drvDefault.NodeID()}, nodes)
+
+	nodes, err = drvStaging.GetNodes(ctx)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{drvStaging.NodeID(), drvStaging2.NodeID()}, nodes)
+}
+
+func TestRedisDriver_NodeName(t *testing.T) {
+	rds := miniredis.RunT(t)
+	ctx := context.Background()
+	for name, newDriver := range map[string]func(addr string) driver.DriverV2{
+		"redis":     testFuncNewRedisDriver,
+		"redisZSet": testFuncNewRedisZSetDriver,
+	} {
+		t.Run(name, func(t *testing.T) {
+			newNamedDriver := func(nodeName string, opts ...driver.Option) driver.DriverV2 {
+				drv := newDriver(rds.Addr())
+				drv.Init(t.Name(), append([]driver.Option{
+					driver.NewNodeNameOption(nodeName),
+					driver.NewTimeoutOption(5 * time.Second),
+					driver.NewLoggerOption(dlog.NewLoggerForTest(t)),
+				}, opts...)...)
+				return drv
+			}
+			drv1 := newNamedDriver("pod-0")
+			require.Equal(t, driver.GetKeyPre(t.Name())+"pod-0", drv1.NodeID())
+			require.Nil(t, drv1.Start(ctx))
+			defer drv1.Stop(ctx)
+
+			drv2 := newNamedDriver("pod-0")
+			require.Equal(t, driver.ErrNodeIDExist, drv2.Start(ctx))
+
+			// the name is taken over while drv1 is alive.
+			drv4 := newNamedDriver("pod-0", driver.NewTakeOverOption())
+			require.Nil(t, drv4.Start(ctx))
+			defer drv4.Stop(ctx)
+			nodes, err := drv4.GetNodes(ctx)
+			require.Nil(t, err)
+			require.Equal(t, []string{drv4.NodeID()}, nodes)
+
+			drv3 := newNamedDriver("pod:1")
+			require.Equal(t, driver.ErrInvalidNodeName, drv3.Start(ctx))
+		})
+	}
+}
@@ -18,22 +18,26 @@ type RedisZSetDriver struct {
 	nodeID      string
 	timeout     time.Duration
 	logger      dlog.Logger
+	weight      int
+	keyPrefix   string
+	nodeName    string
+	takeOver    bool
 	started     bool
 
 	// this context is used to define
 	// the lifetime of this driver.
 	runtimeCtx    context.Context
 	runtimeCancel context.CancelFunc
+	// closed when heartBeat returned.
+	heartBeatDone chan struct{}
 
 	sync.Mutex
 }
 
 func newRedisZSetDriver(redisClient redis.UniversalClient) *RedisZSetDriver {
 	rd := &RedisZSetDriver{
-		c: redisClient,
-		logger: &dlog.StdLogger{
-			Log: log.Default(),
-		},
+		c:       redisClient,
+		logger:  dlog.DefaultPrintfLogger(log.Default()),
 		timeout: redisDefaultTimeout,
 	}
 	rd.started = false
@@ -42,10 +46,10 @@ func newRedisZSetDriver(redisClient redis.UniversalClient) *RedisZSetDriver {
 
 func (rd *RedisZSetDriver) Init(serviceName string, opts ...Option) {
 	rd.serviceName = serviceName
-	rd.nodeID = GetNodeId(serviceName)
 	for _, opt := range opts {
 		rd.WithOption(opt)
 	}
+	rd.nodeID = rd.keyPrefix + GetNodeIdWithName(serviceName, rd.nodeName, rd.weight)
 }
 
 func (rd *RedisZSetDriver) NodeID() string {
@@ -55,7 +59,7 @@ func (rd *RedisZSetDriver) NodeID() string {
 func (rd *RedisZSetDriver) GetNodes(ctx context.Context) (nodes []string, err error) {
 	rd.Lock()
 	defer rd.Unlock()
-	sliceCmd := rd.c.ZRangeByScore(ctx, GetKeyPre(rd.serviceName), &redis.ZRangeBy{
+	sliceCmd := rd.c.ZRangeByScore(ctx, rd.keyPrefix+GetKeyPre(rd.serviceName), &redis.ZRangeBy{
 		Min: fmt.Sprintf("%d", TimePre(time.Now(), rd.timeout)),
 		Max: "+inf",
 	})
@@ -75,23 +79,45 @@ func (rd *RedisZSetDriver) Start(ctx context.Context) (err error) {
 		err = errors.New("this driver is started")
 		return
 	}
-	rd.runtimeCtx, rd.runtimeCancel = context.WithCancel(context.TODO())
-	rd.started = true
+	if rd.nodeName != "" {
+		if err = rd.checkNodeIDUnique(ctx); err != nil {
+			rd.logger.Errorf("register service error=%v", err)
+			return
+		}
+	}
 	// register
-	err = rd.registerServiceNode()
+	err = rd.registerServiceNode(ctx)
 	if err != nil {
 		rd.logger.Errorf("register service error=%v", err)
 		return
 	}
+	rd.runtimeCtx, rd.runtimeCancel = context.WithCancel(context.TODO())
+	rd.started = true
 	// heartbeat timer
+	rd.heartBeatDone = make(chan struct{})
 	go rd.heartBeat()
 	return
 }
+
+// Stop stops the heartbeat and deregisters this node, so the other nodes
+// see it is gone in their next GetNodes, without waiting for the heartbeat
+// to expire. If the node can not be deregistered, the error is logged and
+// returned, the heartbeat expires in the timeout.
 func (rd *RedisZSetDriver) Stop(ctx context.Context) (err error) {
 	rd.Lock()
 	defer rd.Unlock()
+	if !rd.started {
+		return
+	}
 	rd.runtimeCancel()
 	rd.started = false
+	if rd.heartBeatDone != nil {
+		// the heartbeat must not register the node again after it is deleted.
+		<-rd.heartBeatDone
+	}
+	if err = rd.c.ZRem(ctx, rd.keyPrefix+GetKeyPre(rd.serviceName), rd.nodeID).Err(); err != nil {
+		rd.logger.Errorf("unregister service node error %+v", err)
+	}
 	return
 }
 
@@ -105,6 +131,22 @@ func (rd *RedisZSetDriver) WithOption(opt Option) (err error) {
 		{
 			rd.logger = opt.(LoggerOption).logger
 		}
+	case OptionTypeWeight:
+		{
+			rd.weight = opt.(WeightOption).weight
+		}
+	case OptionTypeKeyPrefix:
+		{
+			rd.keyPrefix = opt.(KeyPrefixOption).prefix
+		}
+	case OptionTypeNodeName:
+		{
+			rd.nodeName = opt.(NodeNameOption).name
+		}
+	case OptionTypeTakeOver:
+		{
+			rd.takeOver = true
+		}
 	}
 	return
 }
@@ -117,24 +159,84 @@ func (rd *RedisZSetDriver) heartBeat() {
 		select {
 		case <-tick.C:
 			{
-				if err := rd.registerServiceNode(); err != nil {
+				if err := rd.registerServiceNode(context.Background()); err != nil {
 					rd.logger.Errorf("register service node error %+v", err)
 				}
 			}
 		case <-rd.runtimeCtx.Done():
 			{
-				if err := rd.c.Del(context.Background(), rd.nodeID, rd.nodeID).Err(); err != nil {
-					rd.logger.Errorf("unregister service node error %+v", err)
-				}
+				tick.Stop()
+				close(rd.heartBeatDone)
 				return
 			}
 		}
 	}
 }
 
-func (rd *RedisZSetDriver) registerServiceNode() error {
-	return rd.c.ZAdd(context.Background(), GetKeyPre(rd.serviceName), redis.Z{
+func (rd *RedisZSetDriver) registerServiceNode(ctx context.Context) error {
+	return rd.c.ZAdd(ctx, rd.keyPrefix+GetKeyPre(rd.serviceName), redis.Z{
 		Score:  float64(time.Now().Unix()),
 		Member: rd.nodeID,
 	}).Err()
 }
+
+// checkNodeIDUnique returns ErrNodeIDExist if another alive node
+// has registered the same nodeID, unless it takes over the nodeID by
+// TakeOverOption.
+func (rd *RedisZSetDriver) checkNodeIDUnique(ctx context.Context) error {
+	if err := CheckNodeName(rd.nodeName); err != nil || rd.takeOver {
+		return err
+	}
+	score, err := rd.c.ZScore(ctx, rd.keyPrefix+GetKeyPre(rd.serviceName), rd.nodeID).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if int64(score) >= TimePre(time.Now(), rd.timeout) {
+		return ErrNodeIDExist
+	}
+	return nil
+}
+
+// HealthCheck implements HealthChecker.
+func (rd *RedisZSetDriver) HealthCheck(ctx context.Context) error {
+	return rd.c.Ping(ctx).Err()
+}
+
+func (rd *RedisZSetDriver) Get(ctx context.Context, key string) (value string, ok bool, err error) {
+	value, err = rd.c.Get(ctx, rd.keyPrefix+GetStoreKey(rd.serviceName, key)).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (rd *RedisZSetDriver) Set(ctx context.Context, key, value string) (err error) {
+	return rd.c.Set(ctx, rd.keyPrefix+GetStoreKey(rd.serviceName, key), value, 0).Err()
+}
+
+func (rd *RedisZSetDriver) Del(ctx context.Context, key string) (err error) {
+	return rd.c.Del(ctx, rd.keyPrefix+GetStoreKey(rd.serviceName, key)).Err()
+}
+
+func (rd *RedisZSetDriver) lockKey(key string) string {
+	return rd.keyPrefix + GetStoreKey(rd.serviceName, key)
+}
+
+func (rd *RedisZSetDriver) AcquireLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	return rd.c.SetNX(ctx, rd.lockKey(key), rd.nodeID, ttl).Result()
+}
+
+func (rd *RedisZSetDriver) ReleaseLock(ctx context.Context, key string) (err error) {
+	return unlockScript.Run(ctx, rd.c, []string{rd.lockKey(key)}, rd.nodeID).Err()
+}
+
+func (rd *RedisZSetDriver) RefreshLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	n, err := refreshScript.Run(ctx, rd.c, []string{rd.lockKey(key)}, rd.nodeID, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
@@ -90,3 +90,29 @@ func TestRedisZSetDriver_Stop(t *testing.T) {
 	drv2.Stop(context.Background())
 	drv1.Stop(context.Background())
 }
+
+func TestRedisZSetDriver_StopDeregisters(t *testing.T) {
+	rds := miniredis.RunT(t)
+	drv1 := testFuncNewRedisZSetDriver(rds.Addr())
+	drv1.Init(t.Name(),
+		driver.NewTimeoutOption(5*time.Second),
+		driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+	drv2 := testFuncNewRedisZSetDriver(rds.Addr())
+	drv2.Init(t.Name(),
+		driver.NewTimeoutOption(5*time.Second),
+		driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+	require.Nil(t, drv1.Start(context.Background()))
+	require.Nil(t, drv2.Start(context.Background()))
+
+	// the node is gone as soon as Stop returns, without waiting for the TTL.
+	require.Nil(t, drv1.Stop(context.Background()))
+	nodes, err := drv2.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, []string{drv2.NodeID()}, nodes)
+
+	// an unreachable server makes Stop return the error instead of hanging.
+	rds.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NotNil(t, drv2.Stop(ctx))
+}
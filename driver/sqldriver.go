@@ -0,0 +1,451 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libi/dcron/dlog"
+)
+
+const (
+	sqlDefaultTimeout = 5 * time.Second
+
+	// SQLNodesTable is the table of the heartbeats of the nodes, it is
+	// created by Start if it does not exist.
+	SQLNodesTable = "dcron_nodes"
+)
+
+// SQLDialect is the SQL database which the SQLDriver talks to.
+type SQLDialect int
+
+const (
+	// SQLDialectPostgres is PostgreSQL, the locks are pg_try_advisory_lock.
+	SQLDialectPostgres SQLDialect = iota
+	// SQLDialectMySQL is MySQL 5.7 or later, the locks are GET_LOCK.
+	SQLDialectMySQL
+)
+
+func (d SQLDialect) String() string {
+	switch d {
+	case SQLDialectPostgres:
+		return "Postgres"
+	case SQLDialectMySQL:
+		return "MySQL"
+	}
+	return "Unknown"
+}
+
+// sqlQueries are the statements in the syntax of a dialect.
+type sqlQueries struct {
+	createTable string
+	heartbeat   string
+	countAlive  string
+	selectNodes string
+	deleteNode  string
+	deleteStale string
+	tryLock     string
+	unlock      string
+	// lockKey converts the key of a lock to the argument of tryLock.
+	lockKey func(key string) any
+}
+
+func newSQLQueries(dialect SQLDialect) sqlQueries {
+	// the time of the database in milliseconds, so the clocks of the
+	// nodes do not matter.
+	now := "CAST(EXTRACT(EPOCH FROM CURRENT_TIMESTAMP) * 1000 AS BIGINT)"
+	upsert := "ON CONFLICT (node_id) DO UPDATE SET last_seen = EXCLUDED.last_seen"
+	tryLock := "SELECT CASE WHEN pg_try_advisory_lock($1) THEN 1 ELSE 0 END"
+	unlock := "SELECT pg_advisory_unlock($1)"
+	lockKey := func(key string) any { return int64(lockHash(key)) }
+	if dialect == SQLDialectMySQL {
+		now = "CAST(UNIX_TIMESTAMP(CURRENT_TIMESTAMP(3)) * 1000 AS SIGNED)"
+		upsert = "ON DUPLICATE KEY UPDATE last_seen = VALUES(last_seen)"
+		tryLock = "SELECT COALESCE(GET_LOCK(?, 0), 0)"
+		unlock = "SELECT RELEASE_LOCK(?)"
+		// the names of the locks are up to 64 characters.
+		lockKey = func(key string) any { return fmt.Sprintf("dcron:%016x", lockHash(key)) }
+	}
+	q := sqlQueries{
+		createTable: "CREATE TABLE IF NOT EXISTS " + SQLNodesTable + " (" +
+			"node_id VARCHAR(255) NOT NULL PRIMARY KEY, " +
+			"service_name VARCHAR(255) NOT NULL, " +
+			"last_seen BIGINT NOT NULL)",
+		heartbeat: "INSERT INTO " + SQLNodesTable + " (node_id, service_name, last_seen) " +
+			"VALUES ($1, $2, " + now + ") " + upsert,
+		countAlive:  "SELECT COUNT(*) FROM " + SQLNodesTable + " WHERE node_id = $1 AND last_seen > " + now + " - $2",
+		selectNodes: "SELECT node_id FROM " + SQLNodesTable + " WHERE service_name = $1 AND last_seen > " + now + " - $2",
+		deleteNode:  "DELETE FROM " + SQLNodesTable + " WHERE node_id = $1",
+		deleteStale: "DELETE FROM " + SQLNodesTable + " WHERE service_name = $1 AND last_seen <= " + now + " - $2",
+		tryLock:     tryLock,
+		unlock:      unlock,
+		lockKey:     lockKey,
+	}
+	if dialect == SQLDialectMySQL {
+		for _, query := range []*string{&q.heartbeat, &q.countAlive, &q.selectNodes, &q.deleteNode, &q.deleteStale} {
+			*query = mysqlPlaceholders(*query)
+		}
+	}
+	return q
+}
+
+// mysqlPlaceholders replaces the placeholders $1, $2... by ?, the query
+// must use them in order.
+func mysqlPlaceholders(query string) string {
+	for i := 9; i >= 1; i-- {
+		query = strings.ReplaceAll(query, fmt.Sprintf("$%d", i), "?")
+	}
+	return query
+}
+
+func lockHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// SQLDriver registers the node in a table of PostgreSQL or MySQL, for the
+// teams whose only shared storage is a relational database. Each node
+// upserts its row in SQLNodesTable with the time of the database every half
+// of the timeout, the nodes are the rows of the service seen in the
+// timeout. The live node with the smallest nodeID deletes the expired rows
+// of its service.
+//
+// It implements LockDriver by the advisory locks of the database, which are
+// held by a connection dedicated to the locks, so a lock is also released
+// once the connection is closed, e.g. this node died. A lock is released
+// after its ttl by this node. KVDriver is not implemented.
+type SQLDriver struct {
+	db          *sql.DB
+	dialect     SQLDialect
+	queries     sqlQueries
+	serviceName string
+	nodeID      string
+	timeout     time.Duration
+	logger      dlog.Logger
+	weight      int
+	keyPrefix   string
+	nodeName    string
+	takeOver    bool
+	started     bool
+	// leader is true if this node has the smallest nodeID in the last
+	// GetNodes, it deletes the expired rows.
+	leader atomic.Bool
+
+	// the connection holding the advisory locks, and the timers
+	// releasing them after their ttl.
+	lockMut  sync.Mutex
+	lockConn *sql.Conn
+	locks    map[string]*time.Timer
+
+	// this context is used to define
+	// the lifetime of this driver.
+	runtimeCtx    context.Context
+	runtimeCancel context.CancelFunc
+	// closed when heartBeat returned.
+	heartBeatDone chan struct{}
+
+	sync.Mutex
+}
+
+func newSQLDriver(db *sql.DB, dialect SQLDialect) *SQLDriver {
+	return &SQLDriver{
+		db:      db,
+		dialect: dialect,
+		queries: newSQLQueries(dialect),
+		logger:  dlog.DefaultPrintfLogger(log.Default()),
+		timeout: sqlDefaultTimeout,
+		locks:   make(map[string]*time.Timer),
+	}
+}
+
+func (sd *SQLDriver) Init(serviceName string, opts ...Option) {
+	sd.serviceName = serviceName
+	for _, opt := range opts {
+		sd.WithOption(opt)
+	}
+	sd.nodeID = sd.keyPrefix + GetNodeIdWithName(sd.serviceName, sd.nodeName, sd.weight)
+}
+
+func (sd *SQLDriver) NodeID() string {
+	return sd.nodeID
+}
+
+// Start creates SQLNodesTable if it does not exist, and registers this node.
+// With NodeNameOption, it returns ErrNodeIDExist if the row of the nodeID
+// is not expired, the check is not atomic with the registration. With
+// TakeOverOption, the row is taken over.
+func (sd *SQLDriver) Start(ctx context.Context) (err error) {
+	sd.Lock()
+	defer sd.Unlock()
+	if sd.started {
+		err = errors.New("this driver is started")
+		return
+	}
+	if _, err = sd.db.ExecContext(ctx, sd.queries.createTable); err != nil {
+		sd.logger.Errorf("create table error=%v", err)
+		return
+	}
+	if sd.nodeName != "" && !sd.takeOver {
+		if err = sd.checkUniqueNode(ctx); err != nil {
+			sd.logger.Errorf("register service error=%v", err)
+			return
+		}
+	}
+	if err = sd.heartbeat(ctx); err != nil {
+		sd.logger.Errorf("register service error=%v", err)
+		return
+	}
+	sd.runtimeCtx, sd.runtimeCancel = context.WithCancel(context.TODO())
+	sd.started = true
+	sd.heartBeatDone = make(chan struct{})
+	go sd.heartBeat()
+	return
+}
+
+// Stop stops the heartbeat, releases the locks and deletes the row of this
+// node. If the row can not be deleted, the error is logged and returned,
+// the row expires in the timeout.
+func (sd *SQLDriver) Stop(ctx context.Context) (err error) {
+	sd.Lock()
+	defer sd.Unlock()
+	if !sd.started {
+		return
+	}
+	sd.runtimeCancel()
+	sd.started = false
+	<-sd.heartBeatDone
+	sd.releaseAllLocks(ctx)
+	if _, err = sd.db.ExecContext(ctx, sd.queries.deleteNode, sd.nodeID); err != nil {
+		sd.logger.Errorf("unregister service node error %+v", err)
+	}
+	return
+}
+
+func (sd *SQLDriver) GetNodes(ctx context.Context) (nodes []string, err error) {
+	rows, err := sd.db.QueryContext(ctx, sd.queries.selectNodes, sd.service(), sd.timeout.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	nodes = make([]string, 0)
+	for rows.Next() {
+		var nodeID string
+		if err = rows.Scan(&nodeID); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, nodeID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(nodes)
+	sd.leader.Store(len(nodes) > 0 && nodes[0] == sd.nodeID)
+	return nodes, nil
+}
+
+// HealthCheck implements HealthChecker, it pings the database.
+func (sd *SQLDriver) HealthCheck(ctx context.Context) error {
+	return sd.db.PingContext(ctx)
+}
+
+func (sd *SQLDriver) WithOption(opt Option) (err error) {
+	switch opt.Type() {
+	case OptionTypeTimeout:
+		{
+			sd.timeout = opt.(TimeoutOption).timeout
+		}
+	case OptionTypeLogger:
+		{
+			sd.logger = opt.(LoggerOption).logger
+		}
+	case OptionTypeWeight:
+		{
+			sd.weight = opt.(WeightOption).weight
+		}
+	case OptionTypeKeyPrefix:
+		{
+			sd.keyPrefix = opt.(KeyPrefixOption).prefix
+		}
+	case OptionTypeNodeName:
+		{
+			sd.nodeName = opt.(NodeNameOption).name
+		}
+	case OptionTypeTakeOver:
+		{
+			sd.takeOver = true
+		}
+	}
+	return
+}
+
+// AcquireLock implements LockDriver, ok is false if the lock is held by
+// any node, including this node.
+func (sd *SQLDriver) AcquireLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	sd.lockMut.Lock()
+	defer sd.lockMut.Unlock()
+	key = sd.storeKey(key)
+	// the advisory locks are reentrant in the same connection.
+	if _, held := sd.locks[key]; held {
+		return false, nil
+	}
+	conn, err := sd.lockConnection(ctx)
+	if err != nil {
+		return false, err
+	}
+	var acquired int64
+	if err = conn.QueryRowContext(ctx, sd.queries.tryLock, sd.queries.lockKey(key)).Scan(&acquired); err != nil {
+		sd.closeLockConnection()
+		return false, err
+	}
+	if acquired != 1 {
+		return false, nil
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(ttl, func() {
+		sd.lockMut.Lock()
+		defer sd.lockMut.Unlock()
+		// the lock may be released and acquired again meanwhile.
+		if sd.locks[key] == timer {
+			_ = sd.releaseLock(context.Background(), key)
+		}
+	})
+	sd.locks[key] = timer
+	return true, nil
+}
+
+// ReleaseLock implements LockDriver.
+func (sd *SQLDriver) ReleaseLock(ctx context.Context, key string) (err error) {
+	sd.lockMut.Lock()
+	defer sd.lockMut.Unlock()
+	return sd.releaseLock(ctx, sd.storeKey(key))
+}
+
+// RefreshLock implements LockRefresher, it resets the timer releasing the
+// lock of key.
+func (sd *SQLDriver) RefreshLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	sd.lockMut.Lock()
+	defer sd.lockMut.Unlock()
+	timer, held := sd.locks[sd.storeKey(key)]
+	if !held || !timer.Stop() {
+		return false, nil
+	}
+	timer.Reset(ttl)
+	return true, nil
+}
+
+// private function
+
+func (sd *SQLDriver) heartBeat() {
+	tick := time.NewTicker(sd.timeout / 2)
+	defer tick.Stop()
+	defer close(sd.heartBeatDone)
+	for {
+		select {
+		case <-tick.C:
+			ctx, cancel := context.WithTimeout(sd.runtimeCtx, sd.timeout)
+			if err := sd.heartbeat(ctx); err != nil {
+				sd.logger.Errorf("register service node error %+v", err)
+			}
+			if sd.leader.Load() {
+				if _, err := sd.db.ExecContext(ctx, sd.queries.deleteStale, sd.service(), sd.timeout.Milliseconds()); err != nil {
+					sd.logger.Errorf("delete expired nodes error %+v", err)
+				}
+			}
+			cancel()
+		case <-sd.runtimeCtx.Done():
+			return
+		}
+	}
+}
+
+func (sd *SQLDriver) heartbeat(ctx context.Context) error {
+	_, err := sd.db.ExecContext(ctx, sd.queries.heartbeat, sd.nodeID, sd.service())
+	return err
+}
+
+func (sd *SQLDriver) checkUniqueNode(ctx context.Context) error {
+	var alive int64
+	if err := sd.db.QueryRowContext(ctx, sd.queries.countAlive, sd.nodeID, sd.timeout.Milliseconds()).Scan(&alive); err != nil {
+		return err
+	}
+	if alive > 0 {
+		return ErrNodeIDExist
+	}
+	return nil
+}
+
+// service is the value of the service_name column, the nodes of the
+// services which share a prefix do not match it.
+func (sd *SQLDriver) service() string {
+	return sd.keyPrefix + sd.serviceName
+}
+
+func (sd *SQLDriver) storeKey(key string) string {
+	return sd.keyPrefix + GetStoreKey(sd.serviceName, key)
+}
+
+// lockConnection returns the connection holding the locks, it must be
+// called with lockMut locked.
+func (sd *SQLDriver) lockConnection(ctx context.Context) (*sql.Conn, error) {
+	if sd.lockConn != nil {
+		return sd.lockConn, nil
+	}
+	conn, err := sd.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sd.lockConn = conn
+	return conn, nil
+}
+
+// closeLockConnection closes the broken connection holding the locks, the
+// locks held by it are lost. It must be called with lockMut locked.
+func (sd *SQLDriver) closeLockConnection() {
+	if sd.lockConn == nil {
+		return
+	}
+	_ = sd.lockConn.Close()
+	sd.lockConn = nil
+	for key, timer := range sd.locks {
+		timer.Stop()
+		delete(sd.locks, key)
+	}
+}
+
+// releaseLock releases the lock of the full key, it must be called with
+// lockMut locked.
+func (sd *SQLDriver) releaseLock(ctx context.Context, key string) error {
+	timer, held := sd.locks[key]
+	if !held {
+		return nil
+	}
+	timer.Stop()
+	delete(sd.locks, key)
+	if _, err := sd.lockConn.ExecContext(ctx, sd.queries.unlock, sd.queries.lockKey(key)); err != nil {
+		sd.closeLockConnection()
+		return err
+	}
+	return nil
+}
+
+// releaseAllLocks releases the locks held by this node, and returns the
+// connection holding them to the pool.
+func (sd *SQLDriver) releaseAllLocks(ctx context.Context) {
+	sd.lockMut.Lock()
+	defer sd.lockMut.Unlock()
+	for key := range sd.locks {
+		if err := sd.releaseLock(ctx, key); err != nil {
+			sd.logger.Errorf("release lock error %+v", err)
+		}
+	}
+	sd.closeLockConnection()
+}
@@ -0,0 +1,329 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libi/dcron/dlog"
+	"github.com/libi/dcron/driver"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSQL is an in-memory database which understands the statements of
+// SQLDriver, the advisory locks are held by the connections as in the
+// real databases.
+type fakeSQL struct {
+	mu       sync.Mutex
+	nodes    map[string]fakeSQLNode
+	locks    map[string]*fakeSQLConn
+	lockRuns int
+}
+
+type fakeSQLNode struct {
+	service  string
+	lastSeen int64
+}
+
+func (f *fakeSQL) Open(name string) (sqldriver.Conn, error) {
+	return &fakeSQLConn{db: f}, nil
+}
+
+// expire makes the row of the node expired, as if it died.
+func (f *fakeSQL) expire(nodeID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	node := f.nodes[nodeID]
+	node.lastSeen = 0
+	f.nodes[nodeID] = node
+}
+
+func (f *fakeSQL) rows() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.nodes)
+}
+
+type fakeSQLConn struct {
+	db *fakeSQL
+}
+
+func (c *fakeSQLConn) Prepare(query string) (sqldriver.Stmt, error) {
+	return &fakeSQLStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeSQLConn) Close() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	for key, owner := range c.db.locks {
+		if owner == c {
+			delete(c.db.locks, key)
+		}
+	}
+	return nil
+}
+
+func (c *fakeSQLConn) Begin() (sqldriver.Tx, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+type fakeSQLStmt struct {
+	conn  *fakeSQLConn
+	query string
+}
+
+func (s *fakeSQLStmt) Close() error  { return nil }
+func (s *fakeSQLStmt) NumInput() int { return -1 }
+
+func (s *fakeSQLStmt) Exec(args []sqldriver.Value) (sqldriver.Result, error) {
+	_, err := s.run(args)
+	return sqldriver.RowsAffected(0), err
+}
+
+func (s *fakeSQLStmt) Query(args []sqldriver.Value) (sqldriver.Rows, error) {
+	return s.run(args)
+}
+
+func (s *fakeSQLStmt) run(args []sqldriver.Value) (*fakeSQLRows, error) {
+	f := s.conn.db
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now().UnixMilli()
+	q := s.query
+	switch {
+	case strings.HasPrefix(q, "CREATE TABLE"):
+		return &fakeSQLRows{}, nil
+	case strings.HasPrefix(q, "INSERT INTO dcron_nodes"):
+		f.nodes[args[0].(string)] = fakeSQLNode{service: args[1].(string), lastSeen: now}
+		return &fakeSQLRows{}, nil
+	case strings.HasPrefix(q, "SELECT COUNT(*)"):
+		var count int64
+		if node, ok := f.nodes[args[0].(string)]; ok && node.lastSeen > now-args[1].(int64) {
+			count = 1
+		}
+		return &fakeSQLRows{values: [][]sqldriver.Value{{count}}}, nil
+	case strings.HasPrefix(q, "SELECT node_id"):
+		rows := &fakeSQLRows{}
+		for nodeID, node := range f.nodes {
+			if node.service == args[0].(string) && node.lastSeen > now-args[1].(int64) {
+				rows.values = append(rows.values, []sqldriver.Value{nodeID})
+			}
+		}
+		return rows, nil
+	case strings.HasPrefix(q, "DELETE FROM dcron_nodes WHERE node_id"):
+		delete(f.nodes, args[0].(string))
+		return &fakeSQLRows{}, nil
+	case strings.HasPrefix(q, "DELETE FROM dcron_nodes WHERE service_name"):
+		for nodeID, node := range f.nodes {
+			if node.service == args[0].(string) && node.lastSeen <= now-args[1].(int64) {
+				delete(f.nodes, nodeID)
+			}
+		}
+		return &fakeSQLRows{}, nil
+	case strings.Contains(q, "pg_try_advisory_lock") || strings.Contains(q, "GET_LOCK"):
+		key := fmt.Sprint(args[0])
+		var acquired int64
+		if owner, held := f.locks[key]; !held || owner == s.conn {
+			f.locks[key] = s.conn
+			acquired = 1
+		}
+		f.lockRuns++
+		return &fakeSQLRows{values: [][]sqldriver.Value{{acquired}}}, nil
+	case strings.Contains(q, "pg_advisory_unlock") || strings.Contains(q, "RELEASE_LOCK"):
+		key := fmt.Sprint(args[0])
+		if f.locks[key] == s.conn {
+			delete(f.locks, key)
+		}
+		return &fakeSQLRows{values: [][]sqldriver.Value{{int64(1)}}}, nil
+	}
+	return nil, fmt.Errorf("unknown query %q", q)
+}
+
+type fakeSQLRows struct {
+	values [][]sqldriver.Value
+}
+
+func (r *fakeSQLRows) Columns() []string {
+	if len(r.values) == 0 {
+		return []string{"c"}
+	}
+	return make([]string, len(r.values[0]))
+}
+
+func (r *fakeSQLRows) Close() error { return nil }
+
+func (r *fakeSQLRows) Next(dest []sqldriver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var fakeSQLSeq int
+var fakeSQLMut sync.Mutex
+
+// newFakeSQL opens a new fake database.
+func newFakeSQL(t *testing.T) (*sql.DB, *fakeSQL) {
+	fakeSQLMut.Lock()
+	fakeSQLSeq++
+	name := fmt.Sprintf("dcron-fake-sql-%d", fakeSQLSeq)
+	fakeSQLMut.Unlock()
+	f := &fakeSQL{nodes: make(map[string]fakeSQLNode), locks: make(map[string]*fakeSQLConn)}
+	sql.Register(name, f)
+	db, err := sql.Open(name, "")
+	require.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db, f
+}
+
+func testFuncNewSQLDriver(t *testing.T, db *sql.DB, dialect driver.SQLDialect, opts ...driver.Option) driver.DriverV2 {
+	drv := driver.NewSQLDriver(db, dialect)
+	opts = append([]driver.Option{
+		driver.NewTimeoutOption(time.Second),
+		driver.NewLoggerOption(dlog.NewLoggerForTest(t)),
+	}, opts...)
+	drv.Init(t.Name(), opts...)
+	return drv
+}
+
+var sqlDialects = []driver.SQLDialect{driver.SQLDialectPostgres, driver.SQLDialectMySQL}
+
+func TestSQLDriver_GetNodes(t *testing.T) {
+	for _, dialect := range sqlDialects {
+		t.Run(dialect.String(), func(t *testing.T) {
+			db, f := newFakeSQL(t)
+			drvs := make([]driver.DriverV2, 0)
+			N := 5
+			for i := 0; i < N; i++ {
+				drv := testFuncNewSQLDriver(t, db, dialect)
+				require.Nil(t, drv.Start(context.Background()))
+				drvs = append(drvs, drv)
+			}
+			// the nodes of a service sharing the prefix are not returned.
+			other := driver.NewSQLDriver(db, dialect)
+			other.Init(t.Name() + ":other")
+			require.Nil(t, other.Start(context.Background()))
+			defer other.Stop(context.Background())
+
+			for _, v := range drvs {
+				nodes, err := v.GetNodes(context.Background())
+				require.Nil(t, err)
+				require.Len(t, nodes, N)
+			}
+			for _, v := range drvs {
+				require.Nil(t, v.Stop(context.Background()))
+			}
+			require.Equal(t, 1, f.rows())
+			nodes, err := other.GetNodes(context.Background())
+			require.Nil(t, err)
+			require.Equal(t, []string{other.NodeID()}, nodes)
+		})
+	}
+}
+
+func TestSQLDriver_ExpiredNode(t *testing.T) {
+	for _, dialect := range sqlDialects {
+		t.Run(dialect.String(), func(t *testing.T) {
+			db, f := newFakeSQL(t)
+			drv1 := testFuncNewSQLDriver(t, db, dialect)
+			drv2 := testFuncNewSQLDriver(t, db, dialect)
+			require.Nil(t, drv1.Start(context.Background()))
+			defer drv1.Stop(context.Background())
+			// the row of a dead node.
+			f.mu.Lock()
+			f.nodes[drv2.NodeID()] = fakeSQLNode{service: t.Name(), lastSeen: 0}
+			f.mu.Unlock()
+
+			nodes, err := drv1.GetNodes(context.Background())
+			require.Nil(t, err)
+			require.Equal(t, []string{drv1.NodeID()}, nodes)
+			// the leader deletes the expired row in its heartbeat.
+			require.Eventually(t, func() bool {
+				return f.rows() == 1
+			}, 3*time.Second, 10*time.Millisecond)
+
+			f.expire(drv1.NodeID())
+			nodes, err = drv1.GetNodes(context.Background())
+			require.Nil(t, err)
+			require.Empty(t, nodes)
+			// the heartbeat registers it again.
+			require.Eventually(t, func() bool {
+				nodes, err := drv1.GetNodes(context.Background())
+				return err == nil && len(nodes) == 1
+			}, 3*time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestSQLDriver_NodeName(t *testing.T) {
+	db, _ := newFakeSQL(t)
+	drv1 := testFuncNewSQLDriver(t, db, driver.SQLDialectPostgres, driver.NewNodeNameOption("node-1"))
+	require.Nil(t, drv1.Start(context.Background()))
+	drv2 := testFuncNewSQLDriver(t, db, driver.SQLDialectPostgres, driver.NewNodeNameOption("node-1"))
+	require.ErrorIs(t, drv2.Start(context.Background()), driver.ErrNodeIDExist)
+	drv3 := testFuncNewSQLDriver(t, db, driver.SQLDialectPostgres, driver.NewNodeNameOption("node-1"), driver.NewTakeOverOption())
+	require.Nil(t, drv3.Start(context.Background()))
+	require.Nil(t, drv3.Stop(context.Background()))
+	require.Nil(t, drv1.Stop(context.Background()))
+	require.Nil(t, drv2.Start(context.Background()))
+	require.Nil(t, drv2.Stop(context.Background()))
+}
+
+func TestSQLDriver_Lock(t *testing.T) {
+	for _, dialect := range sqlDialects {
+		t.Run(dialect.String(), func(t *testing.T) {
+			db, f := newFakeSQL(t)
+			drv1 := testFuncNewSQLDriver(t, db, dialect)
+			drv2 := testFuncNewSQLDriver(t, db, dialect)
+			require.Nil(t, drv1.Start(context.Background()))
+			require.Nil(t, drv2.Start(context.Background()))
+			defer drv2.Stop(context.Background())
+			ld1, ld2 := drv1.(driver.LockDriver), drv2.(driver.LockDriver)
+			ctx := context.Background()
+
+			ok, err := ld1.AcquireLock(ctx, "lock", time.Minute)
+			require.Nil(t, err)
+			require.True(t, ok)
+			// held by this node in the same connection.
+			ok, err = ld1.AcquireLock(ctx, "lock", time.Minute)
+			require.Nil(t, err)
+			require.False(t, ok)
+			ok, err = ld2.AcquireLock(ctx, "lock", time.Minute)
+			require.Nil(t, err)
+			require.False(t, ok)
+
+			require.Nil(t, ld1.ReleaseLock(ctx, "lock"))
+			ok, err = ld2.AcquireLock(ctx, "lock", 100*time.Millisecond)
+			require.Nil(t, err)
+			require.True(t, ok)
+			ok, err = drv2.(driver.LockRefresher).RefreshLock(ctx, "lock", 100*time.Millisecond)
+			require.Nil(t, err)
+			require.True(t, ok)
+			// released after the ttl.
+			require.Eventually(t, func() bool {
+				ok, err := ld1.AcquireLock(ctx, "lock", time.Minute)
+				return err == nil && ok
+			}, 3*time.Second, 10*time.Millisecond)
+			ok, err = drv2.(driver.LockRefresher).RefreshLock(ctx, "lock", time.Minute)
+			require.Nil(t, err)
+			require.False(t, ok)
+
+			// Stop releases the locks of the node.
+			require.Nil(t, drv1.Stop(ctx))
+			ok, err = ld2.AcquireLock(ctx, "lock", time.Minute)
+			require.Nil(t, err)
+			require.True(t, ok)
+			f.mu.Lock()
+			require.Greater(t, f.lockRuns, 0)
+			f.mu.Unlock()
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package driver
 
 import (
+	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,14 +12,88 @@ import (
 // GlobalKeyPrefix is a global redis key prefix
 const GlobalKeyPrefix = "distributed-cron:"
 
+// GlobalStoreKeyPrefix is a global prefix of the keys in KVDriver,
+// it must not be matched by the prefix of nodes.
+const GlobalStoreKeyPrefix = "distributed-cron-store:"
+
 func GetKeyPre(serviceName string) string {
 	return GlobalKeyPrefix + serviceName + ":"
 }
 
+func GetStoreKey(serviceName, key string) string {
+	return GlobalStoreKeyPrefix + serviceName + ":" + key
+}
+
 func GetNodeId(serviceName string) string {
 	return GetKeyPre(serviceName) + uuid.New().String()
 }
 
+// nodeWeightSeparator separates the nodeID and the weight advertised by the node.
+const nodeWeightSeparator = "@"
+
+// GetNodeIdWithWeight returns a nodeID which advertises the weight of the node.
+// If weight <= 0, it is the same as GetNodeId.
+func GetNodeIdWithWeight(serviceName string, weight int) string {
+	if weight <= 0 {
+		return GetNodeId(serviceName)
+	}
+	return GetNodeId(serviceName) + nodeWeightSeparator + strconv.Itoa(weight)
+}
+
+var (
+	ErrNodeIDExist     = errors.New("nodeID already registered by another node")
+	ErrInvalidNodeName = errors.New("node name must not be empty or contain ':', '@', '/' or '*'")
+	// ErrInvalidServiceName is returned if the service name contains
+	// ':', which separates the service name and the keys.
+	ErrInvalidServiceName = errors.New("service name must not contain ':'")
+)
+
+// GetNodeIdWithName returns a nodeID which uses name instead of a random uuid.
+// If name is empty, it is the same as GetNodeIdWithWeight.
+func GetNodeIdWithName(serviceName, name string, weight int) string {
+	if name == "" {
+		return GetNodeIdWithWeight(serviceName, weight)
+	}
+	nodeID := GetKeyPre(serviceName) + name
+	if weight > 0 {
+		nodeID += nodeWeightSeparator + strconv.Itoa(weight)
+	}
+	return nodeID
+}
+
+// CheckNodeName returns ErrInvalidNodeName if the name can not be used
+// in the nodeID, the separators of the nodeID and keys are not allowed.
+func CheckNodeName(name string) error {
+	if name == "" || strings.ContainsAny(name, ":@/*") {
+		return ErrInvalidNodeName
+	}
+	return nil
+}
+
+// CheckServiceName returns ErrInvalidServiceName if the name can not be
+// used in the nodeIDs and the store keys, since the keys of a service
+// named "a" would be a prefix of the keys of the service named "a:b".
+func CheckServiceName(name string) error {
+	if strings.Contains(name, ":") {
+		return ErrInvalidServiceName
+	}
+	return nil
+}
+
+// GetNodeWeight returns the weight advertised in the nodeID,
+// 1 is returned if there is no weight in it.
+func GetNodeWeight(nodeID string) int {
+	i := strings.LastIndex(nodeID, nodeWeightSeparator)
+	if i < 0 || i < strings.LastIndex(nodeID, ":") {
+		return 1
+	}
+	weight, err := strconv.Atoi(nodeID[i+1:])
+	if err != nil || weight <= 0 {
+		return 1
+	}
+	return weight
+}
+
 func TimePre(t time.Time, preDuration time.Duration) int64 {
 	return t.Add(-preDuration).Unix()
 }
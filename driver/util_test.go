@@ -0,0 +1,17 @@
+package driver_test
+
+import (
+	"testing"
+
+	"github.com/libi/dcron/driver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetNodeWeight(t *testing.T) {
+	require.Equal(t, 1, driver.GetNodeWeight(driver.GetNodeId(t.Name())))
+	require.Equal(t, 1, driver.GetNodeWeight(driver.GetNodeIdWithWeight(t.Name(), 0)))
+	require.Equal(t, 4, driver.GetNodeWeight(driver.GetNodeIdWithWeight(t.Name(), 4)))
+	// the separator in service name is not a weight.
+	require.Equal(t, 1, driver.GetNodeWeight(driver.GetNodeId("svc@3")))
+	require.Equal(t, 1, driver.GetNodeWeight("distributed-cron:svc:id@x"))
+}
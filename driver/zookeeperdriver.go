@@ -0,0 +1,364 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/libi/dcron/dlog"
+)
+
+const (
+	zkDefaultTimeout = 5 * time.Second
+)
+
+// ZookeeperDriver registers the node as an ephemeral znode under the
+// service path, the znode disappears when the session of zookeeper
+// is expired. The session timeout is set when connecting to zookeeper.
+type ZookeeperDriver struct {
+	conn        *zk.Conn
+	serviceName string
+	nodeID      string
+	timeout     time.Duration
+	logger      dlog.Logger
+	weight      int
+	keyPrefix   string
+	nodeName    string
+	takeOver    bool
+	started     bool
+
+	nodes   []string
+	nodesMu sync.RWMutex
+
+	// this context is used to define
+	// the lifetime of this driver.
+	runtimeCtx    context.Context
+	runtimeCancel context.CancelFunc
+
+	sync.Mutex
+}
+
+func newZookeeperDriver(conn *zk.Conn) *ZookeeperDriver {
+	zd := &ZookeeperDriver{
+		conn:    conn,
+		logger:  dlog.DefaultPrintfLogger(log.Default()),
+		timeout: zkDefaultTimeout,
+		nodes:   make([]string, 0),
+	}
+	zd.started = false
+	return zd
+}
+
+func (zd *ZookeeperDriver) Init(serviceName string, opts ...Option) {
+	zd.serviceName = serviceName
+	for _, opt := range opts {
+		zd.WithOption(opt)
+	}
+	zd.nodeID = zd.keyPrefix + GetNodeIdWithName(serviceName, zd.nodeName, zd.weight)
+}
+
+func (zd *ZookeeperDriver) NodeID() string {
+	return zd.nodeID
+}
+
+// GetNodes returns the nodes cached by the children watcher.
+func (zd *ZookeeperDriver) GetNodes(ctx context.Context) (nodes []string, err error) {
+	zd.nodesMu.RLock()
+	defer zd.nodesMu.RUnlock()
+	nodes = make([]string, len(zd.nodes))
+	copy(nodes, zd.nodes)
+	return
+}
+
+func (zd *ZookeeperDriver) Start(ctx context.Context) (err error) {
+	zd.Lock()
+	defer zd.Unlock()
+	if zd.started {
+		err = errors.New("this driver is started")
+		return
+	}
+	if err = zd.ensurePath(zd.servicePath()); err != nil {
+		zd.logger.Errorf("create service path error=%v", err)
+		return
+	}
+	// register
+	if zd.nodeName != "" {
+		err = zd.registerUniqueServiceNode()
+	} else {
+		err = zd.registerServiceNode()
+	}
+	if err != nil {
+		zd.logger.Errorf("register service error=%v", err)
+		return
+	}
+	children, _, watchCh, err := zd.conn.ChildrenW(zd.servicePath())
+	if err != nil {
+		zd.logger.Errorf("watch service error=%v", err)
+		return
+	}
+	zd.setNodes(children)
+	zd.runtimeCtx, zd.runtimeCancel = context.WithCancel(context.TODO())
+	zd.started = true
+	go zd.watcher(zd.runtimeCtx, watchCh)
+	// heartbeat timer
+	go zd.heartBeat(zd.runtimeCtx)
+	return
+}
+
+func (zd *ZookeeperDriver) Stop(ctx context.Context) (err error) {
+	zd.Lock()
+	defer zd.Unlock()
+	if !zd.started {
+		return
+	}
+	zd.runtimeCancel()
+	zd.started = false
+	if err = zd.conn.Delete(zd.nodePath(), -1); err != nil && err != zk.ErrNoNode {
+		zd.logger.Errorf("unregister service node error %+v", err)
+		return
+	}
+	return nil
+}
+
+func (zd *ZookeeperDriver) WithOption(opt Option) (err error) {
+	switch opt.Type() {
+	case OptionTypeTimeout:
+		{
+			zd.timeout = opt.(TimeoutOption).timeout
+		}
+	case OptionTypeLogger:
+		{
+			zd.logger = opt.(LoggerOption).logger
+		}
+	case OptionTypeWeight:
+		{
+			zd.weight = opt.(WeightOption).weight
+		}
+	case OptionTypeKeyPrefix:
+		{
+			zd.keyPrefix = opt.(KeyPrefixOption).prefix
+		}
+	case OptionTypeNodeName:
+		{
+			zd.nodeName = opt.(NodeNameOption).name
+		}
+	case OptionTypeTakeOver:
+		{
+			zd.takeOver = true
+		}
+	}
+	return
+}
+
+// HealthCheck implements HealthChecker.
+func (zd *ZookeeperDriver) HealthCheck(ctx context.Context) error {
+	if state := zd.conn.State(); state != zk.StateHasSession {
+		return fmt.Errorf("zookeeper connection state is %v", state)
+	}
+	return nil
+}
+
+func (zd *ZookeeperDriver) Get(ctx context.Context, key string) (value string, ok bool, err error) {
+	data, _, err := zd.conn.Get(zd.storePath(key))
+	if err == zk.ErrNoNode {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+func (zd *ZookeeperDriver) Set(ctx context.Context, key, value string) (err error) {
+	if err = zd.ensurePath(zd.storeRootPath()); err != nil {
+		return
+	}
+	_, err = zd.conn.Set(zd.storePath(key), []byte(value), -1)
+	if err == zk.ErrNoNode {
+		_, err = zd.conn.Create(zd.storePath(key), []byte(value), 0, zk.WorldACL(zk.PermAll))
+	}
+	return
+}
+
+func (zd *ZookeeperDriver) Del(ctx context.Context, key string) (err error) {
+	err = zd.conn.Delete(zd.storePath(key), -1)
+	if err == zk.ErrNoNode {
+		return nil
+	}
+	return
+}
+
+// private function
+
+func (zd *ZookeeperDriver) servicePath() string {
+	return "/" + zd.keyPrefix + GlobalKeyPrefix + zd.serviceName
+}
+
+func (zd *ZookeeperDriver) nodePath() string {
+	return zd.servicePath() + "/" + zd.nodeID
+}
+
+func (zd *ZookeeperDriver) storeRootPath() string {
+	return "/" + zd.keyPrefix + GlobalStoreKeyPrefix + zd.serviceName
+}
+
+func (zd *ZookeeperDriver) storePath(key string) string {
+	// the key may contain '/', which is not allowed in a znode name.
+	return zd.storeRootPath() + "/" + url.PathEscape(key)
+}
+
+// ensurePath creates the persistent znode of path if it not exist.
+func (zd *ZookeeperDriver) ensurePath(path string) error {
+	_, err := zd.conn.Create(path, nil, 0, zk.WorldACL(zk.PermAll))
+	if err != nil && err != zk.ErrNodeExists {
+		return err
+	}
+	return nil
+}
+
+func (zd *ZookeeperDriver) registerServiceNode() error {
+	_, err := zd.conn.Create(zd.nodePath(), []byte(zd.nodeID), zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
+	if err != nil && err != zk.ErrNodeExists {
+		return err
+	}
+	return nil
+}
+
+// registerUniqueServiceNode registers the named node, it returns
+// ErrNodeIDExist if the znode is owned by the session of another node,
+// unless it takes over the znode by TakeOverOption.
+func (zd *ZookeeperDriver) registerUniqueServiceNode() error {
+	if err := CheckNodeName(zd.nodeName); err != nil {
+		return err
+	}
+	_, err := zd.conn.Create(zd.nodePath(), []byte(zd.nodeID), zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
+	if err != zk.ErrNodeExists {
+		return err
+	}
+	_, stat, err := zd.conn.Get(zd.nodePath())
+	if err != nil {
+		return err
+	}
+	if stat.EphemeralOwner == zd.conn.SessionID() {
+		return nil
+	}
+	if !zd.takeOver {
+		return ErrNodeIDExist
+	}
+	// the znode of another session can not be owned, delete and create it.
+	if err = zd.conn.Delete(zd.nodePath(), stat.Version); err != nil && err != zk.ErrNoNode {
+		return err
+	}
+	_, err = zd.conn.Create(zd.nodePath(), []byte(zd.nodeID), zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
+	return err
+}
+
+func (zd *ZookeeperDriver) setNodes(children []string) {
+	zd.nodesMu.Lock()
+	defer zd.nodesMu.Unlock()
+	zd.nodes = children
+}
+
+// watcher updates the nodes once the children of service path changed.
+func (zd *ZookeeperDriver) watcher(ctx context.Context, watchCh <-chan zk.Event) {
+	for {
+		select {
+		case <-watchCh:
+			{
+				children, _, ch, err := zd.conn.ChildrenW(zd.servicePath())
+				if err != nil {
+					zd.logger.Errorf("watch service error %+v", err)
+					select {
+					case <-time.After(zd.timeout / 2):
+						// watchCh has been fired, make a closed one to retry.
+						closed := make(chan zk.Event)
+						close(closed)
+						watchCh = closed
+					case <-ctx.Done():
+						return
+					}
+					continue
+				}
+				zd.setNodes(children)
+				watchCh = ch
+			}
+		case <-ctx.Done():
+			{
+				return
+			}
+		}
+	}
+}
+
+// heartBeat registers this node again if the ephemeral znode
+// is lost, like the session of zookeeper is expired.
+func (zd *ZookeeperDriver) heartBeat(ctx context.Context) {
+	tick := time.NewTicker(zd.timeout / 2)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			{
+				if err := zd.registerServiceNode(); err != nil {
+					zd.logger.Errorf("register service node error %+v", err)
+				}
+			}
+		case <-ctx.Done():
+			{
+				return
+			}
+		}
+	}
+}
+
+// AcquireLock creates an ephemeral znode of key, the ttl is not supported by
+// zookeeper, the lock is held until ReleaseLock or the session is expired.
+func (zd *ZookeeperDriver) AcquireLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	if err = zd.ensurePath(zd.storeRootPath()); err != nil {
+		return false, err
+	}
+	_, err = zd.conn.Create(zd.storePath(key), []byte(zd.nodeID), zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
+	if err == zk.ErrNodeExists {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (zd *ZookeeperDriver) ReleaseLock(ctx context.Context, key string) (err error) {
+	_, stat, err := zd.conn.Get(zd.storePath(key))
+	if err == zk.ErrNoNode {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stat.EphemeralOwner != zd.conn.SessionID() {
+		return nil
+	}
+	err = zd.conn.Delete(zd.storePath(key), stat.Version)
+	if err == zk.ErrNoNode {
+		return nil
+	}
+	return
+}
+
+// RefreshLock checks the lock is still held by the session of this node,
+// the ephemeral znode has no ttl to be reset.
+func (zd *ZookeeperDriver) RefreshLock(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	_, stat, err := zd.conn.Get(zd.storePath(key))
+	if err == zk.ErrNoNode {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return stat.EphemeralOwner == zd.conn.SessionID(), nil
+}
@@ -0,0 +1,82 @@
+package driver_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/libi/dcron/dlog"
+	"github.com/libi/dcron/driver"
+	"github.com/stretchr/testify/require"
+)
+
+// these tests run only if the address of zookeeper is set in ZK_ADDR,
+// multiple addresses are separated by ','.
+func testFuncNewZookeeperDriver(t *testing.T) driver.DriverV2 {
+	addr := os.Getenv("ZK_ADDR")
+	if addr == "" {
+		t.Skip("ZK_ADDR is not set")
+	}
+	conn, _, err := zk.Connect(strings.Split(addr, ","), 5*time.Second)
+	require.Nil(t, err)
+	t.Cleanup(conn.Close)
+	return driver.NewZookeeperDriver(conn)
+}
+
+func TestZookeeperDriver_GetNodes(t *testing.T) {
+	drvs := make([]driver.DriverV2, 0)
+	N := 10
+	for i := 0; i < N; i++ {
+		drv := testFuncNewZookeeperDriver(t)
+		drv.Init(
+			t.Name(),
+			driver.NewTimeoutOption(5*time.Second),
+			driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+		err := drv.Start(context.Background())
+		require.Nil(t, err)
+		drvs = append(drvs, drv)
+	}
+	<-time.After(time.Second)
+	for _, v := range drvs {
+		nodes, err := v.GetNodes(context.Background())
+		require.Nil(t, err)
+		require.Equal(t, N, len(nodes))
+	}
+
+	for _, v := range drvs {
+		v.Stop(context.Background())
+	}
+}
+
+func TestZookeeperDriver_Stop(t *testing.T) {
+	var err error
+	var nodes []string
+	drv1 := testFuncNewZookeeperDriver(t)
+	drv1.Init(t.Name(),
+		driver.NewTimeoutOption(5*time.Second),
+		driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+
+	drv2 := testFuncNewZookeeperDriver(t)
+	drv2.Init(t.Name(),
+		driver.NewTimeoutOption(5*time.Second),
+		driver.NewLoggerOption(dlog.NewLoggerForTest(t)))
+
+	require.Nil(t, drv2.Start(context.Background()))
+	require.Nil(t, drv1.Start(context.Background()))
+	<-time.After(time.Second)
+
+	nodes, err = drv2.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Len(t, nodes, 2)
+
+	require.Nil(t, drv1.Stop(context.Background()))
+	<-time.After(time.Second)
+	nodes, err = drv2.GetNodes(context.Background())
+	require.Nil(t, err)
+	require.Len(t, nodes, 1)
+
+	drv2.Stop(context.Background())
+}
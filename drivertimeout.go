@@ -0,0 +1,81 @@
+package dcron
+
+import (
+	"context"
+	"time"
+
+	"github.com/libi/dcron/driver"
+)
+
+// driverOpTimeout returns the timeout of each call to the driver, which is
+// the node update duration unless WithDriverTimeout is set.
+func (d *Dcron) driverOpTimeout() time.Duration {
+	if d.driverTimeout > 0 {
+		return d.driverTimeout
+	}
+	return d.nodeUpdateDuration
+}
+
+// timeoutKVDriver bounds each call to kv by timeout.
+type timeoutKVDriver struct {
+	kv      driver.KVDriver
+	timeout time.Duration
+}
+
+func (t timeoutKVDriver) Get(ctx context.Context, key string) (value string, ok bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.kv.Get(ctx, key)
+}
+
+func (t timeoutKVDriver) Set(ctx context.Context, key, value string) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.kv.Set(ctx, key, value)
+}
+
+func (t timeoutKVDriver) Del(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.kv.Del(ctx, key)
+}
+
+// timeoutLockDriver bounds each call to ld by timeout.
+type timeoutLockDriver struct {
+	ld      driver.LockDriver
+	timeout time.Duration
+}
+
+func (t timeoutLockDriver) AcquireLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.ld.AcquireLock(ctx, key, ttl)
+}
+
+func (t timeoutLockDriver) ReleaseLock(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.ld.ReleaseLock(ctx, key)
+}
+
+// timeoutLockRefresher is a timeoutLockDriver of a driver.LockRefresher.
+type timeoutLockRefresher struct {
+	timeoutLockDriver
+	lr driver.LockRefresher
+}
+
+func (t timeoutLockRefresher) RefreshLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.lr.RefreshLock(ctx, key, ttl)
+}
+
+// withLockTimeout returns ld whose calls are bounded by timeout, it is
+// a driver.LockRefresher if ld is.
+func withLockTimeout(ld driver.LockDriver, timeout time.Duration) driver.LockDriver {
+	tl := timeoutLockDriver{ld: ld, timeout: timeout}
+	if lr, ok := ld.(driver.LockRefresher); ok {
+		return timeoutLockRefresher{timeoutLockDriver: tl, lr: lr}
+	}
+	return tl
+}
@@ -0,0 +1,113 @@
+package dcron
+
+import "time"
+
+// eventsBufferSize is the buffer of the channel returned by Events, the
+// events are dropped once it is full.
+const eventsBufferSize = 256
+
+// EventType is the type of an Event.
+type EventType int
+
+const (
+	// EventJobStarted is emitted when a run of a job starts in this node.
+	EventJobStarted EventType = iota
+	// EventJobFinished is emitted when a run of a job in this node returns,
+	// Err is the error of it if the job is a cron.ErrorJob.
+	EventJobFinished
+	// EventJobSkipped is emitted when a run of a job is skipped by
+	// cron.SkipIfStillRunning or cron.DelayIfStillRunningBounded.
+	EventJobSkipped
+	// EventJobPanicked is emitted when a run of a job in this node panics
+	// instead of EventJobFinished, Err wraps ErrJobPanic.
+	EventJobPanicked
+	// EventNodeJoined is emitted when the node of NodeID joins the hash ring.
+	EventNodeJoined
+	// EventNodeLeft is emitted when the node of NodeID leaves the hash ring.
+	EventNodeLeft
+	// EventRebalanced is emitted when the owner of a job changed from
+	// OldOwner to NewOwner.
+	EventRebalanced
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventJobStarted:
+		return "JobStarted"
+	case EventJobFinished:
+		return "JobFinished"
+	case EventJobSkipped:
+		return "JobSkipped"
+	case EventJobPanicked:
+		return "JobPanicked"
+	case EventNodeJoined:
+		return "NodeJoined"
+	case EventNodeLeft:
+		return "NodeLeft"
+	case EventRebalanced:
+		return "Rebalanced"
+	}
+	return "Unknown"
+}
+
+// Event is a lifecycle event of dcron, see Dcron.Events.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// JobName is the job of the job events and EventRebalanced.
+	JobName string
+	// NodeID is the node of EventNodeJoined and EventNodeLeft.
+	NodeID string
+	// OldOwner and NewOwner are the owners of EventRebalanced.
+	OldOwner string
+	NewOwner string
+	// Err is the error of EventJobFinished and EventJobPanicked.
+	Err error
+}
+
+// Events returns the channel of the lifecycle events of dcron, as an
+// alternative to the callbacks, e.g. WithNodeChangeCallback. The events are
+// emitted only after Events is called first, and they never block the
+// scheduler: if the consumer is slow and the buffer of the channel is full,
+// the events are dropped and counted by DroppedEvents. The same channel is
+// returned each time, it is never closed.
+func (d *Dcron) Events() <-chan Event {
+	d.eventsOnce.Do(func() {
+		d.events.Store(make(chan Event, eventsBufferSize))
+	})
+	return d.events.Load().(chan Event)
+}
+
+// DroppedEvents returns the number of the events dropped because the
+// channel returned by Events is full.
+func (d *Dcron) DroppedEvents() uint64 {
+	return d.droppedEvents.Load()
+}
+
+// emit sends the event to the channel of Events without blocking.
+func (d *Dcron) emit(event Event) {
+	events, _ := d.events.Load().(chan Event)
+	if events == nil {
+		return
+	}
+	event.Time = d.clock.Now()
+	select {
+	case events <- event:
+	default:
+		d.droppedEvents.Add(1)
+	}
+}
+
+// onNodeChanged emits the node events, and calls the callback set by
+// WithNodeChangeCallback.
+func (d *Dcron) onNodeChanged(added, removed []string) {
+	for _, nodeID := range added {
+		d.emit(Event{Type: EventNodeJoined, NodeID: nodeID})
+	}
+	for _, nodeID := range removed {
+		d.emit(Event{Type: EventNodeLeft, NodeID: nodeID})
+	}
+	if d.nodeChangeCallback != nil {
+		d.nodeChangeCallback(added, removed)
+	}
+}
@@ -79,12 +79,14 @@ func main() {
 			Id:     i,
 			Logger: logger,
 		}
-		err = dcron.AddJob("write-task"+strconv.Itoa(i), "* * * * *", job)
+		_, err = dcron.AddJob("write-task"+strconv.Itoa(i), "* * * * *", job)
 		if err != nil {
 			panic(err)
 		}
 	}
-	dcron.Start()
+	if err = dcron.Start(); err != nil {
+		panic(err)
+	}
 
 	// run forever
 	tick := time.NewTicker(time.Hour)
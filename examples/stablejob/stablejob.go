@@ -70,7 +70,7 @@ func main() {
 					logger.Printf("unserialize job error: %v", err)
 					continue
 				}
-				err = d.AddJob(k, job.GetCron(), job)
+				_, err = d.AddJob(k, job.GetCron(), job)
 				if err != nil {
 					logger.Printf("add job error: %v", err)
 					continue
@@ -78,7 +78,9 @@ func main() {
 			}
 		}),
 	)
-	dcronInstance.Start()
+	if err := dcronInstance.Start(); err != nil {
+		logger.Fatalf("start dcron error: %v", err)
+	}
 	defer dcronInstance.Stop()
 	// run forever
 	tick := time.NewTicker(time.Hour)
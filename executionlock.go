@@ -0,0 +1,125 @@
+package dcron
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/libi/dcron/dlog"
+	"github.com/libi/dcron/driver"
+)
+
+const (
+	executionLockKeyPre = "lock:"
+
+	// executionLockMinHold is the minimum time the execution lock is held,
+	// a job which finishes faster than the clock skew between the nodes
+	// should not let another node take the same run.
+	executionLockMinHold = 2 * time.Second
+)
+
+// DedupKeyFunc returns the key of the execution lock of the run of the job
+// scheduled at scheduled, the runs of the same key run only once in the
+// cluster, see WithDedupKeyFunc.
+type DedupKeyFunc func(jobName string, scheduled time.Time) string
+
+// defaultDedupKey is the job name and the scheduled time in seconds, with
+// the milliseconds for the runs which are not on a whole second, e.g. of
+// "@every 100ms".
+func defaultDedupKey(jobName string, scheduled time.Time) string {
+	key := jobName + ":" + strconv.FormatInt(scheduled.Unix(), 10)
+	if ns := scheduled.Nanosecond(); ns != 0 {
+		key += "." + strconv.Itoa(1000 + ns/int(time.Millisecond))[1:]
+	}
+	return key
+}
+
+// TruncatedDedupKey returns a DedupKeyFunc of the job name and the scheduled
+// time truncated to granularity, e.g. time.Minute for the jobs which fire at
+// most once a minute, so the scheduled times of the same run computed by
+// the nodes with the clock skew in the minute have the same key. The
+// granularity must not be longer than the interval of the job, or the
+// following runs in the same granularity are skipped.
+func TruncatedDedupKey(granularity time.Duration) DedupKeyFunc {
+	return func(jobName string, scheduled time.Time) string {
+		return defaultDedupKey(jobName, scheduled.Truncate(granularity))
+	}
+}
+
+func (d *Dcron) executionLockKey(jobName string, scheduledTime time.Time) string {
+	return executionLockKeyPre + d.dedupKey(jobName, scheduledTime)
+}
+
+// dedupKey returns the key of the run by the DedupKeyFunc.
+func (d *Dcron) dedupKey(jobName string, scheduledTime time.Time) string {
+	keyFn := d.dedupKeyFunc
+	if keyFn == nil {
+		keyFn = defaultDedupKey
+	}
+	return keyFn(jobName, scheduledTime)
+}
+
+func (d *Dcron) lockDriver() (driver.LockDriver, bool) {
+	if d.executionLockTTL <= 0 || d.runningLocally || d.driver == nil {
+		return nil, false
+	}
+	ld, ok := d.driver.(driver.LockDriver)
+	if !ok {
+		return nil, false
+	}
+	return withLockTimeout(ld, d.driverOpTimeout()), true
+}
+
+// acquireExecutionLock acquires the execution lock of the run, it returns
+// false if the run should be skipped. The returned release func must be
+// called after the run.
+//
+// Two nodes may both think they own a job while the node pools disagree,
+// e.g. in a network partition. The lock makes sure only one of them runs
+// the job for the same scheduled time. The broadcast jobs are not locked.
+func (d *Dcron) acquireExecutionLock(jobName string, scheduledTime time.Time) (release func(), ok bool) {
+	ld, hasLock := d.lockDriver()
+	if !hasLock || d.isBroadcastJob(jobName) {
+		return func() {}, true
+	}
+	ctx := d.runtimeContext()
+	key := d.executionLockKey(jobName, scheduledTime)
+	ok, err := ld.AcquireLock(ctx, key, d.executionLockTTL)
+	if err != nil && ctx.Err() != nil {
+		// dcron is stopped, do not run it unlocked.
+		d.logger.Infof("job '%s' is dropped in acquiring the execution lock, dcron is stopped", jobName)
+		return nil, false
+	}
+	if err != nil {
+		// the lock is a guard, do not miss the run if the driver fails.
+		d.logger.Errorf("acquire execution lock of job '%s' error, err=%v", jobName, err)
+		return func() {}, true
+	}
+	if !ok {
+		dlog.Warnw(d.logger, "job is running on another node, skip it, maybe a split-brain happened",
+			"job_name", jobName, "scheduled_time", scheduledTime.Format(time.RFC3339), "node_id", d.NodeID())
+		return nil, false
+	}
+	acquired := time.Now()
+	return func() {
+		releaseLock := func() {
+			// the runtime context may be canceled by Stop during the run.
+			if err := ld.ReleaseLock(context.Background(), key); err != nil {
+				d.logger.Errorf("release execution lock of job '%s' error, err=%v", jobName, err)
+			}
+		}
+		hold := executionLockMinHold
+		if hold > d.executionLockTTL {
+			hold = d.executionLockTTL
+		}
+		if wait := hold - time.Since(acquired); wait > 0 {
+			untrack := d.trackTimer()
+			time.AfterFunc(wait, func() {
+				defer untrack()
+				releaseLock()
+			})
+			return
+		}
+		releaseLock()
+	}, true
+}
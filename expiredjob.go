@@ -0,0 +1,62 @@
+package dcron
+
+import (
+	"time"
+
+	"github.com/libi/dcron/cron"
+)
+
+// watchExpiredJobs handles the jobs whose schedule never fires again once
+// per node update duration, until dcron is stopped, see
+// WithExpiredJobHandler.
+func (d *Dcron) watchExpiredJobs() {
+	ctx := d.runtimeContext()
+	tick := time.NewTicker(d.nodeUpdateDuration)
+	defer tick.Stop()
+	// the expired jobs which are handled, a job replaced by ReplaceJob
+	// is checked again.
+	handled := make(map[*JobWarpper]struct{})
+	for {
+		handled = d.handleExpiredJobs(handled)
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleExpiredJobs handles the expired jobs which are not in handled, it
+// returns all the expired jobs which are still added.
+func (d *Dcron) handleExpiredJobs(handled map[*JobWarpper]struct{}) map[*JobWarpper]struct{} {
+	entries := make(map[cron.EntryID]cron.Entry)
+	for _, entry := range d.cr.Entries() {
+		entries[entry.ID] = entry
+	}
+	now := d.clock.Now()
+	expired := make(map[*JobWarpper]struct{})
+	fresh := make([]string, 0)
+	d.jobsRWMut.RLock()
+	for jobName, job := range d.jobs {
+		entry, ok := entries[job.ID]
+		if !ok || !entry.Schedule.Next(now).IsZero() {
+			continue
+		}
+		expired[job] = struct{}{}
+		if _, ok := handled[job]; !ok {
+			fresh = append(fresh, jobName)
+		}
+	}
+	d.jobsRWMut.RUnlock()
+
+	for _, jobName := range fresh {
+		d.logger.Warnf("job '%s' never fires again, its schedule has no next run", jobName)
+		if d.removeExpiredJobs && d.RemoveJob(jobName) == nil {
+			d.logger.Infof("expired job '%s' is removed", jobName)
+		}
+		if d.expiredJobHandler != nil {
+			d.expiredJobHandler(jobName)
+		}
+	}
+	return expired
+}
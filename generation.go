@@ -0,0 +1,122 @@
+package dcron
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/libi/dcron/driver"
+)
+
+// ErrStaleGeneration is returned by HealthCheck if a newer incarnation of
+// this node has registered the same nodeID, see WithNodeGeneration.
+var ErrStaleGeneration = errors.New("a newer incarnation of this node has registered the same nodeID")
+
+const generationKeyPre = "generation:"
+
+func generationKey(nodeID string) string {
+	return generationKeyPre + nodeID
+}
+
+// Generation returns the generation claimed by this incarnation of the
+// node when it is started, see WithNodeGeneration. It is 0 if the
+// generations are not enabled or dcron has never been started.
+func (d *Dcron) Generation() int64 {
+	return atomic.LoadInt64(&d.generation)
+}
+
+// isStaleGeneration returns true if a newer incarnation of this node has
+// registered the same nodeID, this node does not run any job then.
+func (d *Dcron) isStaleGeneration() bool {
+	return atomic.LoadInt32(&d.staleGeneration) == 1
+}
+
+// claimGeneration advertises a generation of this incarnation in the
+// driver, which is greater than the generation advertised for the nodeID.
+func (d *Dcron) claimGeneration() {
+	atomic.StoreInt32(&d.staleGeneration, 0)
+	kv, ok := d.kvDriver()
+	if !ok {
+		d.logger.Warnf("driver is not a KVDriver, the generations of the nodes are not advertised")
+		return
+	}
+	ctx := d.runtimeContext()
+	nodeID := d.nodePool.GetNodeID()
+	generation := d.clock.Now().UnixNano()
+	if last, ok, err := d.readGeneration(ctx, kv, nodeID); err != nil {
+		d.logger.Errorf("read the generation of this node error, err=%v", err)
+	} else if ok && last >= generation {
+		generation = last + 1
+	}
+	atomic.StoreInt64(&d.generation, generation)
+	if err := kv.Set(ctx, generationKey(nodeID), strconv.FormatInt(generation, 10)); err != nil {
+		d.logger.Errorf("advertise the generation of this node error, err=%v", err)
+		return
+	}
+	d.logger.Infof("node %s claimed generation %d", nodeID, generation)
+}
+
+// checkGeneration compares the generation of this incarnation with the
+// generation advertised for the nodeID once per node update duration, and
+// marks this node stale once a greater one is advertised. The generation
+// is advertised again if it is missing. It is deleted when dcron is
+// stopped, unless a newer incarnation has advertised its own.
+func (d *Dcron) checkGeneration() {
+	defer d.advertisers.Done()
+	kv, ok := d.kvDriver()
+	if !ok {
+		return
+	}
+	ctx := d.runtimeContext()
+	nodeID := d.nodePool.GetNodeID()
+	generation := d.Generation()
+	defer func() {
+		delCtx, cancel := context.WithTimeout(context.Background(), d.nodeUpdateDuration)
+		defer cancel()
+		if last, ok, err := d.readGeneration(delCtx, kv, nodeID); err != nil || !ok || last != generation {
+			return
+		}
+		if err := kv.Del(delCtx, generationKey(nodeID)); err != nil {
+			d.logger.Errorf("delete the generation of this node error, err=%v", err)
+		}
+	}()
+	tick := time.NewTicker(d.nodeUpdateDuration)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+		last, ok, err := d.readGeneration(ctx, kv, nodeID)
+		switch {
+		case err != nil:
+			d.logger.Errorf("read the generation of this node error, err=%v", err)
+		case ok && last > generation:
+			if atomic.CompareAndSwapInt32(&d.staleGeneration, 0, 1) {
+				d.logger.Warnf("a newer incarnation of node %s has registered, generation %d > %d, "+
+					"this node does not run any job until it is restarted", nodeID, last, generation)
+			}
+		case !ok || last < generation:
+			if err = kv.Set(ctx, generationKey(nodeID), strconv.FormatInt(generation, 10)); err != nil {
+				d.logger.Errorf("advertise the generation of this node error, err=%v", err)
+			}
+		}
+	}
+}
+
+// readGeneration returns the generation advertised for the nodeID.
+func (d *Dcron) readGeneration(ctx context.Context, kv driver.KVDriver, nodeID string) (int64, bool, error) {
+	value, ok, err := kv.Get(ctx, generationKey(nodeID))
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	generation, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		d.logger.Errorf("invalid generation of node %s, err=%v", nodeID, err)
+		return 0, false, nil
+	}
+	return generation, true, nil
+}
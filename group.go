@@ -0,0 +1,102 @@
+package dcron
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/libi/dcron/cron"
+)
+
+var (
+	// ErrEmptyGroup is returned by AddJobToGroup if the group is empty.
+	ErrEmptyGroup = errors.New("group is empty")
+	// ErrGroupNotExist is returned if no job is added to the group.
+	ErrGroupNotExist = errors.New("group not exist")
+)
+
+const pausedGroupKeyPre = "paused-group:"
+
+func pausedGroupKey(group string) string {
+	return pausedGroupKeyPre + group
+}
+
+// AddJobToGroup add a cron func in group, the jobs in a group can be paused
+// and resumed together by PauseGroup and ResumeGroup. A group only controls
+// its jobs, each job is still distributed by its name.
+func (d *Dcron) AddJobToGroup(group, jobName, cronStr string, cmd func()) error {
+	if group == "" {
+		return ErrEmptyGroup
+	}
+	return d.addMarkedJob(jobName, cronStr, cron.FuncJob(cmd), &d.jobGroups, group)
+}
+
+// ListGroups returns the sorted groups which have jobs.
+func (d *Dcron) ListGroups() []string {
+	groups := make([]string, 0)
+	seen := make(map[string]struct{})
+	d.jobGroups.Range(func(_, value any) bool {
+		if _, ok := seen[value.(string)]; !ok {
+			seen[value.(string)] = struct{}{}
+			groups = append(groups, value.(string))
+		}
+		return true
+	})
+	sort.Strings(groups)
+	return groups
+}
+
+// jobGroup returns the group of the job, it is "" if the job is not
+// added by AddJobToGroup.
+func (d *Dcron) jobGroup(jobName string) string {
+	group, _ := d.jobGroups.Load(jobName)
+	name, _ := group.(string)
+	return name
+}
+
+func (d *Dcron) hasGroup(group string) (found bool) {
+	d.jobGroups.Range(func(_, value any) bool {
+		found = value.(string) == group
+		return !found
+	})
+	return
+}
+
+// PauseGroup pauses all the jobs in group as PauseJob does, including the
+// jobs added to it later, until ResumeGroup is called. The jobs paused by
+// PauseJob are still paused after ResumeGroup. If the driver implements
+// driver.KVDriver, the group will be paused in all nodes of this service,
+// otherwise only in this node.
+func (d *Dcron) PauseGroup(group string) error {
+	if !d.hasGroup(group) {
+		return ErrGroupNotExist
+	}
+	if kv, ok := d.kvDriver(); ok {
+		return kv.Set(context.Background(), pausedGroupKey(group), "1")
+	}
+	d.logger.Warnf("driver is not a KVDriver, group '%s' is paused only in this node", group)
+	d.pausedGroups.Store(group, struct{}{})
+	return nil
+}
+
+// ResumeGroup resumes the paused group, see PauseGroup.
+func (d *Dcron) ResumeGroup(group string) error {
+	if !d.hasGroup(group) {
+		return ErrGroupNotExist
+	}
+	if kv, ok := d.kvDriver(); ok {
+		return kv.Del(context.Background(), pausedGroupKey(group))
+	}
+	d.pausedGroups.Delete(group)
+	return nil
+}
+
+// IsGroupPaused returns true if the group is paused.
+func (d *Dcron) IsGroupPaused(group string) (bool, error) {
+	if kv, ok := d.kvDriver(); ok {
+		_, paused, err := kv.Get(context.Background(), pausedGroupKey(group))
+		return paused, err
+	}
+	_, paused := d.pausedGroups.Load(group)
+	return paused, nil
+}
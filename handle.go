@@ -0,0 +1,49 @@
+package dcron
+
+// JobHandle manages a job added to dcron by its name,
+// see AddFuncWithHandle.
+type JobHandle struct {
+	dcron   *Dcron
+	jobName string
+}
+
+// AddFuncWithHandle is the same as AddFunc, but it returns a handle of
+// the job, so the caller can manage the job without holding the Dcron and
+// the name separately.
+func (d *Dcron) AddFuncWithHandle(jobName, cronStr string, cmd func()) (*JobHandle, error) {
+	if err := d.AddFunc(jobName, cronStr, cmd); err != nil {
+		return nil, err
+	}
+	return &JobHandle{dcron: d, jobName: jobName}, nil
+}
+
+// Name returns the name of the job.
+func (h *JobHandle) Name() string {
+	return h.jobName
+}
+
+// Pause is Dcron.PauseJob of the job.
+func (h *JobHandle) Pause() error {
+	return h.dcron.PauseJob(h.jobName)
+}
+
+// Resume is Dcron.ResumeJob of the job.
+func (h *JobHandle) Resume() error {
+	return h.dcron.ResumeJob(h.jobName)
+}
+
+// IsPaused is Dcron.IsJobPaused of the job.
+func (h *JobHandle) IsPaused() (bool, error) {
+	return h.dcron.IsJobPaused(h.jobName)
+}
+
+// Trigger is Dcron.TriggerJob of the job.
+func (h *JobHandle) Trigger() error {
+	return h.dcron.TriggerJob(h.jobName)
+}
+
+// Remove is Dcron.RemoveJob of the job, the other methods return
+// ErrJobNotExist after it is removed.
+func (h *JobHandle) Remove() error {
+	return h.dcron.RemoveJob(h.jobName)
+}
@@ -0,0 +1,109 @@
+package dcron
+
+import (
+	"time"
+
+	"github.com/libi/dcron/driver"
+)
+
+const handoverKeyPre = "handover:"
+
+// handoverHold is the handover lock of a job held by the runs of it in
+// this node, it is released by the last one of them.
+type handoverHold struct {
+	runs    int
+	release func()
+}
+
+// acquireHandover waits until the runs of the job in the other nodes are
+// finished, and holds the handover lock of the job for this run, see
+// WithGracefulHandover. It returns false if dcron is stopped in the wait.
+// The returned release func must be called after the run.
+func (d *Dcron) acquireHandover(jobName string) (release func(), ok bool) {
+	if !d.gracefulHandover || d.runningLocally || d.driver == nil || d.isBroadcastJob(jobName) {
+		return func() {}, true
+	}
+	ld, isLockDriver := d.driver.(driver.LockDriver)
+	if !isLockDriver {
+		d.logger.Warnf("driver is not a LockDriver, graceful handover of job '%s' is disabled", jobName)
+		return func() {}, true
+	}
+	ld = withLockTimeout(ld, d.driverOpTimeout())
+	ctx := d.runtimeContext()
+	ttl := d.heartbeatTTL()
+	key := handoverKeyPre + jobName
+	tick := time.NewTicker(ttl / 10)
+	defer tick.Stop()
+	for waited := false; ; waited = true {
+		// the lock is held by the other run in this node.
+		if release, ok := d.joinHandover(jobName); ok {
+			return release, true
+		}
+		acquired, err := ld.AcquireLock(ctx, key, ttl)
+		if err != nil && ctx.Err() != nil {
+			d.logger.Infof("job '%s' is dropped in the handover, dcron is stopped", jobName)
+			return nil, false
+		}
+		if err != nil {
+			d.logger.Errorf("acquire handover lock of job '%s' error, run it, err=%v", jobName, err)
+			return func() {}, true
+		}
+		if acquired {
+			if waited {
+				d.logger.Infof("job '%s' is handed over to this node", jobName)
+			}
+			return d.holdHandover(ld, jobName, key, ttl), true
+		}
+		if !waited {
+			d.logger.Infof("job '%s' waits for the run in the last owner", jobName)
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			d.logger.Infof("job '%s' is dropped in the handover, dcron is stopped", jobName)
+			return nil, false
+		}
+	}
+}
+
+// joinHandover counts a run in the hold of the job if this node holds the
+// handover lock of it.
+func (d *Dcron) joinHandover(jobName string) (release func(), ok bool) {
+	d.handoverMut.Lock()
+	defer d.handoverMut.Unlock()
+	hold, ok := d.handoverHolds[jobName]
+	if !ok {
+		return nil, false
+	}
+	hold.runs++
+	return func() { d.leaveHandover(jobName) }, true
+}
+
+// holdHandover holds the acquired handover lock of the job until the runs
+// of the job in this node are finished.
+func (d *Dcron) holdHandover(ld driver.LockDriver, jobName, key string, ttl time.Duration) (release func()) {
+	d.handoverMut.Lock()
+	defer d.handoverMut.Unlock()
+	if d.handoverHolds == nil {
+		d.handoverHolds = make(map[string]*handoverHold)
+	}
+	d.handoverHolds[jobName] = &handoverHold{
+		runs:    1,
+		release: d.holdLock(ld, "handover lock", jobName, key, ttl),
+	}
+	return func() { d.leaveHandover(jobName) }
+}
+
+// leaveHandover releases the handover lock of the job after the last run
+// of it in this node.
+func (d *Dcron) leaveHandover(jobName string) {
+	d.handoverMut.Lock()
+	hold := d.handoverHolds[jobName]
+	if hold.runs--; hold.runs > 0 {
+		d.handoverMut.Unlock()
+		return
+	}
+	delete(d.handoverHolds, jobName)
+	d.handoverMut.Unlock()
+	hold.release()
+}
@@ -0,0 +1,39 @@
+package dcron
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// HealthCheck returns nil if this node works well, it can be used as the
+// readiness probe. An error is returned if dcron is not running, the driver
+// can not reach its storage, or the node pool has not been synced from the
+// driver successfully in the last 2 node update durations. In these cases,
+// the ownership of jobs in this node may be stale. ErrStaleGeneration is
+// returned if a newer incarnation of this node is advertised, see
+// WithNodeGeneration.
+// When dcron is running locally, only the running state is checked.
+func (d *Dcron) HealthCheck() error {
+	if atomic.LoadInt32(&d.running) != dcronRunning {
+		return ErrDcronNotRunning
+	}
+	if d.runningLocally {
+		return nil
+	}
+	if d.isStaleGeneration() {
+		return ErrStaleGeneration
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.nodeUpdateDuration)
+	defer cancel()
+	return d.nodePool.HealthCheck(ctx)
+}
+
+// IsSteady returns true once dcron is running and the node pool came to
+// steady for the first time, which means this node has known the
+// membership of the cluster.
+func (d *Dcron) IsSteady() bool {
+	if atomic.LoadInt32(&d.running) != dcronRunning {
+		return false
+	}
+	return d.runningLocally || d.nodePool.IsSteady()
+}
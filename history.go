@@ -0,0 +1,72 @@
+package dcron
+
+import "time"
+
+// Execution is a run of a job in this node, see JobHistory.
+type Execution struct {
+	// StartTime is the time the run started.
+	StartTime time.Time
+	// Duration is the duration of the run.
+	Duration time.Duration
+	// Error is the same as JobStatus.LastError of the run.
+	Error error
+	// NodeID is the nodeID of the node which ran the job,
+	// it is empty if dcron is running locally.
+	NodeID string
+}
+
+// executionRing keeps the latest executions, up to the size of buf.
+type executionRing struct {
+	buf  []Execution
+	next int
+	full bool
+}
+
+func newExecutionRing(size int) *executionRing {
+	return &executionRing{buf: make([]Execution, size)}
+}
+
+func (r *executionRing) add(e Execution) {
+	r.buf[r.next] = e
+	r.next++
+	if r.next == len(r.buf) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// list returns a copy of the executions, from the oldest to the latest.
+func (r *executionRing) list() []Execution {
+	if !r.full {
+		return append([]Execution(nil), r.buf[:r.next]...)
+	}
+	list := make([]Execution, 0, len(r.buf))
+	list = append(list, r.buf[r.next:]...)
+	return append(list, r.buf[:r.next]...)
+}
+
+// JobHistory returns the latest runs of jobName in this node, from the
+// oldest to the latest, up to the size set by WithHistorySize. It returns
+// nil if the history is disabled or the job has not run in this node yet.
+func (d *Dcron) JobHistory(jobName string) []Execution {
+	d.jobStatusMut.RLock()
+	defer d.jobStatusMut.RUnlock()
+	r, ok := d.jobHistory[jobName]
+	if !ok {
+		return nil
+	}
+	return r.list()
+}
+
+// recordJobHistory must be called with jobStatusMut locked.
+func (d *Dcron) recordJobHistory(jobName string, e Execution) {
+	if d.historySize <= 0 {
+		return
+	}
+	r, ok := d.jobHistory[jobName]
+	if !ok {
+		r = newExecutionRing(d.historySize)
+		d.jobHistory[jobName] = r
+	}
+	r.add(e)
+}
@@ -4,18 +4,45 @@ import (
 	"context"
 	"errors"
 	"time"
+
+	"github.com/libi/dcron/consistenthash"
 )
 
 var (
 	ErrNodePoolIsUpgrading = errors.New("nodePool is upgrading")
 	ErrNodePoolIsNil       = errors.New("nodePool is nil")
+	ErrNodePoolIsEmpty     = errors.New("nodePool is empty")
+	ErrNodePoolNotSynced   = errors.New("nodePool is not synced from driver")
+	ErrDriverUnhealthy     = errors.New("driver is unhealthy")
+	// ErrInvalidHashReplicas is returned by SetHashReplicas if the
+	// replicas is not positive.
+	ErrInvalidHashReplicas = errors.New("hash replicas must be positive")
+
+	// errNodePoolStopped is returned by getNodes if the pool is stopped
+	// during the retries.
+	errNodePoolStopped = errors.New("nodePool is stopped")
 )
 
 type INodePool interface {
 	Start(ctx context.Context) error
 	CheckJobAvailable(jobName string) (bool, error)
+	GetJobOwner(jobName string) (string, error)
 	Stop(ctx context.Context) error
 
 	GetNodeID() string
 	GetLastNodesUpdateTime() time.Time
+	// GetNodes returns a copy of the nodes in the hash ring.
+	GetNodes() []string
+	// RingStats returns the stats of each node in the hash ring.
+	RingStats() map[string]consistenthash.KeyStat
+	// HashReplicas returns the virtual nodes of each node in the hash ring.
+	HashReplicas() int
+	// SetHashReplicas rebuilds the hash ring with replicas.
+	SetHashReplicas(replicas int)
+
+	HealthCheck(ctx context.Context) error
+	IsSteady() bool
+	// IsIsolated returns true if the nodes have not been synced from
+	// the driver successfully in the last 2 update durations.
+	IsIsolated() bool
 }
@@ -3,7 +3,10 @@ package dcron_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -105,6 +108,9 @@ func (ts *TestINodePoolSuite) runCheckJobAvailable(numberOfNodes int, ServiceNam
 				ok,
 				(ring.Get(strconv.Itoa(i)) == (*nodePools)[j].GetNodeID()),
 			)
+			owner, err := (*nodePools)[j].GetJobOwner(strconv.Itoa(i))
+			ts.Require().Nil(err)
+			ts.Require().Equal(ring.Get(strconv.Itoa(i)), owner)
 		}
 	}
 }
@@ -172,6 +178,377 @@ func (ts *TestINodePoolSuite) TestCheckJobAvailableFailedWithNodePoolRingIsNil()
 	ts.Equal(dcron.ErrNodePoolIsNil, err)
 }
 
+func (ts *TestINodePoolSuite) TestGetJobOwnerFailedWithNodePoolRingIsNil() {
+	np := &dcron.NodePool{}
+	np.SetLogger(dlog.NewLoggerForTest(ts.T()))
+	_, err := np.GetJobOwner("testjob")
+	ts.Equal(dcron.ErrNodePoolIsNil, err)
+}
+
+func (ts *TestINodePoolSuite) TestGetJobOwnerFailedWithNoNodes() {
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return []string{}, nil
+		},
+	}
+	np := dcron.NewNodePool(
+		"testServiceName",
+		md, 100*time.Millisecond,
+		ts.defaultHashReplicas,
+		dlog.NewLoggerForTest(ts.T()))
+	ts.Require().Nil(np.Start(context.Background()))
+	defer np.Stop(context.Background())
+	_, err := np.GetJobOwner("testjob")
+	ts.NotNil(err)
+}
+
+func (ts *TestINodePoolSuite) TestNonPositiveUpdateDuration() {
+	var syncs atomic.Int32
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			syncs.Add(1)
+			return []string{"testnode"}, nil
+		},
+	}
+	// the default is used instead of syncing in a tight loop.
+	np := dcron.NewNodePool("testServiceName", md, 0, ts.defaultHashReplicas, dlog.NewLoggerForTest(ts.T()))
+	ts.Require().Nil(np.Start(context.Background()))
+	defer np.Stop(context.Background())
+	<-time.After(300 * time.Millisecond)
+	ts.LessOrEqual(syncs.Load(), int32(3))
+}
+
+func (ts *TestINodePoolSuite) TestNodeFilter() {
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return []string{"a", "b", "c"}, nil
+		},
+	}
+	var failed atomic.Bool
+	np := dcron.NewNodePool("testServiceName", md, 100*time.Millisecond, ts.defaultHashReplicas, dlog.NewLoggerForTest(ts.T()),
+		dcron.NodePoolNodeFilter(func(ctx context.Context, nodes []string) ([]string, error) {
+			if failed.Load() {
+				return nil, errors.New("filter failed")
+			}
+			return []string{nodes[0], nodes[2]}, nil
+		}))
+	ts.Require().Nil(np.Start(context.Background()))
+	defer np.Stop(context.Background())
+	ts.Equal([]string{"a", "c"}, np.GetNodes())
+	// the failed filter fails the sync, the last nodes are kept.
+	failed.Store(true)
+	ts.Eventually(func() bool {
+		return np.IsIsolated()
+	}, 2*time.Second, 10*time.Millisecond)
+	ts.Equal([]string{"a", "c"}, np.GetNodes())
+}
+
+func (ts *TestINodePoolSuite) TestWeightedNodes() {
+	nodes := []string{
+		"distributed-cron:TestWeightedNodes:a@1",
+		"distributed-cron:TestWeightedNodes:b@2",
+		"distributed-cron:TestWeightedNodes:c@4",
+	}
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			ret := make([]string, len(nodes))
+			copy(ret, nodes)
+			return ret, nil
+		},
+	}
+	np := dcron.NewNodePool(
+		"TestWeightedNodes",
+		md, 100*time.Millisecond,
+		ts.defaultHashReplicas*10,
+		dlog.NewLoggerForTest(ts.T()))
+	ts.Require().Nil(np.Start(context.Background()))
+	defer np.Stop(context.Background())
+
+	n := 70000
+	owned := make(map[string]int)
+	for i := 0; i < n; i++ {
+		owner, err := np.GetJobOwner(strconv.Itoa(i))
+		ts.Require().Nil(err)
+		owned[owner]++
+	}
+	// expect about 1/7, 2/7, 4/7 of jobs.
+	for i, weight := range []int{1, 2, 4} {
+		expect := float64(n * weight / 7)
+		ts.InDelta(expect, float64(owned[nodes[i]]), expect*0.25, "node=%s", nodes[i])
+	}
+	ts.Less(owned[nodes[0]], owned[nodes[1]])
+	ts.Less(owned[nodes[1]], owned[nodes[2]])
+}
+
+func (ts *TestINodePoolSuite) TestHashFn() {
+	nodes := []string{
+		"distributed-cron:TestHashFn:a",
+		"distributed-cron:TestHashFn:b",
+		"distributed-cron:TestHashFn:c",
+	}
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			ret := make([]string, len(nodes))
+			copy(ret, nodes)
+			return ret, nil
+		},
+	}
+	owners := func(opts ...dcron.NodePoolOption) map[string]string {
+		np := dcron.NewNodePool(
+			"TestHashFn",
+			md, 50*time.Millisecond,
+			ts.defaultHashReplicas,
+			dlog.NewLoggerForTest(ts.T()),
+			opts...)
+		ts.Require().Nil(np.Start(context.Background()))
+		defer np.Stop(context.Background())
+		ret := make(map[string]string)
+		for i := 0; i < 1000; i++ {
+			owner, err := np.GetJobOwner(strconv.Itoa(i))
+			ts.Require().Nil(err)
+			ret[strconv.Itoa(i)] = owner
+		}
+		return ret
+	}
+
+	crcOwners := owners()
+	ts.Equal(crcOwners, owners(dcron.NodePoolHashFn(nil)))
+	fnvOwners := owners(dcron.NodePoolHashFn(consistenthash.FNV1a))
+	ts.Equal(fnvOwners, owners(dcron.NodePoolHashFn(consistenthash.FNV1a)))
+	ts.NotEqual(crcOwners, fnvOwners)
+}
+
+func (ts *TestINodePoolSuite) TestHealthCheckNotSynced() {
+	var failed atomic.Bool
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			if failed.Load() {
+				return nil, errors.New("driver is down")
+			}
+			return []string{"a"}, nil
+		},
+	}
+	np := dcron.NewNodePool(
+		"TestHealthCheckNotSynced",
+		md, 50*time.Millisecond,
+		ts.defaultHashReplicas,
+		dlog.NewLoggerForTest(ts.T()))
+	ts.Require().Nil(np.Start(context.Background()))
+	defer np.Stop(context.Background())
+	ts.True(np.IsSteady())
+	ts.Nil(np.HealthCheck(context.Background()))
+
+	failed.Store(true)
+	<-time.After(200 * time.Millisecond)
+	ts.ErrorIs(np.HealthCheck(context.Background()), dcron.ErrNodePoolNotSynced)
+	// the pool keeps the membership it known.
+	ts.True(np.IsSteady())
+}
+
+func (ts *TestINodePoolSuite) TestDriverRetry() {
+	// the number of the next GetNodes calls which fail.
+	var failures, calls atomic.Int32
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			calls.Add(1)
+			if failures.Add(-1) >= 0 {
+				return nil, errors.New("driver is unreachable")
+			}
+			return []string{"a"}, nil
+		},
+	}
+	np := dcron.NewNodePool(
+		"TestDriverRetry",
+		md, 100*time.Millisecond,
+		ts.defaultHashReplicas,
+		dlog.NewLoggerForTest(ts.T()),
+		dcron.NodePoolDriverRetry(3, 10*time.Millisecond))
+
+	// Start retries too.
+	failures.Store(2)
+	ts.Require().Nil(np.Start(context.Background()))
+	defer np.Stop(context.Background())
+	ts.GreaterOrEqual(calls.Load(), int32(3))
+
+	failures.Store(2)
+	<-time.After(250 * time.Millisecond)
+	ts.Nil(np.HealthCheck(context.Background()))
+	owner, err := np.GetJobOwner("job")
+	ts.Nil(err)
+	ts.Equal("a", owner)
+
+	// more failures than the attempts.
+	failures.Store(100)
+	<-time.After(300 * time.Millisecond)
+	ts.ErrorIs(np.HealthCheck(context.Background()), dcron.ErrNodePoolNotSynced)
+	// the last known ownership is kept.
+	owner, err = np.GetJobOwner("job")
+	ts.Nil(err)
+	ts.Equal("a", owner)
+}
+
+func (ts *TestINodePoolSuite) TestPoolUpdateObserver() {
+	var fail atomic.Bool
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			time.Sleep(20 * time.Millisecond)
+			if fail.Load() {
+				return nil, errors.New("driver is unreachable")
+			}
+			return []string{"a", "b"}, nil
+		},
+	}
+	type update struct {
+		d     time.Duration
+		count int
+		err   error
+	}
+	updates := make(chan update, 100)
+	np := dcron.NewNodePool(
+		"TestPoolUpdateObserver",
+		md, 50*time.Millisecond,
+		ts.defaultHashReplicas,
+		dlog.NewLoggerForTest(ts.T()),
+		dcron.NodePoolUpdateObserver(func(d time.Duration, memberCount int, err error) {
+			updates <- update{d, memberCount, err}
+		}))
+	ts.Require().Nil(np.Start(context.Background()))
+	defer np.Stop(context.Background())
+
+	// the sync in Start is observed too.
+	u := <-updates
+	ts.GreaterOrEqual(u.d, 20*time.Millisecond)
+	ts.Equal(2, u.count)
+	ts.Nil(u.err)
+
+	fail.Store(true)
+	for u = <-updates; u.err == nil; u = <-updates {
+	}
+	ts.GreaterOrEqual(u.d, 20*time.Millisecond)
+	ts.Equal(0, u.count)
+	ts.EqualError(u.err, "driver is unreachable")
+}
+
+func (ts *TestINodePoolSuite) TestPoolUpdateDebounce() {
+	var mut sync.Mutex
+	nodes := []string{"node0"}
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			mut.Lock()
+			defer mut.Unlock()
+			ret := make([]string, len(nodes))
+			copy(ret, nodes)
+			return ret, nil
+		},
+	}
+	var changes atomic.Int32
+	np := dcron.NewNodePool(
+		"TestPoolUpdateDebounce",
+		md, 20*time.Millisecond,
+		ts.defaultHashReplicas,
+		dlog.NewLoggerForTest(ts.T()),
+		dcron.NodePoolUpdateDebounce(200*time.Millisecond),
+		dcron.NodePoolNodeChangeCallback(func(added, removed []string) {
+			changes.Add(1)
+		}))
+	ts.Require().Nil(np.Start(context.Background()))
+	defer np.Stop(context.Background())
+	ts.Equal(int32(1), changes.Load())
+
+	// 10 nodes join one by one, faster than the debounce.
+	for i := 1; i < 10; i++ {
+		mut.Lock()
+		nodes = append(nodes, fmt.Sprintf("node%d", i))
+		mut.Unlock()
+		<-time.After(50 * time.Millisecond)
+		// the last ring is used in the meantime.
+		owner, err := np.GetJobOwner("job")
+		ts.Require().Nil(err)
+		ts.Equal("node0", owner)
+	}
+	ts.Equal(int32(1), changes.Load())
+
+	ts.Require().Eventually(func() bool {
+		return len(np.GetNodes()) == 10
+	}, time.Second, 10*time.Millisecond)
+	<-time.After(300 * time.Millisecond)
+	ts.Equal(int32(2), changes.Load())
+}
+
+func (ts *TestINodePoolSuite) TestNodeChangeCallbacks() {
+	var mut sync.Mutex
+	nodes := []string{"a", "b"}
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			mut.Lock()
+			defer mut.Unlock()
+			ret := make([]string, len(nodes))
+			copy(ret, nodes)
+			return ret, nil
+		},
+	}
+	jobNames := make([]string, 0)
+	for i := 0; i < 100; i++ {
+		jobNames = append(jobNames, strconv.Itoa(i))
+	}
+	type nodeChange struct{ added, removed []string }
+	nodeChanges := make(chan nodeChange, 10)
+	rebalanced := make(map[string][2]string)
+	var rebalancedMut sync.Mutex
+	np := dcron.NewNodePool(
+		"TestNodeChangeCallbacks",
+		md, 50*time.Millisecond,
+		ts.defaultHashReplicas,
+		dlog.NewLoggerForTest(ts.T()),
+		dcron.NodePoolNodeChangeCallback(func(added, removed []string) {
+			nodeChanges <- nodeChange{added, removed}
+		}),
+		dcron.NodePoolJobRebalancedCallback(
+			func() []string { return jobNames },
+			func(jobName, oldOwner, newOwner string) {
+				rebalancedMut.Lock()
+				defer rebalancedMut.Unlock()
+				rebalanced[jobName] = [2]string{oldOwner, newOwner}
+			}))
+	ts.Require().Nil(np.Start(context.Background()))
+	defer np.Stop(context.Background())
+
+	change := <-nodeChanges
+	ts.ElementsMatch([]string{"a", "b"}, change.added)
+	ts.Empty(change.removed)
+
+	owners := make(map[string]string)
+	for _, jobName := range jobNames {
+		owner, err := np.GetJobOwner(jobName)
+		ts.Require().Nil(err)
+		owners[jobName] = owner
+	}
+
+	mut.Lock()
+	nodes = []string{"b", "c"}
+	mut.Unlock()
+	change = <-nodeChanges
+	ts.Equal([]string{"c"}, change.added)
+	ts.Equal([]string{"a"}, change.removed)
+
+	// the pool comes to steady after the callbacks returned.
+	for _, err := np.GetJobOwner(jobNames[0]); err != nil; _, err = np.GetJobOwner(jobNames[0]) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	rebalancedMut.Lock()
+	defer rebalancedMut.Unlock()
+	ts.NotEmpty(rebalanced)
+	for _, jobName := range jobNames {
+		owner, err := np.GetJobOwner(jobName)
+		ts.Require().Nil(err)
+		if owner == owners[jobName] {
+			ts.NotContains(rebalanced, jobName)
+			continue
+		}
+		ts.Equal([2]string{owners[jobName], owner}, rebalanced[jobName])
+	}
+}
+
 func (ts *TestINodePoolSuite) TestStartFailedWithDriverStartError() {
 	expectErr := errors.New("driver start error")
 	md := &MockDriver{
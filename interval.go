@@ -0,0 +1,56 @@
+package dcron
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libi/dcron/cron"
+)
+
+// ErrIntervalTooShort is returned by AddJob if the job fires more often than
+// once per node update duration and WithRejectShortIntervals is set.
+var ErrIntervalTooShort = errors.New("the interval of the job is shorter than the node update duration")
+
+// intervalSamples is the number of the consecutive runs checked to
+// estimate the shortest interval of a schedule.
+const intervalSamples = 8
+
+// checkInterval warns, or returns an error wrapping ErrIntervalTooShort if
+// WithRejectShortIntervals is set, if the job fires more often than once
+// per node update duration. The nodes may disagree on the owner of the job
+// for up to a node update duration after the nodes changed, in which the
+// runs can not be reliably deduplicated.
+func (d *Dcron) checkInterval(jobName string, schedule cron.Schedule) error {
+	if d.runningLocally {
+		return nil
+	}
+	interval := scheduleInterval(schedule, d.clock.Now())
+	if interval <= 0 || interval >= d.nodeUpdateDuration {
+		return nil
+	}
+	if d.rejectShortIntervals {
+		return fmt.Errorf("%w: job '%s' fires every %v, the node update duration is %v",
+			ErrIntervalTooShort, jobName, interval, d.nodeUpdateDuration)
+	}
+	d.logger.Warnf("job '%s' fires every %v, which is shorter than the node update duration %v, "+
+		"the runs may be duplicated or missed when the nodes change", jobName, interval, d.nodeUpdateDuration)
+	return nil
+}
+
+// scheduleInterval returns the shortest interval of the next runs of
+// schedule after from, 0 is returned if it fires at most once.
+func scheduleInterval(schedule cron.Schedule, from time.Time) (interval time.Duration) {
+	prev := schedule.Next(from)
+	for i := 0; i < intervalSamples && !prev.IsZero(); i++ {
+		next := schedule.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if d := next.Sub(prev); interval == 0 || d < interval {
+			interval = d
+		}
+		prev = next
+	}
+	return interval
+}
@@ -0,0 +1,41 @@
+package dcron
+
+// IsolationPolicy decides which jobs a node runs when it is isolated from
+// the cluster, which means the nodes have not been synced from the driver
+// successfully in the last 2 node update durations.
+type IsolationPolicy int
+
+const (
+	// IsolationKeepLast runs the jobs by the last synced nodes, it is the
+	// default. Another node may run the same jobs if the cluster changed.
+	IsolationKeepLast IsolationPolicy = iota
+	// IsolationRunNothing runs no job, as this node may have lost the
+	// ownership. The jobs are missed if all the nodes are isolated.
+	IsolationRunNothing
+	// IsolationRunAll runs all the jobs, the jobs may run on more than one
+	// node, but none of them is missed.
+	IsolationRunAll
+)
+
+func (p IsolationPolicy) String() string {
+	switch p {
+	case IsolationKeepLast:
+		return "KeepLast"
+	case IsolationRunNothing:
+		return "RunNothing"
+	case IsolationRunAll:
+		return "RunAll"
+	}
+	return "Unknown"
+}
+
+// allowIsolatedRun returns the decision by the isolation policy,
+// decided is false if this node is not isolated or keeps the last nodes.
+func (d *Dcron) allowIsolatedRun(jobName string) (ok, decided bool) {
+	if d.isolationPolicy == IsolationKeepLast || !d.nodePool.IsIsolated() {
+		return false, false
+	}
+	ok = d.isolationPolicy == IsolationRunAll
+	d.logger.Warnf("this node is isolated from the cluster, policy=%s, job '%s' run=%v", d.isolationPolicy, jobName, ok)
+	return ok, true
+}
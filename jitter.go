@@ -0,0 +1,36 @@
+package dcron
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// jobJitterOf returns the jitter of the job, it is in [0, jobJitter)
+// and depends on the job name only.
+func (d *Dcron) jobJitterOf(jobName string) time.Duration {
+	if d.jobJitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(jobName))
+	return time.Duration(h.Sum64() % uint64(d.jobJitter))
+}
+
+// waitJitter sleeps the jitter of the job, it returns false if dcron
+// is stopped in the middle of the sleep.
+func (d *Dcron) waitJitter(jobName string) bool {
+	jitter := d.jobJitterOf(jobName)
+	if jitter == 0 {
+		return true
+	}
+	defer d.trackTimer()()
+	timer := d.clock.NewTimer(jitter)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return true
+	case <-d.runtimeContext().Done():
+		d.logger.Infof("job '%s' is dropped in the jitter, dcron is stopped", jobName)
+		return false
+	}
+}
@@ -1,6 +1,12 @@
 package dcron
 
-import "github.com/libi/dcron/cron"
+import (
+	"context"
+	"time"
+
+	"github.com/libi/dcron/cron"
+	"github.com/libi/dcron/dlog"
+)
 
 // Job Interface
 type Job interface {
@@ -23,19 +29,186 @@ type JobWarpper struct {
 	Dcron   *Dcron
 	Name    string
 	CronStr string
-	Job     Job
+	// Location is the time zone to interpret CronStr,
+	// nil means the time zone of dcron.
+	Location *time.Location
+	Job      Job
+
+	// at is the scheduled time of a run by runAt.
+	at time.Time
+	// ctx is the context of a run by RunWithContext.
+	ctx context.Context
+}
+
+// JobName implements cron.NamedJob
+func (job JobWarpper) JobName() string {
+	return job.Name
+}
+
+// JobLogger implements cron.LoggedJob, it is the logger of the job
+// added by AddJobWithLogger, otherwise nil.
+func (job JobWarpper) JobLogger() dlog.Logger {
+	if lj, ok := job.Job.(cron.LoggedJob); ok {
+		return lj.JobLogger()
+	}
+	return nil
+}
+
+// replaceable returns true if the job is a plain func, which can be
+// replaced by ReplaceJob or ReloadJobs without losing its decoration.
+func (job JobWarpper) replaceable() bool {
+	_, plain := job.Job.(cron.FuncJob)
+	return plain
 }
 
 // Run is run job
 func (job JobWarpper) Run() {
+	_ = job.RunWithError()
+}
+
+// RunWithContext implements cron.ContextJob, see RunWithContextError.
+func (job JobWarpper) RunWithContext(ctx context.Context) {
+	_ = job.RunWithContextError(ctx)
+}
+
+// RunWithContextError implements cron.ContextErrorJob, the context passed
+// to the job added by AddJobWithContext is canceled when ctx is done too,
+// e.g. when cron.TimeoutJob wrapping this job times out.
+func (job JobWarpper) RunWithContextError(ctx context.Context) error {
+	job.ctx = ctx
+	return job.RunWithError()
+}
+
+// RunWithError implements cron.ErrorJob, it returns the error
+// of the job if it is a cron.ErrorJob.
+func (job JobWarpper) RunWithError() error {
+	if !job.Dcron.runStarted() {
+		return nil
+	}
+	defer job.Dcron.runFinished()
 	//如果该任务分配给了这个节点 则允许执行
-	if job.Dcron.allowThisNodeRun(job.Name) {
-		job.Execute()
+	allowed := job.Dcron.allowThisNodeRun(job.Name)
+	job.Dcron.logOwnership(job.Name, allowed)
+	if allowed && !job.Dcron.jobPaused(job.Name) {
+		scheduledTime := job.scheduledTime()
+		if job.at.IsZero() {
+			job.Dcron.observeLateRun(job.Name, scheduledTime)
+		}
+		if !job.Dcron.waitJitter(job.Name) {
+			return nil
+		}
+		if job.Dcron.inMaintenanceWindow(job.Name, scheduledTime) {
+			return nil
+		}
+		handover, ok := job.Dcron.acquireHandover(job.Name)
+		if !ok {
+			return nil
+		}
+		defer handover()
+		release, ok := job.Dcron.acquireExecutionLock(job.Name, scheduledTime)
+		if !ok {
+			return nil
+		}
+		defer release()
+		return job.execute(scheduledTime)
+	}
+	return nil
+}
+
+// runAt runs the job through the chain of cron outside of its schedule, as
+// if it was scheduled at scheduledTime, e.g. a run by TriggerJob or a run
+// which catches up a missed time. The run is not observed as a late run.
+func (job JobWarpper) runAt(scheduledTime time.Time) error {
+	job.at = scheduledTime
+	wrapped, ok := job.Dcron.cr.WrapJob(job.ID, job)
+	if !ok {
+		return ErrJobNotExist
+	}
+	if ej, ok := wrapped.(cron.ErrorJob); ok {
+		return ej.RunWithError()
 	}
+	wrapped.Run()
+	return nil
 }
 
+// Execute runs the job directly, without checking the node.
 func (job JobWarpper) Execute() {
-	if job.Job != nil {
-		job.Job.Run()
+	if !job.Dcron.runStarted() {
+		return
+	}
+	defer job.Dcron.runFinished()
+	_ = job.execute(job.Dcron.clock.Now())
+}
+
+func (job JobWarpper) execute(scheduledTime time.Time) (err error) {
+	if job.Job == nil {
+		return nil
+	}
+	job.Dcron.jobStarted(job.Name)
+	defer job.Dcron.jobFinished(job.Name)
+	job.Dcron.emit(Event{Type: EventJobStarted, JobName: job.Name})
+	defer func(start time.Time) {
+		if r := recover(); r != nil {
+			job.Dcron.recordJobStatus(job.Name, start, job.Dcron.clock.Now().Sub(start), panicError(r))
+			job.Dcron.emit(Event{Type: EventJobPanicked, JobName: job.Name, Err: panicError(r)})
+			panic(r)
+		}
+		job.Dcron.recordJobStatus(job.Name, start, job.Dcron.clock.Now().Sub(start), err)
+		job.Dcron.emit(Event{Type: EventJobFinished, JobName: job.Name, Err: err})
+		if err == nil {
+			job.Dcron.recordLastRun(job.Name, start)
+		}
+	}(job.Dcron.clock.Now())
+	if m := job.Dcron.metrics; m != nil {
+		m.IncJobRuns(job.Name)
+		defer func(start time.Time) {
+			if err != nil {
+				m.IncJobErrors(job.Name)
+			}
+			m.ObserveJobDuration(job.Name, job.Dcron.clock.Now().Sub(start))
+		}(job.Dcron.clock.Now())
+	}
+	if cj, ok := job.Job.(cron.ContextJob); ok {
+		ctx, cancel := job.Dcron.jobContext(job.ctx, job.Name, scheduledTime)
+		defer cancel()
+		if cej, ok := cj.(cron.ContextErrorJob); ok {
+			return cej.RunWithContextError(ctx)
+		}
+		cj.RunWithContext(ctx)
+		return nil
+	}
+	if ej, ok := job.Job.(cron.ErrorJob); ok {
+		return ej.RunWithError()
+	}
+	job.Job.Run()
+	return nil
+}
+
+// scheduledTime returns the time that the scheduler fired this job, or
+// the time of the run by runAt.
+// The cron sets Entry.Prev before serving the snapshot, so the
+// entry we get here is already updated for this run.
+func (job JobWarpper) scheduledTime() time.Time {
+	if !job.at.IsZero() {
+		return job.at
+	}
+	if prev := job.Dcron.cr.Entry(job.ID).Prev; !prev.IsZero() {
+		return prev
+	}
+	return job.Dcron.clock.Now()
+}
+
+// Skipped implements cron.NotifiedJob
+func (job JobWarpper) Skipped() {
+	job.Dcron.emit(Event{Type: EventJobSkipped, JobName: job.Name})
+	if m := job.Dcron.metrics; m != nil {
+		m.IncSkipped(job.Name)
+	}
+}
+
+// Delayed implements cron.NotifiedJob
+func (job JobWarpper) Delayed(d time.Duration) {
+	if m := job.Dcron.metrics; m != nil {
+		m.IncDelayed(job.Name)
 	}
 }
@@ -0,0 +1,86 @@
+package dcron
+
+import (
+	"context"
+	"time"
+)
+
+type jobNameCtxKey struct{}
+
+type scheduledTimeCtxKey struct{}
+
+type nodeIDCtxKey struct{}
+
+// JobNameFromContext returns the job name carried by the context
+// which is passed to a job added by AddJobWithContext.
+func JobNameFromContext(ctx context.Context) (string, bool) {
+	jobName, ok := ctx.Value(jobNameCtxKey{}).(string)
+	return jobName, ok
+}
+
+// ScheduledTimeFromContext returns the scheduled time carried by the context
+// which is passed to a job added by AddJobWithContext.
+// If the job is not triggered by the scheduler, it is the time of calling.
+func ScheduledTimeFromContext(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(scheduledTimeCtxKey{}).(time.Time)
+	return t, ok
+}
+
+// NodeIDFromContext returns the nodeID of the node running the job,
+// it is carried by the context which is passed to a job added by
+// AddJobWithContext. There is no nodeID when dcron is running locally.
+func NodeIDFromContext(ctx context.Context) (string, bool) {
+	nodeID, ok := ctx.Value(nodeIDCtxKey{}).(string)
+	return nodeID, ok
+}
+
+// jobContext creates the context for one run of a job.
+// It is canceled when dcron is stopped, when parent is done if it is not
+// nil, or when this node loses the ownership of this job in the middle
+// of the run.
+func (d *Dcron) jobContext(parent context.Context, jobName string, scheduledTime time.Time) (context.Context, context.CancelFunc) {
+	stopped := d.runtimeContext()
+	if parent == nil {
+		parent = stopped
+	}
+	ctx, cancel := context.WithCancel(parent)
+	if parent != stopped {
+		d.goTracked(func() {
+			select {
+			case <-stopped.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		})
+	}
+	ctx = context.WithValue(ctx, jobNameCtxKey{}, jobName)
+	ctx = context.WithValue(ctx, scheduledTimeCtxKey{}, scheduledTime)
+	if !d.runningLocally {
+		ctx = context.WithValue(ctx, nodeIDCtxKey{}, d.nodePool.GetNodeID())
+		d.goTracked(func() { d.watchJobOwnership(ctx, cancel, jobName) })
+	}
+	return ctx, cancel
+}
+
+func (d *Dcron) watchJobOwnership(ctx context.Context, cancel context.CancelFunc, jobName string) {
+	tick := time.NewTicker(d.nodeUpdateDuration)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			// an upgrading node pool returns error, the ownership
+			// is unknown in this state so we keep the job running.
+			// the runs in a cordoned node run to completion.
+			if d.IsCordoned() {
+				continue
+			}
+			if ok, err := d.checkJobAvailable(jobName); err == nil && !ok {
+				d.logger.Warnf("job '%s' lost ownership in this node, cancel it", jobName)
+				cancel()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
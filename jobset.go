@@ -0,0 +1,107 @@
+package dcron
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libi/dcron/driver"
+)
+
+const jobSetKeyPre = "jobset:"
+
+func jobSetKey(nodeID string) string {
+	return jobSetKeyPre + nodeID
+}
+
+// JobSetDivergedHandler is called when some nodes in the node pool have
+// added a different set of job names from this node, diverged is the
+// sorted nodeIDs of them. It is called with an empty diverged once all
+// the nodes have the same set again.
+type JobSetDivergedHandler func(diverged []string)
+
+// jobSetHash returns the hash of the names of the jobs added to this node.
+func (d *Dcron) jobSetHash() string {
+	d.jobsRWMut.RLock()
+	names := make([]string, 0, len(d.jobs))
+	for name := range d.jobs {
+		names = append(names, name)
+	}
+	d.jobsRWMut.RUnlock()
+	sort.Strings(names)
+	sum := sha256.Sum256([]byte(strings.Join(names, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkJobSets advertises the hash of the job names of this node in the
+// driver once per node update duration, and compares it with the hashes of
+// the other nodes in the node pool, see WithJobSetCheck. A node which has
+// not advertised its hash yet is not compared.
+// The hash is deleted when dcron is stopped.
+func (d *Dcron) checkJobSets() {
+	defer d.advertisers.Done()
+	kv, ok := d.kvDriver()
+	if !ok {
+		d.logger.Warnf("driver is not a KVDriver, the job sets of the nodes are not checked")
+		return
+	}
+	ctx := d.runtimeContext()
+	nodeID := d.nodePool.GetNodeID()
+	defer func() {
+		delCtx, cancel := context.WithTimeout(context.Background(), d.nodeUpdateDuration)
+		defer cancel()
+		if err := kv.Del(delCtx, jobSetKey(nodeID)); err != nil {
+			d.logger.Errorf("delete the job set of this node error, err=%v", err)
+		}
+	}()
+	tick := time.NewTicker(d.nodeUpdateDuration)
+	defer tick.Stop()
+	var reported []string
+	for {
+		if diverged, err := d.divergedJobSets(ctx, kv, nodeID); err != nil {
+			d.logger.Errorf("check the job sets of the nodes error, err=%v", err)
+		} else if !equalNodes(diverged, reported) {
+			if len(diverged) > 0 {
+				d.logger.Warnf("nodes %v have added a different set of jobs from this node, "+
+					"their jobs may never run after they left", diverged)
+			} else {
+				d.logger.Infof("all nodes have added the same set of jobs")
+			}
+			if d.jobSetDivergedHandler != nil {
+				d.jobSetDivergedHandler(diverged)
+			}
+			reported = diverged
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// divergedJobSets advertises the hash of this node and returns the nodes
+// whose hash is different from it.
+func (d *Dcron) divergedJobSets(ctx context.Context, kv driver.KVDriver, nodeID string) ([]string, error) {
+	hash := d.jobSetHash()
+	if err := kv.Set(ctx, jobSetKey(nodeID), hash); err != nil {
+		return nil, err
+	}
+	diverged := make([]string, 0)
+	for _, node := range d.nodePool.GetNodes() {
+		if node == nodeID {
+			continue
+		}
+		value, ok, err := kv.Get(ctx, jobSetKey(node))
+		if err != nil {
+			return nil, err
+		}
+		if ok && value != hash {
+			diverged = append(diverged, node)
+		}
+	}
+	return diverged, nil
+}
@@ -0,0 +1,189 @@
+package dcron
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libi/dcron/cron"
+)
+
+// ErrNilJobFunc is returned by AddJobs if the Func of a JobSpec is nil, and
+// by AddJob and the AddFunc family if the job or the func is nil.
+var ErrNilJobFunc = errors.New("job func is nil")
+
+// JobSpec is a job added by AddJobs, Name and CronSpec can be
+// loaded from the configuration files.
+type JobSpec struct {
+	Name     string `json:"name" yaml:"name"`
+	CronSpec string `json:"cron_spec" yaml:"cron_spec"`
+	Func     func() `json:"-" yaml:"-"`
+}
+
+// JobSpecError is the error of a JobSpec in AddJobsError.
+type JobSpecError struct {
+	Name string
+	Err  error
+}
+
+func (e *JobSpecError) Error() string {
+	return fmt.Sprintf("job '%s': %v", e.Name, e.Err)
+}
+
+func (e *JobSpecError) Unwrap() error {
+	return e.Err
+}
+
+// AddJobsError is returned by AddJobs with the errors of all the
+// invalid JobSpecs. errors.Is reports whether any of them matches.
+type AddJobsError struct {
+	Errors []*JobSpecError
+}
+
+func (e *AddJobsError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return "add jobs failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *AddJobsError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddJobs adds all the jobs, or none of them. All the jobs are validated
+// before any of them is added, if any spec is invalid, or any name is
+// duplicated in jobs or with the added jobs, an *AddJobsError with the
+// detail of each invalid JobSpec is returned and no job is added.
+func (d *Dcron) AddJobs(jobs []JobSpec) error {
+	d.jobsRWMut.Lock()
+	defer d.jobsRWMut.Unlock()
+	if d.jobsFrozen() {
+		return ErrJobsFrozen
+	}
+
+	schedules, err := d.parseJobSpecs(jobs, true)
+	if err != nil {
+		return err
+	}
+
+	for i, job := range jobs {
+		d.scheduleJobSpec(job, schedules[i])
+	}
+	return nil
+}
+
+// ReloadJobs makes the added jobs the same as jobs, e.g. after the
+// configuration file is reloaded: the added jobs not in jobs are removed as
+// RemoveJob does, the jobs not added yet are added, and the others are
+// replaced by their JobSpecs as ReplaceJob does, they keep their time zones.
+// All the jobs are validated before any change, if any spec is invalid, any
+// name is duplicated in jobs or any job to replace is not replaceable by
+// ReplaceJob, the same *AddJobsError as AddJobs is returned, with
+// ErrJobNotReplaceable for the latter, and the added jobs are not changed.
+// The changes are made under one lock, so no other change of the jobs is
+// interleaved, the in-flight runs are not affected.
+func (d *Dcron) ReloadJobs(jobs []JobSpec) error {
+	d.jobsRWMut.Lock()
+	defer d.jobsRWMut.Unlock()
+	if d.jobsFrozen() {
+		return ErrJobsFrozen
+	}
+	schedules, err := d.parseJobSpecs(jobs, false)
+	if err != nil {
+		return err
+	}
+
+	reloaded := make(map[string]struct{}, len(jobs))
+	for _, job := range jobs {
+		reloaded[job.Name] = struct{}{}
+	}
+	for jobName, job := range d.jobs {
+		if _, ok := reloaded[jobName]; !ok {
+			d.removeJob(job)
+		}
+	}
+	for i, job := range jobs {
+		added, ok := d.jobs[job.Name]
+		if !ok {
+			d.scheduleJobSpec(job, schedules[i])
+			continue
+		}
+		innerJob := &JobWarpper{
+			ID:       added.ID,
+			Name:     job.Name,
+			CronStr:  job.CronSpec,
+			Location: added.Location,
+			Job:      cron.FuncJob(job.Func),
+			Dcron:    d,
+		}
+		// the spec is valid, and the entry exists while the lock is held.
+		_ = d.cr.ReplaceJobWithLocation(added.ID, job.CronSpec, added.Location, innerJob)
+		d.jobs[job.Name] = innerJob
+		d.logger.Infof("replaceJob '%s' : %s", job.Name, job.CronSpec)
+	}
+	return nil
+}
+
+// parseJobSpecs validates jobs and returns their schedules, or an
+// *AddJobsError. If unique is true, a job which is added already is also
+// a duplicate, otherwise it must be replaceable. The caller must hold
+// jobsRWMut.
+func (d *Dcron) parseJobSpecs(jobs []JobSpec, unique bool) ([]cron.Schedule, error) {
+	var errs []*JobSpecError
+	schedules := make([]cron.Schedule, len(jobs))
+	names := make(map[string]struct{}, len(jobs))
+	for i, job := range jobs {
+		addedJob, added := d.jobs[job.Name]
+		_, duplicated := names[job.Name]
+		names[job.Name] = struct{}{}
+		var err error
+		switch {
+		case (unique && added) || duplicated:
+			err = ErrJobExist
+		case job.Name == "":
+			err = ErrEmptyJobName
+		case job.Func == nil:
+			err = ErrNilJobFunc
+		case added && !addedJob.replaceable():
+			err = ErrJobNotReplaceable
+		default:
+			var loc *time.Location
+			if added {
+				loc = addedJob.Location
+			}
+			if schedules[i], err = d.cr.ParseWithLocation(job.CronSpec, loc); err != nil {
+				err = invalidCronSpec(job.CronSpec, err)
+			} else {
+				err = d.checkInterval(job.Name, schedules[i])
+			}
+		}
+		if err != nil {
+			errs = append(errs, &JobSpecError{Name: job.Name, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &AddJobsError{Errors: errs}
+	}
+	return schedules, nil
+}
+
+// scheduleJobSpec adds the validated job, the caller must hold jobsRWMut.
+func (d *Dcron) scheduleJobSpec(job JobSpec, schedule cron.Schedule) {
+	d.logger.Infof("addJob '%s' : %s", job.Name, job.CronSpec)
+	innerJob := &JobWarpper{
+		Name:    job.Name,
+		CronStr: job.CronSpec,
+		Job:     cron.FuncJob(job.Func),
+		Dcron:   d,
+	}
+	innerJob.ID = d.cr.Schedule(schedule, innerJob)
+	d.jobs[job.Name] = innerJob
+}
@@ -0,0 +1,104 @@
+package dcron
+
+import (
+	"context"
+
+	"github.com/libi/dcron/driver"
+)
+
+const pausedJobKeyPre = "paused:"
+
+func pausedJobKey(jobName string) string {
+	return pausedJobKeyPre + jobName
+}
+
+// kvDriver returns the driver as a driver.KVDriver, if it is supported.
+func (d *Dcron) kvDriver() (driver.KVDriver, bool) {
+	if d.runningLocally || d.driver == nil {
+		return nil, false
+	}
+	kv, ok := d.driver.(driver.KVDriver)
+	if !ok {
+		return nil, false
+	}
+	return timeoutKVDriver{kv: kv, timeout: d.driverOpTimeout()}, true
+}
+
+// PauseJob pauses the job, the paused job is still in the job list,
+// but it will not be triggered until ResumeJob is called.
+// If the driver implements driver.KVDriver, the job will be paused
+// in all nodes of this service, otherwise only in this node.
+// The pause state in the driver is kept by the service and the job name,
+// so it survives the restarts of the whole cluster: a job added later with
+// the same name is paused from the start, until ResumeJob is called.
+// RemoveJob does not clear it.
+func (d *Dcron) PauseJob(jobName string) error {
+	if !d.HasJob(jobName) {
+		return ErrJobNotExist
+	}
+	if kv, ok := d.kvDriver(); ok {
+		return kv.Set(context.Background(), pausedJobKey(jobName), "1")
+	}
+	d.logger.Warnf("driver is not a KVDriver, job '%s' is paused only in this node", jobName)
+	d.pausedJobs.Store(jobName, struct{}{})
+	return nil
+}
+
+// ResumeJob resumes the paused job, it will be triggered from the next
+// scheduled time, the runs missed in the paused time will not be back-filled.
+func (d *Dcron) ResumeJob(jobName string) error {
+	if !d.HasJob(jobName) {
+		return ErrJobNotExist
+	}
+	if kv, ok := d.kvDriver(); ok {
+		return kv.Del(context.Background(), pausedJobKey(jobName))
+	}
+	d.pausedJobs.Delete(jobName)
+	return nil
+}
+
+// IsJobPaused returns true if the job is paused.
+func (d *Dcron) IsJobPaused(jobName string) (bool, error) {
+	if kv, ok := d.kvDriver(); ok {
+		_, paused, err := kv.Get(context.Background(), pausedJobKey(jobName))
+		return paused, err
+	}
+	_, paused := d.pausedJobs.Load(jobName)
+	return paused, nil
+}
+
+// persistedPaused returns true if the job is paused in the driver, it is
+// checked when the job is added, to warn about a job paused before the
+// restart. The errors are left to jobPaused which checks it on every run.
+func (d *Dcron) persistedPaused(jobName string) bool {
+	kv, ok := d.kvDriver()
+	if !ok {
+		return false
+	}
+	_, paused, err := kv.Get(context.Background(), pausedJobKey(jobName))
+	return err == nil && paused
+}
+
+// jobPaused is used before running the job, if the pause state can not be
+// got from the driver, the job is considered not paused.
+func (d *Dcron) jobPaused(jobName string) bool {
+	paused, err := d.IsJobPaused(jobName)
+	if err != nil {
+		d.logger.Errorf("get pause state of job '%s' error, err=%v", jobName, err)
+		return false
+	}
+	if paused {
+		d.logger.Infof("job '%s' is paused, skip it", jobName)
+		return true
+	}
+	if group := d.jobGroup(jobName); group != "" {
+		if paused, err = d.IsGroupPaused(group); err != nil {
+			d.logger.Errorf("get pause state of group '%s' error, err=%v", group, err)
+			return false
+		}
+		if paused {
+			d.logger.Infof("group '%s' of job '%s' is paused, skip it", group, jobName)
+		}
+	}
+	return paused
+}
@@ -0,0 +1,85 @@
+package dcron
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrJobPanic is wrapped by JobStatus.LastError when the last run panics.
+var ErrJobPanic = errors.New("job panic")
+
+// JobStatus is the result of the latest run of a job in this node.
+type JobStatus struct {
+	// LastStartTime is the time the latest run started.
+	LastStartTime time.Time
+	// LastDuration is the duration of the latest run.
+	LastDuration time.Duration
+	// LastError is the error returned by the latest run if the job is
+	// a cron.ErrorJob, or an error wrapping ErrJobPanic if it panics.
+	LastError error
+	// ConsecutiveFailures is the number of the failed runs in a row,
+	// it is reset to 0 by a successful run.
+	ConsecutiveFailures int
+}
+
+// JobStatus returns the status of the latest run of jobName in this node,
+// ok is false if the job has not run in this node yet. The status is kept
+// in memory only, and removed with the job.
+func (d *Dcron) JobStatus(jobName string) (status JobStatus, ok bool) {
+	d.jobStatusMut.RLock()
+	defer d.jobStatusMut.RUnlock()
+	s, ok := d.jobStatus[jobName]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return *s, true
+}
+
+// RecentlyRanLocally returns the time the latest run of jobName started in
+// this node, ok is false if the job has not run in this node, e.g. it is
+// owned by another node. Unlike LastRunTime, it is kept in this node only,
+// so it is cheap to check which jobs this node is running.
+func (d *Dcron) RecentlyRanLocally(jobName string) (ranAt time.Time, ok bool) {
+	status, ok := d.JobStatus(jobName)
+	return status.LastStartTime, ok
+}
+
+func (d *Dcron) recordJobStatus(jobName string, start time.Time, duration time.Duration, err error) {
+	var nodeID string
+	if d.historySize > 0 && !d.runningLocally {
+		nodeID = d.NodeID()
+	}
+	d.jobStatusMut.Lock()
+	defer d.jobStatusMut.Unlock()
+	s, ok := d.jobStatus[jobName]
+	if !ok {
+		s = &JobStatus{}
+		d.jobStatus[jobName] = s
+	}
+	s.LastStartTime = start
+	s.LastDuration = duration
+	s.LastError = err
+	if err != nil {
+		s.ConsecutiveFailures++
+	} else {
+		s.ConsecutiveFailures = 0
+	}
+	d.recordJobHistory(jobName, Execution{
+		StartTime: start,
+		Duration:  duration,
+		Error:     err,
+		NodeID:    nodeID,
+	})
+}
+
+func (d *Dcron) removeJobStatus(jobName string) {
+	d.jobStatusMut.Lock()
+	defer d.jobStatusMut.Unlock()
+	delete(d.jobStatus, jobName)
+	delete(d.jobHistory, jobName)
+}
+
+func panicError(r interface{}) error {
+	return fmt.Errorf("%w: %v", ErrJobPanic, r)
+}
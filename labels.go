@@ -0,0 +1,158 @@
+package dcron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/libi/dcron/consistenthash"
+	"github.com/libi/dcron/cron"
+	"github.com/libi/dcron/driver"
+)
+
+// ErrNoMatchingNode is returned by GetJobOwnerNode if no node in the node
+// pool matches the selector of the job, see AddJobWithSelector.
+var ErrNoMatchingNode = errors.New("no node matches the selector of the job")
+
+const labelsKeyPre = "labels:"
+
+func labelsKey(nodeID string) string {
+	return labelsKeyPre + nodeID
+}
+
+// AddJobWithSelector add a cron func which only runs in the nodes whose
+// labels set by WithNodeLabels match all the labels of selector, e.g. the
+// nodes in region eu. The job is distributed by the hash ring of the
+// matching nodes, and it does not run if no node matches, which is logged
+// at Warn level. The labels are advertised in the driver, which must
+// implement driver.KVDriver, once per node update duration, so a node
+// which just joined takes the jobs matching its labels after that.
+func (d *Dcron) AddJobWithSelector(jobName, cronStr string, selector map[string]string, cmd func()) error {
+	copied := make(map[string]string, len(selector))
+	for k, v := range selector {
+		copied[k] = v
+	}
+	if err := d.addMarkedJob(jobName, cronStr, cron.FuncJob(cmd), &d.selectorJobs, copied); err != nil {
+		return err
+	}
+	if atomic.LoadInt32(&d.running) == dcronRunning {
+		d.watchNodeLabels()
+	}
+	return nil
+}
+
+// watchNodeLabels starts watchingNodeLabels if it is not running.
+func (d *Dcron) watchNodeLabels() {
+	if d.runningLocally || !atomic.CompareAndSwapInt32(&d.labelsWatching, 0, 1) {
+		return
+	}
+	d.advertisers.Add(1)
+	d.goTracked(d.watchingNodeLabels)
+}
+
+// watchingNodeLabels advertises the labels of this node in the driver once
+// per node update duration, and reads the labels of the other nodes in
+// the node pool. The labels are deleted when dcron is stopped.
+func (d *Dcron) watchingNodeLabels() {
+	defer d.advertisers.Done()
+	defer atomic.StoreInt32(&d.labelsWatching, 0)
+	kv, ok := d.kvDriver()
+	if !ok {
+		d.logger.Warnf("driver is not a KVDriver, the labels of the nodes are not advertised")
+		return
+	}
+	ctx := d.runtimeContext()
+	nodeID := d.nodePool.GetNodeID()
+	defer func() {
+		delCtx, cancel := context.WithTimeout(context.Background(), d.nodeUpdateDuration)
+		defer cancel()
+		if err := kv.Del(delCtx, labelsKey(nodeID)); err != nil {
+			d.logger.Errorf("delete the labels of this node error, err=%v", err)
+		}
+	}()
+	tick := time.NewTicker(d.nodeUpdateDuration)
+	defer tick.Stop()
+	for {
+		if labels, err := d.readNodeLabels(ctx, kv, nodeID); err != nil {
+			d.logger.Errorf("sync the labels of the nodes error, err=%v", err)
+		} else {
+			d.peerLabelsMut.Lock()
+			d.peerLabels = labels
+			d.peerLabelsMut.Unlock()
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// readNodeLabels advertises the labels of this node and returns the labels
+// of the other nodes, the nodes which have not advertised have no labels.
+func (d *Dcron) readNodeLabels(ctx context.Context, kv driver.KVDriver, nodeID string) (map[string]map[string]string, error) {
+	value, err := json.Marshal(d.nodeLabels)
+	if err != nil {
+		return nil, err
+	}
+	if err = kv.Set(ctx, labelsKey(nodeID), string(value)); err != nil {
+		return nil, err
+	}
+	labels := make(map[string]map[string]string)
+	for _, node := range d.nodePool.GetNodes() {
+		if node == nodeID {
+			continue
+		}
+		value, ok, err := kv.Get(ctx, labelsKey(node))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		var nodeLabels map[string]string
+		if err = json.Unmarshal([]byte(value), &nodeLabels); err != nil {
+			d.logger.Errorf("invalid labels of node %s, err=%v", node, err)
+			continue
+		}
+		labels[node] = nodeLabels
+	}
+	return labels, nil
+}
+
+// labelsOf returns the labels of the node, which are advertised by it.
+func (d *Dcron) labelsOf(nodeID string) map[string]string {
+	if nodeID == d.nodePool.GetNodeID() {
+		return d.nodeLabels
+	}
+	d.peerLabelsMut.RLock()
+	defer d.peerLabelsMut.RUnlock()
+	return d.peerLabels[nodeID]
+}
+
+// selectorOwner returns the owner of the job by the hash ring of the
+// nodes matching selector.
+func (d *Dcron) selectorOwner(jobName string, selector map[string]string) (string, error) {
+	ring := consistenthash.New(d.nodePool.HashReplicas(), d.hashFn)
+	for _, node := range d.nodePool.GetNodes() {
+		if matchLabels(d.labelsOf(node), selector) {
+			ring.AddWithWeight(node, driver.GetNodeWeight(node))
+		}
+	}
+	if ring.IsEmpty() {
+		return "", ErrNoMatchingNode
+	}
+	return ring.Get(jobName), nil
+}
+
+// matchLabels returns true if labels have all the labels of selector.
+func matchLabels(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
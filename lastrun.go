@@ -0,0 +1,56 @@
+package dcron
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotKVDriver is returned if the feature needs a driver.KVDriver.
+var ErrNotKVDriver = errors.New("driver is not a KVDriver")
+
+const lastRunKeyPre = "lastrun:"
+
+func lastRunKey(jobName string) string {
+	return lastRunKeyPre + jobName
+}
+
+// LastRunTime returns the start time of the last successful run of the job
+// in the cluster, and the nodeID of the node which ran it. The run is
+// recorded in the driver, so it is kept after the node restarted. The zero
+// time is returned if the job has not succeeded since it was recorded.
+// The driver must implement driver.KVDriver, or ErrNotKVDriver is returned.
+func (d *Dcron) LastRunTime(jobName string) (t time.Time, nodeID string, err error) {
+	if !d.HasJob(jobName) {
+		return time.Time{}, "", ErrJobNotExist
+	}
+	kv, ok := d.kvDriver()
+	if !ok {
+		return time.Time{}, "", ErrNotKVDriver
+	}
+	value, ok, err := kv.Get(context.Background(), lastRunKey(jobName))
+	if err != nil || !ok {
+		return time.Time{}, "", err
+	}
+	unixNano, nodeID, _ := strings.Cut(value, ",")
+	nsec, err := strconv.ParseInt(unixNano, 10, 64)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return time.Unix(0, nsec), nodeID, nil
+}
+
+// recordLastRun writes the successful run started at start to the driver,
+// see LastRunTime.
+func (d *Dcron) recordLastRun(jobName string, start time.Time) {
+	kv, ok := d.kvDriver()
+	if !ok {
+		return
+	}
+	value := strconv.FormatInt(start.UnixNano(), 10) + "," + d.NodeID()
+	if err := kv.Set(context.Background(), lastRunKey(jobName), value); err != nil {
+		d.logger.Errorf("record the last run of job '%s' error, err=%v", jobName, err)
+	}
+}
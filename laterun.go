@@ -0,0 +1,19 @@
+package dcron
+
+import "time"
+
+// LateRunObserver is called when a job starts later than its scheduled time
+// by more than the threshold of WithLateRunObserver, actual is the time it
+// starts in this node.
+type LateRunObserver func(jobName string, scheduled, actual time.Time)
+
+// observeLateRun reports the run to the LateRunObserver if it starts late.
+// It is called before the jitter, which delays the run on purpose.
+func (d *Dcron) observeLateRun(jobName string, scheduled time.Time) {
+	if d.lateRunObserver == nil {
+		return
+	}
+	if actual := d.clock.Now(); actual.Sub(scheduled) > d.lateRunThreshold {
+		d.lateRunObserver(jobName, scheduled, actual)
+	}
+}
@@ -0,0 +1,28 @@
+package dcron
+
+import "sync/atomic"
+
+// leaderKey is the reserved key whose owner in the hash ring is the leader.
+const leaderKey = "distributed-cron-leader"
+
+// IsLeader returns true if this node is the leader of the cluster, which
+// is the node owning a reserved key in the hash ring, like a job, so the
+// leader moves to another node once it leaves. It can gate the maintenance
+// which should run on one node. There is no leader while the node pool is
+// upgrading, so two nodes never consider themselves the leaders by the same
+// hash ring, and a stale incarnation of a node, see WithNodeGeneration, or a
+// cordoned node, see Cordon, is never the leader. When dcron is running
+// locally, this node is the leader.
+func (d *Dcron) IsLeader() bool {
+	if atomic.LoadInt32(&d.running) != dcronRunning {
+		return false
+	}
+	if d.runningLocally {
+		return true
+	}
+	if d.isStaleGeneration() || d.IsCordoned() {
+		return false
+	}
+	ok, err := d.nodePool.CheckJobAvailable(leaderKey)
+	return err == nil && ok
+}
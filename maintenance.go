@@ -0,0 +1,105 @@
+package dcron
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libi/dcron/cron"
+)
+
+// ErrInvalidMaintenanceWindow is returned by Err if the duration of a
+// maintenance window is not positive, see WithMaintenanceWindow.
+var ErrInvalidMaintenanceWindow = errors.New("invalid maintenance window")
+
+// maintenanceWindow starts when schedule fires and lasts for duration.
+type maintenanceWindow struct {
+	spec     string
+	duration time.Duration
+	schedule cron.Schedule
+}
+
+// parseMaintenanceWindows parses the specs of the maintenance windows by
+// the parser of dcron, it is called after the cron is created.
+func (d *Dcron) parseMaintenanceWindows() error {
+	for i := range d.maintenanceWindows {
+		w := &d.maintenanceWindows[i]
+		if w.duration <= 0 {
+			return fmt.Errorf("%w: duration %v of '%s' must be positive", ErrInvalidMaintenanceWindow, w.duration, w.spec)
+		}
+		schedule, err := d.cr.Parse(w.spec)
+		if err != nil {
+			return invalidCronSpec(w.spec, err)
+		}
+		w.schedule = schedule
+	}
+	return nil
+}
+
+// maintenanceEnd returns the end of the maintenance window which now is in,
+// ok is false if now is not in any of them.
+func (d *Dcron) maintenanceEnd(now time.Time) (end time.Time, ok bool) {
+	now = now.In(d.cr.Location())
+	for _, w := range d.maintenanceWindows {
+		// the first start after now-duration is the start of the
+		// window which now is in, if it is not after now.
+		start := w.schedule.Next(now.Add(-w.duration))
+		if start.IsZero() || start.After(now) {
+			continue
+		}
+		if e := start.Add(w.duration); e.After(end) {
+			end, ok = e, true
+		}
+	}
+	return end, ok
+}
+
+// inMaintenanceWindow returns true if the run of the job scheduled at
+// scheduledTime should be skipped in a maintenance window, and arranges the
+// catch-up run of it if WithMaintenanceCatchUp is set.
+func (d *Dcron) inMaintenanceWindow(jobName string, scheduledTime time.Time) bool {
+	if len(d.maintenanceWindows) == 0 {
+		return false
+	}
+	end, ok := d.maintenanceEnd(d.clock.Now())
+	if !ok {
+		return false
+	}
+	d.logger.Infof("job '%s' is skipped in the maintenance window until %v", jobName, end)
+	if d.maintenanceCatchUp {
+		removed := make(chan struct{})
+		if _, pending := d.maintenanceMissed.LoadOrStore(jobName, removed); !pending {
+			d.goTracked(func() { d.catchUpAfterMaintenance(jobName, scheduledTime, end, removed) })
+		}
+	}
+	return true
+}
+
+// catchUpAfterMaintenance runs the job once after the maintenance window
+// ends at end, it is run through the wrapper chain with the scheduled
+// time of the first run skipped in the window, so the owner is checked
+// again. removed is closed if the job is removed in the window, then the
+// catch-up is dropped.
+func (d *Dcron) catchUpAfterMaintenance(jobName string, scheduledTime, end time.Time, removed chan struct{}) {
+	defer d.trackTimer()()
+	timer := d.clock.NewTimer(end.Sub(d.clock.Now()))
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+	case <-removed:
+		return
+	case <-d.runtimeContext().Done():
+		d.maintenanceMissed.Delete(jobName)
+		return
+	}
+	// a run skipped in the next window after this point catches up again.
+	d.maintenanceMissed.Delete(jobName)
+	d.jobsRWMut.RLock()
+	job, ok := d.jobs[jobName]
+	d.jobsRWMut.RUnlock()
+	if !ok {
+		return
+	}
+	d.logger.Infof("run job '%s' missed in the maintenance window", jobName)
+	_ = job.runAt(scheduledTime)
+}
@@ -0,0 +1,205 @@
+package dcron
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/libi/dcron/cron"
+	"github.com/libi/dcron/driver"
+)
+
+var (
+	// ErrInvalidMaxRuns is returned by AddJobWithMaxRuns if the max runs
+	// is not positive.
+	ErrInvalidMaxRuns = errors.New("max runs must be positive")
+	// ErrMaxRunsUnsupported is returned by AddJobWithMaxRuns if the driver
+	// does not implement both driver.KVDriver and driver.LockDriver.
+	ErrMaxRunsUnsupported = errors.New("the driver does not support the jobs with max runs")
+)
+
+const (
+	maxRunsKeyPre     = "maxruns:"
+	maxRunsLockKeyPre = "maxruns-lock:"
+
+	// the interval of polling a lock by lockKey.
+	lockPoll = 10 * time.Millisecond
+)
+
+// AddJobWithMaxRuns add a cron func which runs at most maxRuns times in the
+// cluster, then it is removed, e.g. a warm-up job. The runs are counted in
+// the driver, so the count is not reset by restarts or the owner moving to
+// another node. Each run takes the next count before cmd is called, under a
+// lock of the driver, so maxRuns is never exceeded even if two nodes run the
+// same scheduled time while the owner moves. A run which fails or panics is
+// counted too, and a run is skipped if the count can not be taken.
+//
+// The node which takes the last count removes the job after the run, and
+// calls the handler set by WithExpiredJobHandler. The other nodes remove
+// the job once they see the count reached, within a node update duration.
+// The count is kept in the driver, so a job added with the same name again
+// does not run, use another name to run it again.
+//
+// The driver must implement driver.KVDriver and driver.LockDriver, or
+// ErrMaxRunsUnsupported is returned. Running locally, the runs are counted
+// in this node.
+func (d *Dcron) AddJobWithMaxRuns(jobName, cronStr string, maxRuns int, cmd func()) error {
+	if err := validateJob(jobName, cmd); err != nil {
+		return err
+	}
+	if maxRuns <= 0 {
+		return ErrInvalidMaxRuns
+	}
+	if !d.runningLocally {
+		_, isKV := d.driver.(driver.KVDriver)
+		_, isLock := d.driver.(driver.LockDriver)
+		if !isKV || !isLock {
+			return ErrMaxRunsUnsupported
+		}
+	}
+	job := cron.FuncContextJob(func(ctx context.Context) {
+		run, err := d.takeRun(ctx, jobName)
+		if err != nil {
+			d.logger.Errorf("count the run of job '%s' error, it does not run, err=%v", jobName, err)
+			return
+		}
+		if run > int64(maxRuns) {
+			d.maxRunsReached(jobName, false)
+			return
+		}
+		if run == int64(maxRuns) {
+			defer d.maxRunsReached(jobName, true)
+		}
+		cmd()
+	})
+	if _, err := d.addJob(jobName, cronStr, nil, job); err != nil {
+		return err
+	}
+	d.maxRunsJobs.Store(jobName, maxRuns)
+	if atomic.LoadInt32(&d.running) == dcronRunning {
+		d.watchMaxRunsJobs()
+	}
+	return nil
+}
+
+// takeRun increases the run count of the job, and returns the count.
+func (d *Dcron) takeRun(ctx context.Context, jobName string) (int64, error) {
+	kv, ok := d.kvDriver()
+	if !ok {
+		count, _ := d.maxRunsCounts.LoadOrStore(jobName, new(int64))
+		return atomic.AddInt64(count.(*int64), 1), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.driverOpTimeout())
+	defer cancel()
+	release, err := d.lockKey(ctx, maxRunsLockKeyPre+jobName)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	count, err := d.readRunCount(ctx, kv, jobName)
+	if err != nil {
+		return 0, err
+	}
+	count++
+	if err = kv.Set(ctx, maxRunsKeyPre+jobName, strconv.FormatInt(count, 10)); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// lockKey acquires the lock of key in the driver, which must be a
+// driver.LockDriver, polling it until ctx is done. The lock outlives ctx,
+// so the work under it is bounded by ctx and never done by two nodes.
+func (d *Dcron) lockKey(ctx context.Context, key string) (release func(), err error) {
+	ld := withLockTimeout(d.driver.(driver.LockDriver), d.driverOpTimeout())
+	for {
+		acquired, err := ld.AcquireLock(ctx, key, 2*d.driverOpTimeout())
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+		select {
+		case <-time.After(lockPoll):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return func() {
+		if err := ld.ReleaseLock(context.Background(), key); err != nil {
+			d.logger.Errorf("release the lock of '%s' error, err=%v", key, err)
+		}
+	}, nil
+}
+
+// readRunCount returns the run count of the job in the driver.
+func (d *Dcron) readRunCount(ctx context.Context, kv driver.KVDriver, jobName string) (int64, error) {
+	value, ok, err := kv.Get(ctx, maxRunsKeyPre+jobName)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// maxRunsReached removes the job which has run max times, the handler set
+// by WithExpiredJobHandler is called if last is true.
+func (d *Dcron) maxRunsReached(jobName string, last bool) {
+	if d.RemoveJob(jobName) == nil {
+		d.logger.Infof("job '%s' has run the max times, it is removed", jobName)
+	}
+	if last && d.expiredJobHandler != nil {
+		d.expiredJobHandler(jobName)
+	}
+}
+
+// hasMaxRunsJobs returns true if any job is added by AddJobWithMaxRuns.
+func (d *Dcron) hasMaxRunsJobs() bool {
+	has := false
+	d.maxRunsJobs.Range(func(_, _ any) bool {
+		has = true
+		return false
+	})
+	return has
+}
+
+// watchMaxRunsJobs starts watchingMaxRunsJobs if it is not running.
+func (d *Dcron) watchMaxRunsJobs() {
+	if d.runningLocally || !atomic.CompareAndSwapInt32(&d.maxRunsWatching, 0, 1) {
+		return
+	}
+	d.goTracked(d.watchingMaxRunsJobs)
+}
+
+// watchingMaxRunsJobs removes the jobs added by AddJobWithMaxRuns whose
+// count is reached by the other nodes once per node update duration,
+// until dcron is stopped.
+func (d *Dcron) watchingMaxRunsJobs() {
+	defer atomic.StoreInt32(&d.maxRunsWatching, 0)
+	kv, ok := d.kvDriver()
+	if !ok {
+		return
+	}
+	ctx := d.runtimeContext()
+	tick := time.NewTicker(d.nodeUpdateDuration)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+		d.maxRunsJobs.Range(func(key, value any) bool {
+			jobName := key.(string)
+			count, err := d.readRunCount(ctx, kv, jobName)
+			if err != nil {
+				d.logger.Errorf("read the run count of job '%s' error, err=%v", jobName, err)
+			} else if count >= int64(value.(int)) {
+				d.maxRunsReached(jobName, false)
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package dcron
+
+import "time"
+
+// MetricsCollector collects the metrics of dcron.
+// All methods are called concurrently, so they must be goroutine safe.
+type MetricsCollector interface {
+	// IncJobRuns is called each time a job is executed in this node.
+	IncJobRuns(jobName string)
+	// IncJobErrors is called when a job which is a cron.ErrorJob returns an error.
+	IncJobErrors(jobName string)
+	// ObserveJobDuration is called when a job execution is finished.
+	ObserveJobDuration(jobName string, d time.Duration)
+	// IncSkipped is called when a run is skipped by cron.SkipIfStillRunning.
+	IncSkipped(jobName string)
+	// IncDelayed is called when a run is delayed by cron.DelayIfStillRunning.
+	IncDelayed(jobName string)
+	// SetOwnedJobs sets the number of jobs this node currently owns.
+	SetOwnedJobs(n int)
+}
+
+// watchOwnedJobs refreshes the owned jobs gauge each time
+// the node pool is updated, until dcron is stopped.
+func (d *Dcron) watchOwnedJobs() {
+	ctx := d.runtimeContext()
+	tick := time.NewTicker(d.nodeUpdateDuration)
+	defer tick.Stop()
+	for {
+		d.metrics.SetOwnedJobs(len(d.GetJobs(!d.runningLocally)))
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
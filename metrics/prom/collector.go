@@ -0,0 +1,93 @@
+// Package prom provides a dcron.MetricsCollector based on prometheus.
+package prom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultNamespace = "dcron"
+
+// Collector is a dcron.MetricsCollector which exports metrics to prometheus.
+type Collector struct {
+	jobRuns     *prometheus.CounterVec
+	jobErrors   *prometheus.CounterVec
+	jobDuration *prometheus.HistogramVec
+	jobSkipped  *prometheus.CounterVec
+	jobDelayed  *prometheus.CounterVec
+	ownedJobs   prometheus.Gauge
+}
+
+// NewCollector create a Collector and register it to reg.
+// If namespace is empty, "dcron" is used.
+func NewCollector(namespace string, reg prometheus.Registerer) (*Collector, error) {
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	c := &Collector{
+		jobRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "job_runs_total",
+			Help:      "Total number of job executions in this node.",
+		}, []string{"job"}),
+		jobErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "job_errors_total",
+			Help:      "Total number of job executions returned an error in this node.",
+		}, []string{"job"}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "job_duration_seconds",
+			Help:      "Duration of job executions in this node.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"job"}),
+		jobSkipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "job_skipped_total",
+			Help:      "Total number of runs skipped because the previous run is still running.",
+		}, []string{"job"}),
+		jobDelayed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "job_delayed_total",
+			Help:      "Total number of runs delayed because the previous run is still running.",
+		}, []string{"job"}),
+		ownedJobs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "owned_jobs",
+			Help:      "Number of jobs this node currently owns.",
+		}),
+	}
+	for _, collector := range []prometheus.Collector{
+		c.jobRuns, c.jobErrors, c.jobDuration, c.jobSkipped, c.jobDelayed, c.ownedJobs,
+	} {
+		if err := reg.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Collector) IncJobRuns(jobName string) {
+	c.jobRuns.WithLabelValues(jobName).Inc()
+}
+
+func (c *Collector) IncJobErrors(jobName string) {
+	c.jobErrors.WithLabelValues(jobName).Inc()
+}
+
+func (c *Collector) ObserveJobDuration(jobName string, d time.Duration) {
+	c.jobDuration.WithLabelValues(jobName).Observe(d.Seconds())
+}
+
+func (c *Collector) IncSkipped(jobName string) {
+	c.jobSkipped.WithLabelValues(jobName).Inc()
+}
+
+func (c *Collector) IncDelayed(jobName string) {
+	c.jobDelayed.WithLabelValues(jobName).Inc()
+}
+
+func (c *Collector) SetOwnedJobs(n int) {
+	c.ownedJobs.Set(float64(n))
+}
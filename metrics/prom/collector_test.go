@@ -0,0 +1,41 @@
+package prom_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libi/dcron"
+	"github.com/libi/dcron/metrics/prom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+var _ dcron.MetricsCollector = (*prom.Collector)(nil)
+
+func TestCollector(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c, err := prom.NewCollector("", reg)
+	require.Nil(t, err)
+
+	c.IncJobRuns("job1")
+	c.IncJobRuns("job1")
+	c.IncJobErrors("job1")
+	c.ObserveJobDuration("job1", time.Second)
+	c.IncSkipped("job1")
+	c.IncDelayed("job2")
+	c.SetOwnedJobs(3)
+
+	count, err := testutil.GatherAndCount(reg,
+		"dcron_job_runs_total",
+		"dcron_job_errors_total",
+		"dcron_job_duration_seconds",
+		"dcron_job_skipped_total",
+		"dcron_job_delayed_total",
+		"dcron_owned_jobs")
+	require.Nil(t, err)
+	require.Equal(t, 6, count)
+
+	_, err = prom.NewCollector("", reg)
+	require.NotNil(t, err)
+}
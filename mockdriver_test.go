@@ -10,6 +10,7 @@ import (
 
 type MockDriver struct {
 	StartFunc    func(context.Context) error
+	StopFunc     func(context.Context) error
 	GetNodesFunc func(context.Context) ([]string, error)
 }
 
@@ -34,6 +35,9 @@ func (md *MockDriver) Start(ctx context.Context) (err error) {
 }
 
 func (md *MockDriver) Stop(ctx context.Context) (err error) {
+	if md.StopFunc != nil {
+		return md.StopFunc(ctx)
+	}
 	return
 }
 
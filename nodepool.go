@@ -2,8 +2,10 @@ package dcron
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -37,16 +39,146 @@ type NodePool struct {
 	nodes *consistenthash.Map
 
 	driver         driver.DriverV2
+	driverOpts     []driver.Option
 	hashReplicas   int
 	hashFn         consistenthash.Hash
 	updateDuration time.Duration
 
+	// GetNodes is retried up to retryAttempts times in total,
+	// sleeping retryBaseDelay<<attempt between the attempts.
+	retryAttempts  int
+	retryBaseDelay time.Duration
+	// driverTimeout bounds each call to the driver, 0 means no timeout.
+	driverTimeout time.Duration
+
 	logger   dlog.Logger
 	stopChan chan int
 	preNodes []string // sorted
 
 	lastUpdateNodesTime atomic.Value
 	state               atomic.Value
+
+	// the time and the error of the last GetNodes from the driver.
+	lastSyncTime atomic.Value
+	lastSyncErr  atomic.Value
+	// becameSteady is set once the pool came to steady for the first time.
+	becameSteady atomic.Bool
+
+	nodeChangeCallback    NodeChangeCallback
+	jobNames              func() []string
+	jobRebalancedCallback JobRebalancedCallback
+	poolUpdateObserver    PoolUpdateObserver
+
+	// the changed nodes are committed to the hash ring after they are
+	// unchanged for updateDebounce, see NodePoolUpdateDebounce.
+	updateDebounce time.Duration
+	pendingNodes   []string // sorted
+	pendingSince   time.Time
+
+	// nodeFilter drops the nodes excluded from the hash ring, see
+	// NodePoolNodeFilter.
+	nodeFilter NodeFilter
+}
+
+// NodeChangeCallback is called when the nodes in the hash ring changed,
+// added and removed are the nodeIDs which joined and left.
+type NodeChangeCallback func(added, removed []string)
+
+// JobRebalancedCallback is called when the owner of a job changed.
+type JobRebalancedCallback func(jobName, oldOwner, newOwner string)
+
+// PoolUpdateObserver is called after each sync of the nodes from the driver
+// with the duration of the sync including the retries, the number of the
+// nodes got and the error of the sync.
+type PoolUpdateObserver func(d time.Duration, memberCount int, err error)
+
+// NodeFilter returns the nodes got from the driver which are in the hash
+// ring, e.g. without the cordoned nodes. An error fails the sync.
+type NodeFilter func(ctx context.Context, nodes []string) ([]string, error)
+
+// NodePoolOption is NodePool Option
+type NodePoolOption func(*NodePool)
+
+// NodePoolDriverOptions pass the options to the driver when NodePool init it.
+func NodePoolDriverOptions(opts ...driver.Option) NodePoolOption {
+	return func(np *NodePool) {
+		np.driverOpts = append(np.driverOpts, opts...)
+	}
+}
+
+// NodePoolHashFn set the hash function of the hash ring,
+// nil means the default crc32.
+func NodePoolHashFn(fn consistenthash.Hash) NodePoolOption {
+	return func(np *NodePool) {
+		np.hashFn = fn
+	}
+}
+
+// NodePoolDriverRetry retries GetNodes of the driver up to maxAttempts times
+// in total, with the exponential backoff starting from baseDelay, before the
+// sync is considered failed. The hash ring is kept during the retries.
+func NodePoolDriverRetry(maxAttempts int, baseDelay time.Duration) NodePoolOption {
+	return func(np *NodePool) {
+		np.retryAttempts = maxAttempts
+		np.retryBaseDelay = baseDelay
+	}
+}
+
+// NodePoolDriverTimeout bounds each call to the driver by d, e.g. each
+// attempt of GetNodes, so a hung driver fails the sync instead of blocking
+// the update loop. 0 means no timeout, which is the default.
+func NodePoolDriverTimeout(d time.Duration) NodePoolOption {
+	return func(np *NodePool) {
+		np.driverTimeout = d
+	}
+}
+
+// NodePoolNodeChangeCallback set the callback which is called when the
+// nodes in the hash ring changed.
+// The callback runs in the NodePool update loop, so it must not block.
+func NodePoolNodeChangeCallback(fn NodeChangeCallback) NodePoolOption {
+	return func(np *NodePool) {
+		np.nodeChangeCallback = fn
+	}
+}
+
+// NodePoolJobRebalancedCallback set the callback which is called for every
+// job returned by jobNames whose owner changed after the hash ring changed.
+// The callback runs in the NodePool update loop, so it must not block.
+func NodePoolJobRebalancedCallback(jobNames func() []string, fn JobRebalancedCallback) NodePoolOption {
+	return func(np *NodePool) {
+		np.jobNames = jobNames
+		np.jobRebalancedCallback = fn
+	}
+}
+
+// NodePoolUpdateObserver set the observer which is called after each sync
+// of the nodes from the driver, e.g. to measure the latency of the driver.
+// The observer runs in the NodePool update loop, so it must not block.
+func NodePoolUpdateObserver(fn PoolUpdateObserver) NodePoolOption {
+	return func(np *NodePool) {
+		np.poolUpdateObserver = fn
+	}
+}
+
+// NodePoolUpdateDebounce set the duration the changed nodes must be unchanged
+// for before the hash ring is rebuilt by them, so the nodes joining one by
+// one in a rolling deployment rebalance the jobs once. The last hash ring
+// is used in the meantime, which means the jobs of a dead node are not
+// run for d longer.
+func NodePoolUpdateDebounce(d time.Duration) NodePoolOption {
+	return func(np *NodePool) {
+		np.updateDebounce = d
+	}
+}
+
+// NodePoolNodeFilter set the filter of the nodes got from the driver in each
+// sync, the nodes it drops are not in the hash ring. It runs in the NodePool
+// update loop in the driver timeout, so it must not block.
+func NodePoolNodeFilter(fn NodeFilter) NodePoolOption {
+	return func(np *NodePool) {
+		np.nodeFilter = fn
+	}
 }
 
 func NewNodePool(
@@ -55,23 +187,33 @@ func NewNodePool(
 	updateDuration time.Duration,
 	hashReplicas int,
 	logger dlog.Logger,
+	opts ...NodePoolOption,
 ) INodePool {
 	np := &NodePool{
-		serviceName:    serviceName,
-		driver:         drv,
-		hashReplicas:   hashReplicas,
-		updateDuration: updateDuration,
-		logger: &dlog.StdLogger{
-			Log: log.Default(),
-		},
-		stopChan: make(chan int, 1),
+		serviceName:  serviceName,
+		driver:       drv,
+		hashReplicas: hashReplicas,
+		logger:       dlog.DefaultPrintfLogger(log.Default()),
+		stopChan:     make(chan int, 1),
 	}
 	if logger != nil {
 		np.logger = logger
 	}
-	np.driver.Init(serviceName,
+	// a non-positive duration would sync the nodes in a tight loop.
+	if updateDuration <= 0 {
+		np.logger.Warnf("node update duration %v is not positive, use the default %v", updateDuration, defaultDuration)
+		updateDuration = defaultDuration
+	}
+	updateDuration = clampNodeUpdateDuration(updateDuration, np.logger)
+	np.updateDuration = updateDuration
+	for _, opt := range opts {
+		opt(np)
+	}
+	driverOpts := []driver.Option{
 		driver.NewTimeoutOption(updateDuration),
-		driver.NewLoggerOption(np.logger))
+		driver.NewLoggerOption(np.logger),
+	}
+	np.driver.Init(serviceName, append(driverOpts, np.driverOpts...)...)
 	return np
 }
 
@@ -80,15 +222,22 @@ func (np *NodePool) SetLogger(logger dlog.Logger) {
 }
 
 func (np *NodePool) Start(ctx context.Context) (err error) {
-	err = np.driver.Start(ctx)
+	np.becameSteady.Store(false)
+	startCtx, cancel := np.driverContext(ctx)
+	err = np.driver.Start(startCtx)
+	cancel()
 	if err != nil {
 		np.logger.Errorf("start pool error: %v", err)
 		return
 	}
 	np.nodeID = np.driver.NodeID()
-	nowNodes, err := np.driver.GetNodes(ctx)
+	nowNodes, err := np.syncNodes(ctx, nil)
 	if err != nil {
 		np.logger.Errorf("get nodes error: %v", err)
+		// do not leave this node registered, it does not run any job.
+		stopCtx, cancel := np.driverContext(ctx)
+		_ = np.driver.Stop(stopCtx)
+		cancel()
 		return
 	}
 	np.state.Store(NodePoolStateUpgrade)
@@ -106,31 +255,111 @@ func (np *NodePool) Start(ctx context.Context) (err error) {
 
 // Check if this job can be run in this node.
 func (np *NodePool) CheckJobAvailable(jobName string) (bool, error) {
+	targetNode, err := np.GetJobOwner(jobName)
+	if err == ErrNodePoolIsEmpty {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if np.nodeID == targetNode {
+		dlog.Infow(np.logger, "job is running in this node", "job_name", jobName, "node_id", np.nodeID)
+	}
+
+	return np.nodeID == targetNode, nil
+}
+
+// GetJobOwner returns the nodeID of the node which this job will be run in.
+func (np *NodePool) GetJobOwner(jobName string) (string, error) {
 	np.rwMut.RLock()
 	defer np.rwMut.RUnlock()
 	if np.nodes == nil {
 		np.logger.Errorf("nodeID=%s, NodePool.nodes is nil", np.nodeID)
-		return false, ErrNodePoolIsNil
+		return "", ErrNodePoolIsNil
 	}
 	if np.nodes.IsEmpty() {
-		return false, nil
+		return "", ErrNodePoolIsEmpty
 	}
 	if np.state.Load().(string) != NodePoolStateSteady {
-		return false, ErrNodePoolIsUpgrading
+		return "", ErrNodePoolIsUpgrading
 	}
-	targetNode := np.nodes.Get(jobName)
-	if np.nodeID == targetNode {
-		np.logger.Infof("job %s, running in node: %s, nodeID is %s", jobName, targetNode, np.nodeID)
-	}
-
-	return np.nodeID == targetNode, nil
+	return np.nodes.Get(jobName), nil
 }
 
+// Stop stops syncing the nodes and deregisters this node from the driver.
+// If the driver fails to deregister it, the error is logged and returned,
+// the node pool is stopped anyway.
 func (np *NodePool) Stop(ctx context.Context) error {
 	np.stopChan <- 1
-	np.driver.Stop(ctx)
+	ctx, cancel := np.driverContext(ctx)
+	defer cancel()
+	err := np.driver.Stop(ctx)
+	if err != nil {
+		np.logger.Errorf("stop driver error: %v", err)
+	}
+	np.rwMut.Lock()
 	np.preNodes = make([]string, 0)
-	return nil
+	np.rwMut.Unlock()
+	return err
+}
+
+// GetNodes returns a copy of the sorted nodes in the hash ring.
+func (np *NodePool) GetNodes() []string {
+	np.rwMut.RLock()
+	defer np.rwMut.RUnlock()
+	nodes := make([]string, len(np.preNodes))
+	copy(nodes, np.preNodes)
+	return nodes
+}
+
+// RingStats returns the stats of each node in the hash ring, it is empty
+// if the nodes have not been synced.
+func (np *NodePool) RingStats() map[string]consistenthash.KeyStat {
+	np.rwMut.RLock()
+	defer np.rwMut.RUnlock()
+	if np.nodes == nil {
+		return make(map[string]consistenthash.KeyStat)
+	}
+	return np.nodes.Stats()
+}
+
+// HashReplicas returns the virtual nodes of each node in the hash ring
+// per unit of weight.
+func (np *NodePool) HashReplicas() int {
+	np.rwMut.RLock()
+	defer np.rwMut.RUnlock()
+	return np.hashReplicas
+}
+
+// SetHashReplicas rebuilds the hash ring of the current nodes with
+// replicas virtual nodes of each node. The pool is upgrading until the next
+// sync, as the other nodes may still use the last replicas, then the owners
+// of the jobs are changed. The callback of NodePoolJobRebalancedCallback is
+// called for the jobs whose owner changed.
+func (np *NodePool) SetHashReplicas(replicas int) {
+	np.rwMut.Lock()
+	if replicas == np.hashReplicas {
+		np.rwMut.Unlock()
+		return
+	}
+	np.logger.Infof("set hash replicas from %d to %d", np.hashReplicas, replicas)
+	np.hashReplicas = replicas
+	oldRing := np.nodes
+	if oldRing == nil {
+		// the ring is built with it by the first sync.
+		np.rwMut.Unlock()
+		return
+	}
+	np.lastUpdateNodesTime.Store(time.Now())
+	np.state.Store(NodePoolStateUpgrade)
+	np.nodes = consistenthash.New(np.hashReplicas, np.hashFn)
+	for _, v := range np.preNodes {
+		np.nodes.AddWithWeight(v, driver.GetNodeWeight(v))
+	}
+	newRing := np.nodes
+	np.rwMut.Unlock()
+
+	np.notifyJobRebalanced(oldRing, newRing)
 }
 
 func (np *NodePool) GetNodeID() string {
@@ -141,6 +370,49 @@ func (np *NodePool) GetLastNodesUpdateTime() time.Time {
 	return np.lastUpdateNodesTime.Load().(time.Time)
 }
 
+type syncErr struct{ err error }
+
+func (np *NodePool) recordSync(err error) {
+	np.lastSyncErr.Store(syncErr{err})
+	if err == nil {
+		np.lastSyncTime.Store(time.Now())
+	}
+}
+
+// HealthCheck returns an error if the driver is not healthy, or the nodes
+// are not synced from the driver successfully in the last 2 update durations.
+func (np *NodePool) HealthCheck(ctx context.Context) error {
+	if hc, ok := np.driver.(driver.HealthChecker); ok {
+		ctx, cancel := np.driverContext(ctx)
+		defer cancel()
+		if err := hc.HealthCheck(ctx); err != nil {
+			return fmt.Errorf("%w: %v", ErrDriverUnhealthy, err)
+		}
+	}
+	lastSyncTime, ok := np.lastSyncTime.Load().(time.Time)
+	if !ok {
+		return ErrNodePoolNotSynced
+	}
+	if np.IsIsolated() {
+		lastErr, _ := np.lastSyncErr.Load().(syncErr)
+		return fmt.Errorf("%w: last synced %v ago, err=%v", ErrNodePoolNotSynced, time.Since(lastSyncTime), lastErr.err)
+	}
+	return nil
+}
+
+// IsIsolated returns true if the nodes have not been synced from the driver
+// successfully in the last 2 update durations, or never.
+func (np *NodePool) IsIsolated() bool {
+	lastSyncTime, ok := np.lastSyncTime.Load().(time.Time)
+	return !ok || time.Since(lastSyncTime) > 2*np.updateDuration
+}
+
+// IsSteady returns true once the pool came to steady for the first time,
+// which means this node has known the membership of the cluster.
+func (np *NodePool) IsSteady() bool {
+	return np.becameSteady.Load()
+}
+
 func (np *NodePool) getState() string {
 	return np.state.Load().(string)
 }
@@ -150,7 +422,10 @@ func (np *NodePool) waitingForHashRing() {
 	for {
 		select {
 		case <-tick.C:
-			nowNodes, err := np.driver.GetNodes(context.Background())
+			nowNodes, err := np.syncNodes(context.Background(), np.stopChan)
+			if err == errNodePoolStopped {
+				return
+			}
 			if err != nil {
 				np.logger.Errorf("get nodes error %v", err)
 				continue
@@ -162,23 +437,198 @@ func (np *NodePool) waitingForHashRing() {
 	}
 }
 
+// syncNodes gets the nodes from the driver, records the result of the sync
+// and reports it to the PoolUpdateObserver.
+func (np *NodePool) syncNodes(ctx context.Context, stop <-chan int) ([]string, error) {
+	start := time.Now()
+	nodes, err := np.getNodes(ctx, stop)
+	if err == errNodePoolStopped {
+		return nil, err
+	}
+	np.recordSync(err)
+	if np.poolUpdateObserver != nil {
+		np.poolUpdateObserver(time.Since(start), len(nodes), err)
+	}
+	return nodes, err
+}
+
+// getNodes gets the nodes from the driver, and retries on failure as
+// set by NodePoolDriverRetry. The retries are interrupted by stop.
+func (np *NodePool) getNodes(ctx context.Context, stop <-chan int) ([]string, error) {
+	for attempt := 1; ; attempt++ {
+		nodes, err := np.getNodesOnce(ctx)
+		if err == nil || attempt >= np.retryAttempts {
+			return nodes, err
+		}
+		delay := np.retryBaseDelay << (attempt - 1)
+		np.logger.Warnf("get nodes error, retry attempt=%d, delay=%v, err=%v", attempt, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return nil, errNodePoolStopped
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// getNodesOnce gets the nodes from the driver in the driver timeout.
+func (np *NodePool) getNodesOnce(ctx context.Context) ([]string, error) {
+	opCtx, cancel := np.driverContext(ctx)
+	defer cancel()
+	nodes, err := np.driver.GetNodes(opCtx)
+	if err != nil && ctx.Err() == nil && opCtx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("get nodes timed out after %v: %w", np.driverTimeout, context.DeadlineExceeded)
+	}
+	if err != nil || np.nodeFilter == nil {
+		return np.serviceNodes(nodes), err
+	}
+	return np.nodeFilter(opCtx, np.serviceNodes(nodes))
+}
+
+// serviceNodes drops the nodes of the other services from nodes. The
+// drivers find the nodes by the key prefix of the service, which is also a
+// prefix of the nodes of the services named like "<serviceName>:sub", so
+// two services sharing a driver would see the nodes of each other. Such
+// names are rejected by driver.CheckServiceName, but the nodes registered
+// by the older versions of dcron may still be listed. A node
+// of this service is the prefix of this node followed by a name without
+// ':', as the names of the nodes must not contain it.
+func (np *NodePool) serviceNodes(nodes []string) []string {
+	keyPre := driver.GetKeyPre(np.serviceName)
+	i := strings.LastIndex(np.nodeID, keyPre)
+	if i < 0 {
+		// the driver does not follow the format of the nodeID.
+		return nodes
+	}
+	prefix := np.nodeID[:i+len(keyPre)]
+	ret := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if strings.HasPrefix(node, prefix) && !strings.Contains(node[len(prefix):], ":") {
+			ret = append(ret, node)
+		}
+	}
+	return ret
+}
+
+// driverContext returns the context of a call to the driver, which is
+// bounded by the driver timeout.
+func (np *NodePool) driverContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if np.driverTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, np.driverTimeout)
+}
+
 func (np *NodePool) updateHashRing(nodes []string) {
+	sort.Strings(nodes)
 	np.rwMut.Lock()
-	defer np.rwMut.Unlock()
 	if np.equalRing(nodes) {
 		np.state.Store(NodePoolStateSteady)
+		np.becameSteady.Store(true)
 		np.logger.Infof("nowNodes=%v, preNodes=%v", nodes, np.preNodes)
+		np.pendingNodes = nil
+		np.rwMut.Unlock()
+		return
+	}
+	if np.debounceNodes(nodes) {
+		np.rwMut.Unlock()
 		return
 	}
 	np.lastUpdateNodesTime.Store(time.Now())
 	np.state.Store(NodePoolStateUpgrade)
 	np.logger.Infof("update hashRing nodes=%+v", nodes)
+	added, removed := diffNodes(np.preNodes, nodes)
+	oldRing := np.nodes
+	np.pendingNodes = nil
 	np.preNodes = make([]string, len(nodes))
 	copy(np.preNodes, nodes)
 	np.nodes = consistenthash.New(np.hashReplicas, np.hashFn)
 	for _, v := range nodes {
-		np.nodes.Add(v)
+		np.nodes.AddWithWeight(v, driver.GetNodeWeight(v))
+	}
+	newRing := np.nodes
+	np.rwMut.Unlock()
+
+	// callbacks are called without the lock,
+	// so that they can query the NodePool.
+	if np.nodeChangeCallback != nil {
+		np.nodeChangeCallback(added, removed)
+	}
+	np.notifyJobRebalanced(oldRing, newRing)
+}
+
+// notifyJobRebalanced calls jobRebalancedCallback for the jobs whose owner
+// changed from oldRing to newRing. Nothing is reported for the first ring.
+func (np *NodePool) notifyJobRebalanced(oldRing, newRing *consistenthash.Map) {
+	if np.jobRebalancedCallback == nil || np.jobNames == nil || oldRing == nil {
+		return
+	}
+	for _, jobName := range np.jobNames() {
+		oldOwner, newOwner := ringOwner(oldRing, jobName), ringOwner(newRing, jobName)
+		if oldOwner != newOwner {
+			np.jobRebalancedCallback(jobName, oldOwner, newOwner)
+		}
+	}
+}
+
+func ringOwner(ring *consistenthash.Map, jobName string) string {
+	if ring.IsEmpty() {
+		return ""
+	}
+	return ring.Get(jobName)
+}
+
+// diffNodes returns the nodes in now but not in pre, and in pre but not in now.
+func diffNodes(pre, now []string) (added, removed []string) {
+	preSet := make(map[string]struct{}, len(pre))
+	for _, v := range pre {
+		preSet[v] = struct{}{}
+	}
+	nowSet := make(map[string]struct{}, len(now))
+	for _, v := range now {
+		nowSet[v] = struct{}{}
+		if _, ok := preSet[v]; !ok {
+			added = append(added, v)
+		}
+	}
+	for _, v := range pre {
+		if _, ok := nowSet[v]; !ok {
+			removed = append(removed, v)
+		}
+	}
+	return
+}
+
+// debounceNodes returns true if the changed nodes should not be committed
+// to the hash ring yet. It must be called with rwMut locked.
+func (np *NodePool) debounceNodes(nodes []string) bool {
+	if np.updateDebounce <= 0 || len(np.preNodes) == 0 {
+		return false
+	}
+	if !equalNodes(np.pendingNodes, nodes) {
+		np.pendingNodes = make([]string, len(nodes))
+		copy(np.pendingNodes, nodes)
+		np.pendingSince = time.Now()
+		np.logger.Infof("nodes changed to %v, debounce for %v", nodes, np.updateDebounce)
+		return true
+	}
+	return time.Since(np.pendingSince) < np.updateDebounce
+}
+
+func equalNodes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
 	}
+	return true
 }
 
 func (np *NodePool) equalRing(a []string) bool {
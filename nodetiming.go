@@ -0,0 +1,77 @@
+package dcron
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libi/dcron/dlog"
+)
+
+// MinNodeUpdateDuration is the shortest node update duration, a shorter
+// positive one is raised to it, so the nodes do not sync with the driver
+// in a tight loop.
+const MinNodeUpdateDuration = 50 * time.Millisecond
+
+// The drivers keep a node alive by refreshing its heartbeat every half of
+// the heartbeat TTL, the heartbeat expires in the TTL after the node died.
+// Every node syncs the nodes from the driver once per node update duration,
+// a node which found the nodes changed stops running jobs until the next
+// sync returns the same nodes. So the jobs owned by a dead node are not run
+// for at most FailoverWindow.
+
+// heartbeatTTL returns the TTL of the heartbeat of this node in the driver,
+// which is the node update duration unless WithHeartbeatTTL is set.
+func (d *Dcron) heartbeatTTL() time.Duration {
+	if d.heartbeatTTLOverride != 0 {
+		return d.heartbeatTTLOverride
+	}
+	return d.nodeUpdateDuration
+}
+
+// validateHeartbeatTTL returns an error wrapping ErrUnsafeNodeUpdateDuration
+// if the TTL set by WithHeartbeatTTL is not longer than the node update
+// duration, with which it makes no difference.
+func (d *Dcron) validateHeartbeatTTL() error {
+	if d.heartbeatTTLOverride == 0 || d.heartbeatTTLOverride > d.nodeUpdateDuration {
+		return nil
+	}
+	return fmt.Errorf("%w: heartbeat TTL %v must be longer than the node update duration %v",
+		ErrUnsafeNodeUpdateDuration, d.heartbeatTTLOverride, d.nodeUpdateDuration)
+}
+
+// clampNodeUpdateDuration raises a positive updateDuration shorter than
+// MinNodeUpdateDuration to it, the others are returned as they are.
+func clampNodeUpdateDuration(updateDuration time.Duration, logger dlog.Logger) time.Duration {
+	if updateDuration > 0 && updateDuration < MinNodeUpdateDuration {
+		logger.Warnf("node update duration %v is too short, use %v", updateDuration, MinNodeUpdateDuration)
+		return MinNodeUpdateDuration
+	}
+	return updateDuration
+}
+
+// ValidateNodeUpdateDuration returns an error wrapping
+// ErrUnsafeNodeUpdateDuration if updateDuration is not positive, or it is
+// longer than heartbeatTTL, in which case the heartbeat of a live node may
+// expire between two syncs and the node is dropped from the cluster.
+func ValidateNodeUpdateDuration(updateDuration, heartbeatTTL time.Duration) error {
+	if updateDuration <= 0 {
+		return fmt.Errorf("%w: node update duration %v must be positive", ErrUnsafeNodeUpdateDuration, updateDuration)
+	}
+	if heartbeatTTL/2 <= 0 {
+		return fmt.Errorf("%w: heartbeat TTL %v is too short", ErrUnsafeNodeUpdateDuration, heartbeatTTL)
+	}
+	if updateDuration > heartbeatTTL {
+		return fmt.Errorf("%w: node update duration %v is longer than the heartbeat TTL %v",
+			ErrUnsafeNodeUpdateDuration, updateDuration, heartbeatTTL)
+	}
+	return nil
+}
+
+// FailoverWindow returns the upper bound of the time from a node died
+// to its jobs are run by the other nodes: the heartbeat of the node expires
+// in heartbeatTTL, the next sync finds it is gone in updateDuration, and
+// the sync after it makes the nodes steady in another updateDuration.
+// The retries set by WithDriverRetry are not counted.
+func FailoverWindow(updateDuration, heartbeatTTL time.Duration) time.Duration {
+	return heartbeatTTL + 2*updateDuration
+}
@@ -0,0 +1,127 @@
+package dcron
+
+import (
+	"context"
+	"time"
+)
+
+const onceJobKeyPre = "once:"
+
+func onceJobKey(epoch, jobName string) string {
+	return onceJobKeyPre + epoch + ":" + jobName
+}
+
+// AddOnceJob add a func which runs exactly once in the cluster, on the node
+// which owns jobName, after the node pool is steady. It is not scheduled.
+//
+// If the driver implements driver.KVDriver, the completion is recorded by
+// the service name, the epoch set by WithOnceJobEpoch and jobName, so the
+// restarted nodes will not run it again in the same epoch. Otherwise, it
+// runs once each time the owner node starts.
+//
+// The completion is recorded after cmd returned. If the owner node dies in
+// the middle of the run, the node which owns jobName after rebalancing runs
+// it again, so cmd should be idempotent. If cmd panics, the panic is logged
+// and the completion is not recorded, the job is not retried until restart.
+func (d *Dcron) AddOnceJob(jobName string, cmd func()) error {
+	if err := validateJob(jobName, cmd); err != nil {
+		return err
+	}
+	d.jobsRWMut.Lock()
+	defer d.jobsRWMut.Unlock()
+	if d.jobsFrozen() {
+		return ErrJobsFrozen
+	}
+	if _, ok := d.onceJobs[jobName]; ok {
+		return ErrJobExist
+	}
+	d.logger.Infof("addOnceJob '%s'", jobName)
+	d.onceJobs[jobName] = cmd
+	return nil
+}
+
+func (d *Dcron) pendingOnceJobs(done map[string]struct{}) map[string]func() {
+	d.jobsRWMut.RLock()
+	defer d.jobsRWMut.RUnlock()
+	pending := make(map[string]func())
+	for jobName, cmd := range d.onceJobs {
+		if _, ok := done[jobName]; !ok {
+			pending[jobName] = cmd
+		}
+	}
+	return pending
+}
+
+// runOnceJobs checks the once jobs each time the node pool is updated,
+// until dcron is stopped. The nodes keep checking the jobs which are not
+// completed, so the new owner picks up the job if the owner node dies.
+func (d *Dcron) runOnceJobs() {
+	ctx := d.runtimeContext()
+	tick := time.NewTicker(d.nodeUpdateDuration)
+	defer tick.Stop()
+	// the jobs which are completed or should not be checked anymore.
+	done := make(map[string]struct{})
+	for {
+		for jobName, cmd := range d.pendingOnceJobs(done) {
+			if d.checkOnceJob(ctx, jobName, cmd) {
+				done[jobName] = struct{}{}
+			}
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkOnceJob runs the once job if this node owns it and it is not
+// completed yet, it returns true if the job needs no more checks.
+func (d *Dcron) checkOnceJob(ctx context.Context, jobName string, cmd func()) bool {
+	kv, hasKV := d.kvDriver()
+	key := onceJobKey(d.onceJobEpoch, jobName)
+	if hasKV {
+		_, completed, err := kv.Get(ctx, key)
+		if err != nil {
+			d.logger.Errorf("get state of once job '%s' error, err=%v", jobName, err)
+			return false
+		}
+		if completed {
+			return true
+		}
+	}
+	if !d.runningLocally {
+		ok, err := d.checkJobAvailable(jobName)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	if !d.runOnceJob(jobName, cmd) {
+		return true
+	}
+	if !hasKV {
+		if !d.runningLocally {
+			d.logger.Warnf("driver is not a KVDriver, once job '%s' is not recorded", jobName)
+		}
+		return true
+	}
+	if err := kv.Set(ctx, key, time.Now().Format(time.RFC3339)); err != nil {
+		d.logger.Errorf("record once job '%s' error, err=%v", jobName, err)
+	}
+	return true
+}
+
+// runOnceJob runs the once job, it returns false if the job panics.
+func (d *Dcron) runOnceJob(jobName string, cmd func()) (ok bool) {
+	d.jobStarted(jobName)
+	defer d.jobFinished(jobName)
+	defer func() {
+		if r := recover(); r != nil {
+			d.logger.Errorf("once job '%s' panic: %v", jobName, r)
+			ok = false
+		}
+	}()
+	d.logger.Infof("run once job '%s'", jobName)
+	cmd()
+	return true
+}
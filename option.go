@@ -20,13 +20,63 @@ func WithLogger(logger dlog.Logger) Option {
 	}
 }
 
-// WithNodeUpdateDuration set node update duration
+// WithLogLevel drops the messages of the dcron and cron logger below level,
+// e.g. dlog.LevelError keeps only the errors. The loggers passed to the
+// cron wrappers like cron.SkipIfStillRunning are not affected, wrap them
+// with dlog.WithLevel. The default loggers also respect DCRON_LOG_LEVEL.
+func WithLogLevel(level dlog.Level) Option {
+	return func(dcron *Dcron) {
+		dcron.logLevel = level
+	}
+}
+
+// WithSuppressedLogs mutes the categories of the messages of the wrappers,
+// e.g. cron.LogDelay|cron.LogSkip for the jobs which are expected to run
+// long, while the panics are still logged. Like WithLogLevel, it applies
+// to the dcron and cron logger, which is used by WithPanicPolicy, wrap
+// the loggers passed to the wrappers in CronOptionChain with
+// cron.SuppressLogs.
+func WithSuppressedLogs(categories cron.LogCategory) Option {
+	return func(dcron *Dcron) {
+		dcron.suppressedLogs |= categories
+	}
+}
+
+// WithNodeUpdateDuration set node update duration, which is also the TTL
+// of the heartbeat of this node in the driver unless WithHeartbeatTTL is
+// set. The default is 3 seconds. It must be positive, or Start refuses to
+// start, see Err, and a duration shorter than MinNodeUpdateDuration is
+// raised to it. The jobs of a dead node are moved to the other nodes in
+// FailoverWindow.
 func WithNodeUpdateDuration(d time.Duration) Option {
 	return func(dcron *Dcron) {
 		dcron.nodeUpdateDuration = d
 	}
 }
 
+// WithHeartbeatTTL set the TTL of the heartbeat of this node in the driver
+// separately from the node update duration, so the nodes are synced often
+// while a node paused longer than the node update duration, e.g. by a long
+// GC, is not dropped from the cluster. The jobs of a dead node are moved
+// later, see FailoverWindow. It must be longer than the node update
+// duration, or Start refuses to start, see Err.
+func WithHeartbeatTTL(ttl time.Duration) Option {
+	return func(dcron *Dcron) {
+		dcron.heartbeatTTLOverride = ttl
+	}
+}
+
+// WithClock set the source of time of the scheduler and the job runs,
+// e.g. a testclock.Clock to advance the time manually in the tests.
+// Use cron.DelayIfStillRunningWithClock to measure the delays by it.
+// The node pool and the drivers still use the wall clock.
+func WithClock(clock cron.Clock) Option {
+	return func(dcron *Dcron) {
+		dcron.clock = clock
+		dcron.crOptions = append(dcron.crOptions, cron.WithClock(clock))
+	}
+}
+
 // WithHashReplicas set hashReplicas
 func WithHashReplicas(d int) Option {
 	return func(dcron *Dcron) {
@@ -34,30 +84,488 @@ func WithHashReplicas(d int) Option {
 	}
 }
 
-// CronOptionLocation is warp cron with location
-func CronOptionLocation(loc *time.Location) Option {
+// WithNodeID set a stable identity of this node, e.g. the pod name of a
+// StatefulSet, instead of a random uuid. The nodeID advertised to the driver
+// and used in the consistent hash is the service key prefix followed by id.
+// The id must be unique in the service, if another node has registered it,
+// the driver fails to start with driver.ErrNodeIDExist.
+func WithNodeID(id string) Option {
+	return func(dcron *Dcron) {
+		dcron.nodeName = id
+	}
+}
+
+// WithHashFn set the hash function of the consistent hash ring,
+// e.g. consistenthash.FNV1a, xxhash or fnv. The default is crc32.
+// All nodes of a cluster must use the same hash function,
+// changing it re-shuffles the owners of jobs.
+func WithHashFn(fn func(data []byte) uint32) Option {
+	return func(dcron *Dcron) {
+		dcron.hashFn = fn
+	}
+}
+
+// WithNodeWeight set the weight of this node, this node will own
+// about weight times of jobs than the node whose weight is 1.
+// The weight is advertised through the driver, 0 means the default weight.
+func WithNodeWeight(w int) Option {
+	return func(dcron *Dcron) {
+		dcron.nodeWeight = w
+	}
+}
+
+// WithKeyPrefix set the prefix of all keys in the driver,
+// it is used to isolate the clusters which share the same storage.
+func WithKeyPrefix(prefix string) Option {
 	return func(dcron *Dcron) {
+		dcron.keyPrefix = prefix
+	}
+}
+
+// WithNodeChangeCallback set the callback which is called when nodes
+// join or leave the cluster, it runs in the NodePool update loop,
+// so it must not block.
+func WithNodeChangeCallback(fn NodeChangeCallback) Option {
+	return func(dcron *Dcron) {
+		dcron.nodeChangeCallback = fn
+	}
+}
+
+// WithOnRegister set the callback which is called once this node is
+// registered in the driver by Start, e.g. to update an external service
+// registry. It is called in Start with the nodeID of this node, before
+// any job runs. Unlike WithNodeChangeCallback, it is only about this node.
+func WithOnRegister(fn func(nodeID string)) Option {
+	return func(dcron *Dcron) {
+		dcron.onRegister = fn
+	}
+}
+
+// WithOnDeregister set the callback which is called once this node is
+// deregistered from the driver by Stop, including the Stop by the done
+// context of NewDcronWithContext. It is called in Stop, so it must not
+// call Stop. If the driver is unreachable, it is still called, the
+// heartbeat of this node expires in the driver then.
+func WithOnDeregister(fn func(nodeID string)) Option {
+	return func(dcron *Dcron) {
+		dcron.onDeregister = fn
+	}
+}
+
+// WithJobSetCheck makes the nodes check that all of them have added the
+// same set of job names, since a job added only in some nodes never runs
+// once they left. The hash of the job names of each node is advertised in
+// the driver, which must implement driver.KVDriver. A divergence is logged,
+// and fn is called with the diverged nodes if it is not nil, fn must not
+// block.
+func WithJobSetCheck(fn JobSetDivergedHandler) Option {
+	return func(dcron *Dcron) {
+		dcron.jobSetCheck = true
+		dcron.jobSetDivergedHandler = fn
+	}
+}
+
+// WithNodeGeneration makes each start of this node claim a generation, an
+// incarnation number greater than the last one advertised for its nodeID
+// in the driver, which must implement driver.KVDriver. It is meant for the
+// stable nodeID set by WithNodeID: in a rolling restart, an old incarnation
+// which comes back from a pause heartbeats the same nodeID as the new one.
+// Once it sees the greater generation, within a node update duration, it
+// stops running jobs and being the leader, and HealthCheck returns
+// ErrStaleGeneration, so the fresh incarnation owns the jobs of the nodeID
+// alone. A stale incarnation stays stale until it is started again. The
+// fresh incarnation takes over the nodeID even if the heartbeat of the old
+// one has not expired, see driver.TakeOverOption.
+func WithNodeGeneration() Option {
+	return func(dcron *Dcron) {
+		dcron.nodeGeneration = true
+	}
+}
+
+// WithClockSkewWarning makes the nodes check the skew between their clocks,
+// which may make a job run twice or be missed around its scheduled time.
+// The time of each node is advertised in the driver, which must implement
+// driver.KVDriver, and a node whose clock differs from this node by more
+// than threshold is logged at Warn level. Since the time is advertised once
+// per node update duration, a node behind this node is reported only if it
+// is behind by more than threshold plus the node update duration.
+func WithClockSkewWarning(threshold time.Duration) Option {
+	return func(dcron *Dcron) {
+		dcron.clockSkewThreshold = threshold
+	}
+}
+
+// WithClockSkewCallback set the callback which is called with the skewed
+// node found by WithClockSkewWarning, it must not block.
+func WithClockSkewCallback(fn ClockSkewCallback) Option {
+	return func(dcron *Dcron) {
+		dcron.clockSkewCallback = fn
+	}
+}
+
+// WithOwnershipConflictDetection makes the nodes cross-check once per
+// interval that no job is owned by two nodes at the same time, which
+// detects a split brain or the nodes hashing the jobs differently before
+// the jobs run twice. The names of the jobs owned by each node are
+// advertised in the driver, which must implement driver.KVDriver, and each
+// node compares its jobs with the other nodes in the node pool, so a
+// conflict is reported by each claiming node. Since the nodes see a change
+// of the node pool at slightly different times, a conflict is only logged
+// at Warn level once it is found by two checks in a row. The broadcast jobs
+// are not checked. A non-positive interval disables the detection.
+func WithOwnershipConflictDetection(interval time.Duration) Option {
+	return func(dcron *Dcron) {
+		dcron.ownershipConflictInterval = interval
+	}
+}
+
+// WithOwnershipConflictCallback set the callback which is called with the
+// conflicts found by WithOwnershipConflictDetection, it must not block.
+func WithOwnershipConflictCallback(fn OwnershipConflictCallback) Option {
+	return func(dcron *Dcron) {
+		dcron.ownershipConflictCallback = fn
+	}
+}
+
+// WithPoolUpdateObserver set the observer which is called after each sync of
+// the nodes from the driver with its duration, the number of the nodes and
+// its error, a slow or failing driver shows up here before the jobs are
+// affected. It runs in the NodePool update loop, so it must not block.
+func WithPoolUpdateObserver(fn PoolUpdateObserver) Option {
+	return func(dcron *Dcron) {
+		dcron.poolUpdateObserver = fn
+	}
+}
+
+// WithLateRunObserver set the observer which is called when a job starts in
+// this node later than its scheduled time by more than threshold, e.g.
+// because of a long GC, an overloaded node or the backlog of
+// cron.DelayIfStillRunning, which is a sign of an overloaded node before the
+// jobs fail. It is only called in the node which runs the job, before the
+// jitter set by WithJobJitter. It runs in the goroutine of the job, so it
+// should not block.
+func WithLateRunObserver(threshold time.Duration, fn LateRunObserver) Option {
+	return func(dcron *Dcron) {
+		dcron.lateRunThreshold = threshold
+		dcron.lateRunObserver = fn
+	}
+}
+
+// WithExpiredJobHandler set the handler which is called once for each job
+// whose schedule never fires again, so a misconfigured schedule is
+// surfaced instead of sitting in the scheduler forever. A schedule has no
+// next run if:
+//
+//   - a cron spec matches no time in the next five years, e.g. "0 0 30 2 *"
+//     for February 30th.
+//   - a schedule of the parser set by WithParser returns the zero time from
+//     Next, e.g. a one-time schedule after it fired.
+//
+// The descriptors like @daily and @every always have a next run. The jobs
+// are checked on start and then once per node update duration, in every
+// node, so fn is called in every node. The job is kept unless
+// WithRemoveExpiredJobs is set.
+func WithExpiredJobHandler(fn func(jobName string)) Option {
+	return func(dcron *Dcron) {
+		dcron.expiredJobHandler = fn
+	}
+}
+
+// WithRemoveExpiredJobs removes the jobs whose schedule never fires again,
+// see WithExpiredJobHandler for when they are found. The handler set by
+// WithExpiredJobHandler is called after the job is removed.
+func WithRemoveExpiredJobs() Option {
+	return func(dcron *Dcron) {
+		dcron.removeExpiredJobs = true
+	}
+}
+
+// WithGracefulHandover makes the new owner of a job wait for the run in the
+// last owner to finish before it runs the job, when the ownership moves in
+// the middle of a run, e.g. a node joins. The node running a job holds a
+// lock of the job in the driver during the run, refreshed every half of the
+// heartbeat TTL, and a node runs the job only after it acquires the lock.
+// If the last owner dies in the run, the lock expires with its heartbeat
+// TTL, and then the new owner runs. So the runs of a job do not overlap
+// across the nodes, the runs in the same node still may.
+//
+// The driver must be a driver.LockDriver, or it has no effect, and a
+// driver.LockRefresher for the runs longer than the heartbeat TTL. A run
+// waiting for the lock is dropped if dcron is stopped.
+func WithGracefulHandover() Option {
+	return func(dcron *Dcron) {
+		dcron.gracefulHandover = true
+	}
+}
+
+// WithPoolUpdateDebounce rebalances the jobs only after the nodes are
+// unchanged for d, so the nodes joining one by one in a rolling deployment
+// rebalance the jobs once instead of each time a node joins. The jobs run by
+// the last nodes in the meantime, so the jobs of a dead node are not run
+// for d longer.
+func WithPoolUpdateDebounce(d time.Duration) Option {
+	return func(dcron *Dcron) {
+		dcron.poolUpdateDebounce = d
+	}
+}
+
+// WithJobJitter delays each run of the jobs by up to max, to avoid the jobs
+// scheduled at the same time hammering the downstream together.
+// The delay is computed from the job name, so the same job always splays
+// the same way. The delay is interrupted by Stop, and the run is dropped.
+func WithJobJitter(max time.Duration) Option {
+	return func(dcron *Dcron) {
+		dcron.jobJitter = max
+	}
+}
+
+// WithExecutionLock acquires a distributed lock of ttl by the job name and
+// the scheduled time before each run, and skips the run if the lock is held
+// by another node, which happens in a split-brain when two nodes both think
+// they own the job. The lock is released after the run, but held for at
+// least 2 seconds against the clock skew between the nodes. ttl bounds the
+// lock if the node dies during the run, it should be the expected max
+// runtime of the jobs. The driver must implement driver.LockDriver.
+func WithExecutionLock(ttl time.Duration) Option {
+	return func(dcron *Dcron) {
+		dcron.executionLockTTL = ttl
+	}
+}
+
+// WithDedupKeyFunc set how the key of the execution lock set by
+// WithExecutionLock is formed from the job name and the scheduled time,
+// e.g. TruncatedDedupKey(time.Minute). The default is the job name and the
+// scheduled time in seconds.
+func WithDedupKeyFunc(fn DedupKeyFunc) Option {
+	return func(dcron *Dcron) {
+		dcron.dedupKeyFunc = fn
+	}
+}
+
+// WithMaintenanceWindow skip all the runs of the jobs in the maintenance
+// window which starts when spec fires and lasts for duration, e.g.
+// WithMaintenanceWindow("0 2 * * *", time.Hour) for a nightly backup from
+// 2am. The spec is parsed and interpreted in the time zone as the specs of
+// the jobs, so all the nodes agree on the window without coordination. It
+// can be set more than once for more windows. The skipped runs are not
+// queued unless WithMaintenanceCatchUp is set, and TriggerJob is also
+// skipped in the window.
+func WithMaintenanceWindow(spec string, duration time.Duration) Option {
+	return func(dcron *Dcron) {
+		dcron.maintenanceWindows = append(dcron.maintenanceWindows, maintenanceWindow{spec: spec, duration: duration})
+	}
+}
+
+// WithMaintenanceCatchUp runs each job skipped in a maintenance window
+// once after the window ends, however many runs of it are skipped.
+func WithMaintenanceCatchUp() Option {
+	return func(dcron *Dcron) {
+		dcron.maintenanceCatchUp = true
+	}
+}
+
+// WithCatchUp runs the runs of the jobs missed while no node ran them, e.g.
+// the whole cluster was down at 2am when a daily job should fire. After
+// Start, when the node pool is settled, the node which owns a job computes
+// the runs which should have fired between the last successful run of the
+// job recorded in the driver (see LastRunTime) and Start, and runs them one
+// by one, the last max of them, so a long outage does not cause a storm of
+// runs. A job which has never been recorded does not catch up. Only the
+// successful runs are recorded, so the runs after the last successful run
+// of a failing job are retried by the catch-up of every Start. The driver
+// must implement driver.KVDriver. The catch-up runs go through the wrapper
+// chain as the runs triggered by TriggerJob do, so with WithExecutionLock the
+// catch-up runs of a job in the same second are deduplicated into one.
+func WithCatchUp(max int) Option {
+	return func(dcron *Dcron) {
+		dcron.catchUpMax = max
+	}
+}
+
+// WithScanBatchSize set the COUNT of each SCAN of the redis driver to list
+// the nodes, e.g. 1000 in a redis shared with many keys, see
+// driver.NewScanBatchSizeOption. The other drivers ignore it.
+func WithScanBatchSize(n int) Option {
+	return func(dcron *Dcron) {
+		dcron.scanBatchSize = n
+	}
+}
+
+// WithNodeLabels set the labels of this node, e.g. {"region": "eu"}, which
+// are matched by the selectors of the jobs, see AddJobWithSelector.
+func WithNodeLabels(labels map[string]string) Option {
+	return func(dcron *Dcron) {
+		dcron.nodeLabels = make(map[string]string, len(labels))
+		for k, v := range labels {
+			dcron.nodeLabels[k] = v
+		}
+	}
+}
+
+// WithFreezeJobsOnStart rejects the jobs added while dcron is running
+// with ErrJobsFrozen, for the deployments which declare all the jobs
+// before Start, so a late registration will not rebalance the jobs silently.
+func WithFreezeJobsOnStart() Option {
+	return func(dcron *Dcron) {
+		dcron.freezeJobsOnStart = true
+	}
+}
+
+// WithRejectShortIntervals rejects the jobs which fire more often than once
+// per node update duration, e.g. "@every 100ms", with ErrIntervalTooShort,
+// instead of the warning logged by default. After the nodes changed, the
+// nodes may disagree on the owner of a job for up to a node update
+// duration, the runs in it can not be reliably deduplicated, so the node
+// update duration is the practical lower bound of the interval of a job.
+func WithRejectShortIntervals() Option {
+	return func(dcron *Dcron) {
+		dcron.rejectShortIntervals = true
+	}
+}
+
+// WithVerboseOwnershipLogging logs each time a job fires, whether this node
+// runs it or skips it, with the owner of the job in the hash ring. It logs
+// a line per job per tick in every node, so it is meant for debugging.
+func WithVerboseOwnershipLogging() Option {
+	return func(dcron *Dcron) {
+		dcron.verboseOwnership = true
+	}
+}
+
+// WithHistorySize keeps the latest n runs of each job in this node,
+// which can be queried by JobHistory. The history is disabled if n is 0,
+// which is the default.
+func WithHistorySize(n int) Option {
+	return func(dcron *Dcron) {
+		dcron.historySize = n
+	}
+}
+
+// WithDriverRetry retries a failed sync of the nodes from the driver up to
+// maxAttempts times in total, sleeping baseDelay, 2*baseDelay, 4*baseDelay...
+// between the attempts, before the sync is considered failed and reported by
+// HealthCheck. While the driver is unreachable this node keeps the ownership
+// of the last synced nodes, a node is dropped only when the driver returns
+// the nodes without it, e.g. its heartbeat is expired.
+func WithDriverRetry(maxAttempts int, baseDelay time.Duration) Option {
+	return func(dcron *Dcron) {
+		dcron.driverRetry = maxAttempts
+		dcron.driverRetryDelay = baseDelay
+	}
+}
+
+// WithDriverTimeout bounds each call to the driver by d, e.g. each attempt
+// of the sync of the nodes and each read of the paused jobs, so a hung
+// driver fails the call, which is logged and retried, instead of blocking
+// forever. While the sync is failing, this node keeps the last synced
+// nodes, subject to WithIsolationPolicy. The default is the node update
+// duration. The heartbeats sent by the drivers themselves are bounded by
+// the drivers.
+func WithDriverTimeout(d time.Duration) Option {
+	return func(dcron *Dcron) {
+		dcron.driverTimeout = d
+	}
+}
+
+// WithIsolationPolicy set what this node runs when it can not sync the
+// nodes from the driver, see IsolationPolicy. It is checked each time a
+// job fires, the default is IsolationKeepLast.
+func WithIsolationPolicy(policy IsolationPolicy) Option {
+	return func(dcron *Dcron) {
+		dcron.isolationPolicy = policy
+	}
+}
+
+// WithPinFallback set where a job added by AddPinnedJob runs when the node
+// which it is pinned to is not in the node pool, see PinFallback.
+// The default is PinFallbackHashOwner.
+func WithPinFallback(fallback PinFallback) Option {
+	return func(dcron *Dcron) {
+		dcron.pinFallback = fallback
+	}
+}
+
+// WithOnceJobEpoch set the epoch of the jobs added by AddOnceJob,
+// e.g. the version of the deployment. A once job runs again
+// when the epoch is changed.
+func WithOnceJobEpoch(epoch string) Option {
+	return func(dcron *Dcron) {
+		dcron.onceJobEpoch = epoch
+	}
+}
+
+// WithLocation set the time zone of the scheduler for all the jobs, e.g.
+// time.UTC, so "0 3 * * *" fires at 3am in loc in every node regardless of
+// the time zone of the host. The default is time.Local. A job added by
+// AddJobWithTimezone or with a CRON_TZ= prefix keeps its own time zone.
+// If loc is nil, Err returns ErrNilLocation and dcron refuses to start.
+func WithLocation(loc *time.Location) Option {
+	return func(dcron *Dcron) {
+		if loc == nil {
+			dcron.optionErr = ErrNilLocation
+			return
+		}
 		f := cron.WithLocation(loc)
 		dcron.crOptions = append(dcron.crOptions, f)
 	}
 }
 
-// CronOptionSeconds is warp cron with seconds
-func CronOptionSeconds() Option {
+// CronOptionLocation is warp cron with location, it is the same as WithLocation.
+func CronOptionLocation(loc *time.Location) Option {
+	return WithLocation(loc)
+}
+
+// WithSeconds enables the seconds field of the cron specs. By default a spec
+// has 5 fields, "minute hour dom month dow", with this option it must have 6
+// fields with the seconds at first, e.g. "*/5 * * * * *". The descriptors like
+// "@hourly" and "@every 30s" are accepted either way. Adding a job whose spec
+// does not match returns ErrInvalidCronSpec.
+func WithSeconds() Option {
 	return func(dcron *Dcron) {
 		f := cron.WithSeconds()
 		dcron.crOptions = append(dcron.crOptions, f)
 	}
 }
 
-// CronOptionParser is warp cron with schedules.
-func CronOptionParser(p cron.ScheduleParser) Option {
+// CronOptionSeconds is warp cron with seconds, it is the same as WithSeconds.
+func CronOptionSeconds() Option {
+	return WithSeconds()
+}
+
+// WithParser set the parser of the cron specs of all the jobs, e.g. a
+// cron.Parser built by cron.NewParser with the fields and the descriptors
+// needed, or a custom cron.ScheduleParser. It replaces the parser set by
+// WithSeconds, the last one wins. If p is nil, Err returns ErrNilParser
+// and dcron refuses to start.
+func WithParser(p cron.ScheduleParser) Option {
 	return func(dcron *Dcron) {
+		if p == nil {
+			dcron.optionErr = ErrNilParser
+			return
+		}
 		f := cron.WithParser(p)
 		dcron.crOptions = append(dcron.crOptions, f)
 	}
 }
 
+// CronOptionParser is warp cron with schedules, it is the same as WithParser.
+func CronOptionParser(p cron.ScheduleParser) Option {
+	return WithParser(p)
+}
+
+// WithPanicPolicy recovers the panics of all the jobs and handles them by
+// policy, e.g. cron.PanicRethrow to crash the process, so the orchestrator
+// restarts it, instead of continuing in a possibly corrupt state. The panic
+// is logged with its stack, then handler is called if it is not nil.
+// It wraps the chain set by CronOptionChain, which must not have a
+// cron.Recover.
+func WithPanicPolicy(policy cron.PanicPolicy, handler cron.PanicHandler) Option {
+	return func(dcron *Dcron) {
+		f := cron.WithPanicPolicy(policy, handler)
+		dcron.crOptions = append(dcron.crOptions, f)
+	}
+}
+
 // CronOptionChain is Warp cron with chain
 func CronOptionChain(wrappers ...cron.JobWrapper) Option {
 	return func(dcron *Dcron) {
@@ -87,3 +595,10 @@ func RunningLocally() Option {
 		d.runningLocally = true
 	}
 }
+
+// WithMetrics set the collector of dcron metrics.
+func WithMetrics(collector MetricsCollector) Option {
+	return func(d *Dcron) {
+		d.metrics = collector
+	}
+}
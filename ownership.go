@@ -0,0 +1,142 @@
+package dcron
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/libi/dcron/driver"
+)
+
+const ownershipKeyPre = "ownership:"
+
+func ownershipKey(nodeID string) string {
+	return ownershipKeyPre + nodeID
+}
+
+// OwnershipConflictCallback is called when the job of jobName is claimed
+// by this node and the other nodes at the same time, nodeIDs is the sorted
+// nodeIDs of all the claiming nodes, including this node.
+type OwnershipConflictCallback func(jobName string, nodeIDs []string)
+
+// ownedJobNames returns the sorted names of the jobs owned by this node,
+// the broadcast jobs are owned by every node and are left out.
+func (d *Dcron) ownedJobNames() []string {
+	names := make([]string, 0)
+	for _, job := range d.GetJobs(true) {
+		if !d.isBroadcastJob(job.Name) {
+			names = append(names, job.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkOwnershipConflicts advertises the names of the jobs owned by this
+// node in the driver once per interval, and compares them with the jobs
+// owned by the other nodes in the node pool, see
+// WithOwnershipConflictDetection. The names are deleted when dcron is
+// stopped.
+func (d *Dcron) checkOwnershipConflicts() {
+	defer d.advertisers.Done()
+	kv, ok := d.kvDriver()
+	if !ok {
+		d.logger.Warnf("driver is not a KVDriver, the ownership conflicts of the jobs are not checked")
+		return
+	}
+	ctx := d.runtimeContext()
+	nodeID := d.nodePool.GetNodeID()
+	defer func() {
+		delCtx, cancel := context.WithTimeout(context.Background(), d.nodeUpdateDuration)
+		defer cancel()
+		if err := kv.Del(delCtx, ownershipKey(nodeID)); err != nil {
+			d.logger.Errorf("delete the owned jobs of this node error, err=%v", err)
+		}
+	}()
+	tick := time.NewTicker(d.ownershipConflictInterval)
+	defer tick.Stop()
+	// the conflicts found by the last check, a conflict is only reported
+	// if it is found by two checks in a row, since the nodes see a change
+	// of the node pool at slightly different times. reported is the
+	// conflicts reported, they are reported again after they are gone.
+	var last map[string][]string
+	reported := make(map[string][]string)
+	for {
+		conflicts, err := d.ownershipConflicts(ctx, kv, nodeID)
+		if err != nil {
+			d.logger.Errorf("check the ownership conflicts of the jobs error, err=%v", err)
+		} else {
+			for jobName, nodes := range conflicts {
+				if !equalNodes(nodes, last[jobName]) || equalNodes(nodes, reported[jobName]) {
+					continue
+				}
+				reported[jobName] = nodes
+				d.logger.Warnf("job '%s' is claimed by nodes %v at the same time, "+
+					"it may run twice, the nodes may be split or hashed differently", jobName, nodes)
+				if d.ownershipConflictCallback != nil {
+					d.ownershipConflictCallback(jobName, nodes)
+				}
+			}
+			for jobName := range reported {
+				if _, ok := conflicts[jobName]; !ok {
+					delete(reported, jobName)
+				}
+			}
+			last = conflicts
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ownershipConflicts advertises the jobs owned by this node and returns
+// the jobs among them which are owned by the other nodes too, with the
+// sorted nodeIDs of all the owners.
+func (d *Dcron) ownershipConflicts(ctx context.Context, kv driver.KVDriver, nodeID string) (map[string][]string, error) {
+	names := d.ownedJobNames()
+	value, err := json.Marshal(names)
+	if err != nil {
+		return nil, err
+	}
+	if err = kv.Set(ctx, ownershipKey(nodeID), string(value)); err != nil {
+		return nil, err
+	}
+	owners := make(map[string][]string, len(names))
+	for _, name := range names {
+		owners[name] = []string{nodeID}
+	}
+	for _, node := range d.nodePool.GetNodes() {
+		if node == nodeID {
+			continue
+		}
+		value, ok, err := kv.Get(ctx, ownershipKey(node))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		var claimed []string
+		if err = json.Unmarshal([]byte(value), &claimed); err != nil {
+			d.logger.Errorf("invalid owned jobs of node %s, err=%v", node, err)
+			continue
+		}
+		for _, name := range claimed {
+			if nodes, ok := owners[name]; ok {
+				owners[name] = append(nodes, node)
+			}
+		}
+	}
+	conflicts := make(map[string][]string)
+	for name, nodes := range owners {
+		if len(nodes) > 1 {
+			sort.Strings(nodes)
+			conflicts[name] = nodes
+		}
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,110 @@
+package dcron
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/libi/dcron/cron"
+)
+
+// ErrPinnedNodeAbsent is returned by GetJobOwnerNode if the node which the
+// job is pinned to is not in the node pool, and the fallback policy is
+// PinFallbackSkip.
+var ErrPinnedNodeAbsent = errors.New("the node which the job is pinned to is absent")
+
+// ErrEmptyPinnedNode is returned by AddPinnedJob if the nodeID is empty.
+var ErrEmptyPinnedNode = errors.New("the node which the job is pinned to is empty")
+
+// PinFallback decides where a pinned job runs when the node which it is
+// pinned to is not in the node pool, see AddPinnedJob.
+type PinFallback int
+
+const (
+	// PinFallbackHashOwner runs the job in the node which owns it by the
+	// hash ring, as if it is not pinned. It is the default.
+	PinFallbackHashOwner PinFallback = iota
+	// PinFallbackSkip runs the job nowhere, the runs are missed until the
+	// pinned node joins the node pool again.
+	PinFallbackSkip
+)
+
+func (p PinFallback) String() string {
+	switch p {
+	case PinFallbackHashOwner:
+		return "HashOwner"
+	case PinFallbackSkip:
+		return "Skip"
+	}
+	return "Unknown"
+}
+
+// AddPinnedJob add a cron func which only runs in the node of nodeID,
+// e.g. the node which has the GPU, regardless of the hash ring. nodeID is
+// the full ID returned by NodeID, use WithNodeID to make it stable across
+// restarts. If the node is not in the node pool, the job runs by the policy
+// set by WithPinFallback.
+func (d *Dcron) AddPinnedJob(jobName, cronStr string, nodeID string, cmd func()) error {
+	if nodeID == "" {
+		return ErrEmptyPinnedNode
+	}
+	return d.addMarkedJob(jobName, cronStr, cron.FuncJob(cmd), &d.pinnedJobs, nodeID)
+}
+
+// jobOwner returns the node which runs the job, it is the pinned node of
+// the job if it is in the node pool, otherwise the fallback decides. The
+// job with a selector is owned by the hash ring of the matching nodes, and
+// the broadcast job is owned by every node, so this node is returned.
+func (d *Dcron) jobOwner(jobName string) (string, error) {
+	if d.isBroadcastJob(jobName) {
+		return d.nodePool.GetNodeID(), nil
+	}
+	owner, err := d.nodePool.GetJobOwner(jobName)
+	if err != nil {
+		return "", err
+	}
+	if selector, ok := d.selectorJobs.Load(jobName); ok {
+		return d.selectorOwner(jobName, selector.(map[string]string))
+	}
+	pinned, ok := d.pinnedJobs.Load(jobName)
+	if !ok {
+		return owner, nil
+	}
+	nodes := d.nodePool.GetNodes()
+	if i := sort.SearchStrings(nodes, pinned.(string)); i < len(nodes) && nodes[i] == pinned.(string) {
+		return pinned.(string), nil
+	}
+	if d.pinFallback == PinFallbackSkip {
+		return "", ErrPinnedNodeAbsent
+	}
+	return owner, nil
+}
+
+// checkJobAvailable returns true if the job runs in this node, it is
+// NodePool.CheckJobAvailable which respects the pinned jobs and the jobs
+// with selectors. No job runs in a stale incarnation, see WithNodeGeneration,
+// or in a cordoned node, see Cordon.
+func (d *Dcron) checkJobAvailable(jobName string) (bool, error) {
+	if d.isStaleGeneration() || d.IsCordoned() {
+		return false, nil
+	}
+	if d.isBroadcastJob(jobName) {
+		return true, nil
+	}
+	_, pinned := d.pinnedJobs.Load(jobName)
+	_, selected := d.selectorJobs.Load(jobName)
+	if !pinned && !selected {
+		return d.nodePool.CheckJobAvailable(jobName)
+	}
+	owner, err := d.jobOwner(jobName)
+	if errors.Is(err, ErrNoMatchingNode) {
+		d.logger.Warnf("no node matches the selector of job '%s', it does not run", jobName)
+		return false, nil
+	}
+	if errors.Is(err, ErrNodePoolIsEmpty) || errors.Is(err, ErrPinnedNodeAbsent) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return owner == d.nodePool.GetNodeID(), nil
+}
@@ -0,0 +1,46 @@
+package dcron
+
+import (
+	"time"
+)
+
+// AddAdaptiveJob add a cron func which decides when it runs next, e.g. a
+// job draining a queue backs off when the queue is empty and speeds up when
+// there is a backlog. If cmd returns a non-nil duration, the next run is
+// that long after cmd returned instead of the next time of cronStr, once,
+// the runs after it follow cronStr again unless cmd overrides them too.
+// The override is kept by the scheduler of this node, the node which runs
+// the job is still decided when it fires, so if the job moves to another
+// node, the new owner follows cronStr.
+func (d *Dcron) AddAdaptiveJob(jobName, cronStr string, cmd func() *time.Duration) error {
+	if err := validateJob(jobName, cmd); err != nil {
+		return err
+	}
+	_, err := d.addJob(jobName, cronStr, nil, adaptiveJob(func() {
+		if delay := cmd(); delay != nil {
+			d.rescheduleOnce(jobName, *delay)
+		}
+	}))
+	return err
+}
+
+// adaptiveJob is the job of AddAdaptiveJob, it is not a cron.FuncJob, so
+// ReplaceJob does not replace it with a plain func.
+type adaptiveJob func()
+
+func (f adaptiveJob) Run() { f() }
+
+// rescheduleOnce makes the job run delay later from now, once.
+func (d *Dcron) rescheduleOnce(jobName string, delay time.Duration) {
+	d.jobsRWMut.RLock()
+	job, ok := d.jobs[jobName]
+	d.jobsRWMut.RUnlock()
+	if !ok {
+		return
+	}
+	if err := d.cr.RescheduleOnce(job.ID, d.clock.Now().Add(delay)); err != nil {
+		d.logger.Errorf("reschedule job '%s' error, it follows the schedule, err=%v", jobName, err)
+		return
+	}
+	d.logger.Infof("job '%s' runs again in %v", jobName, delay)
+}
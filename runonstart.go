@@ -0,0 +1,99 @@
+package dcron
+
+import (
+	"context"
+	"time"
+
+	"github.com/libi/dcron/cron"
+)
+
+// AddJobRunOnStart add a cron func which also runs once shortly after dcron
+// started, then follows cronStr. The initial run waits until the node pool
+// is steady and one more node update duration passed, so the nodes started
+// together have joined, then it runs on the node which owns jobName at that
+// time. If the node pool is upgrading then, the owner is checked again in
+// the next node update duration. Running locally, it runs immediately.
+// The initial run is only made by Start, a job added after dcron started
+// does not run on start until dcron is stopped and started again. The
+// initial run has the time it starts as its scheduled time.
+func (d *Dcron) AddJobRunOnStart(jobName, cronStr string, cmd func()) error {
+	return d.addMarkedJob(jobName, cronStr, cron.FuncJob(cmd), &d.runOnStartJobs, struct{}{})
+}
+
+// runJobsOnStart runs the jobs added by AddJobRunOnStart once on their
+// owners, it returns when all of them are checked or dcron is stopped.
+func (d *Dcron) runJobsOnStart() {
+	ctx := d.runtimeContext()
+	pending := make(map[string]struct{})
+	d.runOnStartJobs.Range(func(key, _ any) bool {
+		pending[key.(string)] = struct{}{}
+		return true
+	})
+	if len(pending) == 0 {
+		return
+	}
+	if !d.runningLocally {
+		tick := time.NewTicker(d.nodeUpdateDuration)
+		defer tick.Stop()
+		// the ownership does not change right after the run.
+		if !d.waitForSettledPool(ctx, tick) {
+			return
+		}
+		for {
+			for jobName := range pending {
+				if d.runJobOnStart(jobName) {
+					delete(pending, jobName)
+				}
+			}
+			if len(pending) == 0 {
+				return
+			}
+			select {
+			case <-tick.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	for jobName := range pending {
+		d.runJobOnStart(jobName)
+	}
+}
+
+// runJobOnStart runs the job if this node owns it, it returns false
+// if the owner can not be decided now.
+func (d *Dcron) runJobOnStart(jobName string) bool {
+	d.jobsRWMut.RLock()
+	job, ok := d.jobs[jobName]
+	d.jobsRWMut.RUnlock()
+	if !ok {
+		return true
+	}
+	if !d.runningLocally {
+		owned, err := d.checkJobAvailable(jobName)
+		if err != nil {
+			return false
+		}
+		if !owned {
+			return true
+		}
+	}
+	d.logger.Infof("run job '%s' on start", jobName)
+	d.goTracked(func() { _ = job.runAt(d.clock.Now()) })
+	return true
+}
+
+// waitForSettledPool waits for the node pool to be steady, and then for one
+// more tick, so the nodes started together have joined. It returns false
+// if dcron is stopped.
+func (d *Dcron) waitForSettledPool(ctx context.Context, tick *time.Ticker) bool {
+	for settled := false; !settled; {
+		settled = d.nodePool.IsSteady()
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+package dcron
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libi/dcron/cron"
+)
+
+// SimulateSchedule returns the next n times after from that cronSpec fires,
+// without starting a Dcron or touching the driver. The spec is parsed by
+// the same parser a Dcron created with opts uses, e.g. pass WithSeconds()
+// or WithLocation(loc) as the Dcron does, and an invalid spec returns
+// the same error as AddJob, without the job name. The times are in the
+// location of the Dcron, as NextRun. The result is shorter than n if the
+// spec never fires again, e.g. "0 0 30 2 *".
+func SimulateSchedule(cronSpec string, from time.Time, n int, opts ...Option) ([]time.Time, error) {
+	cr, err := specCron(opts)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := cr.Parse(cronSpec)
+	if err != nil {
+		return nil, invalidCronSpec(cronSpec, err)
+	}
+	if n < 0 {
+		n = 0
+	}
+	times := make([]time.Time, 0, n)
+	for t := from.In(cr.Location()); len(times) < n; {
+		if t = schedule.Next(t); t.IsZero() {
+			break
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// ValidateSpec returns the error AddJob returns for cronSpec without the job
+// name, if the Dcron is created with opts, e.g. pass WithSeconds() to
+// validate a 6 fields spec. It returns nil if the spec is valid, no job is added.
+func ValidateSpec(cronSpec string, opts ...Option) error {
+	_, err := parseSpec(cronSpec, opts)
+	return err
+}
+
+// ValidateSpec returns the error AddJob of this Dcron returns for cronSpec
+// without the job name, it returns nil if the spec is valid, no job is added.
+func (d *Dcron) ValidateSpec(cronSpec string) error {
+	if _, err := d.cr.Parse(cronSpec); err != nil {
+		return invalidCronSpec(cronSpec, err)
+	}
+	return nil
+}
+
+// NextRun returns the first time after after that cronSpec fires, as the
+// scheduler of a Dcron created with opts computes it, e.g. pass WithSeconds()
+// or WithLocation(loc) as the Dcron does. An invalid spec returns the
+// same error as ValidateSpec. The zero time is returned if the spec never
+// fires again, e.g. "0 0 30 2 *".
+func NextRun(cronSpec string, after time.Time, opts ...Option) (time.Time, error) {
+	cr, err := specCron(opts)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return nextRun(cr, cronSpec, after)
+}
+
+// NextRun returns the first time after after that cronSpec fires by the
+// parser and the location of this Dcron, see NextRun.
+func (d *Dcron) NextRun(cronSpec string, after time.Time) (time.Time, error) {
+	return nextRun(d.cr, cronSpec, after)
+}
+
+// nextRun computes the next time in the location of cr, since a spec
+// without CRON_TZ fires in the location of the time passed to Next.
+func nextRun(cr *cron.Cron, cronSpec string, after time.Time) (time.Time, error) {
+	schedule, err := cr.Parse(cronSpec)
+	if err != nil {
+		return time.Time{}, invalidCronSpec(cronSpec, err)
+	}
+	return schedule.Next(after.In(cr.Location())), nil
+}
+
+// ParserInfo describes the specs accepted by the parser of a Dcron, e.g. to
+// show the right placeholder for a spec in a UI and validate it client-side.
+type ParserInfo struct {
+	// Fields are the names of the fields of a spec in order, one of
+	// "second", "minute", "hour", "dom", "month" and "dow".
+	Fields []string
+	// Optional is the name of the field which may be omitted, it is empty
+	// if all the fields are required.
+	Optional string
+	// Seconds is true if the spec has the seconds field, as WithSeconds.
+	Seconds bool
+	// Descriptors are the descriptors accepted, e.g. "@hourly", nil if
+	// the descriptors are not accepted.
+	Descriptors []string
+	// Custom is true if the parser set by WithParser is not a cron.Parser,
+	// the other fields are empty as they can not be decided.
+	Custom bool
+}
+
+// ParserInfo returns the description of the specs accepted by the parser
+// of this Dcron, which is the same parser AddJob uses.
+func (d *Dcron) ParserInfo() ParserInfo {
+	var p cron.Parser
+	switch parser := d.cr.Parser().(type) {
+	case cron.Parser:
+		p = parser
+	case *cron.Parser:
+		p = *parser
+	default:
+		return ParserInfo{Custom: true}
+	}
+	options := p.Options()
+	info := ParserInfo{
+		Fields:      p.Fields(),
+		Seconds:     options&(cron.Second|cron.SecondOptional) > 0,
+		Descriptors: p.Descriptors(),
+	}
+	switch {
+	case options&cron.SecondOptional > 0:
+		info.Optional = "second"
+	case options&cron.DowOptional > 0:
+		info.Optional = "dow"
+	}
+	return info
+}
+
+// parseSpec parses cronSpec by the parser of a Dcron created with opts.
+func parseSpec(cronSpec string, opts []Option) (cron.Schedule, error) {
+	cr, err := specCron(opts)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := cr.Parse(cronSpec)
+	if err != nil {
+		return nil, invalidCronSpec(cronSpec, err)
+	}
+	return schedule, nil
+}
+
+// specCron returns the cron of a Dcron created with opts.
+func specCron(opts []Option) (*cron.Cron, error) {
+	d := newDcron("")
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.optionErr != nil {
+		return nil, d.optionErr
+	}
+	return cron.New(d.crOptions...), nil
+}
+
+func invalidCronSpec(cronSpec string, err error) error {
+	return &cronSpecError{spec: cronSpec, err: err}
+}
+
+// invalidJobCronSpec is invalidCronSpec with the name of the job.
+func invalidJobCronSpec(jobName, cronSpec string, err error) error {
+	return &cronSpecError{jobName: jobName, spec: cronSpec, err: err}
+}
+
+// cronSpecError is ErrInvalidCronSpec with the spec and the error of the
+// parser, use errors.As to get the *cron.FieldError of the invalid field.
+type cronSpecError struct {
+	jobName string
+	spec    string
+	err     error
+}
+
+func (e *cronSpecError) Error() string {
+	if e.jobName == "" {
+		return fmt.Sprintf("%v '%s': %v", ErrInvalidCronSpec, e.spec, e.err)
+	}
+	return fmt.Sprintf("%v '%s' of job '%s': %v", ErrInvalidCronSpec, e.spec, e.jobName, e.err)
+}
+
+func (e *cronSpecError) Is(target error) bool {
+	return target == ErrInvalidCronSpec
+}
+
+func (e *cronSpecError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,122 @@
+// Package testclock provides a fake cron.Clock which only moves when it is
+// advanced, to test the scheduled behavior without sleeping.
+//
+//	clock := testclock.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+//	dcr := dcron.NewDcronWithOption("app", nil, dcron.RunningLocally(), dcron.WithClock(clock))
+//	dcr.AddFunc("job", "* * * * *", job)
+//	dcr.Start()
+//	clock.BlockUntil(1) // the scheduler is waiting for the next run
+//	clock.Advance(time.Minute)
+package testclock
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libi/dcron/cron"
+)
+
+// Clock is a fake cron.Clock, it is safe for concurrent use.
+type Clock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*timer
+}
+
+type timer struct {
+	clock    *Clock
+	deadline time.Time
+	c        chan time.Time
+}
+
+var _ cron.Clock = (*Clock)(nil)
+
+// New returns a Clock whose time is now.
+func New(now time.Time) *Clock {
+	c := &Clock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After is the same as NewTimer(d).C().
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer returns a Timer which fires when the clock is advanced by d,
+// it fires immediately if d is not positive.
+func (c *Clock) NewTimer(d time.Duration) cron.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &timer{clock: c, deadline: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d, and fires the timers whose
+// deadline is reached, in the order of the deadlines.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	n := 0
+	for ; n < len(c.timers) && !c.timers[n].deadline.After(c.now); n++ {
+		c.timers[n].c <- c.timers[n].deadline
+	}
+	c.timers = c.timers[n:]
+	c.cond.Broadcast()
+}
+
+// Timers returns the number of the timers waiting to fire.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until at least n timers are waiting to fire,
+// e.g. the scheduler is waiting for the next run.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop prevents the timer from firing, it returns false if the timer
+// has already fired or been stopped.
+func (t *timer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, v := range c.timers {
+		if v == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package testclock_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libi/dcron/cron"
+	"github.com/libi/dcron/testclock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := testclock.New(start)
+	require.Equal(t, start, clock.Now())
+
+	t1 := clock.NewTimer(2 * time.Second)
+	t2 := clock.After(time.Second)
+	t3 := clock.NewTimer(3 * time.Second)
+	require.Equal(t, 3, clock.Timers())
+	require.True(t, t3.Stop())
+	require.False(t, t3.Stop())
+
+	clock.Advance(500 * time.Millisecond)
+	require.Empty(t, t2)
+	clock.Advance(2 * time.Second)
+	require.Equal(t, start.Add(time.Second), <-t2)
+	require.Equal(t, start.Add(2*time.Second), <-t1.C())
+	require.False(t, t1.Stop())
+	require.Equal(t, 0, clock.Timers())
+	require.Equal(t, start.Add(2500*time.Millisecond), clock.Now())
+
+	// fired immediately.
+	require.Equal(t, clock.Now(), <-clock.After(0))
+}
+
+func TestClock_BlockUntil(t *testing.T) {
+	clock := testclock.New(time.Now())
+	done := make(chan struct{})
+	go func() {
+		clock.BlockUntil(2)
+		close(done)
+	}()
+	clock.NewTimer(time.Second)
+	select {
+	case <-done:
+		t.Fatal("BlockUntil returned with 1 timer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	clock.NewTimer(time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("BlockUntil did not return with 2 timers")
+	}
+}
+
+func TestCronWithClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 30, 0, time.UTC)
+	clock := testclock.New(start)
+	c := cron.New(cron.WithClock(clock), cron.WithLocation(time.UTC))
+	fired := make(chan time.Time, 1)
+	_, err := c.AddFunc("* * * * *", func() { fired <- clock.Now() })
+	require.Nil(t, err)
+	c.Start()
+	defer c.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(29 * time.Second)
+	select {
+	case <-fired:
+		t.Fatal("fired before the minute")
+	case <-time.After(50 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	select {
+	case now := <-fired:
+		require.Equal(t, start.Add(30*time.Second), now)
+	case <-time.After(time.Second):
+		t.Fatal("not fired at the minute")
+	}
+	require.Equal(t, start.Add(30*time.Second), c.Entries()[0].Prev)
+	require.Equal(t, start.Add(90*time.Second), c.Entries()[0].Next)
+}
+
+// infoRecorder records the messages logged at Info level.
+type infoRecorder struct {
+	mu   sync.Mutex
+	msgs []string
+	errs []string
+}
+
+func (r *infoRecorder) Printf(format string, args ...any) {}
+func (r *infoRecorder) Warnf(format string, args ...any)  {}
+
+func (r *infoRecorder) Infof(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, fmt.Sprintf(format, args...))
+}
+
+func (r *infoRecorder) Errorf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func (r *infoRecorder) errors() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.errs...)
+}
+
+func (r *infoRecorder) messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.msgs...)
+}
+
+func TestSkipIfStillRunningWithClock(t *testing.T) {
+	clock := testclock.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	logger := &infoRecorder{}
+	running, release := make(chan struct{}), make(chan struct{})
+	job := cron.NewChain(cron.SkipIfStillRunningWithClock(logger, clock)).
+		Then(cron.FuncJob(func() {
+			close(running)
+			<-release
+		}))
+	go job.Run()
+	<-running
+	defer close(release)
+
+	// the first skip is logged immediately, the others in the minute are counted.
+	for i := 0; i < 37; i++ {
+		job.Run()
+		clock.Advance(time.Second)
+	}
+	require.Equal(t, []string{"skip"}, logger.messages())
+
+	clock.Advance(23 * time.Second)
+	job.Run()
+	require.Equal(t, []string{"skip", "skipped times=37, in_the_last=1m0s"}, logger.messages())
+
+	// a skip after a quiet minute is logged immediately.
+	clock.Advance(time.Minute)
+	job.Run()
+	require.Equal(t, []string{"skip", "skipped times=37, in_the_last=1m0s", "skip"}, logger.messages())
+}
+
+func TestSkipIfStillRunningWarnAfter(t *testing.T) {
+	clock := testclock.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	logger := &infoRecorder{}
+	running, release := make(chan struct{}), make(chan struct{})
+	job := cron.NewChain(cron.SkipIfStillRunningWarnAfterWithClock(time.Hour, logger, clock)).
+		Then(cron.FuncJob(func() {
+			close(running)
+			<-release
+		}))
+	go job.Run()
+	<-running
+	defer close(release)
+
+	// the brief overlaps are skipped quietly.
+	job.Run()
+	clock.Advance(30 * time.Second)
+	job.Run()
+	require.Equal(t, []string{"skip"}, logger.messages())
+	require.Empty(t, logger.errors())
+
+	// every skip is an error once the job is running for the threshold.
+	clock.Advance(59*time.Minute + 30*time.Second)
+	job.Run()
+	clock.Advance(time.Hour)
+	job.Run()
+	require.Equal(t, []string{"skip"}, logger.messages())
+	require.Equal(t, []string{
+		"skip, job is still running running_for=1h0m0s, skipped_runs=3",
+		"skip, job is still running running_for=2h0m0s, skipped_runs=4",
+	}, logger.errors())
+}
@@ -0,0 +1,92 @@
+// Package otel provides the OpenTelemetry tracing of dcron jobs.
+// It is a separate module, so that the users who do not need it
+// are not forced to pull in the OpenTelemetry dependencies.
+package otel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/libi/dcron"
+	"github.com/libi/dcron/cron"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The attributes recorded in the span of a job run.
+const (
+	JobNameKey       = attribute.Key("dcron.job.name")
+	ScheduledTimeKey = attribute.Key("dcron.job.scheduled_time")
+	NodeIDKey        = attribute.Key("dcron.node.id")
+)
+
+// defaultSpanName is used when the job name is not in the context.
+const defaultSpanName = "dcron.job"
+
+// TracingJob returns a cron.JobWrapper which starts a span named after the
+// job for every run, records the scheduled time and the nodeID as attributes,
+// and marks the span as errored if the job panics or, if it is a
+// cron.ErrorJob, returns an error.
+// The context passed to the job carries the span, if the job is a cron.ContextJob.
+//
+// The job name, scheduled time and nodeID are taken from the context which
+// dcron passes to the job, so wrap the job before adding it to dcron:
+//
+//	dcr.AddJob("job", "* * * * *", otel.TracingJob(tracer)(job))
+//
+// In this way the span is only started in the node which runs the job.
+func TracingJob(tracer trace.Tracer) cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		return &tracingJob{job: j, tracer: tracer}
+	}
+}
+
+type tracingJob struct {
+	job    cron.Job
+	tracer trace.Tracer
+}
+
+func (j *tracingJob) Run() {
+	j.RunWithContext(context.Background())
+}
+
+func (j *tracingJob) RunWithContext(ctx context.Context) {
+	spanName := defaultSpanName
+	attrs := make([]attribute.KeyValue, 0, 3)
+	if jobName, ok := dcron.JobNameFromContext(ctx); ok {
+		spanName = jobName
+		attrs = append(attrs, JobNameKey.String(jobName))
+	}
+	if scheduledTime, ok := dcron.ScheduledTimeFromContext(ctx); ok {
+		attrs = append(attrs, ScheduledTimeKey.String(scheduledTime.Format(time.RFC3339Nano)))
+	}
+	if nodeID, ok := dcron.NodeIDFromContext(ctx); ok {
+		attrs = append(attrs, NodeIDKey.String(nodeID))
+	}
+
+	ctx, span := j.tracer.Start(ctx, spanName, trace.WithAttributes(attrs...))
+	defer func() {
+		if r := recover(); r != nil {
+			span.RecordError(fmt.Errorf("panic: %v", r), trace.WithStackTrace(true))
+			span.SetStatus(codes.Error, fmt.Sprint(r))
+			span.End()
+			panic(r)
+		}
+		span.End()
+	}()
+
+	if cj, ok := j.job.(cron.ContextJob); ok {
+		cj.RunWithContext(ctx)
+		return
+	}
+	if ej, ok := j.job.(cron.ErrorJob); ok {
+		if err := ej.RunWithError(); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return
+	}
+	j.job.Run()
+}
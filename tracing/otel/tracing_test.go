@@ -0,0 +1,73 @@
+package otel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/libi/dcron"
+	"github.com/libi/dcron/cron"
+	dcronotel "github.com/libi/dcron/tracing/otel"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTracer() (trace.Tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return provider.Tracer("test"), recorder
+}
+
+func TestTracingJob(t *testing.T) {
+	tracer, recorder := newTracer()
+	dcr := dcron.NewDcronWithOption(t.Name(), nil, dcron.RunningLocally())
+
+	var jobSpan trace.SpanContext
+	job := cron.FuncContextJob(func(ctx context.Context) {
+		jobSpan = trace.SpanContextFromContext(ctx)
+	})
+	_, err := dcr.AddJob("job1", "* * * * *", dcronotel.TracingJob(tracer)(job))
+	require.Nil(t, err)
+	require.Nil(t, dcr.TriggerJob("job1"))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "job1", spans[0].Name())
+	require.Equal(t, spans[0].SpanContext(), jobSpan)
+	keys := make(map[string]bool)
+	for _, attr := range spans[0].Attributes() {
+		keys[string(attr.Key)] = true
+	}
+	require.True(t, keys[string(dcronotel.JobNameKey)])
+	require.True(t, keys[string(dcronotel.ScheduledTimeKey)])
+	require.Equal(t, codes.Unset, spans[0].Status().Code)
+}
+
+func TestTracingJobPanic(t *testing.T) {
+	tracer, recorder := newTracer()
+	job := dcronotel.TracingJob(tracer)(cron.FuncJob(func() {
+		panic("YOLO")
+	}))
+
+	require.PanicsWithValue(t, "YOLO", job.Run)
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, codes.Error, spans[0].Status().Code)
+	require.Len(t, spans[0].Events(), 1)
+}
+
+func TestTracingJobError(t *testing.T) {
+	tracer, recorder := newTracer()
+	job := dcronotel.TracingJob(tracer)(cron.FuncErrorJob(func() error {
+		return errors.New("failed")
+	}))
+
+	job.Run()
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, codes.Error, spans[0].Status().Code)
+	require.Equal(t, "failed", spans[0].Status().Description)
+}